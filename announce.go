@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// listenAddr is where the HTTP server listens. With -port 0 the port is
+// chosen by the system, so supervisors and test harnesses learn it from the
+// announcement line or the -addr-file.
+type listenAddr struct {
+	URL  string `json:"url"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	PID  int    `json:"pid"`
+}
+
+// resolveListenAddr returns the address ln listens on, keeping host as
+// configured: a listener on 127.0.0.1:0 reports 127.0.0.1 and its port.
+func resolveListenAddr(host string, ln net.Listener) listenAddr {
+	port := 0
+	if tcp, ok := ln.Addr().(*net.TCPAddr); ok {
+		port = tcp.Port
+	}
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	return listenAddr{URL: "http://" + hostPort + "/", Host: host, Port: port, PID: os.Getpid()}
+}
+
+// announce prints the address as a single MCP_URL=... line, which a shell
+// can eval and a harness can match.
+func (a listenAddr) announce(w io.Writer) {
+	fmt.Fprintf(w, "MCP_URL=%s\n", a.URL)
+}
+
+// defaultAddrFile is next to the PID file in the data directory.
+func defaultAddrFile() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate address file: %w", err)
+	}
+	return filepath.Join(dir, "mcp.addr.json"), nil
+}
+
+// writeAddrFile records a in path as JSON.
+func writeAddrFile(path string, a listenAddr) error {
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create address file dir: %w", err)
+	}
+	return writeFileAtomic(path, append(raw, '\n'), 0o644)
+}
+
+// readAddrFile returns the address recorded in path.
+func readAddrFile(path string) (listenAddr, error) {
+	var a listenAddr
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return a, fmt.Errorf("address file %s is damaged: %w", path, err)
+	}
+	return a, nil
+}
+
+// removeAddrFile removes path if it still describes this process.
+func removeAddrFile(path string) {
+	if a, err := readAddrFile(path); err == nil && a.PID == os.Getpid() {
+		if err := os.Remove(path); err != nil {
+			Warn("Failed to remove address file", "path", path, "error", err)
+		}
+	}
+}
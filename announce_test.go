@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestListenAddrEphemeralPort(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	a := resolveListenAddr("127.0.0.1", ln)
+	if a.Port == 0 || a.PID != os.Getpid() {
+		t.Fatalf("addr = %+v", a)
+	}
+	if want := "http://127.0.0.1:" + strconv.Itoa(a.Port) + "/"; a.URL != want {
+		t.Errorf("URL = %q, want %q", a.URL, want)
+	}
+	var b strings.Builder
+	a.announce(&b)
+	if b.String() != "MCP_URL="+a.URL+"\n" {
+		t.Errorf("announcement = %q", b.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "run", "mcp.addr.json")
+	if err := writeAddrFile(path, a); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readAddrFile(path)
+	if err != nil || got != a {
+		t.Fatalf("readAddrFile = %+v, %v; want %+v", got, err, a)
+	}
+	removeAddrFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("address file not removed: %v", err)
+	}
+
+	// Another process's file is left alone.
+	a.PID++
+	if err := writeAddrFile(path, a); err != nil {
+		t.Fatal(err)
+	}
+	removeAddrFile(path)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("foreign address file removed: %v", err)
+	}
+}
+
+func TestListenAddrIPv6(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	}
+	defer ln.Close()
+	if a := resolveListenAddr("::1", ln); !strings.HasPrefix(a.URL, "http://[::1]:") {
+		t.Errorf("URL = %q", a.URL)
+	}
+}
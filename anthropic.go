@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	providerAnthropic = "anthropic"
+	// anthropicVersion is the Messages API version header value.
+	anthropicVersion = "2023-06-01"
+	// anthropicWebSearchTool is the server-side web search tool version.
+	anthropicWebSearchTool = "web_search_20250305"
+	// anthropicMaxSearches caps web searches per request.
+	anthropicMaxSearches = 5
+	// anthropicAnswerTokens is the output budget on top of any thinking
+	// budget when the caller sets no max_output_tokens; the API requires
+	// max_tokens on every request.
+	anthropicAnswerTokens = 8192
+)
+
+// anthropicThinkingBudget maps reasoning effort onto an extended thinking
+// budget; zero disables thinking.
+var anthropicThinkingBudget = map[string]int{
+	"low":    1024,
+	"medium": 4096,
+	"high":   16384,
+	"xhigh":  32000,
+}
+
+var anthropicModel = vendorModel("claude-sonnet-4-5", "claude-")
+
+// Messages API request structures.
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Thinking    *anthropicThinking `json:"thinking,omitempty"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	TopP        *float64           `json:"top_p,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block in requests and responses; fields apply
+// to the block types noted.
+type anthropicBlock struct {
+	Type string `json:"type"`
+	// text
+	Text      string              `json:"text,omitempty"`
+	Citations []anthropicCitation `json:"citations,omitempty"`
+	// image, document
+	Source *anthropicSource `json:"source,omitempty"`
+	Title  string           `json:"title,omitempty"`
+	// thinking
+	Thinking string `json:"thinking,omitempty"`
+	// server_tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// web_search_tool_result: Content lists the pages a search returned.
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicCitation struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type anthropicTool struct {
+	Type           string        `json:"type"`
+	Name           string        `json:"name"`
+	MaxUses        int           `json:"max_uses,omitempty"`
+	AllowedDomains []string      `json:"allowed_domains,omitempty"`
+	BlockedDomains []string      `json:"blocked_domains,omitempty"`
+	UserLocation   *UserLocation `json:"user_location,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// checkAnthropic lists what the web search tool ignores: it has no search
+// context size.
+func checkAnthropic(p CallAPIParams) ([]string, error) {
+	if p.UseWebSearch && p.SearchContextSize != "" {
+		return []string{"search_context_size"}, nil
+	}
+	return nil, nil
+}
+
+// sendAnthropic performs one Messages API call with the server-side web
+// search tool.
+func sendAnthropic(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	body := anthropicRequest{
+		Model:       p.Model,
+		System:      p.Instructions,
+		Messages:    anthropicMessages(p),
+		Temperature: p.Temperature,
+		TopP:        p.TopP,
+	}
+	if p.OutputSchema != nil {
+		// The Messages API has no response format; ask for the schema in
+		// the system prompt and rely on runWebSearch's validation.
+		body.System = strings.TrimSpace(body.System + "\n\nReply with a single JSON document, and nothing else, matching this JSON Schema:\n" + string(p.OutputSchema.raw))
+	}
+	if p.UseWebSearch {
+		body.Tools = []anthropicTool{{
+			Type:           anthropicWebSearchTool,
+			Name:           "web_search",
+			MaxUses:        anthropicMaxSearches,
+			AllowedDomains: p.AllowedDomains,
+			UserLocation:   p.UserLocation,
+		}}
+		// The API takes one list or the other; allowed domains already
+		// keep the search away from the rest.
+		if len(p.AllowedDomains) == 0 {
+			body.Tools[0].BlockedDomains = p.BlockedDomains
+		}
+	}
+
+	// Extended thinking rejects custom sampling, so explicit sampling
+	// parameters win over effort.
+	budget := anthropicThinkingBudget[p.Effort]
+	if p.Temperature != nil || p.TopP != nil {
+		budget = 0
+	}
+	body.MaxTokens = p.MaxOutputTokens
+	if body.MaxTokens == 0 {
+		body.MaxTokens = budget + anthropicAnswerTokens
+	}
+	if budget > 0 && budget < body.MaxTokens {
+		body.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: budget}
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	req, err := newAPIRequest(ctx, p, buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var ar anthropicResponse
+	if err := json.Unmarshal(bodyBytes, &ar); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	return ar.toAPIResponse(), nil
+}
+
+// anthropicMessages builds the earlier turns followed by the user message.
+func anthropicMessages(p CallAPIParams) []anthropicMessage {
+	messages := make([]anthropicMessage, 0, len(p.Messages)+1)
+	for _, m := range p.Messages {
+		messages = append(messages, anthropicMessage{Role: m.Role, Content: []anthropicBlock{{Type: "text", Text: m.Content}}})
+	}
+	return append(messages, anthropicMessage{Role: "user", Content: anthropicContent(p)})
+}
+
+// anthropicContent builds the user message: the question, then any
+// documents and images as their own blocks.
+func anthropicContent(p CallAPIParams) []anthropicBlock {
+	blocks := []anthropicBlock{{Type: "text", Text: p.Query}}
+	for _, f := range p.Files {
+		if f.Data == "" {
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: f.inlineText()})
+			continue
+		}
+		mediaType, data := splitDataURL(f.Data)
+		blocks = append(blocks, anthropicBlock{Type: "document", Title: f.Name,
+			Source: &anthropicSource{Type: "base64", MediaType: mediaType, Data: data}})
+	}
+	for _, img := range p.Images {
+		src := &anthropicSource{Type: "url", URL: img}
+		if mediaType, data := splitDataURL(img); data != "" {
+			src = &anthropicSource{Type: "base64", MediaType: mediaType, Data: data}
+		}
+		blocks = append(blocks, anthropicBlock{Type: "image", Source: src})
+	}
+	return blocks
+}
+
+// splitDataURL splits a base64 data URL into media type and payload; data
+// is empty for anything else.
+func splitDataURL(s string) (mediaType, data string) {
+	rest, ok := strings.CutPrefix(s, "data:")
+	if !ok {
+		return "", ""
+	}
+	meta, payload, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", ""
+	}
+	return strings.TrimSuffix(meta, ";base64"), payload
+}
+
+// toAPIResponse joins the text blocks into one output_text, turning each
+// block's citations into url_citation annotations over that block's span.
+// Thinking becomes a reasoning item and server-side searches become
+// web_search_call items carrying the pages they returned as sources, so
+// ExtractAnswer sees the usual shape.
+func (r *anthropicResponse) toAPIResponse() *apiResponse {
+	ar := &apiResponse{ID: r.ID, Model: r.Model, Status: "completed"}
+	var text strings.Builder
+	content := respContent{Type: "output_text"}
+	var items []respItem
+	for _, b := range r.Content {
+		switch b.Type {
+		case "text":
+			start := utf8.RuneCountInString(text.String())
+			text.WriteString(b.Text)
+			end := start + utf8.RuneCountInString(b.Text)
+			for _, c := range b.Citations {
+				if c.URL == "" {
+					continue
+				}
+				content.Annotations = append(content.Annotations, respAnnotation{
+					Type: "url_citation", URL: c.URL, Title: c.Title, StartIndex: start, EndIndex: end,
+				})
+			}
+		case "thinking":
+			items = append(items, respItem{Type: "reasoning", Summary: []respSummary{{Type: "summary_text", Text: b.Thinking}}})
+		case "server_tool_use":
+			var in struct {
+				Query string `json:"query"`
+			}
+			_ = json.Unmarshal(b.Input, &in) //nolint:errcheck // best-effort detail
+			items = append(items, respItem{Type: "web_search_call", ID: b.ID, Status: "completed",
+				Action: &respAction{Type: "search", Query: in.Query}})
+		case "web_search_tool_result":
+			// A failed search carries an error object instead of a list.
+			var results []struct {
+				URL   string `json:"url"`
+				Title string `json:"title"`
+			}
+			if json.Unmarshal(b.Content, &results) != nil {
+				continue
+			}
+			for i := range items {
+				if items[i].ID == b.ToolUseID && items[i].Action != nil {
+					for _, r := range results {
+						items[i].Action.Sources = append(items[i].Action.Sources, respSource{Type: "url", URL: r.URL, Title: r.Title})
+					}
+				}
+			}
+		}
+	}
+	content.Text = text.String()
+	ar.Output = append(items, respItem{Type: "message", Content: []respContent{content}})
+	if r.StopReason == "max_tokens" {
+		ar.Status = "incomplete"
+		ar.IncompleteDetails = &incompleteDetails{Reason: "max_output_tokens"}
+	}
+	if r.Usage != nil {
+		ar.Usage = &apiUsage{
+			InputTokens:  r.Usage.InputTokens,
+			OutputTokens: r.Usage.OutputTokens,
+			TotalTokens:  r.Usage.InputTokens + r.Usage.OutputTokens,
+		}
+	}
+	return ar
+}
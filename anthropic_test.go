@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCallAPI_Anthropic(t *testing.T) {
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "ak" || r.Header.Get("Authorization") != "" {
+			t.Errorf("auth headers = %v, want x-api-key only", r.Header)
+		}
+		if r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("anthropic-version = %q", r.Header.Get("anthropic-version"))
+		}
+		var body anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if body.Model != "claude-sonnet-4-5" {
+			t.Errorf("model = %q", body.Model)
+		}
+		if len(body.Tools) != 1 || body.Tools[0].Type != anthropicWebSearchTool || body.Tools[0].AllowedDomains[0] != "go.dev" || body.Tools[0].BlockedDomains != nil {
+			t.Errorf("tools = %+v", body.Tools)
+		}
+		if body.Thinking == nil || body.Thinking.BudgetTokens != 1024 || body.MaxTokens <= 1024 {
+			t.Errorf("thinking = %+v, max_tokens = %d", body.Thinking, body.MaxTokens)
+		}
+		if len(body.Messages) != 1 || body.Messages[0].Content[0].Text != "latest Go?" {
+			t.Errorf("messages = %+v", body.Messages)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "msg_1",
+			"model": "claude-sonnet-4-5",
+			"content": []map[string]any{
+				{"type": "thinking", "thinking": "check the release notes"},
+				{"type": "server_tool_use", "id": "srvtoolu_1", "name": "web_search", "input": map[string]any{"query": "go release"}},
+				{"type": "web_search_tool_result", "tool_use_id": "srvtoolu_1", "content": []map[string]any{
+					{"type": "web_search_result", "url": "https://go.dev/doc/devel/release", "title": "Release History", "encrypted_content": "…"},
+				}},
+				{"type": "text", "text": "Go ≥ "},
+				{"type": "text", "text": "1.25 is current.", "citations": []map[string]any{
+					{"type": "web_search_result_location", "url": "https://go.dev/doc/devel/release", "title": "Release History"},
+				}},
+			},
+			"stop_reason": "end_turn",
+			"usage":       map[string]any{"input_tokens": 10, "output_tokens": 5},
+		})
+	})
+
+	resp, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:       providerAnthropic,
+		APIKey:         "ak",
+		BaseURL:        base,
+		Query:          "latest Go?",
+		Model:          "claude-sonnet-4-5",
+		Effort:         "low",
+		UseWebSearch:   true,
+		AllowedDomains: []string{"go.dev"},
+		BlockedDomains: []string{"reddit.com"},
+		// The web search tool has no context size.
+		SearchContextSize: "high",
+		Timeout:           time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Ignored) != 1 || resp.Ignored[0] != "search_context_size" {
+		t.Errorf("ignored = %q, want [search_context_size]", resp.Ignored)
+	}
+	ea := ExtractAnswer(resp)
+	if ea.Text != "Go ≥ 1.25 is current." {
+		t.Errorf("answer = %q", ea.Text)
+	}
+	if len(ea.Citations) != 1 || ea.Citations[0].URL != "https://go.dev/doc/devel/release" {
+		t.Fatalf("citations = %+v", ea.Citations)
+	}
+	if s := ea.Citations[0].Span; s == nil || ea.Text[s.StartByte:s.EndByte] != "1.25 is current." {
+		t.Errorf("citation span = %+v", s)
+	}
+	if len(ea.ToolCalls) != 1 || ea.ToolCalls[0].Action.Query != "go release" || len(ea.ToolCalls[0].Action.Sources) != 1 {
+		t.Errorf("tool calls = %+v", ea.ToolCalls)
+	}
+	if len(ea.Reasoning) != 1 {
+		t.Errorf("reasoning = %v", ea.Reasoning)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicResponse_MaxTokens(t *testing.T) {
+	r := anthropicResponse{StopReason: "max_tokens", Content: []anthropicBlock{{Type: "text", Text: "partial"}}}
+	ar := r.toAPIResponse()
+	if ar.Status != "incomplete" || ar.IncompleteDetails == nil || ar.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("status = %q, details = %+v", ar.Status, ar.IncompleteDetails)
+	}
+}
+
+func TestSplitDataURL(t *testing.T) {
+	if mt, data := splitDataURL("data:image/png;base64,AAAA"); mt != "image/png" || data != "AAAA" {
+		t.Errorf("got %q, %q", mt, data)
+	}
+	if _, data := splitDataURL("https://example.com/a.png"); data != "" {
+		t.Errorf("url data = %q, want empty", data)
+	}
+}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -11,14 +16,25 @@ import (
 	"runtime"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 const maxResponseBodySize = 10 * 1024 * 1024 // 10 MB
 
+// httpClient is shared by every outbound call so keep-alive connections are
+// pooled across requests. It deliberately has no Client.Timeout: deadlines
+// come from per-request contexts (see doAPIRequest), which lets effort-based
+// timeouts differ per call without rebuilding the client.
+//
+// Responses are requested gzip-compressed and decompressed transparently,
+// which matters for long high-verbosity answers on slow links. A custom
+// DialContext would leave HTTP/2 off, so it is asked for explicitly.
 var httpClient = &http.Client{
 	Transport: &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		ForceAttemptHTTP2: true,
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
@@ -31,59 +47,329 @@ var httpClient = &http.Client{
 	},
 }
 
+// gzipRequests compresses request bodies of at least minGzipRequestBytes;
+// set from GZIP_REQUESTS.
+var gzipRequests bool
+
+// minGzipRequestBytes is the smallest body worth compressing; attachments
+// and long conversations easily pass it.
+const minGzipRequestBytes = 8 << 10
+
+// offlineMode makes CallAPI fail fast with ErrOffline; set from OFFLINE.
+var offlineMode bool
+
+// idempotencyKeys also sends each call's request ID as Idempotency-Key, so
+// a retried request is not executed twice upstream; set from
+// IDEMPOTENCY_KEYS.
+var idempotencyKeys bool
+
+// noStoreDefault sends store=false on every OpenAI call, whatever the
+// caller asked for; set from NO_STORE.
+var noStoreDefault bool
+
+// defaultUserLocation is the web search location hint used when a request
+// does not carry its own; set from USER_LOCATION.
+var defaultUserLocation *UserLocation
+
 // CallAPIParams groups the inputs for CallAPI to keep the signature readable.
 type CallAPIParams struct {
-	APIKey             string
-	BaseURL            string
-	Query              string
+	// Provider selects the backend (see providers); empty means OpenAI.
+	Provider string
+	// APIKey and BaseURL are resolved per call rather than fixed at startup,
+	// so callers can route individual queries through a gateway (LiteLLM,
+	// a proxy) while others go direct; the shared httpClient carries no
+	// endpoint or credential state. An empty BaseURL uses the provider's
+	// default endpoint.
+	APIKey  string
+	BaseURL string
+	Query   string
+	// Messages are earlier turns of the conversation, sent before Query.
+	Messages           []Message
 	Model              string
 	Effort             string
 	Verbosity          string
 	PreviousResponseID string
 	PromptCacheKey     string
-	Timeout            time.Duration
-	UseWebSearch       bool
+	// Truncation is the Responses API truncation strategy, "auto" or
+	// "disabled"; empty means auto when PreviousResponseID is set and the
+	// API default otherwise (see truncationFor).
+	Truncation string
+	// NoStore sends store=false so OpenAI keeps no copy of the response;
+	// it cannot be combined with PreviousResponseID.
+	NoStore bool
+	// Images are image URLs or base64 data URLs asked about together with
+	// Query (see loadImage).
+	Images []string
+	// Files are local documents (see loadAttachment) sent as context.
+	Files []attachment
+	// Instructions are system-level guidance sent alongside the query.
+	// CallAPI appends the glossary's terminology rules when one is loaded.
+	Instructions string
+	// SearchContextSize sets the web search tool's search_context_size
+	// (low, medium, high); empty leaves the API default.
+	SearchContextSize string
+	// UserLocation localizes web search results; nil sends no hint.
+	UserLocation *UserLocation
+	// AllowedDomains restricts web search to these domains. Domain filters
+	// are only accepted by the GA "web_search" tool, so setting them
+	// overrides a "web_search_preview" tool type (see webSearchTools).
+	AllowedDomains []string
+	// BlockedDomains excludes these domains from web search: Anthropic and
+	// Perplexity filter them natively, other providers are told to avoid
+	// them, and their citations and sources are dropped either way.
+	BlockedDomains []string
+	// MaxOutputTokens caps output (reasoning included) for the call; zero
+	// leaves the model's default limit.
+	MaxOutputTokens int
+	// Temperature and TopP are passed through when set; CallAPI rejects
+	// them for reasoning models (see validateSampling).
+	Temperature *float64
+	TopP        *float64
+	// OutputSchema, when set, asks for JSON output matching the schema.
+	OutputSchema *outputSchema
+	// Tools are hosted tools attached in addition to web search, e.g.
+	// code_interpreter or file_search (see hostedTools). Only providers
+	// with hostedTools set accept them.
+	Tools        []reqTool
+	Timeout      time.Duration
+	UseWebSearch bool
+	// SourcesOnly asks for the search results rather than an answer (see
+	// retrieveSources): providers return them as web_search_call sources.
+	SourcesOnly bool
+	// OnDelta, when set, switches the call to streaming mode: each output
+	// text delta is passed to it as it arrives, and the final response is
+	// still returned once the stream completes.
+	OnDelta func(delta string)
+	// Retry controls retries of transient failures; the zero value makes a
+	// single attempt.
+	Retry RetryPolicy
+	// RequestID identifies the call in logs, errors and the provider's
+	// dashboard (X-Client-Request-Id header). CallAPI generates one when
+	// empty; retries reuse it.
+	RequestID string
+
+	// keyHeader is copied from the provider by CallAPI (see
+	// provider.keyHeader).
+	keyHeader string
 }
 
 // CallAPI makes the actual API call - reusable for both CLI and MCP
 func CallAPI(ctx context.Context, p CallAPIParams) (*apiResponse, error) {
-	if p.APIKey == "" {
+	if offlineMode {
+		return nil, ErrOffline
+	}
+	pr, err := lookupProvider(p.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if p.APIKey == "" && pr.keyEnv != "" {
 		return nil, ErrNoAPIKey
 	}
+	p.NoStore = p.NoStore || noStoreDefault
+	if p.NoStore && p.PreviousResponseID != "" {
+		return nil, ErrNoStoreContinuation
+	}
+	if p.PreviousResponseID != "" && !chainsResponses(pr.name) {
+		return nil, fmt.Errorf("provider %s: %w", pr.name, ErrNoContinuation)
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = pr.defaultURL
+	}
+	p.keyHeader = pr.keyHeader
+	p.Model = pr.modelFor(p.Model)
+	if len(p.AllowedDomains) > 0 {
+		if p.AllowedDomains = activeDomains.withoutBlocked(p.AllowedDomains); len(p.AllowedDomains) == 0 {
+			return nil, ErrDomainsBlocked
+		}
+	}
+	if len(p.BlockedDomains) > 0 && p.UseWebSearch {
+		p.Instructions = strings.TrimSpace(p.Instructions + "\n\nDo not use or cite sources from these domains: " + strings.Join(p.BlockedDomains, ", ") + ".")
+	}
+	var ignored []string
+	if pr.check != nil {
+		if ignored, err = pr.check(p); err != nil {
+			return nil, err
+		}
+		if len(ignored) > 0 {
+			Warn("Provider ignores request parameters", "provider", pr.name, "ignored", ignored)
+		}
+	}
+	if rules := activeGlossary.instructions(); rules != "" {
+		p.Instructions = strings.TrimSpace(p.Instructions + "\n\n" + rules)
+	}
+	if p.Query, err = activeWasm.transform(ctx, wasmStageQuery, p.Query); err != nil {
+		return nil, err
+	}
+	if err := validateSampling(p.Model, p.Effort, p.Temperature, p.TopP); err != nil {
+		return nil, err
+	}
+	p.Provider = pr.name
+	if p.RequestID == "" {
+		p.RequestID = newRequestID()
+	}
+	if err := activeHooks.beforeQuery(ctx, p); err != nil {
+		return nil, err
+	}
+	callID, err := activeCompliance.logQuery(p)
+	if err != nil {
+		return nil, err
+	}
+	release, err := activePool.acquire(ctx, laneFrom(ctx))
+	if err != nil {
+		activeCompliance.logAnswer(callID, p, nil, err)
+		return nil, fmt.Errorf("waiting for a call slot: %w (request_id=%s)", err, p.RequestID)
+	}
+	Debug("Calling API", "provider", p.Provider, "model", p.Model, "effort", p.Effort, "web_search", p.UseWebSearch, "request_id", p.RequestID)
+	started := time.Now()
+	ar, err := callWithRetries(ctx, pr, p)
+	release()
+	if err == nil {
+		Debug("API call finished", "request_id", p.RequestID, "elapsed", time.Since(started).Round(time.Millisecond).String())
+	}
+	activeCompliance.logAnswer(callID, p, ar, err)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.RequestID = p.RequestID
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w (request_id=%s)", err, p.RequestID)
+	}
+	ar.RequestID, ar.Ignored = p.RequestID, ignored
+	activeHooks.afterAnswer(ctx, p, ar)
+	return ar, nil
+}
+
+// newRequestID returns a random ID for the X-Client-Request-Id header.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) //nolint:errcheck // never fails
+	return "ws-" + hex.EncodeToString(b[:])
+}
+
+// callWithRetries sends p to pr, retrying transient failures per p.Retry.
+func callWithRetries(ctx context.Context, pr *provider, p CallAPIParams) (*apiResponse, error) {
+	// Deltas already shown to the caller cannot be taken back, so a stream
+	// that produced output is never retried.
+	// With the streaming feature off the call is sent whole and the answer
+	// is delivered as one delta, as for providers that cannot stream.
+	streams := pr.streams && activeFeatures.enabled(featureStreaming)
+	streamed := false
+	onDelta := p.OnDelta
+	if !activeFeatures.enabled(featureStreaming) {
+		onDelta = nil
+	}
+	if onDelta != nil {
+		onDelta = func(d string) {
+			streamed = true
+			p.OnDelta(d)
+		}
+	}
+
+	attempts := p.Retry.attempts()
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		Debug("Sending request", "attempt", attempt, "max_attempts", attempts, "stream", onDelta != nil && streams, "request_id", p.RequestID)
+		ar, err := pr.send(ctx, p, onDelta)
+		if err == nil {
+			ar.RetryWait = waited
+			if p.OnDelta != nil && !streams {
+				if text := ExtractAnswer(ar).Text; text != "" {
+					p.OnDelta(text)
+				}
+			}
+			return ar, nil
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.Waited = waited
+			if apiErr.StatusCode == http.StatusTooManyRequests {
+				activeDowngrade.recordRateLimit(pr.name)
+			}
+		}
+		if attempt >= attempts || streamed || !isRetryable(err) {
+			return nil, err
+		}
+		delay := p.Retry.retryDelay(attempt, err)
+		Warn("Retrying API call after transient failure", "attempt", attempt, "max_attempts", attempts, "delay", delay, "request_id", p.RequestID, "error", err)
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return nil, err
+		}
+		waited += delay
+	}
+}
+
+// sendOpenAI performs one OpenAI Responses API call.
+func sendOpenAI(ctx context.Context, p CallAPIParams, onDelta func(string)) (*apiResponse, error) {
 	body := requestBody{
-		Model: p.Model,
-		Input: p.Query,
+		Model:        p.Model,
+		Input:        p.Query,
+		Messages:     p.Messages,
+		Instructions: p.Instructions,
+		Images:       p.Images,
+		Files:        p.Files,
 		Reasoning: reqReasoning{
 			Effort: p.Effort,
 		},
 		Text: reqText{
 			Verbosity: p.Verbosity,
+			Format:    p.OutputSchema.textFormat(),
 		},
 		PreviousResponseID: p.PreviousResponseID,
 		PromptCacheKey:     p.PromptCacheKey,
+		Store:              storeFlag(p.NoStore),
+		Stream:             onDelta != nil,
+		MaxOutputTokens:    p.MaxOutputTokens,
+		Temperature:        p.Temperature,
+		TopP:               p.TopP,
+		Truncation:         truncationFor(p.Truncation, p.PreviousResponseID),
 	}
 
 	// Conditionally add web search tool
 	if p.UseWebSearch {
-		body.Tools = []reqTool{
-			{Type: "web_search_preview"},
+		tool := reqTool{Type: webSearchTools.typeFor(p.Model), SearchContextSize: p.SearchContextSize, UserLocation: p.UserLocation}
+		if len(p.AllowedDomains) > 0 {
+			tool.Type = toolWebSearch
+			tool.Filters = &reqFilters{AllowedDomains: p.AllowedDomains}
 		}
+		body.Tools = []reqTool{tool}
+	}
+	body.Tools = append(body.Tools, p.Tools...)
+	if p.SourcesOnly && p.UseWebSearch {
+		body.Include = []string{"web_search_call.action.sources"}
+		body.ToolChoice = "required"
 	}
 
 	buf, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
+	return doAPIRequest(ctx, p, buf, onDelta)
+}
+
+// storeFlag maps NoStore onto the request's store field.
+func storeFlag(noStore bool) *bool {
+	if !noStore {
+		return nil
+	}
+	store := false
+	return &store
+}
 
+// doAPIRequest performs a single HTTP round trip for CallAPI. The effort-based
+// timeout applies per attempt so a retry gets the full budget again.
+func doAPIRequest(ctx context.Context, p CallAPIParams, buf []byte, onDelta func(string)) (*apiResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL, bytes.NewReader(buf))
+	req, err := newAPIRequest(ctx, p, buf)
 	if err != nil {
-		return nil, fmt.Errorf("build request: %w", err)
+		return nil, err
+	}
+	stream := onDelta != nil
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.APIKey)
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -91,14 +377,13 @@ func CallAPI(ctx context.Context, p CallAPIParams) (*apiResponse, error) {
 	}
 	defer resp.Body.Close()
 
-	limitedReader := io.LimitReader(resp.Body, maxResponseBodySize)
-	bodyBytes, err := io.ReadAll(limitedReader)
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+	if stream && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return readResponseStream(io.LimitReader(resp.Body, maxResponseBodySize), onDelta)
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		return nil, err
 	}
 
 	var ar apiResponse
@@ -109,57 +394,339 @@ func CallAPI(ctx context.Context, p CallAPIParams) (*apiResponse, error) {
 	return &ar, nil
 }
 
-// ExtractAnswer extracts the answer text from the API response
-func ExtractAnswer(apiResp *apiResponse) string {
+// newAPIRequest builds an authenticated JSON POST, including any gateway
+// headers.
+func newAPIRequest(ctx context.Context, p CallAPIParams, buf []byte) (*http.Request, error) {
+	compressed := gzipRequests && len(buf) >= minGzipRequestBytes
+	if compressed {
+		var err error
+		if buf, err = gzipBytes(buf); err != nil {
+			return nil, fmt.Errorf("compress request: %w", err)
+		}
+	}
+	req, err := newAuthRequest(ctx, http.MethodPost, p.BaseURL, bytes.NewReader(buf), p)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if p.RequestID != "" && idempotencyKeys {
+		req.Header.Set("Idempotency-Key", p.RequestID)
+	}
+	return req, nil
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// newAuthRequest builds a request to url carrying p's API key, request ID
+// and the gateway headers.
+func newAuthRequest(ctx context.Context, method, url string, body io.Reader, p CallAPIParams) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if p.RequestID != "" {
+		req.Header.Set("X-Client-Request-Id", p.RequestID)
+	}
+	if debugEnabled() {
+		req = traceRequest(req, p.RequestID)
+	}
+	switch {
+	case p.APIKey == "":
+		// Keyless providers such as a local Ollama server.
+	case p.keyHeader != "":
+		req.Header.Set(p.keyHeader, p.APIKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	applyOutboundHeaders(req)
+	return req, nil
+}
+
+// readAPIResponse reads a bounded response body, turning non-2xx statuses
+// into an *APIError.
+func readAPIResponse(resp *http.Response) ([]byte, error) {
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &APIError{
+			StatusCode:        resp.StatusCode,
+			Body:              string(bodyBytes),
+			RetryAfter:        parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			UpstreamRequestID: cmp.Or(resp.Header.Get("X-Request-Id"), resp.Header.Get("Request-Id")),
+		}
+	}
+	return bodyBytes, nil
+}
+
+// ExtractedAnswer is the typed view of a response's output produced by
+// ExtractAnswer.
+type ExtractedAnswer struct {
+	// Text is the answer: the output_text segments joined with
+	// answerSegmentSeparator, less the citation markers of blocked domains.
+	Text string
+	// Segments are the individual output_text parts in output order.
+	Segments []string
+	// Citations are the url_citation annotations mapped onto Text.
+	Citations []Citation
+	// Reasoning holds the reasoning summary texts, when the model sent any.
+	Reasoning []string
+	// ToolCalls lists the hosted tool invocations (e.g. web_search_call).
+	ToolCalls []ToolCall
+}
+
+// ToolCall describes one hosted tool invocation from the response output.
+type ToolCall struct {
+	Type   string
+	ID     string
+	Status string
+	Action *respAction
+}
+
+// ExtractAnswer extracts the answer text, citations, reasoning summaries and
+// tool calls from the API response. A nil response yields the zero value.
+func ExtractAnswer(apiResp *apiResponse) ExtractedAnswer {
+	var ea ExtractedAnswer
 	if apiResp == nil {
-		return ""
+		return ea
 	}
 	var sb strings.Builder
+	for _, content := range answerSegments(apiResp) {
+		if sb.Len() > 0 {
+			sb.WriteString(answerSegmentSeparator)
+		}
+		sb.WriteString(content.Text)
+		ea.Segments = append(ea.Segments, content.Text)
+	}
+	ea.Text, ea.Citations = activeDomains.scoreCitations(sb.String(), ExtractCitations(apiResp))
+
 	for _, item := range apiResp.Output {
-		if item.Type != "message" {
+		switch {
+		case item.Type == "reasoning":
+			for _, s := range item.Summary {
+				if s.Text != "" {
+					ea.Reasoning = append(ea.Reasoning, s.Text)
+				}
+			}
+		case strings.HasSuffix(item.Type, "_call"):
+			ea.ToolCalls = append(ea.ToolCalls, ToolCall{
+				Type:   item.Type,
+				ID:     item.ID,
+				Status: item.Status,
+				Action: item.Action,
+			})
+		}
+	}
+	return ea
+}
+
+// answerSegmentSeparator joins output_text segments in ExtractAnswer.
+const answerSegmentSeparator = " "
+
+// answerSegments returns the non-empty output_text parts of message items in
+// the order ExtractAnswer joins them. Gateways that translate other backends
+// sometimes omit the item type or label text parts plain "text"; both are
+// accepted.
+func answerSegments(apiResp *apiResponse) []respContent {
+	if apiResp == nil {
+		return nil
+	}
+	var segments []respContent
+	for _, item := range apiResp.Output {
+		if item.Type != "message" && item.Type != "" {
 			continue
 		}
 		for _, content := range item.Content {
-			if content.Type == "output_text" && content.Text != "" {
-				if sb.Len() > 0 {
-					sb.WriteString(" ")
-				}
-				sb.WriteString(content.Text)
+			if (content.Type == "output_text" || content.Type == "text") && content.Text != "" {
+				segments = append(segments, content)
 			}
 		}
 	}
-	return sb.String()
+	return segments
+}
+
+// checkReasoningOnly returns a *ReasoningOnlyError when the response holds
+// reasoning items but no message output, and nil otherwise.
+func checkReasoningOnly(apiResp *apiResponse) error {
+	if apiResp == nil {
+		return nil
+	}
+	hasReasoning := false
+	for _, item := range apiResp.Output {
+		switch item.Type {
+		case "message":
+			return nil
+		case "reasoning":
+			hasReasoning = true
+		}
+	}
+	if !hasReasoning {
+		return nil
+	}
+	e := &ReasoningOnlyError{}
+	if apiResp.IncompleteDetails != nil {
+		e.Reason = apiResp.IncompleteDetails.Reason
+	}
+	if apiResp.Usage != nil {
+		e.OutputTokens = apiResp.Usage.OutputTokens
+		e.ReasoningTokens = apiResp.Usage.OutputTokensDetails.ReasoningTokens
+	}
+	return e
 }
 
 // webSearchArgs holds the validated arguments extracted from a tool-call map.
 type webSearchArgs struct {
 	query              string
+	messages           []Message
 	model              string
 	effort             string
 	verbosity          string
 	previousResponseID string
 	promptCacheKey     string
+	truncation         string
+	searchContextSize  string
+	userLocation       *UserLocation
+	domains            []string
+	provider           string
+	maxOutputTokens    int
+	temperature        *float64
+	topP               *float64
+	outputSchema       *outputSchema
+	tools              []reqTool
+	images             []string
+	files              []attachment
+	related            bool
 	useWebSearch       bool
+	// noSynthesis returns the search results without an answer.
+	noSynthesis bool
+	// noCache skips cache lookups; the fresh answer is still cached.
+	noCache bool
+	// noStore opts out of OpenAI response storage (see CallAPIParams).
+	noStore bool
 }
 
 func extractWebSearchArgs(args map[string]interface{}) webSearchArgs {
 	previousResponseID, _ := args["previous_response_id"].(string) //nolint:errcheck
 
 	query, _ := args["query"].(string) //nolint:errcheck
+	// The handler validates messages before any upstream call.
+	messages, _ := args["messages"].([]Message) //nolint:errcheck
 
 	model, _ := args["model"].(string) //nolint:errcheck
 	if model == "" {
-		model = defaultModel
+		model = toolModel
 	}
 
 	effort, _ := args["reasoning_effort"].(string) //nolint:errcheck
-	effort = validateEffort(effort)
+	effort = validateEffort(cmp.Or(effort, toolEffort))
 
 	verbosity, _ := args["verbosity"].(string) //nolint:errcheck
-	verbosity = validateVerbosity(verbosity)
+	verbosity = validateVerbosity(cmp.Or(verbosity, toolVerbosity))
 
 	promptCacheKey, _ := args["prompt_cache_key"].(string) //nolint:errcheck
 
+	truncation, _ := args["truncation"].(string) //nolint:errcheck
+	truncation = validateTruncation(truncation)
+
+	searchContextSize, _ := args["search_context_size"].(string) //nolint:errcheck
+	searchContextSize = validateSearchContextSize(searchContextSize)
+
+	userLocation := defaultUserLocation
+	if raw, ok := args["user_location"].(map[string]any); ok && len(raw) > 0 {
+		fields := make(map[string]string, len(raw))
+		for k, v := range raw {
+			if s, ok := v.(string); ok && s != "" {
+				fields[k] = s
+			}
+		}
+		// An invalid hint is dropped rather than failing the search.
+		if loc, err := newUserLocation(fields); err == nil && loc != nil {
+			userLocation = loc
+		}
+	}
+
+	var domains []string
+	switch v := args["domains"].(type) {
+	case []string:
+		domains = v
+	case []any:
+		for _, d := range v {
+			if s, ok := d.(string); ok {
+				domains = append(domains, s)
+			}
+		}
+	}
+	domains = normalizeDomainFilter(domains)
+
+	provider, _ := args["provider"].(string) //nolint:errcheck
+	if provider == "" {
+		provider = defaultProvider
+	}
+
+	var maxOutputTokens int
+	switch v := args["max_output_tokens"].(type) {
+	case int:
+		maxOutputTokens = v
+	case float64:
+		maxOutputTokens = int(v)
+	}
+	maxOutputTokens = validateMaxOutputTokens(maxOutputTokens)
+
+	temperature := floatArg(args, "temperature")
+	topP := floatArg(args, "top_p")
+	// The handler compiles output_schema so it can reject a bad schema
+	// before any upstream call.
+	schema, _ := args["output_schema"].(*outputSchema) //nolint:errcheck
+
+	// The handler resolves image to a URL or data URL so a bad path fails
+	// before any upstream call.
+	var images []string
+	if image, _ := args["image"].(string); image != "" { //nolint:errcheck
+		images = []string{image}
+	}
+
+	// Likewise files arrive already read by the handler.
+	files, _ := args["files"].([]attachment) //nolint:errcheck
+
+	codeInterpreter, _ := args["code_interpreter"].(bool) //nolint:errcheck
+	var vectorStoreIDs []string
+	switch v := args["vector_store_ids"].(type) {
+	case []string:
+		vectorStoreIDs = v
+	case []any:
+		for _, id := range v {
+			if s, ok := id.(string); ok {
+				vectorStoreIDs = append(vectorStoreIDs, s)
+			}
+		}
+	}
+
+	related, _ := args["related"].(bool)  //nolint:errcheck
+	noCache, _ := args["no_cache"].(bool) //nolint:errcheck
+
+	noStore := noStoreDefault
+	if store, ok := args["store"].(bool); ok {
+		noStore = !store
+	}
+
+	// synthesis defaults to true, so only an explicit false counts.
+	synthesis, ok := args["synthesis"].(bool)
+	noSynthesis := ok && !synthesis
+
 	useWebSearch := true
 	if webSearchVal, exists := args["web_search"]; exists {
 		if webSearchBool, ok := webSearchVal.(bool); ok {
@@ -169,13 +736,45 @@ func extractWebSearchArgs(args map[string]interface{}) webSearchArgs {
 
 	return webSearchArgs{
 		query:              query,
+		messages:           messages,
 		model:              model,
 		effort:             effort,
 		verbosity:          verbosity,
 		previousResponseID: previousResponseID,
 		promptCacheKey:     promptCacheKey,
+		truncation:         truncation,
+		searchContextSize:  searchContextSize,
+		userLocation:       userLocation,
+		domains:            domains,
+		provider:           provider,
+		maxOutputTokens:    maxOutputTokens,
+		temperature:        temperature,
+		topP:               topP,
+		outputSchema:       schema,
+		tools:              hostedTools(codeInterpreter, vectorStoreIDs),
+		images:             images,
+		files:              files,
+		related:            related,
 		useWebSearch:       useWebSearch,
+		noSynthesis:        noSynthesis,
+		noStore:            noStore,
+		noCache:            noCache,
+	}
+}
+
+// floatArg returns the numeric argument named key, or nil when it is absent
+// so "unset" stays distinct from zero.
+func floatArg(args map[string]interface{}, key string) *float64 {
+	switch v := args[key].(type) {
+	case float64:
+		return &v
+	case *float64:
+		return v
+	case int:
+		f := float64(v)
+		return &f
 	}
+	return nil
 }
 
 // resolvePromptCacheKey picks the prompt_cache_key to send upstream:
@@ -205,76 +804,265 @@ func HandleWebSearch(ctx context.Context, apiKey, baseURL string, args map[strin
 			PreviousResponseID: wa.previousResponseID,
 		}, nil
 	}
+	return answerQuery(ctx, apiKey, baseURL, wa)
+}
 
+// answerQuery answers validated arguments from the exact or semantic cache
+// when possible, otherwise upstream, and caches and records the result.
+func answerQuery(ctx context.Context, apiKey, baseURL string, wa webSearchArgs) (*WebSearchResult, error) {
+	cacheKey := cacheKeyFor(wa)
+	scope := semanticScopeFor(wa)
+	var vec []float64
+	if !wa.noCache {
+		if cached, state := answerCache.get(cacheKey); state != cacheMiss {
+			cached.Cached = true
+			// Entries cached by older versions carry no hash.
+			cached.ContentHash = cmp.Or(cached.ContentHash, contentHash(cached.Answer))
+			if state == cacheStale {
+				cached.Stale = true
+				if !offlineMode {
+					refreshCachedAnswer(ctx, apiKey, baseURL, wa, cacheKey)
+				}
+			}
+			logToClient(ctx, mcp.LoggingLevelDebug, "api_handler", fmt.Sprintf("Serving cached answer (stale=%t, age=%s)", cached.Stale, cached.CacheAge))
+			return &cached, nil
+		}
+
+		similar, score, v, ok := querySemanticCache.lookup(ctx, apiKey, scope, wa.query)
+		if ok {
+			similar.Cached = true
+			similar.CacheSimilarity = score
+			similar.MatchedQuery = similar.Query
+			similar.Query = wa.query
+			logToClient(ctx, mcp.LoggingLevelDebug, "api_handler", fmt.Sprintf("Serving semantically cached answer (similarity=%.3f)", score))
+			return &similar, nil
+		}
+		vec = v
+	}
+
+	result, err := coalescedWebSearch(ctx, apiKey, baseURL, wa, cacheKey)
+	if err != nil {
+		return nil, err
+	}
+	// A downgraded answer is not what was asked for, so it is not reused.
+	if len(result.Degraded) == 0 {
+		answerCache.set(cacheKey, *result)
+		querySemanticCache.store(ctx, apiKey, scope, wa.query, vec, *result)
+	}
+	answerHistory.recordResult(ctx, result)
+	return result, nil
+}
+
+// refreshCachedAnswer re-runs a search whose cached answer went stale and
+// replaces the cache entry. It runs in the background, detached from the
+// caller's cancellation, and at most once per key at a time.
+func refreshCachedAnswer(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, cacheKey string) {
+	if !answerCache.beginRefresh(cacheKey) {
+		return
+	}
+	bgCtx := context.WithoutCancel(ctx)
+	go func() {
+		defer answerCache.endRefresh(cacheKey)
+		// A caller that missed the cache meanwhile joins the refresh.
+		result, err := coalescedWebSearch(bgCtx, apiKey, baseURL, wa, cacheKey)
+		if err != nil {
+			Warn("Background cache refresh failed", "error", err)
+			return
+		}
+		answerCache.set(cacheKey, *result)
+		Debug("Background cache refresh completed", "id", result.ID)
+	}()
+}
+
+// runWebSearch performs the upstream call for validated arguments and builds
+// the structured result.
+func runWebSearch(ctx context.Context, apiKey, baseURL string, wa webSearchArgs) (*WebSearchResult, error) {
+	pr, err := lookupProvider(wa.provider)
+	if err != nil {
+		return nil, err
+	}
+	requestedModel, requestedEffort := wa.model, wa.effort
+	wa, degraded := activeDowngrade.apply(pr.name, wa)
+	if len(degraded) > 0 {
+		logToClient(ctx, mcp.LoggingLevelWarning, "api_handler", fmt.Sprintf("Provider %s is rate limiting; downgraded: %s", pr.name, strings.Join(degraded, ", ")))
+	}
 	query, model, effort, verbosity := wa.query, wa.model, wa.effort, wa.verbosity
 	previousResponseID, useWebSearch := wa.previousResponseID, wa.useWebSearch
 	timeout := getTimeoutForEffort(effort)
 	cacheKey := resolvePromptCacheKey(ctx, wa.promptCacheKey)
+	if useWebSearch && !pr.webSearch {
+		logToClient(ctx, mcp.LoggingLevelInfo, "api_handler", fmt.Sprintf("Provider %s has no web search; answering from model knowledge", pr.name))
+		useWebSearch = false
+	}
+	tools := wa.tools
+	if len(tools) > 0 && !pr.hostedTools {
+		logToClient(ctx, mcp.LoggingLevelInfo, "api_handler", fmt.Sprintf("Provider %s has no hosted tools; ignoring code_interpreter/file_search", pr.name))
+		tools = nil
+	}
+	allowDomains, blockDomains := splitDomainFilter(wa.domains)
 
-	apiResp, err := CallAPI(ctx, CallAPIParams{
-		APIKey:             apiKey,
-		BaseURL:            baseURL,
+	params := CallAPIParams{
+		Provider:           pr.name,
+		APIKey:             pr.keyFor(apiKey),
+		BaseURL:            pr.endpointFor(baseURL),
 		Query:              query,
+		Messages:           wa.messages,
 		Model:              model,
 		Effort:             effort,
 		Verbosity:          verbosity,
 		PreviousResponseID: previousResponseID,
 		PromptCacheKey:     cacheKey,
+		Truncation:         wa.truncation,
+		NoStore:            wa.noStore,
+		SearchContextSize:  wa.searchContextSize,
+		UserLocation:       wa.userLocation,
+		AllowedDomains:     allowDomains,
+		BlockedDomains:     blockDomains,
+		MaxOutputTokens:    wa.maxOutputTokens,
+		Temperature:        wa.temperature,
+		TopP:               wa.topP,
+		OutputSchema:       wa.outputSchema,
+		Tools:              tools,
+		Images:             wa.images,
+		Files:              wa.files,
 		Timeout:            timeout,
 		UseWebSearch:       useWebSearch,
-	})
+		Retry:              retryPolicy,
+	}
+	if wa.noSynthesis {
+		return retrieveSources(ctx, params, wa, degraded)
+	}
+	apiResp, err := CallAPI(ctx, params)
 	if err != nil {
 		return nil, err
 	}
 
 	// Extract answer from response
-	answer := ExtractAnswer(apiResp)
+	extracted := ExtractAnswer(apiResp)
+	extracted.Text, extracted.Citations = withoutDomains(extracted.Text, extracted.Citations, blockDomains)
+	answer := answerPipeline.apply(extracted.Text)
+	if wa.outputSchema != nil {
+		// Post-processing could break the JSON document.
+		answer = extracted.Text
+	}
 	if answer == "" {
 		errMsg := "No answer found in response"
+		if err := checkReasoningOnly(apiResp); err != nil {
+			errMsg = err.Error()
+		}
 		logToClient(ctx, mcp.LoggingLevelWarning, "api_handler", errMsg)
 		return &WebSearchResult{
 			Success:            false,
 			Error:              errMsg,
 			Query:              query,
-			RequestedModel:     model,
-			RequestedEffort:    effort,
+			RequestedModel:     requestedModel,
+			RequestedEffort:    requestedEffort,
 			WebSearchUsed:      useWebSearch,
 			TimeoutUsed:        timeout.String(),
 			PreviousResponseID: previousResponseID,
+			Provider:           pr.name,
+			Degraded:           degraded,
 		}, nil
 	}
 
 	// Log successful completion
-	logToClient(ctx, mcp.LoggingLevelDebug, "api_handler", fmt.Sprintf("Search completed successfully, answer length: %d characters", len(answer)))
+	logToClient(ctx, mcp.LoggingLevelDebug, "api_handler", fmt.Sprintf("Search completed successfully, answer length: %d characters", utf8.RuneCountInString(answer)))
+
+	// Gateways may drop model/reasoning from the payload; fall back to what
+	// was requested rather than reporting empty values.
+	respModel, respEffort := apiResp.Model, apiResp.Reasoning.Effort
+	if respModel == "" {
+		respModel = model
+	}
+	if respEffort == "" {
+		respEffort = effort
+	}
 
 	// Return structured response
-	return &WebSearchResult{
+	result := &WebSearchResult{
 		Success:            true,
 		Answer:             answer,
 		Query:              query,
-		Model:              apiResp.Model,
-		Effort:             apiResp.Reasoning.Effort,
+		Model:              respModel,
+		Effort:             respEffort,
 		TimeoutUsed:        timeout.String(),
 		ID:                 apiResp.ID,
-		RequestedModel:     model,
-		RequestedEffort:    effort,
+		RequestedModel:     requestedModel,
+		RequestedEffort:    requestedEffort,
 		WebSearchUsed:      useWebSearch,
 		PreviousResponseID: previousResponseID,
-	}, nil
+		Provider:           pr.name,
+		RequestID:          apiResp.RequestID,
+		Degraded:           degraded,
+		Ignored:            apiResp.Ignored,
+		ContentHash:        contentHash(answer),
+		Usage:              apiResp.Usage,
+	}
+	if apiResp.RetryWait > 0 {
+		result.RetryWait = apiResp.RetryWait.String()
+	}
+	result.Citations = extracted.Citations
+	if answer != extracted.Text {
+		result.Citations = withoutSpans(result.Citations)
+	}
+	if err := wa.outputSchema.validate(answer); err != nil {
+		logToClient(ctx, mcp.LoggingLevelWarning, "api_handler", err.Error())
+		result.Success = false
+		result.Error = err.Error()
+	}
+	if wa.related && result.Success {
+		// Suggestions are a convenience; the answer stands without them.
+		if result.Related, err = suggestRelated(ctx, pr.name, apiKey, baseURL, query, answer); err != nil {
+			logToClient(ctx, mcp.LoggingLevelWarning, "api_handler", err.Error())
+		}
+	}
+	return result, nil
 }
 
 // WebSearchResult defines the structured result returned to MCP clients
 type WebSearchResult struct {
-	Success            bool   `json:"success"`
-	Answer             string `json:"answer,omitempty"`
-	Query              string `json:"query"`
-	Model              string `json:"model"`
-	Effort             string `json:"effort"`
-	TimeoutUsed        string `json:"timeout_used"`
-	ID                 string `json:"id,omitempty"`
-	RequestedModel     string `json:"requested_model"`
-	RequestedEffort    string `json:"requested_effort"`
-	WebSearchUsed      bool   `json:"web_search_used"`
-	PreviousResponseID string `json:"previous_response_id,omitempty"`
-	Error              string `json:"error,omitempty"`
+	// SchemaVersion is set on results sent to MCP clients (see
+	// resultSchemaVersion).
+	SchemaVersion      int        `json:"schema_version,omitempty"`
+	Success            bool       `json:"success"`
+	Answer             string     `json:"answer,omitempty"`
+	Query              string     `json:"query"`
+	Model              string     `json:"model"`
+	Effort             string     `json:"effort"`
+	TimeoutUsed        string     `json:"timeout_used"`
+	ID                 string     `json:"id,omitempty"`
+	RequestedModel     string     `json:"requested_model"`
+	RequestedEffort    string     `json:"requested_effort"`
+	WebSearchUsed      bool       `json:"web_search_used"`
+	PreviousResponseID string     `json:"previous_response_id,omitempty"`
+	Cached             bool       `json:"cached,omitempty"`
+	Stale              bool       `json:"stale,omitempty"`
+	CacheAge           string     `json:"cache_age,omitempty"`
+	CacheSimilarity    float64    `json:"cache_similarity,omitempty"`
+	MatchedQuery       string     `json:"matched_query,omitempty"`
+	RetryWait          string     `json:"retry_wait,omitempty"`
+	RequestID          string     `json:"request_id,omitempty"`
+	Provider           string     `json:"provider,omitempty"`
+	Citations          []Citation `json:"citations,omitempty"`
+	Related            []string   `json:"related_questions,omitempty"`
+	Error              string     `json:"error,omitempty"`
+	// Degraded lists the downgrades applied because the provider was rate
+	// limiting (see DOWNGRADE_POLICY), e.g. "effort: high -> medium".
+	Degraded []string `json:"degraded,omitempty"`
+	// Ignored lists the request parameters the provider could not honor,
+	// e.g. "user_location" on OpenRouter.
+	Ignored []string `json:"ignored,omitempty"`
+	// AnswerPages and NextPage are set when Answer holds only the first
+	// page of a long answer (see answerPager).
+	AnswerPages int    `json:"answer_pages,omitempty"`
+	NextPage    string `json:"next_page,omitempty"`
+	// ContentHash identifies the full answer text (see contentHash).
+	ContentHash string `json:"content_hash,omitempty"`
+	// Provenance is the answer's provenance manifest, when asked for.
+	Provenance *Manifest `json:"provenance,omitempty"`
+	// Usage is the upstream token usage, when the provider reports it.
+	Usage *apiUsage `json:"usage,omitempty"`
+	// Sources are the search results returned instead of an answer when
+	// synthesis is off (see retrieveSources).
+	Sources []SearchSource `json:"sources,omitempty"`
 }
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -85,7 +87,7 @@ func TestCallAPI_Success_WebSearchTrue_VerifyMethodPathAndBody(t *testing.T) {
 		if reqBody.PromptCacheKey != "test-cache-key" {
 			t.Errorf("expected prompt_cache_key 'test-cache-key', got %s", reqBody.PromptCacheKey)
 		}
-		if len(reqBody.Tools) != 1 || reqBody.Tools[0].Type != "web_search_preview" {
+		if len(reqBody.Tools) != 1 || reqBody.Tools[0].Type != toolWebSearch {
 			t.Errorf("expected web search tool, got %+v", reqBody.Tools)
 		}
 
@@ -441,7 +443,7 @@ func TestExtractAnswer(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got := ExtractAnswer(tt.apiResp)
+			got := ExtractAnswer(tt.apiResp).Text
 			if got != tt.want {
 				t.Errorf("ExtractAnswer() got = %v, want %v", got, tt.want)
 			}
@@ -449,6 +451,50 @@ func TestExtractAnswer(t *testing.T) {
 	}
 }
 
+func TestExtractAnswerTyped(t *testing.T) {
+	t.Parallel()
+
+	raw := `{
+		"id": "resp_x",
+		"output": [
+			{"type": "reasoning", "id": "rs_1", "summary": [{"type": "summary_text", "text": "Looked up the score."}]},
+			{"type": "web_search_call", "id": "ws_1", "status": "completed", "action": {"type": "search", "query": "super bowl 2024"}},
+			{"type": "message", "content": [
+				{"type": "output_text", "text": "Chiefs won.", "annotations": [
+					{"type": "url_citation", "url": "https://example.com", "title": "Ex", "start_index": 0, "end_index": 6}
+				]}
+			]},
+			{"type": "message", "content": null}
+		]
+	}`
+	var apiResp apiResponse
+	if err := json.Unmarshal([]byte(raw), &apiResp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	got := ExtractAnswer(&apiResp)
+	if got.Text != "Chiefs won." || len(got.Segments) != 1 {
+		t.Fatalf("Text/Segments = %q/%v", got.Text, got.Segments)
+	}
+	if len(got.Citations) != 1 || got.Citations[0].URL != "https://example.com" {
+		t.Errorf("Citations = %+v", got.Citations)
+	}
+	if len(got.Reasoning) != 1 || got.Reasoning[0] != "Looked up the score." {
+		t.Errorf("Reasoning = %v", got.Reasoning)
+	}
+	if len(got.ToolCalls) != 1 {
+		t.Fatalf("ToolCalls = %+v", got.ToolCalls)
+	}
+	tc := got.ToolCalls[0]
+	if tc.Type != "web_search_call" || tc.ID != "ws_1" || tc.Status != "completed" || tc.Action == nil || tc.Action.Query != "super bowl 2024" {
+		t.Errorf("ToolCall = %+v", tc)
+	}
+
+	if zero := ExtractAnswer(&apiResponse{}); zero.Text != "" || zero.Citations != nil || zero.ToolCalls != nil {
+		t.Errorf("ExtractAnswer(empty) = %+v, want zero value", zero)
+	}
+}
+
 func TestResolvePromptCacheKey(t *testing.T) {
 	t.Parallel()
 
@@ -500,3 +546,450 @@ func TestResolvePromptCacheKey(t *testing.T) {
 		})
 	}
 }
+
+func TestCallAPI_OfflineRefusesUpstream(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected upstream call in offline mode")
+	}
+	_, base := newJSONServer(t, handler)
+
+	offlineMode = true
+	t.Cleanup(func() { offlineMode = false })
+
+	_, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: time.Second,
+	})
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline, got %v", err)
+	}
+}
+
+func TestCallAPI_Streaming_DeliversDeltasAndFinalResponse(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var reqBody requestBody
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if !reqBody.Stream {
+			t.Errorf("expected stream=true in request body")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"response.created","response":{"id":"resp_s"}}`,
+			`{"type":"response.output_text.delta","delta":"Hel"}`,
+			`{"type":"response.output_text.delta","delta":"lo"}`,
+			`{"type":"response.completed","response":{"id":"resp_s","model":"m","output":[{"type":"message","content":[{"type":"output_text","text":"Hello"}]}]}}`,
+		}
+		for _, ev := range events {
+			_, _ = w.Write([]byte("event: x\ndata: " + ev + "\n\n"))
+			w.(http.Flusher).Flush()
+		}
+	}
+	_, base := newJSONServer(t, handler)
+
+	var deltas []string
+	apiResp, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: 2 * time.Second,
+		OnDelta: func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(deltas, "") != "Hello" {
+		t.Errorf("deltas = %q, want Hel+lo", deltas)
+	}
+	if apiResp.ID != "resp_s" || ExtractAnswer(apiResp).Text != "Hello" {
+		t.Errorf("unexpected final response: %+v", apiResp)
+	}
+}
+
+func TestReadResponseStream_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		stream  string
+		wantErr string
+	}{
+		{name: "truncated", stream: "data: {\"type\":\"response.output_text.delta\",\"delta\":\"x\"}\n\n", wantErr: ErrStreamIncomplete.Error()},
+		{name: "failed", stream: "data: {\"type\":\"response.failed\",\"response\":{\"error\":{\"code\":\"server_error\",\"message\":\"boom\"}}}\n\n", wantErr: "boom"},
+		{name: "error_event", stream: "data: {\"type\":\"error\",\"code\":\"rate_limit\",\"message\":\"slow down\"}\n\n", wantErr: "slow down"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := readResponseStream(strings.NewReader(tt.stream), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckReasoningOnly(t *testing.T) {
+	t.Parallel()
+
+	reasoningOnly := &apiResponse{
+		Status:            "incomplete",
+		IncompleteDetails: &incompleteDetails{Reason: "max_output_tokens"},
+		Output:            []respItem{{Type: "reasoning"}},
+		Usage: &apiUsage{
+			OutputTokens:        512,
+			OutputTokensDetails: outputTokensDetails{ReasoningTokens: 512},
+		},
+	}
+	err := checkReasoningOnly(reasoningOnly)
+	if !errors.Is(err, ErrReasoningOnly) {
+		t.Fatalf("expected ErrReasoningOnly, got %v", err)
+	}
+	var roErr *ReasoningOnlyError
+	if !errors.As(err, &roErr) || roErr.OutputTokens != 512 || roErr.Reason != "max_output_tokens" {
+		t.Errorf("unexpected error details: %+v", roErr)
+	}
+	if !strings.Contains(err.Error(), "max_output_tokens") {
+		t.Errorf("error should suggest raising max_output_tokens: %v", err)
+	}
+
+	withMessage := &apiResponse{Output: []respItem{{Type: "reasoning"}, {Type: "message"}}}
+	if err := checkReasoningOnly(withMessage); err != nil {
+		t.Errorf("expected nil for response with a message, got %v", err)
+	}
+	if err := checkReasoningOnly(&apiResponse{}); err != nil {
+		t.Errorf("expected nil for empty response, got %v", err)
+	}
+}
+
+func TestCallAPI_ReusesConnections(t *testing.T) {
+	var newConns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	for i := 0; i < 5; i++ {
+		if _, err := CallAPI(context.Background(), CallAPIParams{
+			APIKey:  "k",
+			BaseURL: srv.URL,
+			Query:   "q",
+			Timeout: time.Second,
+		}); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if got := newConns.Load(); got != 1 {
+		t.Errorf("opened %d connections for 5 sequential calls, want 1", got)
+	}
+}
+
+func TestCallAPI_SendsSearchContextSize(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody requestBody
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(reqBody.Tools) != 1 || reqBody.Tools[0].SearchContextSize != "high" {
+			t.Errorf("expected search_context_size=high, got %+v", reqBody.Tools)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:            "k",
+		BaseURL:           base,
+		Query:             "q",
+		SearchContextSize: "high",
+		Timeout:           time.Second,
+		UseWebSearch:      true,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCallAPI_PerCallEndpointAndKey(t *testing.T) {
+	t.Parallel()
+
+	newServer := func(wantKey, id string) string {
+		_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("Authorization"); got != "Bearer "+wantKey {
+				t.Errorf("%s: Authorization = %q, want key %q", id, got, wantKey)
+			}
+			writeJSON(t, w, http.StatusOK, map[string]any{"id": id})
+		})
+		return base
+	}
+	direct := newServer("direct-key", "resp_direct")
+	gateway := newServer("gateway-key", "resp_gateway")
+
+	for _, tc := range []struct{ base, key, wantID string }{
+		{direct, "direct-key", "resp_direct"},
+		{gateway, "gateway-key", "resp_gateway"},
+		{direct, "direct-key", "resp_direct"},
+	} {
+		ar, err := CallAPI(context.Background(), CallAPIParams{
+			APIKey:  tc.key,
+			BaseURL: tc.base,
+			Query:   "q",
+			Timeout: time.Second,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ar.ID != tc.wantID {
+			t.Errorf("ID = %q, want %q", ar.ID, tc.wantID)
+		}
+	}
+}
+
+func TestCallAPI_SendsUserLocation(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw struct {
+			Tools []map[string]any `json:"tools"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		loc, _ := raw.Tools[0]["user_location"].(map[string]any)
+		if loc["type"] != "approximate" || loc["country"] != "US" || loc["city"] != "Boston" {
+			t.Errorf("user_location = %v", loc)
+		}
+		if _, ok := loc["region"]; ok {
+			t.Errorf("empty region should be omitted: %v", loc)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{
+		"query":         "weather",
+		"user_location": map[string]any{"country": "us", "city": "Boston"},
+	})
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:       "k",
+		BaseURL:      base,
+		Query:        wa.query,
+		UserLocation: wa.userLocation,
+		Timeout:      time.Second,
+		UseWebSearch: true,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCallAPI_AllowedDomainsUseWebSearchFilters(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody requestBody
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(reqBody.Tools) != 1 {
+			t.Fatalf("expected one tool, got %+v", reqBody.Tools)
+		}
+		tool := reqBody.Tools[0]
+		if tool.Type != "web_search" {
+			t.Errorf("tool type = %q, want web_search", tool.Type)
+		}
+		if tool.Filters == nil || strings.Join(tool.Filters.AllowedDomains, ",") != "arxiv.org,nih.gov" {
+			t.Errorf("filters = %+v", tool.Filters)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{
+		"query":   "q",
+		"domains": []any{"https://www.arxiv.org/list", "nih.gov", "ARXIV.org", ""},
+	})
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:         "k",
+		BaseURL:        base,
+		Query:          wa.query,
+		AllowedDomains: wa.domains,
+		Timeout:        time.Second,
+		UseWebSearch:   true,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCallAPI_HostedTools(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody requestBody
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(reqBody.Tools) != 3 {
+			t.Fatalf("expected three tools, got %+v", reqBody.Tools)
+		}
+		if reqBody.Tools[0].Type != toolWebSearch {
+			t.Errorf("first tool = %q, want web search", reqBody.Tools[0].Type)
+		}
+		if ci := reqBody.Tools[1]; ci.Type != toolCodeInterpreter || ci.Container == nil || ci.Container.Type != "auto" {
+			t.Errorf("code interpreter tool = %+v", ci)
+		}
+		if fs := reqBody.Tools[2]; fs.Type != toolFileSearch || strings.Join(fs.VectorStoreIDs, ",") != "vs_1,vs_2" {
+			t.Errorf("file search tool = %+v", fs)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{
+		"query":            "q",
+		"code_interpreter": true,
+		"vector_store_ids": []any{"vs_1", " vs_2", "vs_1", ""},
+	})
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:       "k",
+		BaseURL:      base,
+		Query:        wa.query,
+		Tools:        wa.tools,
+		Timeout:      time.Second,
+		UseWebSearch: true,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCallAPI_SendsMaxOutputTokens(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody requestBody
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if reqBody.MaxOutputTokens != 16 {
+			t.Errorf("max_output_tokens = %d, want 16 (raised to the minimum)", reqBody.MaxOutputTokens)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{"query": "q", "max_output_tokens": float64(5)})
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:          "k",
+		BaseURL:         base,
+		Query:           wa.query,
+		MaxOutputTokens: wa.maxOutputTokens,
+		Timeout:         time.Second,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := extractWebSearchArgs(map[string]any{"query": "q"}).maxOutputTokens; got != 0 {
+		t.Errorf("unset max_output_tokens = %d, want 0", got)
+	}
+}
+
+func TestCallAPI_NoStore(t *testing.T) {
+	t.Parallel()
+
+	var stores []any
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		stores = append(stores, raw["store"])
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	for _, args := range []map[string]any{{"query": "q", "store": false}, {"query": "q"}} {
+		wa := extractWebSearchArgs(args)
+		if _, err := CallAPI(context.Background(), CallAPIParams{APIKey: "k", BaseURL: base, Query: wa.query, NoStore: wa.noStore, Timeout: time.Second}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(stores) != 2 || stores[0] != false || stores[1] != nil {
+		t.Errorf("store fields sent = %v, want [false <nil>]", stores)
+	}
+
+	_, err := CallAPI(context.Background(), CallAPIParams{APIKey: "k", BaseURL: base, Query: "q", NoStore: true, PreviousResponseID: "resp_1", Timeout: time.Second})
+	if !errors.Is(err, ErrNoStoreContinuation) || len(stores) != 2 {
+		t.Errorf("err = %v, want ErrNoStoreContinuation without an upstream call", err)
+	}
+}
+
+func TestCallAPI_Truncation(t *testing.T) {
+	t.Parallel()
+
+	var sent []any
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		sent = append(sent, raw["truncation"])
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	for _, args := range []map[string]any{
+		{"query": "q"},
+		{"query": "q", "previous_response_id": "resp_1"},
+		{"query": "q", "previous_response_id": "resp_1", "truncation": "disabled"},
+		{"query": "q", "truncation": "auto"},
+		{"query": "q", "truncation": "bogus"},
+	} {
+		wa := extractWebSearchArgs(args)
+		params := CallAPIParams{APIKey: "k", BaseURL: base, Query: wa.query, PreviousResponseID: wa.previousResponseID, Truncation: wa.truncation, Timeout: time.Second}
+		if _, err := CallAPI(context.Background(), params); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []any{nil, "auto", "disabled", "auto", nil}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("truncation sent = %v, want %v", sent, want)
+	}
+}
+
+func TestCallAPI_SamplingParameters(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if raw["temperature"] != 0.0 || raw["top_p"] != 0.5 {
+			t.Errorf("temperature/top_p = %v/%v, want 0/0.5", raw["temperature"], raw["top_p"])
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{"query": "q", "temperature": 0.0, "top_p": 0.5})
+	params := CallAPIParams{
+		APIKey:      "k",
+		BaseURL:     base,
+		Query:       wa.query,
+		Model:       "gpt-4.1",
+		Effort:      "medium",
+		Temperature: wa.temperature,
+		TopP:        wa.topP,
+		Timeout:     time.Second,
+	}
+	if _, err := CallAPI(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	params.Model = "gpt-5.4-mini"
+	if _, err := CallAPI(context.Background(), params); !errors.Is(err, ErrSamplingUnsupported) {
+		t.Errorf("expected ErrSamplingUnsupported for a reasoning model, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxAttachmentBytes is the API's per-file limit for inline PDFs.
+	maxAttachmentBytes = 32 << 20
+	// maxTextAttachmentBytes bounds text documents, which count fully
+	// against the input token budget.
+	maxTextAttachmentBytes = 1 << 20
+)
+
+// attachRoot is ATTACH_DIR: MCP clients may attach only files below it.
+var attachRoot string
+
+// attachment is a local document sent as context with the question.
+type attachment struct {
+	Name string
+	// Text holds the content of a plain-text document (txt, md, ...); Data
+	// holds a base64 data URL for a PDF, which the model reads natively.
+	Text string
+	Data string
+}
+
+// loadAttachment reads a document from path. PDFs are inlined as data URLs;
+// any other file must be UTF-8 text.
+func loadAttachment(path string) (attachment, error) {
+	a := attachment{Name: filepath.Base(path)}
+	info, err := os.Stat(path)
+	if err != nil {
+		return a, fmt.Errorf("read attachment: %w", err)
+	}
+	if info.Size() > maxAttachmentBytes {
+		return a, fmt.Errorf("attachment %s is %d bytes; the limit is %d", path, info.Size(), maxAttachmentBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return a, fmt.Errorf("read attachment: %w", err)
+	}
+
+	if http.DetectContentType(data) == "application/pdf" {
+		a.Data = "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(data)
+		return a, nil
+	}
+	if !utf8.Valid(data) {
+		return a, fmt.Errorf("attachment %s is neither a PDF nor UTF-8 text", path)
+	}
+	if len(data) > maxTextAttachmentBytes {
+		return a, fmt.Errorf("text attachment %s is %d bytes; the limit is %d", path, len(data), maxTextAttachmentBytes)
+	}
+	a.Text = string(data)
+	return a, nil
+}
+
+// loadAttachments reads every path, skipping blanks.
+func loadAttachments(paths []string) ([]attachment, error) {
+	var out []attachment
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		a, err := loadAttachment(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// resolveAttachPath maps a path supplied by an MCP client onto a file below
+// root. It refuses ".." components and symlinks leading out of root, and
+// every path when root is empty, so a client cannot read arbitrary server
+// files such as keys or .env. Relative paths are taken from root.
+func resolveAttachPath(root, path string) (string, error) {
+	if root == "" {
+		return "", ErrAttachDisabled
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %s", ErrAttachOutsideRoot, path)
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", fmt.Errorf("ATTACH_DIR: %w", err)
+	}
+	full := filepath.Clean(path)
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(root, full)
+	}
+	// Check before touching the file, so paths outside root are not probed,
+	// and again after resolving symlinks.
+	if !withinDir(root, full) {
+		return "", fmt.Errorf("%w: %s", ErrAttachOutsideRoot, path)
+	}
+	resolved, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", fmt.Errorf("read attachment: %w", err)
+	}
+	if !withinDir(root, resolved) {
+		return "", fmt.Errorf("%w: %s", ErrAttachOutsideRoot, path)
+	}
+	return resolved, nil
+}
+
+// withinDir reports whether path is dir or below it; both must be clean
+// and absolute.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// loadClientAttachments is loadAttachments for paths from MCP clients,
+// confined to attachRoot.
+func loadClientAttachments(paths []string) ([]attachment, error) {
+	var out []attachment
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		resolved, err := resolveAttachPath(attachRoot, p)
+		if err != nil {
+			return nil, err
+		}
+		a, err := loadAttachment(resolved)
+		if err != nil {
+			return nil, err
+		}
+		a.Name = filepath.Base(p)
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// readPipedStdin returns the text piped into the process, or "" when stdin
+// is a terminal or a device such as /dev/null. Like a text attachment, it
+// must be UTF-8 and at most maxTextAttachmentBytes.
+func readPipedStdin(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return "", nil
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxTextAttachmentBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("read stdin: %w", err)
+	}
+	if len(data) > maxTextAttachmentBytes {
+		return "", fmt.Errorf("stdin holds more than %d bytes", maxTextAttachmentBytes)
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("stdin is not UTF-8 text")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// inlineText renders a text attachment as a labelled block of input.
+func (a attachment) inlineText() string {
+	return fmt.Sprintf("Attached document %q:\n\n%s", a.Name, a.Text)
+}
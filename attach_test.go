@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadAttachment(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	md, err := loadAttachment(write("contract.md", []byte("# Terms\nPayment within 30 days.")))
+	if err != nil || md.Name != "contract.md" || md.Data != "" || !strings.Contains(md.Text, "30 days") {
+		t.Errorf("markdown = %+v, %v", md, err)
+	}
+
+	pdf, err := loadAttachment(write("policy.pdf", []byte("%PDF-1.7\n%âãÏÓ\n")))
+	if err != nil || pdf.Text != "" || !strings.HasPrefix(pdf.Data, "data:application/pdf;base64,JVBERi0xLjc") {
+		t.Errorf("pdf = %+v, %v", pdf, err)
+	}
+
+	if _, err := loadAttachment(write("blob.bin", []byte{0xff, 0xfe, 0x00, 0x01})); err == nil {
+		t.Error("expected error for binary file")
+	}
+	if got, err := loadAttachments([]string{"", " "}); err != nil || len(got) != 0 {
+		t.Errorf("blank paths = %+v, %v", got, err)
+	}
+}
+
+func TestResolveAttachPath(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	root := filepath.Join(base, "attach")
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"attach/docs/brief.md": "brief", "secret.env": "KEY=1"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "secret.env"), filepath.Join(root, "link.env")); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+
+	want, err := filepath.EvalSymlinks(filepath.Join(root, "docs", "brief.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"docs/brief.md", filepath.Join(root, "docs", "brief.md")} {
+		if got, err := resolveAttachPath(root, p); err != nil || got != want {
+			t.Errorf("resolveAttachPath(%q) = %q, %v; want %q", p, got, err, want)
+		}
+	}
+	for _, p := range []string{"../secret.env", "docs/../../secret.env", filepath.Join(base, "secret.env"), "link.env", "/etc/passwd"} {
+		if _, err := resolveAttachPath(root, p); !errors.Is(err, ErrAttachOutsideRoot) {
+			t.Errorf("resolveAttachPath(%q) err = %v, want ErrAttachOutsideRoot", p, err)
+		}
+	}
+	if _, err := resolveAttachPath("", "docs/brief.md"); !errors.Is(err, ErrAttachDisabled) {
+		t.Errorf("no root err = %v, want ErrAttachDisabled", err)
+	}
+}
+
+func TestCallAPI_SendsAttachments(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input []inputMessage `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(body.Input) != 1 || len(body.Input[0].Content) != 3 {
+			t.Fatalf("input = %+v", body.Input)
+		}
+		parts := body.Input[0].Content
+		if parts[1].Type != "input_text" || !strings.Contains(parts[1].Text, `"notes.md"`) || !strings.Contains(parts[1].Text, "Payment within 30 days") {
+			t.Errorf("text document part = %+v", parts[1])
+		}
+		if parts[2].Type != "input_file" || parts[2].Filename != "policy.pdf" || parts[2].FileData != "data:application/pdf;base64,AAAA" {
+			t.Errorf("pdf part = %+v", parts[2])
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "does this comply?",
+		Files: []attachment{
+			{Name: "notes.md", Text: "Payment within 30 days."},
+			{Name: "policy.pdf", Data: "data:application/pdf;base64,AAAA"},
+		},
+		Timeout: time.Second,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReadPipedStdin(t *testing.T) {
+	t.Parallel()
+
+	pipe := func(data string) *os.File {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("pipe: %v", err)
+		}
+		t.Cleanup(func() { r.Close() })
+		go func() {
+			w.WriteString(data) //nolint:errcheck
+			w.Close()
+		}()
+		return r
+	}
+
+	if got, err := readPipedStdin(pipe("  panic: nil map\n")); err != nil || got != "panic: nil map" {
+		t.Errorf("piped text = %q, %v", got, err)
+	}
+	if _, err := readPipedStdin(pipe("\xff\xfe")); err == nil {
+		t.Error("non-UTF-8 input: expected an error")
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	if got, err := readPipedStdin(devNull); err != nil || got != "" {
+		t.Errorf("device input = %q, %v; want nothing read", got, err)
+	}
+}
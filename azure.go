@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	providerAzure = "azure"
+	// defaultAzureAPIVersion is the api-version sent when
+	// AZURE_OPENAI_API_VERSION is unset; the Responses API needs a preview
+	// version.
+	defaultAzureAPIVersion = "2025-04-01-preview"
+)
+
+// azureSettings locates an Azure OpenAI resource. Azure addresses models by
+// deployment name in the URL and authenticates with an api-key header.
+type azureSettings struct {
+	// Endpoint is the resource URL, e.g. https://myres.openai.azure.com.
+	Endpoint   string
+	APIVersion string
+	// Deployment, when set, replaces the requested model name.
+	Deployment string
+}
+
+// azure holds the Azure OpenAI settings; set from AZURE_OPENAI_* at startup.
+var azure azureSettings
+
+// azureModel maps a model name onto the configured deployment.
+func azureModel(model string) string {
+	if azure.Deployment != "" {
+		return azure.Deployment
+	}
+	return model
+}
+
+// azureResponsesURL builds the deployment-scoped Responses endpoint. A base
+// that already contains an /openai/ path is used as given, with api-version
+// added when missing.
+func azureResponsesURL(base, deployment, apiVersion string) (string, error) {
+	if base == "" {
+		return "", fmt.Errorf("azure provider needs AZURE_OPENAI_ENDPOINT (e.g. https://myres.openai.azure.com)")
+	}
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid AZURE_OPENAI_ENDPOINT %q", base)
+	}
+	if !strings.Contains(u.Path, "/openai/") {
+		if deployment == "" {
+			return "", fmt.Errorf("azure provider needs a model or AZURE_OPENAI_DEPLOYMENT")
+		}
+		u.Path = strings.TrimRight(u.Path, "/") + "/openai/deployments/" + url.PathEscape(deployment) + "/responses"
+	}
+	q := u.Query()
+	if q.Get("api-version") == "" {
+		q.Set("api-version", apiVersion)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// sendAzure performs one Responses API call against an Azure deployment.
+func sendAzure(ctx context.Context, p CallAPIParams, onDelta func(string)) (*apiResponse, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = azure.Endpoint
+	}
+	endpoint, err := azureResponsesURL(base, p.Model, azure.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	p.BaseURL = endpoint
+	return sendOpenAI(ctx, p, onDelta)
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultBatchWorkers is how many batch questions are in flight at once.
+	defaultBatchWorkers = 4
+	// maxBatchWorkers caps -batch-workers.
+	maxBatchWorkers = 16
+	// maxBatchItems bounds one batch_websearch call.
+	maxBatchItems = 50
+	// batchAnswerTokens is the typical size of one answer read by the
+	// synthesis pass, for -confirm estimates.
+	batchAnswerTokens = 1000
+)
+
+// batchSynthesisPrompt asks the synthesis model to merge the answers of a
+// batch into one report that cites the numbered sources.
+const batchSynthesisPrompt = `You are given the answers to %d research questions and the numbered sources they cite.
+Write one report in Markdown that merges the findings, organised by theme rather than question by question.
+Cite sources inline by number in square brackets, e.g. [3], using only the numbers listed.
+Mention any question that could not be answered. Do not write a source list; one is appended for you.
+
+%s`
+
+// BatchResult holds one result per batch question, in input order.
+type BatchResult struct {
+	Results   []WebSearchResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	// Summary is the synthesized report with its numbered source list; only
+	// set when synthesis was requested and succeeded.
+	Summary      string     `json:"summary,omitempty"`
+	Sources      []Citation `json:"sources,omitempty"`
+	SummaryError string     `json:"summary_error,omitempty"`
+}
+
+// BatchCallAPI answers every item with at most workers searches in flight
+// and returns the results in input order. Items go through the answer cache
+// like single searches; a failed item is reported in its result and does
+// not stop the others. progress, when not nil, is told about each item, and
+// onResult, when not nil, gets each result as it is done.
+func BatchCallAPI(ctx context.Context, apiKey, baseURL string, items []webSearchArgs, workers int, progress *batchProgress, onResult func(int, WebSearchResult)) *BatchResult {
+	workers = min(max(workers, 1), maxBatchWorkers)
+	batch := &BatchResult{Results: make([]WebSearchResult, len(items))}
+
+	// Batch questions queue behind interactive calls for upstream slots.
+	ctx = withLane(ctx, laneBatch)
+	progress.begin()
+	var g errgroup.Group
+	g.SetLimit(workers)
+	for i, wa := range items {
+		g.Go(func() error {
+			start := time.Now()
+			batch.Results[i] = batchItem(ctx, apiKey, baseURL, wa)
+			progress.itemDone(i, batch.Results[i], time.Since(start))
+			if onResult != nil {
+				onResult(i, batch.Results[i])
+			}
+			return nil
+		})
+	}
+	_ = g.Wait() //nolint:errcheck // items never return errors
+	progress.finish()
+
+	for _, r := range batch.Results {
+		if r.Success {
+			batch.Succeeded++
+		} else {
+			batch.Failed++
+		}
+	}
+	return batch
+}
+
+// batchLines writes batch results as JSON lines in input order, each as
+// soon as it and every earlier result are done, so a long sweep can be
+// followed and an interrupted one keeps what it finished.
+type batchLines struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	pending map[int]WebSearchResult
+	next    int
+	err     error
+}
+
+func newBatchLines(w io.Writer) *batchLines {
+	return &batchLines{enc: json.NewEncoder(w), pending: make(map[int]WebSearchResult)}
+}
+
+// add records result i and writes every result that is now next in line.
+func (l *batchLines) add(i int, r WebSearchResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending[i] = r
+	for {
+		r, ok := l.pending[l.next]
+		if !ok {
+			return
+		}
+		delete(l.pending, l.next)
+		l.next++
+		if l.err == nil {
+			l.err = l.enc.Encode(r)
+		}
+	}
+}
+
+// batchItem answers one batch question, turning errors into a failed result.
+func batchItem(ctx context.Context, apiKey, baseURL string, wa webSearchArgs) WebSearchResult {
+	failed := WebSearchResult{Query: wa.query, RequestedModel: wa.model, RequestedEffort: wa.effort, Provider: wa.provider}
+	if err := ctx.Err(); err != nil {
+		failed.Error = err.Error()
+		return failed
+	}
+	if wa.query == "" {
+		failed.Error = "empty question"
+		return failed
+	}
+	r, err := answerQuery(ctx, apiKey, baseURL, wa)
+	if err != nil {
+		failed.Error = err.Error()
+		return failed
+	}
+	return *r
+}
+
+// synthesizeBatch merges the successful answers of batch into one cited
+// report, using the model settings of wa. Failures are recorded in
+// SummaryError; the individual results are kept either way.
+func synthesizeBatch(ctx context.Context, apiKey, baseURL string, batch *BatchResult, wa webSearchArgs) {
+	if batch.Succeeded == 0 {
+		batch.SummaryError = "no question was answered; nothing to synthesize"
+		return
+	}
+
+	// Number each distinct source once across the whole batch.
+	index := make(map[string]int)
+	var sources []Citation
+	var sb strings.Builder
+	for i, r := range batch.Results {
+		fmt.Fprintf(&sb, "--- Question %d: %s ---\n", i+1, r.Query)
+		if !r.Success {
+			fmt.Fprintf(&sb, "(not answered: %s)\n\n", r.Error)
+			continue
+		}
+		var refs []string
+		for _, c := range r.Citations {
+			n, ok := index[c.URL]
+			if !ok {
+				sources = append(sources, Citation{URL: c.URL, Title: c.Title})
+				n = len(sources)
+				index[c.URL] = n
+			}
+			if ref := fmt.Sprintf("[%d]", n); !slices.Contains(refs, ref) {
+				refs = append(refs, ref)
+			}
+		}
+		fmt.Fprintf(&sb, "%s\n", r.Answer)
+		if len(refs) > 0 {
+			fmt.Fprintf(&sb, "Sources: %s\n", strings.Join(refs, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	if len(sources) > 0 {
+		sb.WriteString("--- Sources ---\n")
+		for i, s := range sources {
+			fmt.Fprintf(&sb, "[%d] %s %s\n", i+1, s.Title, s.URL)
+		}
+	}
+
+	text, err := synthesize(ctx, apiKey, baseURL, wa, fmt.Sprintf(batchSynthesisPrompt, len(batch.Results), sb.String()))
+	if err != nil {
+		batch.SummaryError = "synthesis failed: " + err.Error()
+		return
+	}
+	if len(sources) > 0 {
+		var list strings.Builder
+		list.WriteString("\n\n## Sources\n\n")
+		for i, s := range sources {
+			title := cmp.Or(s.Title, s.URL)
+			fmt.Fprintf(&list, "%d. [%s](%s)\n", i+1, title, s.URL)
+		}
+		text = strings.TrimRight(text, "\n") + strings.TrimRight(list.String(), "\n")
+	}
+	batch.Summary = text
+	batch.Sources = sources
+}
+
+// batchQuestion is one item of a batch file. Fields left empty keep the
+// command-line settings, so one batch can mix cheap lookups with deep
+// research.
+type batchQuestion struct {
+	Query     string `json:"query" yaml:"query"`
+	Model     string `json:"model,omitempty" yaml:"model"`
+	Effort    string `json:"effort,omitempty" yaml:"effort"`
+	Verbosity string `json:"verbosity,omitempty" yaml:"verbosity"`
+	// Schema is a JSON Schema object, or the path of a schema file
+	// relative to the batch file.
+	Schema    any   `json:"schema,omitempty" yaml:"schema"`
+	WebSearch *bool `json:"web_search,omitempty" yaml:"web_search"`
+}
+
+// readBatchFile returns the questions in path. A .jsonl or .ndjson file
+// holds one JSON item per line and a .yaml or .yml file a list of items;
+// any other file holds one plain question per line. Blank lines and lines
+// starting with # are skipped.
+func readBatchFile(path string) ([]batchQuestion, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read batch file: %w", err)
+	}
+
+	var questions []batchQuestion
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(raw, &questions); err != nil {
+			return nil, fmt.Errorf("batch file %s: want a YAML list of items: %w", path, err)
+		}
+	default:
+		jsonl := strings.EqualFold(filepath.Ext(path), ".jsonl") || strings.EqualFold(filepath.Ext(path), ".ndjson")
+		sc := bufio.NewScanner(bytes.NewReader(raw))
+		sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+		for n := 1; sc.Scan(); n++ {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !jsonl {
+				questions = append(questions, batchQuestion{Query: line})
+				continue
+			}
+			var q batchQuestion
+			if err := json.Unmarshal([]byte(line), &q); err != nil {
+				return nil, fmt.Errorf("batch file %s line %d: %w", path, n, err)
+			}
+			questions = append(questions, q)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read batch file: %w", err)
+		}
+	}
+	for i, q := range questions {
+		if strings.TrimSpace(q.Query) == "" {
+			return nil, fmt.Errorf("batch file %s item %d: query is required", path, i+1)
+		}
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("batch file %s has no questions", path)
+	}
+	return questions, nil
+}
+
+// apply overrides the CLI-derived arguments with the item's settings.
+// Relative schema paths are resolved against dir.
+func (q batchQuestion) apply(wa webSearchArgs, dir string) (webSearchArgs, error) {
+	wa.query = strings.TrimSpace(q.Query)
+	if q.Model != "" {
+		wa.model = q.Model
+	}
+	if q.Effort != "" {
+		wa.effort = validateEffort(q.Effort)
+	}
+	if q.Verbosity != "" {
+		wa.verbosity = validateVerbosity(q.Verbosity)
+	}
+	if q.WebSearch != nil {
+		wa.useWebSearch = *q.WebSearch
+	}
+	switch s := q.Schema.(type) {
+	case nil:
+	case string:
+		if !filepath.IsAbs(s) {
+			s = filepath.Join(dir, s)
+		}
+		schema, err := loadOutputSchema(s)
+		if err != nil {
+			return wa, err
+		}
+		wa.outputSchema = schema
+	case map[string]any:
+		doc, err := json.Marshal(s)
+		if err != nil {
+			return wa, fmt.Errorf("schema: %w", err)
+		}
+		if wa.outputSchema, err = compileOutputSchema(doc); err != nil {
+			return wa, err
+		}
+	default:
+		return wa, fmt.Errorf("schema must be a JSON Schema object or a file path")
+	}
+	return wa, nil
+}
+
+// runCLIBatch answers every question of the -batch file and prints one
+// JSON result per line, in file order, as the answers come in.
+func runCLIBatch(envCfg EnvConfig, args cliArgs) {
+	questions, err := readBatchFile(args.batch)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	pr, err := lookupProvider(args.provider)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	base := args.webSearchArgs(pr.name)
+	base.promptCacheKey = args.promptCacheKey
+	base.noCache = args.noCache
+	if args.schemaPath != "" {
+		if base.outputSchema, err = loadOutputSchema(args.schemaPath); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	items := make([]webSearchArgs, len(questions))
+	for i, q := range questions {
+		if items[i], err = q.apply(base, filepath.Dir(args.batch)); err != nil {
+			fail(2, fmt.Sprintf("batch item %d: %v", i+1, err))
+		}
+	}
+
+	if args.confirm {
+		var est costEstimate
+		for _, wa := range items {
+			est = est.add(estimateCost(pr.modelFor(wa.model), wa))
+		}
+		if args.synthesize {
+			// The synthesis pass runs on the default provider without search
+			// and reads every answer.
+			est = est.add(costEstimate{
+				InputTokens:  len(items) * batchAnswerTokens,
+				OutputTokens: expectedOutputTokens[base.effort],
+			}.priced(base.model, false))
+		}
+		params := append([][2]string{{"questions", fmt.Sprint(len(items))}}, callParams(pr, items[0], args.timeout.String())...)
+		params = append(params, [2]string{"synthesize", fmt.Sprint(args.synthesize)})
+		if !confirmCall(os.Stderr, confirmInput(), params, est) {
+			fail(1, "cancelled")
+		}
+	}
+
+	Info("Running batch", "questions", len(items), "workers", args.batchWorkers)
+	started := time.Now()
+	// Ctrl-C fails the questions not yet answered instead of killing the
+	// run, so the lines already written are complete.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Progress goes to stderr so the JSON lines on stdout stay clean.
+	var progressOut io.Writer = os.Stderr
+	if args.quiet {
+		progressOut = io.Discard
+	}
+	progress := newBatchProgress(progressOut, items, args.batchWorkers)
+	var lines *batchLines
+	var onResult func(int, WebSearchResult)
+	if !args.showAll && !args.synthesize {
+		lines = newBatchLines(os.Stdout)
+		onResult = lines.add
+	}
+	batch := BatchCallAPI(ctx, envCfg.APIKey, args.baseURL, items, args.batchWorkers, progress, onResult)
+	if args.synthesize {
+		Info("Synthesizing batch report", "answered", batch.Succeeded)
+		synthesizeBatch(ctx, envCfg.APIKey, args.baseURL, batch, base)
+	}
+	activeRun.saveJSON("batch.json", batch)
+
+	switch {
+	case args.showAll:
+		raw, _ := json.MarshalIndent(batch, "", "  ") //nolint:errcheck // Debug output, error ok to ignore
+		fmt.Println(string(raw))
+	case args.synthesize:
+		if batch.SummaryError != "" {
+			fail(3, batch.SummaryError)
+		}
+		fmt.Println(batch.Summary)
+	case lines.err != nil:
+		fail(2, lines.err.Error())
+	}
+	var usage runUsage
+	for i := range batch.Results {
+		usage.addResult(&batch.Results[i])
+	}
+	args.printUsage(&usage, started)
+	if batch.Failed > 0 {
+		fail(3, fmt.Sprintf("%d of %d batch questions failed", batch.Failed, len(items)))
+	}
+}
+
+// newBatchWebsearchTool builds the batch_websearch tool definition.
+func newBatchWebsearchTool() mcp.Tool {
+	return mcp.NewTool("batch_websearch",
+		mcp.WithDescription(fmt.Sprintf("Answer up to %d independent questions in one call. "+
+			"Questions run concurrently and results come back in the same order", maxBatchItems)),
+		mcp.WithArray("queries",
+			mcp.Required(),
+			mcp.Description("The questions to answer"),
+			mcp.WithStringItems(mcp.MinLength(1)),
+			mcp.MinItems(1),
+			mcp.MaxItems(maxBatchItems),
+		),
+		mcp.WithString("model",
+			mcp.DefaultString(toolModel),
+			mcp.Description("The model used for every question (default: gpt-5.4-mini)"),
+		),
+		mcp.WithString("reasoning_effort",
+			mcp.DefaultString(toolEffort),
+			mcp.Description("Reasoning effort level: none, low, medium, high, or xhigh"),
+			mcp.Enum("none", "low", "medium", "high", "xhigh"),
+		),
+		mcp.WithString("verbosity",
+			mcp.DefaultString(toolVerbosity),
+			mcp.Description("Response verbosity: low, medium, or high"),
+			mcp.Enum("low", "medium", "high"),
+		),
+		mcp.WithBoolean("web_search",
+			mcp.DefaultBool(true),
+			mcp.Description("Use web search (default: true)"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("Optional: backend provider for every question"),
+			mcp.Enum(providerNames()...),
+		),
+		mcp.WithBoolean("synthesize",
+			mcp.DefaultBool(false),
+			mcp.Description("Also merge the answers into one report citing numbered sources, returned in summary (default: false)"),
+		),
+		mcp.WithSchemaAdditionalProperties(false),
+		mcp.WithOutputSchema[BatchResult](),
+	)
+}
+
+// batchHandler returns the handler for the batch_websearch tool.
+func batchHandler(apiKey, baseURL string) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		queries, err := request.RequireStringSlice("queries")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(queries) == 0 || len(queries) > maxBatchItems {
+			return mcp.NewToolResultError(fmt.Sprintf("queries must hold 1-%d questions", maxBatchItems)), nil
+		}
+		items := make([]webSearchArgs, len(queries))
+		for i, q := range queries {
+			items[i] = extractWebSearchArgs(map[string]interface{}{
+				"query":            q,
+				"model":            request.GetString("model", toolModel),
+				"reasoning_effort": request.GetString("reasoning_effort", toolEffort),
+				"verbosity":        request.GetString("verbosity", toolVerbosity),
+				"web_search":       request.GetBool("web_search", true),
+				"provider":         request.GetString("provider", ""),
+			})
+		}
+
+		logToClient(ctx, mcp.LoggingLevelInfo, "batch", fmt.Sprintf("Executing batch search: %d questions", len(items)))
+		batch := BatchCallAPI(ctx, apiKey, baseURL, items, defaultBatchWorkers, nil, nil)
+		logToClient(ctx, mcp.LoggingLevelInfo, "batch", fmt.Sprintf("Batch completed: %d succeeded, %d failed", batch.Succeeded, batch.Failed))
+		if request.GetBool("synthesize", false) {
+			synthesizeBatch(ctx, apiKey, baseURL, batch, items[0])
+		}
+		return mcp.NewToolResultStructuredOnly(batch), nil
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchCallAPI_OrderedAndBounded(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, peak atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if body.Input == "broken" {
+			writeJSON(t, w, http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "bad question"}})
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_" + body.Input,
+			"output": []map[string]any{
+				{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "answer to " + body.Input}}},
+			},
+		})
+	})
+
+	questions := []string{"q1", "q2", "broken", "q4", "q5", "q6"}
+	items := make([]webSearchArgs, len(questions))
+	for i, q := range questions {
+		items[i] = extractWebSearchArgs(map[string]interface{}{"query": q, "reasoning_effort": "low"})
+	}
+	var out strings.Builder
+	lines := newBatchLines(&out)
+	batch := BatchCallAPI(context.Background(), "k", base, items, 2, nil, lines.add)
+
+	if batch.Succeeded != 5 || batch.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 5/1", batch.Succeeded, batch.Failed)
+	}
+	for i, r := range batch.Results {
+		if r.Query != questions[i] {
+			t.Errorf("result %d is for %q, want %q", i, r.Query, questions[i])
+		}
+		if questions[i] == "broken" {
+			if r.Success || r.Error == "" {
+				t.Errorf("broken item = %+v, want an error", r)
+			}
+		} else if r.Answer != "answer to "+questions[i] {
+			t.Errorf("result %d answer = %q", i, r.Answer)
+		}
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", got)
+	}
+
+	// The JSON lines came out in file order although answers finished out
+	// of order.
+	written := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(written) != len(questions) || lines.err != nil {
+		t.Fatalf("wrote %d lines (%v), want %d", len(written), lines.err, len(questions))
+	}
+	for i, line := range written {
+		var r WebSearchResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil || r.Query != questions[i] {
+			t.Errorf("line %d = %s, want the result for %q", i+1, line, questions[i])
+		}
+	}
+}
+
+func TestReadBatchFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "questions.txt")
+	if err := os.WriteFile(path, []byte("# competitors\nWho makes X?\n\n  Who makes Y?  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readBatchFile(path)
+	if err != nil || !slices.Equal(got, []batchQuestion{{Query: "Who makes X?"}, {Query: "Who makes Y?"}}) {
+		t.Errorf("readBatchFile = %+v, %v", got, err)
+	}
+	if err := os.WriteFile(path, []byte("# nothing\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBatchFile(path); err == nil {
+		t.Error("expected error for a file without questions")
+	}
+}
+
+func TestReadBatchFile_Overrides(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("price.json", `{"type":"object","properties":{"price":{"type":"number"}},"required":["price"]}`)
+	base := webSearchArgs{model: modelMini, effort: "medium", verbosity: "medium", useWebSearch: true}
+
+	jsonl := write("mixed.jsonl", `{"query":"Capital of France?","model":"gpt-5.4-nano","effort":"none","web_search":false}
+# deep dive
+{"query":"Compare vendor roadmaps","model":"gpt-5.4","effort":"high","verbosity":"high"}
+{"query":"Price of X?","schema":"price.json"}
+`)
+	yml := write("mixed.yaml", `- query: Capital of France?
+  model: gpt-5.4-nano
+  effort: none
+  web_search: false
+- query: Compare vendor roadmaps
+  model: gpt-5.4
+  effort: high
+  verbosity: high
+- query: Price of X?
+  schema:
+    type: object
+    properties:
+      price: {type: number}
+    required: [price]
+`)
+	for _, path := range []string{jsonl, yml} {
+		questions, err := readBatchFile(path)
+		if err != nil || len(questions) != 3 {
+			t.Fatalf("%s: readBatchFile = %+v, %v", path, questions, err)
+		}
+		items := make([]webSearchArgs, len(questions))
+		for i, q := range questions {
+			if items[i], err = q.apply(base, dir); err != nil {
+				t.Fatalf("%s item %d: %v", path, i+1, err)
+			}
+		}
+		if cheap := items[0]; cheap.model != modelNano || cheap.effort != "none" || cheap.useWebSearch || cheap.verbosity != "medium" {
+			t.Errorf("%s: cheap item = %+v", path, cheap)
+		}
+		if deep := items[1]; deep.model != modelFull || deep.effort != "high" || deep.verbosity != "high" || !deep.useWebSearch {
+			t.Errorf("%s: deep item = %+v", path, deep)
+		}
+		if s := items[2].outputSchema; s == nil || s.validate(`{"price": 3}`) != nil || s.validate(`{}`) == nil {
+			t.Errorf("%s: schema item not applied", path)
+		}
+	}
+
+	if _, err := readBatchFile(write("bad.jsonl", `{"model":"gpt-5.4"}`)); err == nil {
+		t.Error("expected error for an item without a query")
+	}
+	q := batchQuestion{Query: "q", Schema: 42}
+	if _, err := q.apply(base, dir); err == nil {
+		t.Error("expected error for a non-object schema")
+	}
+}
+
+func TestSynthesizeBatch(t *testing.T) {
+	t.Parallel()
+
+	var prompt string
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		prompt = body.Input
+		if len(body.Tools) != 0 {
+			t.Error("synthesis pass should not use web search")
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp_report",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "Both vendors ship in 2027 [1][2]."}}}},
+		})
+	})
+
+	batch := &BatchResult{
+		Results: []WebSearchResult{
+			{Success: true, Query: "When does A ship?", Answer: "A ships in 2027.", Citations: []Citation{{URL: "https://a.example", Title: "A news"}}},
+			{Success: false, Query: "When does C ship?", Error: "timeout"},
+			{Success: true, Query: "When does B ship?", Answer: "B ships in 2027.", Citations: []Citation{
+				{URL: "https://b.example"}, {URL: "https://a.example", Title: "A news"},
+			}},
+		},
+		Succeeded: 2,
+		Failed:    1,
+	}
+	wa := extractWebSearchArgs(map[string]any{"query": "unused", "reasoning_effort": "low"})
+	synthesizeBatch(context.Background(), "k", base, batch, wa)
+
+	if batch.SummaryError != "" {
+		t.Fatalf("summary error: %s", batch.SummaryError)
+	}
+	for _, want := range []string{"Question 1: When does A ship?", "A ships in 2027.", "Sources: [2], [1]", "not answered: timeout", "[2]  https://b.example"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	if len(batch.Sources) != 2 || batch.Sources[0].URL != "https://a.example" || batch.Sources[1].URL != "https://b.example" {
+		t.Errorf("sources = %+v, want a then b, each once", batch.Sources)
+	}
+	want := "Both vendors ship in 2027 [1][2].\n\n## Sources\n\n1. [A news](https://a.example)\n2. [https://b.example](https://b.example)"
+	if batch.Summary != want {
+		t.Errorf("summary = %q, want %q", batch.Summary, want)
+	}
+
+	empty := &BatchResult{Results: []WebSearchResult{{Query: "q", Error: "down"}}, Failed: 1}
+	synthesizeBatch(context.Background(), "k", base, empty, wa)
+	if empty.Summary != "" || empty.SummaryError == "" {
+		t.Errorf("synthesis of an all-failed batch = %+v, want an error", empty)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheState reports how a cache lookup was satisfied.
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	cacheFresh
+	cacheStale
+)
+
+// responseCacheMaxEntries bounds the answers a responseCache holds in
+// memory; the least recently used is dropped first. Entries on disk are
+// kept and read back on demand.
+const responseCacheMaxEntries = 1000
+
+// cacheEntry is a stored answer plus the time it was produced.
+type cacheEntry struct {
+	result   WebSearchResult
+	storedAt time.Time
+}
+
+// diskCacheEntry is the on-disk form of a cacheEntry.
+type diskCacheEntry struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Result   WebSearchResult `json:"result"`
+}
+
+// responseCache is an in-memory TTL cache of successful web search results.
+//
+// Entries younger than ttl are fresh. When staleFor > 0, entries that expired
+// less than staleFor ago are still returned (as stale) so the caller can
+// answer immediately and refresh in the background — stale-while-revalidate.
+// A nil *responseCache is valid and behaves as a disabled cache.
+//
+// With a directory (see useDisk), entries are also written there as one
+// JSON file per key, so answers survive restarts and are shared with CLI
+// runs. At most maxEntries are held in memory; order lists their keys,
+// least recently used first.
+type responseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	staleFor   time.Duration
+	dir        string
+	sealer     *storeSealer
+	entries    map[string]cacheEntry
+	order      []string
+	maxEntries int
+	refreshing map[string]bool
+	now        func() time.Time
+}
+
+// answerCache is the process-wide cache used by HandleWebSearch. It stays nil
+// (disabled) unless CACHE_TTL is configured.
+var answerCache *responseCache
+
+// newResponseCache returns a cache with the given freshness and
+// stale-while-revalidate windows, or nil when ttl is not positive.
+func newResponseCache(ttl, staleFor time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if staleFor < 0 {
+		staleFor = 0
+	}
+	return &responseCache{
+		ttl:        ttl,
+		staleFor:   staleFor,
+		entries:    make(map[string]cacheEntry),
+		maxEntries: responseCacheMaxEntries,
+		refreshing: make(map[string]bool),
+		now:        time.Now,
+	}
+}
+
+// useDisk persists the cache in dir (env CACHE_DIR), encrypting the files
+// with sealer when it is set.
+func (c *responseCache) useDisk(dir string, sealer *storeSealer) error {
+	if c == nil || dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	c.dir, c.sealer = dir, sealer
+	return nil
+}
+
+// cacheKeyFor derives a cache key from the parameters that influence the
+// answer. Requests chained to a previous response or carrying earlier turns
+// are never cacheable since their answer depends on conversation state, so
+// an empty key is returned.
+func cacheKeyFor(wa webSearchArgs) string {
+	if wa.previousResponseID != "" || len(wa.messages) > 0 {
+		return ""
+	}
+	parts := []string{
+		strings.TrimSpace(wa.query),
+		wa.model,
+		wa.effort,
+		wa.verbosity,
+		wa.searchContextSize,
+		strconv.FormatBool(wa.useWebSearch),
+		wa.provider,
+		strconv.Itoa(wa.maxOutputTokens),
+		formatOptionalFloat(wa.temperature),
+		formatOptionalFloat(wa.topP),
+		string(wa.outputSchema.rawSchema()),
+		strconv.FormatBool(wa.related),
+	}
+	parts = append(parts, strings.Join(wa.domains, ","))
+	parts = append(parts, wa.images...)
+	for _, f := range wa.files {
+		parts = append(parts, f.Name, f.Text, f.Data)
+	}
+	for _, t := range wa.tools {
+		parts = append(parts, t.Type, strings.Join(t.VectorStoreIDs, ","))
+	}
+	if loc := wa.userLocation; loc != nil {
+		parts = append(parts, loc.Country, loc.Region, loc.City, loc.Timezone)
+	}
+	if wa.noSynthesis {
+		// Appended only when set so existing keys stay valid.
+		parts = append(parts, "sources-only")
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatOptionalFloat renders an optional parameter for cacheKeyFor; unset
+// and zero must not collide.
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*f, 'g', -1, 64)
+}
+
+// get looks up key and reports whether the entry is fresh, stale, or missing.
+func (c *responseCache) get(key string) (WebSearchResult, cacheState) {
+	if c == nil || key == "" {
+		return WebSearchResult{}, cacheMiss
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		if entry, ok = c.load(key); !ok {
+			return WebSearchResult{}, cacheMiss
+		}
+	}
+	c.keep(key, entry)
+	age := c.now().Sub(entry.storedAt)
+	entry.result.CacheAge = age.Round(time.Second).String()
+	switch {
+	case age < c.ttl:
+		return entry.result, cacheFresh
+	case age < c.ttl+c.staleFor:
+		return entry.result, cacheStale
+	default:
+		c.drop(key)
+		c.remove(key)
+		return WebSearchResult{}, cacheMiss
+	}
+}
+
+// keep holds entry in memory as the most recently used, dropping the least
+// recently used entry when the cache is full. c.mu must be held.
+func (c *responseCache) keep(key string, entry cacheEntry) {
+	if i := slices.Index(c.order, key); i >= 0 {
+		c.order = slices.Delete(c.order, i, i+1)
+	} else if len(c.order) >= c.maxEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.order = append(c.order, key)
+	c.entries[key] = entry
+}
+
+// drop forgets key in memory. c.mu must be held.
+func (c *responseCache) drop(key string) {
+	delete(c.entries, key)
+	if i := slices.Index(c.order, key); i >= 0 {
+		c.order = slices.Delete(c.order, i, i+1)
+	}
+}
+
+// set stores a successful result under key.
+func (c *responseCache) set(key string, result WebSearchResult) {
+	if c == nil || key == "" || !result.Success {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := cacheEntry{result: result, storedAt: c.now()}
+	c.keep(key, entry)
+	c.store(key, entry)
+}
+
+// cachePath returns the file of key in the cache directory.
+func (c *responseCache) cachePath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// load reads key from the cache directory; unreadable files count as
+// misses.
+func (c *responseCache) load(key string) (cacheEntry, bool) {
+	if c.dir == "" {
+		return cacheEntry{}, false
+	}
+	raw, err := os.ReadFile(c.cachePath(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var d diskCacheEntry
+	if raw, err = c.sealer.open(raw); err != nil {
+		Warn("Ignoring unreadable cache file", "key", key, "error", err)
+		return cacheEntry{}, false
+	}
+	if err := json.Unmarshal(raw, &d); err != nil {
+		Warn("Ignoring unreadable cache file", "key", key, "error", err)
+		return cacheEntry{}, false
+	}
+	return cacheEntry{result: d.Result, storedAt: d.StoredAt}, true
+}
+
+// store writes an entry to the cache directory; failures are only logged
+// since the memory copy still serves.
+func (c *responseCache) store(key string, entry cacheEntry) {
+	if c.dir == "" {
+		return
+	}
+	raw, err := json.Marshal(diskCacheEntry{StoredAt: entry.storedAt, Result: entry.result})
+	if err == nil {
+		raw, err = c.sealer.seal(raw)
+	}
+	if err == nil {
+		err = writeFileAtomic(c.cachePath(key), raw, 0o600)
+	}
+	if err != nil {
+		Warn("Failed to write cache file", "key", key, "error", err)
+	}
+}
+
+// remove deletes key from the cache directory.
+func (c *responseCache) remove(key string) {
+	if c.dir != "" {
+		os.Remove(c.cachePath(key)) //nolint:errcheck // already gone is fine
+	}
+}
+
+// beginRefresh marks key as being refreshed and reports whether the caller
+// won the right to do so; concurrent stale hits trigger a single refresh.
+func (c *responseCache) beginRefresh(key string) bool {
+	if c == nil || key == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing[key] {
+		return false
+	}
+	c.refreshing[key] = true
+	return true
+}
+
+// len reports the number of entries held in memory.
+func (c *responseCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// endRefresh clears the in-progress marker set by beginRefresh.
+func (c *responseCache) endRefresh(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCache_FreshStaleMiss(t *testing.T) {
+	t.Parallel()
+
+	c := newResponseCache(time.Minute, 30*time.Second)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.set("k", WebSearchResult{Success: true, Answer: "a"})
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  cacheState
+	}{
+		{name: "fresh", after: 10 * time.Second, want: cacheFresh},
+		{name: "stale", after: 70 * time.Second, want: cacheStale},
+		{name: "expired", after: 2 * time.Minute, want: cacheMiss},
+	}
+	for _, tt := range tests {
+		c.now = func() time.Time { return now.Add(tt.after) }
+		if _, got := c.get("k"); got != tt.want {
+			t.Errorf("%s: state = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	c := newResponseCache(time.Minute, 0)
+	c.maxEntries = 2
+	c.set("a", WebSearchResult{Success: true, Answer: "a"})
+	c.set("b", WebSearchResult{Success: true, Answer: "b"})
+	c.get("a")
+	c.set("c", WebSearchResult{Success: true, Answer: "c"})
+
+	if n := c.len(); n != 2 {
+		t.Errorf("len = %d, want 2", n)
+	}
+	for key, want := range map[string]cacheState{"a": cacheFresh, "b": cacheMiss, "c": cacheFresh} {
+		if _, got := c.get(key); got != want {
+			t.Errorf("get(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestResponseCache_NilAndFailures(t *testing.T) {
+	t.Parallel()
+
+	var nilCache *responseCache
+	nilCache.set("k", WebSearchResult{Success: true})
+	if _, state := nilCache.get("k"); state != cacheMiss {
+		t.Errorf("nil cache get = %v, want miss", state)
+	}
+
+	c := newResponseCache(time.Minute, 0)
+	c.set("k", WebSearchResult{Success: false, Error: "boom"})
+	if _, state := c.get("k"); state != cacheMiss {
+		t.Errorf("failed results must not be cached, got %v", state)
+	}
+
+	if newResponseCache(0, time.Minute) != nil {
+		t.Error("zero TTL should disable the cache")
+	}
+}
+
+func TestResponseCache_Disk(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	open := func() *responseCache {
+		c := newResponseCache(time.Minute, 0)
+		if err := c.useDisk(dir, nil); err != nil {
+			t.Fatalf("useDisk: %v", err)
+		}
+		c.now = func() time.Time { return now }
+		return c
+	}
+	open().set("k", WebSearchResult{Success: true, Answer: "a"})
+
+	// A new process finds the answer on disk.
+	c := open()
+	now = now.Add(30 * time.Second)
+	got, state := c.get("k")
+	if state != cacheFresh || got.Answer != "a" || got.CacheAge != "30s" {
+		t.Fatalf("get after restart = %+v, %v", got, state)
+	}
+
+	now = now.Add(time.Minute)
+	if _, state := open().get("k"); state != cacheMiss {
+		t.Errorf("expired entry state = %v, want miss", state)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "k.json")); !os.IsNotExist(err) {
+		t.Errorf("expired entry left on disk: %v", err)
+	}
+
+	c = open()
+	c.set("old", WebSearchResult{Success: true, Answer: "old"})
+	if n := open().purge(now.Add(time.Second), false); n != 1 {
+		t.Errorf("purge = %d, want 1", n)
+	}
+	if _, state := open().get("old"); state != cacheMiss {
+		t.Errorf("purged entry state = %v, want miss", state)
+	}
+}
+
+func TestCacheKeyFor(t *testing.T) {
+	t.Parallel()
+
+	base := webSearchArgs{query: "q", model: "m", effort: "low", verbosity: "low", useWebSearch: true}
+	if cacheKeyFor(base) == "" {
+		t.Fatal("expected non-empty key")
+	}
+	other := base
+	other.effort = "high"
+	if cacheKeyFor(base) == cacheKeyFor(other) {
+		t.Error("different effort must yield different keys")
+	}
+	chained := base
+	chained.previousResponseID = "resp_1"
+	if cacheKeyFor(chained) != "" {
+		t.Error("chained requests must not be cacheable")
+	}
+}
+
+func TestHandleWebSearch_StaleWhileRevalidate(t *testing.T) {
+	var hits atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		text := "first"
+		if n > 1 {
+			text = "second"
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "resp",
+			"model": "m",
+			"output": []map[string]any{
+				{"type": "message", "content": []map[string]any{{"type": "output_text", "text": text}}},
+			},
+		})
+	})
+
+	cache := newResponseCache(time.Minute, time.Hour)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	answerCache = cache
+	t.Cleanup(func() { answerCache = nil })
+
+	args := map[string]interface{}{"query": "q", "reasoning_effort": "low"}
+	ctx := context.Background()
+
+	first, err := HandleWebSearch(ctx, "k", base, args)
+	if err != nil || first.Answer != "first" || first.Cached {
+		t.Fatalf("first call = %+v, %v", first, err)
+	}
+
+	// Move past TTL but inside the stale window.
+	now = now.Add(2 * time.Minute)
+	stale, err := HandleWebSearch(ctx, "k", base, args)
+	if err != nil {
+		t.Fatalf("stale call: %v", err)
+	}
+	if !stale.Cached || !stale.Stale || stale.Answer != "first" {
+		t.Fatalf("expected stale cached answer, got %+v", stale)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if res, state := cache.get(cacheKeyFor(extractWebSearchArgs(args))); state == cacheFresh && res.Answer == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not update the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("upstream hits = %d, want 2", got)
+	}
+}
+
+func TestHandleWebSearch_NoCache(t *testing.T) {
+	var hits atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		text := "first"
+		if hits.Add(1) > 1 {
+			text = "second"
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "resp",
+			"model": "m",
+			"output": []map[string]any{
+				{"type": "message", "content": []map[string]any{{"type": "output_text", "text": text}}},
+			},
+		})
+	})
+	answerCache = newResponseCache(time.Hour, 0)
+	t.Cleanup(func() { answerCache = nil })
+
+	ctx := context.Background()
+	args := map[string]interface{}{"query": "q", "reasoning_effort": "low"}
+	if _, err := HandleWebSearch(ctx, "k", base, args); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	cached, err := HandleWebSearch(ctx, "k", base, args)
+	if err != nil || !cached.Cached || cached.CacheAge == "" {
+		t.Fatalf("second call = %+v, %v; want a cache hit with its age", cached, err)
+	}
+
+	args["no_cache"] = true
+	fresh, err := HandleWebSearch(ctx, "k", base, args)
+	if err != nil || fresh.Cached || fresh.Answer != "second" {
+		t.Fatalf("no_cache call = %+v, %v", fresh, err)
+	}
+	delete(args, "no_cache")
+	if again, _ := HandleWebSearch(ctx, "k", base, args); again.Answer != "second" { //nolint:errcheck
+		t.Errorf("cache not refreshed by no_cache call: %+v", again)
+	}
+}
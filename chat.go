@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chat completions structures shared by the OpenAI-compatible providers.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images and Files turn Content into content parts (see MarshalJSON).
+	Images []string     `json:"-"`
+	Files  []attachment `json:"-"`
+}
+
+type chatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *chatImageURL `json:"image_url,omitempty"`
+	File     *chatFile     `json:"file,omitempty"`
+}
+
+// chatFile is an inline document part (OpenRouter's PDF input format).
+type chatFile struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+type chatImageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON sends Content as a string, or as content parts when Images or
+// Files are attached.
+func (m chatMessage) MarshalJSON() ([]byte, error) {
+	type plain chatMessage
+	if len(m.Images) == 0 && len(m.Files) == 0 {
+		return json.Marshal(plain(m))
+	}
+	parts := []chatContentPart{{Type: "text", Text: m.Content}}
+	for _, f := range m.Files {
+		if f.Data != "" {
+			parts = append(parts, chatContentPart{Type: "file", File: &chatFile{Filename: f.Name, FileData: f.Data}})
+		} else {
+			parts = append(parts, chatContentPart{Type: "text", Text: f.inlineText()})
+		}
+	}
+	for _, img := range m.Images {
+		parts = append(parts, chatContentPart{Type: "image_url", ImageURL: &chatImageURL{URL: img}})
+	}
+	return json.Marshal(struct {
+		plain
+		Content []chatContentPart `json:"content"`
+	}{plain(m), parts})
+}
+
+type chatPlugin struct {
+	ID string `json:"id"`
+}
+
+type chatWebSearchOptions struct {
+	SearchContextSize string              `json:"search_context_size,omitempty"`
+	UserLocation      *chatSearchLocation `json:"user_location,omitempty"`
+}
+
+// chatSearchLocation biases search results by country (Perplexity).
+type chatSearchLocation struct {
+	Country string `json:"country"`
+}
+
+type chatRequest struct {
+	Model            string                `json:"model"`
+	Messages         []chatMessage         `json:"messages"`
+	Reasoning        *reqReasoning         `json:"reasoning,omitempty"`
+	Plugins          []chatPlugin          `json:"plugins,omitempty"`
+	WebSearchOptions *chatWebSearchOptions `json:"web_search_options,omitempty"`
+	SearchParameters *xaiSearchParameters  `json:"search_parameters,omitempty"`
+	// SearchDomainFilter and DisableSearch are Perplexity search controls.
+	SearchDomainFilter []string            `json:"search_domain_filter,omitempty"`
+	DisableSearch      bool                `json:"disable_search,omitempty"`
+	MaxTokens          int                 `json:"max_tokens,omitempty"`
+	Temperature        *float64            `json:"temperature,omitempty"`
+	TopP               *float64            `json:"top_p,omitempty"`
+	ResponseFormat     *chatResponseFormat `json:"response_format,omitempty"`
+}
+
+type chatResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema"`
+}
+
+// xaiSearchParameters configures xAI Live Search.
+type xaiSearchParameters struct {
+	Mode            string      `json:"mode"`
+	ReturnCitations bool        `json:"return_citations"`
+	Sources         []xaiSource `json:"sources,omitempty"`
+}
+
+// xaiSource is one Live Search source. Web sources take a site filter,
+// allowed or excluded but not both, and a country code.
+type xaiSource struct {
+	Type             string   `json:"type"`
+	AllowedWebsites  []string `json:"allowed_websites,omitempty"`
+	ExcludedWebsites []string `json:"excluded_websites,omitempty"`
+	Country          string   `json:"country,omitempty"`
+}
+
+type chatAnnotation struct {
+	Type        string `json:"type"`
+	URLCitation struct {
+		URL        string `json:"url"`
+		Title      string `json:"title,omitempty"`
+		StartIndex int    `json:"start_index"`
+		EndIndex   int    `json:"end_index"`
+	} `json:"url_citation"`
+}
+
+type chatChoice struct {
+	Message struct {
+		Content     string           `json:"content"`
+		Annotations []chatAnnotation `json:"annotations,omitempty"`
+	} `json:"message"`
+	FinishReason string `json:"finish_reason"`
+	// Error is set when generation failed after the response started
+	// (OpenRouter reports upstream failures this way with HTTP 200).
+	Error *chatError `json:"error,omitempty"`
+}
+
+// chatError is the {"error": {...}} envelope of OpenAI-compatible APIs.
+// OpenRouter puts the HTTP status in code and names the upstream provider
+// in metadata; OpenAI-style APIs use a string code.
+type chatError struct {
+	Code     json.RawMessage `json:"code"`
+	Message  string          `json:"message"`
+	Metadata struct {
+		ProviderName string `json:"provider_name"`
+	} `json:"metadata"`
+}
+
+// status returns the HTTP status carried in the code, or fallback when the
+// code is not one.
+func (e *chatError) status(fallback int) int {
+	if n, err := strconv.Atoi(string(e.Code)); err == nil && n >= 400 && n < 600 {
+		return n
+	}
+	return fallback
+}
+
+// message describes the error, naming the upstream provider when known.
+func (e *chatError) message() string {
+	if e.Metadata.ProviderName != "" {
+		return e.Metadata.ProviderName + ": " + e.Message
+	}
+	return e.Message
+}
+
+// parseChatError decodes an error envelope; nil when body is not one.
+func parseChatError(body []byte) *chatError {
+	var env struct {
+		Error *chatError `json:"error"`
+	}
+	if json.Unmarshal(body, &env) != nil || env.Error == nil || env.Error.Message == "" {
+		return nil
+	}
+	return env.Error
+}
+
+type chatUsage struct {
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	TotalTokens             int `json:"total_tokens"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+	// Citations lists source URLs without offsets (xAI Live Search,
+	// Perplexity). The text may refer to them with [n] markers.
+	Citations []string `json:"citations,omitempty"`
+	// SearchResults carries titles for the citations (Perplexity).
+	SearchResults []chatSearchResult `json:"search_results,omitempty"`
+}
+
+type chatSearchResult struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// citationMarkerRe matches the [n] markers that refer to top-level citations.
+var citationMarkerRe = regexp.MustCompile(`\[(\d+)\]`)
+
+// newChatRequest builds the provider-neutral part of a chat completions call.
+func newChatRequest(p CallAPIParams) chatRequest {
+	req := chatRequest{
+		Model:       p.Model,
+		Messages:    chatHistory(p.Messages),
+		MaxTokens:   p.MaxOutputTokens,
+		Temperature: p.Temperature,
+		TopP:        p.TopP,
+	}
+	req.Messages = append(req.Messages, chatMessage{Role: "user", Content: p.Query, Images: p.Images, Files: p.Files})
+	if p.Instructions != "" {
+		req.Messages = append([]chatMessage{{Role: "system", Content: p.Instructions}}, req.Messages...)
+	}
+	if p.OutputSchema != nil {
+		req.ResponseFormat = &chatResponseFormat{Type: "json_schema"}
+		req.ResponseFormat.JSONSchema.Name = outputSchemaName
+		req.ResponseFormat.JSONSchema.Schema = p.OutputSchema.raw
+	}
+	return req
+}
+
+// chatHistory converts earlier turns into chat messages.
+func chatHistory(messages []Message) []chatMessage {
+	history := make([]chatMessage, 0, len(messages)+1)
+	for _, m := range messages {
+		history = append(history, chatMessage{Role: m.Role, Content: m.Content})
+	}
+	return history
+}
+
+// doChatRequest posts a chat completions request and normalizes the reply.
+func doChatRequest(ctx context.Context, p CallAPIParams, body any, headers map[string]string) (*apiResponse, error) {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	req, err := newAPIRequest(ctx, p, buf)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if ce := parseChatError([]byte(apiErr.Body)); ce != nil {
+				apiErr.Message = ce.message()
+			}
+		}
+		return nil, err
+	}
+
+	var cr chatResponse
+	if err := json.Unmarshal(bodyBytes, &cr); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	// Errors can also arrive with HTTP 200, either instead of choices or on
+	// the choice itself. They keep their status so retries still apply.
+	ce := parseChatError(bodyBytes)
+	if ce == nil && len(cr.Choices) > 0 {
+		ce = cr.Choices[0].Error
+	}
+	if ce != nil && (len(cr.Choices) == 0 || cr.Choices[0].Message.Content == "") {
+		return nil, &APIError{StatusCode: ce.status(http.StatusBadGateway), Body: string(bodyBytes), Message: ce.message()}
+	}
+	return cr.toAPIResponse(), nil
+}
+
+// toAPIResponse maps the first choice onto a single Responses-style message
+// item, carrying url_citation annotations across unchanged. Top-level
+// citation URLs have no offsets: they are attached to the [n] markers that
+// cite them, and any left unreferenced become zero-width annotations at the
+// end of the text. A leading <think> block becomes a reasoning item.
+func (cr *chatResponse) toAPIResponse() *apiResponse {
+	ar := &apiResponse{ID: cr.ID, Model: cr.Model, Status: "completed"}
+	if len(cr.Choices) > 0 {
+		choice := cr.Choices[0]
+		text := choice.Message.Content
+		if rest, ok := strings.CutPrefix(strings.TrimLeft(text, " \n"), "<think>"); ok {
+			if thought, answer, ok := strings.Cut(rest, "</think>"); ok {
+				ar.Output = append(ar.Output, respItem{Type: "reasoning", Summary: []respSummary{{Type: "summary_text", Text: strings.TrimSpace(thought)}}})
+				text = strings.TrimLeft(answer, " \n")
+			}
+		}
+		content := respContent{Type: "output_text", Text: text}
+		for _, a := range choice.Message.Annotations {
+			content.Annotations = append(content.Annotations, respAnnotation{
+				Type:       "url_citation",
+				URL:        a.URLCitation.URL,
+				Title:      a.URLCitation.Title,
+				StartIndex: a.URLCitation.StartIndex,
+				EndIndex:   a.URLCitation.EndIndex,
+			})
+		}
+		content.Annotations = append(content.Annotations, cr.markerAnnotations(text)...)
+		ar.Output = append(ar.Output, respItem{Type: "message", Content: []respContent{content}})
+		if choice.FinishReason == "length" {
+			ar.Status = "incomplete"
+			ar.IncompleteDetails = &incompleteDetails{Reason: "max_output_tokens"}
+		}
+	}
+	if cr.Usage != nil {
+		ar.Usage = &apiUsage{
+			InputTokens:  cr.Usage.PromptTokens,
+			OutputTokens: cr.Usage.CompletionTokens,
+			TotalTokens:  cr.Usage.TotalTokens,
+			OutputTokensDetails: outputTokensDetails{
+				ReasoningTokens: cr.Usage.CompletionTokensDetails.ReasoningTokens,
+			},
+		}
+	}
+	return ar
+}
+
+// markerAnnotations turns the top-level citations into url_citation
+// annotations spanning the [n] markers in text that refer to them.
+func (cr *chatResponse) markerAnnotations(text string) []respAnnotation {
+	if len(cr.Citations) == 0 {
+		return nil
+	}
+	titles := make(map[string]string, len(cr.SearchResults))
+	for _, r := range cr.SearchResults {
+		titles[r.URL] = r.Title
+	}
+	var out []respAnnotation
+	cited := make([]bool, len(cr.Citations))
+	for _, m := range citationMarkerRe.FindAllStringSubmatchIndex(text, -1) {
+		n, err := strconv.Atoi(text[m[2]:m[3]])
+		if err != nil || n < 1 || n > len(cr.Citations) {
+			continue
+		}
+		u := cr.Citations[n-1]
+		cited[n-1] = true
+		start := utf8.RuneCountInString(text[:m[0]])
+		out = append(out, respAnnotation{
+			Type: "url_citation", URL: u, Title: titles[u],
+			StartIndex: start, EndIndex: start + utf8.RuneCountInString(text[m[0]:m[1]]),
+		})
+	}
+	end := utf8.RuneCountInString(text)
+	for i, u := range cr.Citations {
+		if !cited[i] {
+			out = append(out, respAnnotation{Type: "url_citation", URL: u, Title: titles[u], StartIndex: end, EndIndex: end})
+		}
+	}
+	return out
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// textSpan locates a range of the joined answer in the three units clients
+// commonly index by: UTF-8 bytes (Go, Rust), code points (Python) and UTF-16
+// code units (JavaScript, Java).
+type textSpan struct {
+	StartByte  int `json:"start_byte"`
+	EndByte    int `json:"end_byte"`
+	StartRune  int `json:"start_char"`
+	EndRune    int `json:"end_char"`
+	StartUTF16 int `json:"start_utf16"`
+	EndUTF16   int `json:"end_utf16"`
+}
+
+// mappedAnnotation is a url_citation annotation whose offsets were translated
+// from its segment into the answer returned by ExtractAnswer.
+type mappedAnnotation struct {
+	Annotation respAnnotation
+	Span       textSpan
+}
+
+// Citation is a source the answer cites: a url_citation annotation with its
+// location in the answer text.
+type Citation struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	// Span is nil when the answer was rewritten by post-processing and the
+	// original offsets no longer apply.
+	Span *textSpan `json:"span,omitempty"`
+	// Reputation is the cited domain's tier (trusted, neutral or low); see
+	// domains.go. Citations of blocked domains are dropped.
+	Reputation string `json:"reputation,omitempty"`
+}
+
+// ExtractCitations returns the url_citation annotations of the response in
+// answer order, with offsets into the text returned by ExtractAnswer.
+func ExtractCitations(apiResp *apiResponse) []Citation {
+	var out []Citation
+	for _, m := range mapAnnotations(apiResp) {
+		span := m.Span
+		out = append(out, Citation{URL: m.Annotation.URL, Title: m.Annotation.Title, Span: &span})
+	}
+	return out
+}
+
+// citationURLs returns the cited URLs in answer order.
+func citationURLs(citations []Citation) []string {
+	urls := make([]string, 0, len(citations))
+	for _, c := range citations {
+		urls = append(urls, c.URL)
+	}
+	return urls
+}
+
+// withoutSpans drops offsets from citations, for answers whose text no
+// longer matches the one the offsets were computed against.
+func withoutSpans(citations []Citation) []Citation {
+	out := make([]Citation, len(citations))
+	for i, c := range citations {
+		c.Span = nil
+		out[i] = c
+	}
+	return out
+}
+
+// withoutCitations drops the citations drop selects and removes their
+// markers, with the space before them, from answer. Spans of the kept
+// citations are shifted to the new text; one that overlapped a removed
+// marker loses its span. A dropped citation of a passage leaves the
+// passage alone.
+func withoutCitations(answer string, citations []Citation, drop func(Citation) bool) (string, []Citation) {
+	var cuts []citationEdit
+	var kept []Citation
+	for _, c := range citations {
+		if !drop(c) {
+			kept = append(kept, c)
+			continue
+		}
+		if start, end, ok := markerRange(answer, c); ok {
+			if start > 0 && answer[start-1] == ' ' {
+				start--
+			}
+			cuts = append(cuts, citationEdit{start: start, end: end})
+		}
+	}
+	if len(cuts) == 0 {
+		return answer, kept
+	}
+	slices.SortFunc(cuts, func(a, b citationEdit) int { return cmp.Compare(a.start, b.start) })
+	merged := cuts[:1]
+	for _, c := range cuts[1:] {
+		if last := &merged[len(merged)-1]; c.start <= last.end {
+			last.end = max(last.end, c.end)
+		} else {
+			merged = append(merged, c)
+		}
+	}
+
+	var sb strings.Builder
+	pos := 0
+	for _, c := range merged {
+		sb.WriteString(answer[pos:c.start])
+		pos = c.end
+	}
+	sb.WriteString(answer[pos:])
+	for i, c := range kept {
+		if c.Span != nil {
+			kept[i].Span = shiftSpan(*c.Span, answer, merged)
+		}
+	}
+	return sb.String(), kept
+}
+
+// shiftSpan moves s past the sorted cuts removed from answer, or returns
+// nil when a cut overlaps it.
+func shiftSpan(s textSpan, answer string, cuts []citationEdit) *textSpan {
+	for _, c := range cuts {
+		switch {
+		case c.end <= s.StartByte:
+			removed := answer[c.start:c.end]
+			n, runes, units := len(removed), utf8.RuneCountInString(removed), utf16Len(removed)
+			s.StartByte, s.EndByte = s.StartByte-n, s.EndByte-n
+			s.StartRune, s.EndRune = s.StartRune-runes, s.EndRune-runes
+			s.StartUTF16, s.EndUTF16 = s.StartUTF16-units, s.EndUTF16-units
+		case c.start < s.EndByte:
+			return nil
+		}
+	}
+	return &s
+}
+
+// mapAnnotations translates url_citation offsets from per-segment code point
+// indices into spans over the joined answer text. Offsets are clamped to the
+// segment and never split a multi-byte sequence, so slicing the answer with
+// Span.StartByte:Span.EndByte is always valid UTF-8.
+func mapAnnotations(apiResp *apiResponse) []mappedAnnotation {
+	var out []mappedAnnotation
+	var byteBase, runeBase, utf16Base int
+
+	for i, seg := range answerSegments(apiResp) {
+		if i > 0 {
+			byteBase += len(answerSegmentSeparator)
+			runeBase += utf8.RuneCountInString(answerSegmentSeparator)
+			utf16Base += utf16Len(answerSegmentSeparator)
+		}
+
+		for _, a := range seg.Annotations {
+			if a.Type != "url_citation" {
+				continue
+			}
+			start, end := clampRange(a.StartIndex, a.EndIndex, utf8.RuneCountInString(seg.Text))
+			startByte := runeToByteOffset(seg.Text, start)
+			endByte := runeToByteOffset(seg.Text, end)
+			out = append(out, mappedAnnotation{
+				Annotation: a,
+				Span: textSpan{
+					StartByte:  byteBase + startByte,
+					EndByte:    byteBase + endByte,
+					StartRune:  runeBase + start,
+					EndRune:    runeBase + end,
+					StartUTF16: utf16Base + utf16Len(seg.Text[:startByte]),
+					EndUTF16:   utf16Base + utf16Len(seg.Text[:endByte]),
+				},
+			})
+		}
+
+		byteBase += len(seg.Text)
+		runeBase += utf8.RuneCountInString(seg.Text)
+		utf16Base += utf16Len(seg.Text)
+	}
+	return out
+}
+
+// clampRange bounds [start, end) to [0, n] and keeps start <= end.
+func clampRange(start, end, n int) (int, int) {
+	start = max(0, min(start, n))
+	end = max(start, min(end, n))
+	return start, end
+}
+
+// runeToByteOffset returns the byte offset of the idx-th code point in s, or
+// len(s) when idx is past the end.
+func runeToByteOffset(s string, idx int) int {
+	if idx <= 0 {
+		return 0
+	}
+	n := 0
+	for i := range s {
+		if n == idx {
+			return i
+		}
+		n++
+	}
+	return len(s)
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
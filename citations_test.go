@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func TestMapAnnotations_AcrossSegmentsAndMultibyteText(t *testing.T) {
+	t.Parallel()
+
+	// Code point indices: "東京 🗼 is tall." -> "東京" is [0,2), "tall" is [8,12).
+	first := "東京 🗼 is tall."
+	second := "Source: naïve café 😀 guide."
+	apiResp := &apiResponse{
+		Output: []respItem{
+			{Type: "message", Content: []respContent{{
+				Type: "output_text",
+				Text: first,
+				Annotations: []respAnnotation{
+					{Type: "url_citation", URL: "https://a.example", StartIndex: 0, EndIndex: 2},
+					{Type: "url_citation", URL: "https://b.example", StartIndex: 8, EndIndex: 12},
+				},
+			}}},
+			{Type: "message", Content: []respContent{{
+				Type: "output_text",
+				Text: second,
+				Annotations: []respAnnotation{
+					{Type: "file_citation", StartIndex: 0, EndIndex: 1},
+					{Type: "url_citation", URL: "https://c.example", StartIndex: 21, EndIndex: 26},
+					{Type: "url_citation", URL: "https://d.example", StartIndex: 20, EndIndex: 999},
+				},
+			}}},
+		},
+	}
+
+	answer := ExtractAnswer(apiResp).Text
+	mapped := mapAnnotations(apiResp)
+	if len(mapped) != 4 {
+		t.Fatalf("got %d mapped annotations, want 4", len(mapped))
+	}
+
+	wantText := []string{"東京", "tall", "guide", " guide."}
+	units := utf16.Encode([]rune(answer))
+	runes := []rune(answer)
+	for i, m := range mapped {
+		got := answer[m.Span.StartByte:m.Span.EndByte]
+		if got != wantText[i] {
+			t.Errorf("annotation %d (%s): byte span text = %q, want %q", i, m.Annotation.URL, got, wantText[i])
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("annotation %d: span splits a multi-byte sequence", i)
+		}
+		if r := string(runes[m.Span.StartRune:m.Span.EndRune]); r != wantText[i] {
+			t.Errorf("annotation %d: rune span text = %q, want %q", i, r, wantText[i])
+		}
+		if u := string(utf16.Decode(units[m.Span.StartUTF16:m.Span.EndUTF16])); u != wantText[i] {
+			t.Errorf("annotation %d: utf16 span text = %q, want %q", i, u, wantText[i])
+		}
+	}
+}
+
+func TestRuneToByteOffset(t *testing.T) {
+	t.Parallel()
+
+	s := "a😀b"
+	tests := []struct{ idx, want int }{{-1, 0}, {0, 0}, {1, 1}, {2, 5}, {3, 6}, {10, 6}}
+	for _, tt := range tests {
+		if got := runeToByteOffset(s, tt.idx); got != tt.want {
+			t.Errorf("runeToByteOffset(%q, %d) = %d, want %d", s, tt.idx, got, tt.want)
+		}
+	}
+}
+
+func TestExtractCitations(t *testing.T) {
+	t.Parallel()
+
+	apiResp := &apiResponse{Output: []respItem{{Type: "message", Content: []respContent{{
+		Type: "output_text",
+		Text: "Go 1.25 shipped.",
+		Annotations: []respAnnotation{
+			{Type: "url_citation", URL: "https://go.dev/blog", Title: "Go Blog", StartIndex: 0, EndIndex: 7},
+		},
+	}}}}}
+
+	got := ExtractCitations(apiResp)
+	if len(got) != 1 {
+		t.Fatalf("got %d citations, want 1", len(got))
+	}
+	c := got[0]
+	if c.URL != "https://go.dev/blog" || c.Title != "Go Blog" || c.Span == nil || c.Span.StartRune != 0 || c.Span.EndRune != 7 {
+		t.Errorf("citation = %+v (span %+v)", c, c.Span)
+	}
+	if ExtractCitations(nil) != nil {
+		t.Error("ExtractCitations(nil) should be nil")
+	}
+	if stripped := withoutSpans(got); stripped[0].Span != nil || got[0].Span == nil {
+		t.Error("withoutSpans should clear spans on a copy")
+	}
+}
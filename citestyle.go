@@ -0,0 +1,215 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Citation styles applied to answers by renderCitations. The empty style
+// leaves the answer as the model wrote it.
+const (
+	citeInline    = "inline"
+	citeFootnotes = "footnotes"
+	citeAPA       = "apa"
+	citeNone      = "none"
+)
+
+// defaultCitationStyle is the style used when a call names none; set at
+// startup from CITATION_STYLE.
+var defaultCitationStyle string
+
+// parseCitationStyle validates a citation style; "" keeps answers as
+// written.
+func parseCitationStyle(style string) (string, error) {
+	switch style = strings.ToLower(strings.TrimSpace(style)); style {
+	case "", citeInline, citeFootnotes, citeAPA, citeNone:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unknown citation style %q (want %s, %s, %s or %s)", style, citeInline, citeFootnotes, citeAPA, citeNone)
+	}
+}
+
+// withCitationStyle declares the citation_style argument of the tools that
+// return answers.
+func withCitationStyle() mcp.ToolOption {
+	return mcp.WithString("citation_style",
+		mcp.Description("Optional: how citations appear in the answer: inline (Markdown links), footnotes "+
+			"(numbered markers and a Sources list), apa (author-date markers and a References list) or none "+
+			"(the model's citation links removed; the citations field still lists them). "+
+			"Defaults to the server's CITATION_STYLE, else the answer as the model wrote it."),
+		mcp.Enum(citeInline, citeFootnotes, citeAPA, citeNone),
+	)
+}
+
+// citationStyleArg returns the call's citation_style, or the default.
+func citationStyleArg(request mcp.CallToolRequest) (string, error) {
+	return parseCitationStyle(request.GetString("citation_style", defaultCitationStyle))
+}
+
+// citationEdit replaces answer[start:end] with text; start == end inserts.
+type citationEdit struct {
+	start, end int
+	text       string
+}
+
+// renderCitations rewrites the answer's citations in style:
+//
+//   - inline: each citation as a Markdown link, "([example.com](url))"
+//   - footnotes: numbered markers, "[1]", and a Sources list
+//   - apa: author-date markers, "(example.com, n.d.)", and a References list
+//   - none: no markers and no list
+//
+// A citation whose span is the model's own link or "[n]" marker is replaced
+// by the new marker, or removed with none; any other span (a cited passage)
+// gets the marker after it. Citations without a span, because the answer
+// was rewritten after the offsets were taken, are only listed.
+func renderCitations(answer string, citations []Citation, style string) string {
+	if style == "" || len(citations) == 0 {
+		return answer
+	}
+
+	// Sources are numbered by first appearance.
+	var sources []Citation
+	number := make(map[string]int)
+	for _, c := range citations {
+		if _, ok := number[c.URL]; !ok {
+			sources = append(sources, c)
+			number[c.URL] = len(sources)
+		}
+	}
+
+	var edits []citationEdit
+	for _, c := range citations {
+		s := c.Span
+		if s == nil || s.StartByte < 0 || s.StartByte > s.EndByte || s.EndByte > len(answer) {
+			continue
+		}
+		var marker string
+		switch style {
+		case citeInline:
+			marker = fmt.Sprintf("([%s](%s))", citationHost(c.URL), c.URL)
+		case citeFootnotes:
+			marker = fmt.Sprintf("[%d]", number[c.URL])
+		case citeAPA:
+			marker = fmt.Sprintf("(%s, n.d.)", citationHost(c.URL))
+		}
+		if start, end, ok := markerRange(answer, c); ok {
+			if style == citeNone && start > 0 && answer[start-1] == ' ' {
+				start--
+			}
+			edits = append(edits, citationEdit{start, end, marker})
+			continue
+		}
+		if style == citeNone {
+			continue
+		}
+		if style != citeFootnotes {
+			marker = " " + marker
+		}
+		edits = append(edits, citationEdit{s.EndByte, s.EndByte, marker})
+	}
+	slices.SortStableFunc(edits, func(a, b citationEdit) int {
+		return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(a.end, b.end))
+	})
+
+	var sb strings.Builder
+	pos := 0
+	for i, e := range edits {
+		if e.start < pos || (i > 0 && e == edits[i-1]) {
+			continue // overlaps an applied edit, or repeats it
+		}
+		sb.WriteString(answer[pos:e.start])
+		sb.WriteString(e.text)
+		pos = e.end
+	}
+	sb.WriteString(answer[pos:])
+
+	switch style {
+	case citeFootnotes:
+		sb.WriteString("\n\n" + footnoteSources(sources))
+	case citeAPA:
+		refs := make([]string, 0, len(sources))
+		for _, c := range sources {
+			ref := citationHost(c.URL) + ". (n.d.)."
+			if c.Title != "" {
+				ref += " *" + c.Title + "*."
+			}
+			refs = append(refs, "- "+ref+" "+c.URL)
+		}
+		slices.Sort(refs)
+		sb.WriteString("\n\nReferences:\n\n")
+		sb.WriteString(strings.Join(refs, "\n"))
+	}
+	return sb.String()
+}
+
+// footnoteSources is the numbered Sources list of the footnotes style, each
+// distinct URL numbered by first appearance.
+func footnoteSources(citations []Citation) string {
+	var sb strings.Builder
+	sb.WriteString("Sources:\n")
+	seen := make(map[string]bool)
+	for _, c := range citations {
+		if seen[c.URL] {
+			continue
+		}
+		seen[c.URL] = true
+		if c.Title != "" {
+			fmt.Fprintf(&sb, "\n%d. %s: %s", len(seen), c.Title, c.URL)
+		} else {
+			fmt.Fprintf(&sb, "\n%d. %s", len(seen), c.URL)
+		}
+	}
+	return sb.String()
+}
+
+// markerRange returns the bytes of answer holding c's marker, when its span
+// is one the model wrote itself (see isCitationMarker), taking in the
+// parentheses around a bare link.
+func markerRange(answer string, c Citation) (start, end int, ok bool) {
+	s := c.Span
+	if s == nil || s.StartByte < 0 || s.StartByte > s.EndByte || s.EndByte > len(answer) {
+		return 0, 0, false
+	}
+	start, end = s.StartByte, s.EndByte
+	if !isCitationMarker(answer[start:end], c.URL) {
+		return 0, 0, false
+	}
+	if start > 0 && end < len(answer) && answer[start-1] == '(' && answer[end] == ')' {
+		start, end = start-1, end+1
+	}
+	return start, end, true
+}
+
+// isCitationMarker reports whether text is a citation the model wrote
+// itself: a Markdown link to url, optionally in parentheses, or a bracketed
+// number such as "[3]".
+func isCitationMarker(text, url string) bool {
+	text = strings.TrimSpace(text)
+	if inner, ok := strings.CutPrefix(text, "("); ok {
+		text = strings.TrimSuffix(inner, ")")
+	}
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]("+url+")") {
+		return true
+	}
+	n, ok := strings.CutPrefix(text, "[")
+	n, ok2 := strings.CutSuffix(n, "]")
+	return ok && ok2 && n != "" && strings.Trim(n, "0123456789") == ""
+}
+
+// styleCitations returns result with its answer rendered in style. result
+// itself is not modified, since it may be shared with the cache; the copy's
+// citations lose their spans, which no longer match the answer.
+func styleCitations(result *WebSearchResult, style string) *WebSearchResult {
+	if result == nil || style == "" || len(result.Citations) == 0 {
+		return result
+	}
+	styled := *result
+	styled.Answer = renderCitations(result.Answer, result.Citations, style)
+	styled.Citations = withoutSpans(result.Citations)
+	return &styled
+}
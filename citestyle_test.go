@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// spanOf returns the byte span of the first occurrence of sub in s.
+func spanOf(t *testing.T, s, sub string) *textSpan {
+	t.Helper()
+	i := strings.Index(s, sub)
+	if i < 0 {
+		t.Fatalf("%q not in %q", sub, s)
+	}
+	return &textSpan{StartByte: i, EndByte: i + len(sub)}
+}
+
+func TestRenderCitations(t *testing.T) {
+	t.Parallel()
+
+	const a, b = "https://www.example.com/a", "https://news.example.org/b"
+	answer := "Go 1.22 changed loops ([example.com](" + a + ")). It also added ranges [2]. Cited passage."
+	citations := []Citation{
+		{URL: a, Title: "Loop change", Span: spanOf(t, answer, "[example.com]("+a+")")},
+		{URL: b, Span: spanOf(t, answer, "[2]")},
+		{URL: a, Title: "Loop change", Span: spanOf(t, answer, "Cited passage")},
+	}
+
+	for _, tc := range []struct{ style, want string }{
+		{"", answer},
+		{citeFootnotes, "Go 1.22 changed loops [1]. It also added ranges [2]. Cited passage[1]." +
+			"\n\nSources:\n\n1. Loop change: " + a + "\n2. " + b},
+		{citeAPA, "Go 1.22 changed loops (example.com, n.d.). It also added ranges (news.example.org, n.d.). Cited passage (example.com, n.d.)." +
+			"\n\nReferences:\n\n- example.com. (n.d.). *Loop change*. " + a + "\n- news.example.org. (n.d.). " + b},
+		{citeNone, "Go 1.22 changed loops. It also added ranges. Cited passage."},
+		{citeInline, "Go 1.22 changed loops ([example.com](" + a + ")). It also added ranges ([news.example.org](" + b + ")). " +
+			"Cited passage ([example.com](" + a + "))."},
+	} {
+		if got := renderCitations(answer, citations, tc.style); got != tc.want {
+			t.Errorf("style %q:\n got %q\nwant %q", tc.style, got, tc.want)
+		}
+	}
+
+	// Without spans the answer is kept and the sources are only listed.
+	got := renderCitations("Rewritten.", withoutSpans(citations), citeFootnotes)
+	if want := "Rewritten.\n\nSources:\n\n1. Loop change: " + a + "\n2. " + b; got != want {
+		t.Errorf("without spans = %q, want %q", got, want)
+	}
+}
+
+func TestStyleCitations(t *testing.T) {
+	t.Parallel()
+
+	result := &WebSearchResult{Answer: "Claim.", Citations: []Citation{{URL: "https://example.com", Span: &textSpan{StartByte: 0, EndByte: 5}}}}
+	styled := styleCitations(result, citeFootnotes)
+	if styled.Answer != "Claim[1].\n\nSources:\n\n1. https://example.com" || styled.Citations[0].Span != nil {
+		t.Errorf("styled = %+v", styled)
+	}
+	if result.Answer != "Claim." || result.Citations[0].Span == nil {
+		t.Errorf("original result modified: %+v", result)
+	}
+
+	if _, err := parseCitationStyle("mla"); err == nil {
+		t.Error("parseCitationStyle(mla): expected an error")
+	}
+	if style, err := parseCitationStyle(" APA "); err != nil || style != citeAPA {
+		t.Errorf("parseCitationStyle(APA) = %q, %v", style, err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// proxySchemes are the PROXY_URL schemes net/http can dial through.
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// configureHTTPClient points the shared outbound transports at the
+// configured proxy and TLS settings, and applies the API transport
+// timeouts. Without PROXY_URL the standard HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY variables apply.
+func configureHTTPClient(envCfg EnvConfig) error {
+	proxy, err := newProxyFunc(envCfg.ProxyURL, envCfg.NoProxy)
+	if err != nil {
+		return err
+	}
+	tlsCfg, err := newTLSConfig(envCfg.CACertFile, envCfg.TLSInsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	api := httpClient.Transport.(*http.Transport)
+	api.Proxy, api.TLSClientConfig = proxy, tlsCfg
+	if envCfg.TLSHandshakeTimeout > 0 {
+		api.TLSHandshakeTimeout = envCfg.TLSHandshakeTimeout
+	}
+	// No response header timeout by default: a non-streaming call gets its
+	// headers only once the whole answer is ready.
+	api.ResponseHeaderTimeout = envCfg.ResponseHeaderTimeout
+	pages := http.DefaultTransport.(*http.Transport).Clone()
+	pages.Proxy, pages.TLSClientConfig = proxy, tlsCfg
+	snapshotClient.Transport = pages
+	// Callbacks keep their own dialer and skip the proxy (see webhookClient).
+	for _, c := range []*http.Client{webhookClient, webhookAllowedClient} {
+		c.Transport.(*http.Transport).TLSClientConfig = tlsCfg
+	}
+	return nil
+}
+
+// newTLSConfig trusts the certificates in caFile in addition to the system
+// roots, for TLS-intercepting proxies and gateways with private
+// certificates. It returns nil, the Go defaults, when nothing is configured.
+func newTLSConfig(caFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && !insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("CA_CERT_FILE: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA_CERT_FILE %s: no PEM certificates found", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if insecure {
+		Warn("TLS_INSECURE_SKIP_VERIFY is set: server certificates are NOT verified; " +
+			"anyone on the network path can read and alter API traffic, including your API key")
+		cfg.InsecureSkipVerify = true //nolint:gosec // explicit opt-in, warned above
+	}
+	return cfg, nil
+}
+
+// newProxyFunc returns the proxy selector for PROXY_URL. Hosts matched by
+// noProxy, and loopback addresses such as a local Ollama server, are
+// reached directly.
+func newProxyFunc(proxyURL, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if strings.TrimSpace(proxyURL) == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(strings.TrimSpace(proxyURL))
+	if err != nil || u.Host == "" || !proxySchemes[u.Scheme] {
+		return nil, fmt.Errorf("PROXY_URL %q: want http://, https://, socks5:// or socks5h://host:port", proxyURL)
+	}
+	bypass := strings.Split(noProxy, ",")
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), bypass) {
+			return nil, nil
+		}
+		return u, nil
+	}, nil
+}
+
+// bypassProxy reports whether host is loopback or matches a NO_PROXY entry:
+// "*", an IP address or CIDR range, or a domain that also covers its
+// subdomains (with or without a leading dot).
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	if host == "localhost" || ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case ip != nil && strings.Contains(entry, "/"):
+			if _, block, err := net.ParseCIDR(entry); err == nil && block.Contains(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// traceRequest logs req's progress at debug level: the request built, its
+// body written to the connection and the first response byte, each with the
+// time since it was built. Time to first byte is most of a non-streaming
+// call, since the headers come only with the whole answer.
+func traceRequest(req *http.Request, requestID string) *http.Request {
+	built := time.Now()
+	elapsed := func() string { return time.Since(built).Round(time.Millisecond).String() }
+	Debug("Request built", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "bytes", req.ContentLength, "request_id", requestID)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			Debug("Connection ready", "reused", info.Reused, "elapsed", elapsed(), "request_id", requestID)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				Debug("Request not sent", "elapsed", elapsed(), "request_id", requestID, "error", info.Err)
+				return
+			}
+			Debug("Request sent", "elapsed", elapsed(), "request_id", requestID)
+		},
+		GotFirstResponseByte: func() {
+			Debug("First response byte", "ttfb", elapsed(), "request_id", requestID)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
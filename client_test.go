@@ -0,0 +1,247 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBypassProxy(t *testing.T) {
+	t.Parallel()
+
+	noProxy := strings.Split("corp.example, .internal.test,10.0.0.0/8, registry:5000", ",")
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"corp.example", true},
+		{"api.corp.example", true},
+		{"notcorp.example", false},
+		{"svc.internal.test", true},
+		{"10.1.2.3", true},
+		{"11.1.2.3", false},
+		{"registry", true},
+		{"api.openai.com", false},
+	}
+	for _, tt := range tests {
+		if got := bypassProxy(tt.host, noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	if !bypassProxy("api.openai.com", []string{"*"}) {
+		t.Error("* should bypass every host")
+	}
+}
+
+func TestNewProxyFunc(t *testing.T) {
+	t.Parallel()
+
+	proxy, err := newProxyFunc("socks5://proxy.corp:1080", "")
+	if err != nil {
+		t.Fatalf("newProxyFunc: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/responses", nil)
+	if u, err := proxy(req); err != nil || u == nil || u.String() != "socks5://proxy.corp:1080" {
+		t.Errorf("proxy = %v, %v", u, err)
+	}
+	for _, bad := range []string{"ftp://proxy:21", "proxy.corp:8080", "://"} {
+		if _, err := newProxyFunc(bad, ""); err == nil {
+			t.Errorf("newProxyFunc(%q): expected error", bad)
+		}
+	}
+}
+
+func TestCallAPI_ThroughProxy(t *testing.T) {
+	var proxied string
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy sees the absolute target URL.
+		proxied = r.URL.String()
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_p", "output": []any{}})
+	}))
+	t.Cleanup(proxySrv.Close)
+
+	transport := httpClient.Transport.(*http.Transport)
+	saved := transport.Proxy
+	t.Cleanup(func() { transport.Proxy = saved })
+	proxyURL, _ := url.Parse(proxySrv.URL) //nolint:errcheck
+	transport.Proxy = http.ProxyURL(proxyURL)
+
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: "http://api.example.test/v1/responses",
+		Query:   "q",
+		Timeout: 2 * time.Second,
+	}); err != nil {
+		t.Fatalf("CallAPI: %v", err)
+	}
+	if got := proxied; got != "http://api.example.test/v1/responses" {
+		t.Errorf("proxy saw %q", got)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	get := func(tlsCfg *tls.Config) error {
+		tr := &http.Transport{TLSClientConfig: tlsCfg}
+		resp, err := (&http.Client{Transport: tr, Timeout: 5 * time.Second}).Get(srv.URL)
+		if err == nil {
+			resp.Body.Close() //nolint:errcheck
+		}
+		return err
+	}
+
+	if cfg, err := newTLSConfig("", false); cfg != nil || err != nil {
+		t.Fatalf("newTLSConfig(defaults) = %v, %v; want nil, nil", cfg, err)
+	}
+	if err := get(nil); err == nil {
+		t.Fatal("expected the private certificate to be rejected by default")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, block, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := newTLSConfig(caFile, false)
+	if err != nil {
+		t.Fatalf("newTLSConfig(ca): %v", err)
+	}
+	if err := get(cfg); err != nil {
+		t.Errorf("request with CA_CERT_FILE: %v", err)
+	}
+
+	insecure, err := newTLSConfig("", true)
+	if err != nil || !insecure.InsecureSkipVerify {
+		t.Fatalf("newTLSConfig(insecure) = %+v, %v", insecure, err)
+	}
+	if err := get(insecure); err != nil {
+		t.Errorf("request with TLS_INSECURE_SKIP_VERIFY: %v", err)
+	}
+
+	if _, err := newTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), false); err == nil {
+		t.Error("expected error for a missing CA file")
+	}
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTLSConfig(caFile, false); err == nil {
+		t.Error("expected error for a file without certificates")
+	}
+}
+
+func TestCallAPI_Gzip(t *testing.T) {
+	saved := gzipRequests
+	gzipRequests = true
+	t.Cleanup(func() { gzipRequests = saved })
+
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("request body is not gzip: %v", err)
+			}
+			body = zr
+		}
+		var req requestBody
+		if err := json.NewDecoder(body).Decode(&req); err != nil || !strings.HasPrefix(req.Input, "q") {
+			t.Errorf("decoded request = %+v, %v", req, err)
+		}
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, `{"id":"resp_gz","output":[{"type":"message","content":[{"type":"output_text","text":"compressed"}]}]}`)
+		zw.Close() //nolint:errcheck
+	}))
+	t.Cleanup(srv.Close)
+
+	for _, query := range []string{"q", "q" + strings.Repeat(" long question", minGzipRequestBytes/14)} {
+		ar, err := CallAPI(context.Background(), CallAPIParams{APIKey: "k", BaseURL: srv.URL, Query: query, Timeout: 2 * time.Second})
+		if err != nil {
+			t.Fatalf("CallAPI: %v", err)
+		}
+		if got := ExtractAnswer(ar).Text; got != "compressed" {
+			t.Errorf("answer = %q", got)
+		}
+	}
+	if strings.Join(encodings, ",") != ",gzip" {
+		t.Errorf("request encodings = %q, want only the large body compressed", encodings)
+	}
+}
+
+// logBuffer collects log records written from the transport's goroutines.
+type logBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *logBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+func TestTraceRequestVerbose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "{}")
+	}))
+	defer srv.Close()
+	var logs logBuffer
+	teeLog(&logs)
+	setVerbose(true)
+	t.Cleanup(func() {
+		setVerbose(false)
+		teeLog(nil)
+	})
+
+	req, err := newAuthRequest(context.Background(), http.MethodPost, srv.URL+"/v1/responses", strings.NewReader("{}"), CallAPIParams{RequestID: "ws-trace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck
+	resp.Body.Close()
+	for _, msg := range []string{`"msg":"Request built"`, `"bytes":2`, `"msg":"Request sent"`, `"msg":"First response byte"`, `"ttfb":`} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("verbose log lacks %s:\n%s", msg, logs.String())
+		}
+	}
+
+	setQuiet()
+	if debugEnabled() {
+		t.Error("debug logging enabled in quiet mode")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxComplianceLine bounds one record when reading the log back; answers
+// are bounded by maxResponseBodySize, so records stay well below this.
+const maxComplianceLine = 64 << 20
+
+// complianceRecord is one line of the compliance log. Each record carries
+// the hash of the previous one, so removing, reordering or editing any
+// record breaks the chain from that point on (see verifyComplianceLog).
+type complianceRecord struct {
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// Kind is query for an outbound request, answer for its reply and
+	// error when the request failed; CallID links the two. A purge record
+	// may lead the log (see Purged).
+	Kind         string    `json:"kind"`
+	CallID       string    `json:"call_id"`
+	RequestID    string    `json:"request_id,omitempty"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model,omitempty"`
+	Query        string    `json:"query,omitempty"`
+	Instructions string    `json:"instructions,omitempty"`
+	WebSearch    bool      `json:"web_search,omitempty"`
+	Answer       string    `json:"answer,omitempty"`
+	Citations    []string  `json:"citations,omitempty"`
+	ResponseID   string    `json:"response_id,omitempty"`
+	Usage        *apiUsage `json:"usage,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	// Purged is set on the "purge" record that stands in for records
+	// removed by answer purge: it keeps the sequence number and hash of the
+	// last removed record so the chain continues.
+	Purged   int64  `json:"purged,omitempty"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// digest returns the record's hash: SHA-256 over its JSON encoding without
+// the hash field.
+func (r complianceRecord) digest() string {
+	r.Hash = ""
+	raw, _ := json.Marshal(r) //nolint:errcheck // plain data
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// complianceLog is an append-only, hash-chained JSON Lines log of every
+// upstream query and answer (env COMPLIANCE_LOG). A nil *complianceLog
+// records nothing.
+type complianceLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	now  func() time.Time
+	seq  int64
+	prev string
+}
+
+// activeCompliance is the compliance log; set at startup when
+// COMPLIANCE_LOG names a file.
+var activeCompliance *complianceLog
+
+// openComplianceLog opens (creating if needed) the log at path and
+// continues its chain.
+func openComplianceLog(path string) (*complianceLog, error) {
+	l := &complianceLog{path: path, now: time.Now}
+	last, err := lastComplianceRecord(path)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		l.seq, l.prev = last.Seq, last.Hash
+	}
+	if l.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return nil, fmt.Errorf("open compliance log: %w", err)
+	}
+	return l, nil
+}
+
+func lastComplianceRecord(path string) (*complianceRecord, error) {
+	var last *complianceRecord
+	err := scanComplianceLog(path, func(r complianceRecord) error {
+		last = &r
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return last, err
+}
+
+// scanComplianceLog calls fn for each record of the log at path, in order.
+func scanComplianceLog(path string, fn func(complianceRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), maxComplianceLine)
+	for line := 1; sc.Scan(); line++ {
+		var r complianceRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return fmt.Errorf("compliance log line %d: %w", line, err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read compliance log: %w", err)
+	}
+	return nil
+}
+
+// append chains r onto the log and writes it durably before returning.
+func (l *complianceLog) append(r complianceRecord) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.Seq, r.Time, r.PrevHash = l.seq+1, l.now().UTC(), l.prev
+	r.Hash = r.digest()
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("compliance log: %w", err)
+	}
+	if _, err := l.f.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("compliance log: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("compliance log: %w", err)
+	}
+	l.seq, l.prev = r.Seq, r.Hash
+	return nil
+}
+
+// logQuery records an outbound request and returns the call ID its answer
+// is recorded under. A query that cannot be recorded must not be sent.
+func (l *complianceLog) logQuery(p CallAPIParams) (string, error) {
+	if l == nil {
+		return "", nil
+	}
+	var id [8]byte
+	_, _ = rand.Read(id[:]) //nolint:errcheck // never fails
+	callID := hex.EncodeToString(id[:])
+	return callID, l.append(complianceRecord{
+		Kind:         "query",
+		CallID:       callID,
+		RequestID:    p.RequestID,
+		Provider:     p.Provider,
+		Model:        p.Model,
+		Query:        p.Query,
+		Instructions: p.Instructions,
+		WebSearch:    p.UseWebSearch,
+	})
+}
+
+// logAnswer records the outcome of the call callID: the answer, or err.
+func (l *complianceLog) logAnswer(callID string, p CallAPIParams, ar *apiResponse, err error) {
+	if l == nil {
+		return
+	}
+	r := complianceRecord{Kind: "answer", CallID: callID, Provider: p.Provider, Model: p.Model}
+	if err != nil {
+		r.Kind, r.Error = "error", err.Error()
+	} else {
+		extracted := ExtractAnswer(ar)
+		r.Model, r.ResponseID, r.Usage = ar.Model, ar.ID, ar.Usage
+		r.Answer, r.Citations = extracted.Text, citationURLs(extracted.Citations)
+	}
+	if err := l.append(r); err != nil {
+		Error("Failed to record answer in the compliance log", "call_id", callID, "error", err)
+	}
+}
+
+// verifyComplianceLog checks the chain of the log at path and returns the
+// sequence number of its last record; the error names the first record that
+// does not fit.
+func verifyComplianceLog(path string) (int64, error) {
+	var n, count int64
+	prev := ""
+	err := scanComplianceLog(path, func(r complianceRecord) error {
+		count++
+		if count == 1 && r.Kind == "purge" {
+			// Earlier records were purged; the chain resumes here.
+			n, prev = r.Seq, r.Hash
+			return nil
+		}
+		switch {
+		case r.Seq != n+1:
+			return fmt.Errorf("record %d: sequence %d out of order", n+1, r.Seq)
+		case r.PrevHash != prev:
+			return fmt.Errorf("record %d: previous hash does not match; a record was removed or changed", r.Seq)
+		case r.Hash != r.digest():
+			return fmt.Errorf("record %d: hash does not match its content", r.Seq)
+		}
+		n, prev = r.Seq, r.Hash
+		return nil
+	})
+	return n, err
+}
+
+// runCompliance implements "answer compliance verify [file]".
+func runCompliance(args []string) {
+	fs := flag.NewFlagSet("compliance", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer compliance verify [file]  (default: COMPLIANCE_LOG)")
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	if fs.NArg() == 0 || fs.Arg(0) != "verify" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := os.Getenv("COMPLIANCE_LOG")
+	if fs.NArg() > 1 {
+		path = fs.Arg(1)
+	}
+	if path == "" {
+		fail(2, "no compliance log: pass a file or set COMPLIANCE_LOG")
+	}
+	n, err := verifyComplianceLog(path)
+	if err != nil {
+		fail(3, err.Error())
+	}
+	fmt.Printf("%s: chain intact through record %d\n", path, n)
+}
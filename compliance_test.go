@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComplianceLog_Chain(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "compliance.jsonl")
+	l, err := openComplianceLog(path)
+	if err != nil {
+		t.Fatalf("openComplianceLog: %v", err)
+	}
+	p := CallAPIParams{Provider: providerOpenAI, Model: "gpt-5-mini", Query: "bank rate?"}
+	callID, err := l.logQuery(p)
+	if err != nil || callID == "" {
+		t.Fatalf("logQuery = %q, %v", callID, err)
+	}
+	ar := &apiResponse{ID: "resp_1", Model: "gpt-5-mini", Output: []respItem{{Type: "message", Content: []respContent{{Type: "output_text", Text: "4.25%"}}}}}
+	l.logAnswer(callID, p, ar, nil)
+	l.f.Close() //nolint:errcheck
+
+	// Reopening continues the chain.
+	l, err = openComplianceLog(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	callID, _ = l.logQuery(p) //nolint:errcheck
+	l.logAnswer(callID, p, nil, errors.New("upstream down"))
+	l.f.Close() //nolint:errcheck
+
+	n, err := verifyComplianceLog(path)
+	if err != nil || n != 4 {
+		t.Fatalf("verifyComplianceLog = %d, %v; want 4 intact records", n, err)
+	}
+	var kinds []string
+	_ = scanComplianceLog(path, func(r complianceRecord) error { //nolint:errcheck
+		kinds = append(kinds, r.Kind)
+		return nil
+	})
+	if strings.Join(kinds, ",") != "query,answer,query,error" {
+		t.Errorf("kinds = %v", kinds)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(raw), "4.25%", "5.25%", 1)
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyComplianceLog(path); err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("edited record should break the chain, got %v", err)
+	}
+
+	lines := strings.SplitAfter(string(raw), "\n")
+	if err := os.WriteFile(path, []byte(lines[0]+lines[2]+lines[3]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyComplianceLog(path); err == nil {
+		t.Error("removed record should break the chain")
+	}
+}
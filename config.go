@@ -1,8 +1,12 @@
 package main
 
 import (
+	"cmp"
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -40,45 +44,227 @@ type reqReasoning struct {
 	Effort string `json:"effort"`
 }
 
+// reqTool is a hosted tool attached to a Responses API request. Fields apply
+// to the tool types noted; the rest are omitted.
 type reqTool struct {
+	Type              string        `json:"type"`
+	SearchContextSize string        `json:"search_context_size,omitempty"`
+	UserLocation      *UserLocation `json:"user_location,omitempty"`
+	Filters           *reqFilters   `json:"filters,omitempty"`
+	// VectorStoreIDs lists the stores searched by file_search.
+	VectorStoreIDs []string `json:"vector_store_ids,omitempty"`
+	// Container is the sandbox code_interpreter runs in.
+	Container *reqContainer `json:"container,omitempty"`
+}
+
+// Hosted tool types that can run alongside web search.
+const (
+	toolCodeInterpreter = "code_interpreter"
+	toolFileSearch      = "file_search"
+)
+
+// reqContainer selects the code_interpreter sandbox; "auto" lets the API
+// create one per request.
+type reqContainer struct {
 	Type string `json:"type"`
 }
 
+// reqFilters restricts the web_search tool to the listed domains.
+type reqFilters struct {
+	AllowedDomains []string `json:"allowed_domains"`
+}
+
+// UserLocation is the web search tool's approximate location hint, used to
+// localize results for queries such as "weather" or "events near me".
+type UserLocation struct {
+	Type     string `json:"type"`
+	Country  string `json:"country,omitempty"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
 type reqText struct {
-	Verbosity string `json:"verbosity"`
+	Verbosity string         `json:"verbosity"`
+	Format    *reqTextFormat `json:"format,omitempty"`
+}
+
+// reqTextFormat requests structured output matching a JSON Schema.
+type reqTextFormat struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name,omitempty"`
+	Schema json.RawMessage `json:"schema,omitempty"`
 }
 
 type requestBody struct {
-	Model              string       `json:"model"`
-	Input              string       `json:"input"`
+	Model        string `json:"model"`
+	Input        string `json:"input"`
+	Instructions string `json:"instructions,omitempty"`
+	// Messages are earlier turns sent ahead of Input, and Images and Files
+	// are attached to Input as content parts (see MarshalJSON).
+	Messages           []Message    `json:"-"`
+	Images             []string     `json:"-"`
+	Files              []attachment `json:"-"`
 	Reasoning          reqReasoning `json:"reasoning"`
 	Text               reqText      `json:"text"`
 	Tools              []reqTool    `json:"tools,omitempty"`
 	PreviousResponseID string       `json:"previous_response_id,omitempty"`
 	PromptCacheKey     string       `json:"prompt_cache_key,omitempty"`
+	// Store is sent only as false, to opt out of response storage; nil
+	// leaves the API default (stored).
+	Store           *bool    `json:"store,omitempty"`
+	Stream          bool     `json:"stream,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	// Truncation is "auto" or "disabled"; see truncationFor.
+	Truncation string `json:"truncation,omitempty"`
+	// Include asks for optional output fields, such as the sources of
+	// web_search_call actions.
+	Include    []string `json:"include,omitempty"`
+	ToolChoice string   `json:"tool_choice,omitempty"`
+}
+
+// inputMessage is a structured Responses API input item, used when the
+// query carries images or documents alongside its text.
+type inputMessage struct {
+	Role    string         `json:"role"`
+	Content []inputContent `json:"content"`
+}
+
+type inputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+}
+
+// MarshalJSON sends Input as a plain string, or as a list of input items
+// when Messages, Images or Files are set: the earlier turns, then Input as a
+// user message whose attachments are content parts. Text documents become
+// input_text, PDFs input_file, images input_image.
+func (b requestBody) MarshalJSON() ([]byte, error) {
+	type plain requestBody
+	if len(b.Messages) == 0 && len(b.Images) == 0 && len(b.Files) == 0 {
+		return json.Marshal(plain(b))
+	}
+	items := make([]any, 0, len(b.Messages)+1)
+	for _, m := range b.Messages {
+		items = append(items, m)
+	}
+	if len(b.Images) == 0 && len(b.Files) == 0 {
+		items = append(items, Message{Role: "user", Content: b.Input})
+		return json.Marshal(struct {
+			plain
+			Input []any `json:"input"`
+		}{plain(b), items})
+	}
+	content := []inputContent{{Type: "input_text", Text: b.Input}}
+	for _, f := range b.Files {
+		if f.Data != "" {
+			content = append(content, inputContent{Type: "input_file", Filename: f.Name, FileData: f.Data})
+		} else {
+			content = append(content, inputContent{Type: "input_text", Text: f.inlineText()})
+		}
+	}
+	for _, img := range b.Images {
+		content = append(content, inputContent{Type: "input_image", ImageURL: img})
+	}
+	return json.Marshal(struct {
+		plain
+		Input []any `json:"input"`
+	}{plain(b), append(items, inputMessage{Role: "user", Content: content})})
 }
 
 type respContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type        string           `json:"type"`
+	Text        string           `json:"text"`
+	Annotations []respAnnotation `json:"annotations,omitempty"`
+}
+
+// respAnnotation is an output_text annotation. For url_citation entries the
+// indices are character (code point) offsets into the segment's own text.
+type respAnnotation struct {
+	Type       string `json:"type"`
+	URL        string `json:"url,omitempty"`
+	Title      string `json:"title,omitempty"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
 }
 
 type respItem struct {
 	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Status  string        `json:"status,omitempty"`
 	Content []respContent `json:"content,omitempty"`
+	// Summary carries reasoning summaries on "reasoning" items.
+	Summary []respSummary `json:"summary,omitempty"`
+	// Action describes what a tool call item (e.g. web_search_call) did.
+	Action *respAction `json:"action,omitempty"`
+}
+
+type respSummary struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type respAction struct {
+	Type  string `json:"type"`
+	Query string `json:"query,omitempty"`
+	URL   string `json:"url,omitempty"`
+	// Sources are the pages a search returned. OpenAI sends them only when
+	// asked to (see CallAPIParams.SourcesOnly); other providers fill in what
+	// they report, including titles and snippets.
+	Sources []respSource `json:"sources,omitempty"`
+}
+
+// respSource is one search result on a web_search_call action.
+type respSource struct {
+	Type    string `json:"type"`
+	URL     string `json:"url,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
 }
 
 type apiResponse struct {
-	ID        string       `json:"id"`
-	Model     string       `json:"model"`
-	Reasoning apiReasoning `json:"reasoning"`
-	Output    []respItem   `json:"output"`
+	ID                string             `json:"id"`
+	Model             string             `json:"model"`
+	Status            string             `json:"status,omitempty"`
+	IncompleteDetails *incompleteDetails `json:"incomplete_details,omitempty"`
+	Reasoning         apiReasoning       `json:"reasoning"`
+	Output            []respItem         `json:"output"`
+	Usage             *apiUsage          `json:"usage,omitempty"`
+
+	// RetryWait is the time CallAPI spent backing off before this response
+	// succeeded; it is not part of the API payload.
+	RetryWait time.Duration `json:"-"`
+	// RequestID is the X-Client-Request-Id CallAPI sent.
+	RequestID string `json:"-"`
+	// Ignored names the request parameters the provider could not honor
+	// (see provider.check).
+	Ignored []string `json:"-"`
 }
 
 type apiReasoning struct {
 	Effort string `json:"effort"`
 }
 
+type incompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type apiUsage struct {
+	InputTokens         int                 `json:"input_tokens"`
+	OutputTokens        int                 `json:"output_tokens"`
+	TotalTokens         int                 `json:"total_tokens"`
+	OutputTokensDetails outputTokensDetails `json:"output_tokens_details"`
+}
+
+type outputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
 // EnvConfig centralizes environment-derived configuration.
 type EnvConfig struct {
 	Question   string
@@ -89,6 +275,158 @@ type EnvConfig struct {
 	Timeout    time.Duration
 	HasTimeout bool
 	APIKey     string
+	// CacheTTL enables the in-memory answer cache when positive (env CACHE_TTL).
+	CacheTTL time.Duration
+	// CacheStaleFor is how long past CacheTTL an entry may still be served
+	// stale while a background refresh runs (env CACHE_STALE_WHILE_REVALIDATE).
+	CacheStaleFor time.Duration
+	// CacheDir also keeps cached answers on disk, shared across runs and
+	// restarts (env CACHE_DIR). Empty keeps the cache in memory only.
+	CacheDir string
+	// StorePassphrase encrypts the history database and cache files at rest
+	// (env STORE_PASSPHRASE); StoreKeychain uses a random key kept in the OS
+	// keychain instead (env STORE_KEYCHAIN).
+	StorePassphrase string
+	StoreKeychain   bool
+	// SemanticCache selects the embedder for near-duplicate lookups: "local"
+	// or "openai" (env SEMANTIC_CACHE). Empty disables it.
+	SemanticCache string
+	// SemanticThreshold is the minimum cosine similarity for a semantic hit
+	// (env SEMANTIC_CACHE_THRESHOLD).
+	SemanticThreshold float64
+	// EmbeddingModel is the embeddings model for SEMANTIC_CACHE=openai
+	// (env EMBEDDING_MODEL).
+	EmbeddingModel string
+	// Offline refuses all upstream calls and serves only cached answers
+	// (env OFFLINE).
+	Offline bool
+	// IdempotencyKeys sends each call's request ID as Idempotency-Key
+	// (env IDEMPOTENCY_KEYS).
+	IdempotencyKeys bool
+	// NoStore asks OpenAI not to store responses by default (env NO_STORE).
+	NoStore bool
+	// Features switches subsystems on or off: comma-separated names, with
+	// a leading "-" to switch one off (env FEATURES).
+	Features string
+	// MaxRetries is the total number of attempts for transient API failures
+	// (env MAX_RETRIES); HasMaxRetries reports whether it was set.
+	MaxRetries    int
+	HasMaxRetries bool
+	// RetryBaseDelay is the first backoff delay (env RETRY_BASE_DELAY).
+	RetryBaseDelay time.Duration
+	// PostProcess configures answer cleanup (env ANSWER_TRIM_TRAILING,
+	// ANSWER_COLLAPSE_BLANK_LINES, ANSWER_STRIP_BOILERPLATE,
+	// ANSWER_STRIP_PATTERNS).
+	PostProcess PostProcessConfig
+	// GlossaryFile is a JSON glossary of preferred terms enforced in answers
+	// (env GLOSSARY_FILE); see loadGlossary.
+	GlossaryFile string
+	// AttachDir is the only directory MCP clients may attach server files
+	// from (env ATTACH_DIR); empty refuses server paths. The CLI reads any
+	// path the user names.
+	AttachDir string
+	// History records answers in the local history database (env HISTORY;
+	// location from HISTORY_DB, see historyPath).
+	History bool
+	// HistoryEmbeddings embeds recorded answers for -recall: "local" or
+	// "openai" (env HISTORY_EMBEDDINGS; model from EMBEDDING_MODEL).
+	HistoryEmbeddings string
+	// HistorySnapshots archives the pages cited by each recorded answer
+	// (env HISTORY_SNAPSHOTS).
+	HistorySnapshots bool
+	// RunArtifacts keeps the request, raw response, answer or report,
+	// fetched pages and log of each CLI run in its own directory (env
+	// RUN_ARTIFACTS; location from RUNS_DIR, see runsDir).
+	RunArtifacts bool
+	// ShowUsage prints the tokens, estimated cost and time of each CLI run
+	// after the answer (env SHOW_USAGE).
+	ShowUsage bool
+	// ComplianceLog is the file of the hash-chained log of every upstream
+	// query and answer (env COMPLIANCE_LOG); empty disables it.
+	ComplianceLog string
+	// UserLocation is the default web search location hint as
+	// comma-separated key=value pairs (env USER_LOCATION), e.g.
+	// "country=GB,city=London,timezone=Europe/London".
+	UserLocation string
+	// Gateway selects an OpenAI-compatible gateway preset: litellm, helicone
+	// or portkey (env GATEWAY). GatewayURL overrides the preset's address
+	// (env GATEWAY_URL) and GatewayHeaders adds comma-separated Name=value
+	// headers (env GATEWAY_HEADERS).
+	Gateway        string
+	GatewayURL     string
+	GatewayHeaders string
+	// ExtraHeaders are comma-separated Name=value headers added to every
+	// outbound API request (env EXTRA_HEADERS).
+	ExtraHeaders string
+	// PluginsFile lists external executables the MCP server exposes as
+	// tools (env PLUGINS_FILE).
+	PluginsFile string
+	// ProxyURL routes outbound requests through an http, https or socks5
+	// proxy (env PROXY_URL); NoProxy lists hosts reached directly (env
+	// NO_PROXY). Without ProxyURL, HTTP_PROXY and HTTPS_PROXY apply.
+	ProxyURL string
+	NoProxy  string
+	// CACertFile is a PEM bundle trusted in addition to the system roots
+	// (env CA_CERT_FILE). TLSInsecureSkipVerify turns off certificate
+	// verification altogether (env TLS_INSECURE_SKIP_VERIFY).
+	CACertFile            string
+	TLSInsecureSkipVerify bool
+	// TLSHandshakeTimeout and ResponseHeaderTimeout tune the API transport
+	// (env TLS_HANDSHAKE_TIMEOUT, RESPONSE_HEADER_TIMEOUT); zero keeps the
+	// defaults. GzipRequests compresses large request bodies (env
+	// GZIP_REQUESTS).
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	GzipRequests          bool
+	// HookPreQuery and HookPostAnswer are shell commands run before each
+	// upstream call and after each answer (env HOOK_PRE_QUERY,
+	// HOOK_POST_ANSWER), each bounded by HookTimeout (env HOOK_TIMEOUT).
+	HookPreQuery   string
+	HookPostAnswer string
+	HookTimeout    time.Duration
+	// WasmPlugins lists comma-separated .wasm files that rewrite queries
+	// and answers (env WASM_PLUGINS).
+	WasmPlugins string
+	// DowngradePolicy lists what to make cheaper (effort, model, context)
+	// once DowngradeAfter 429s arrive within DowngradeWindow (env
+	// DOWNGRADE_POLICY, DOWNGRADE_AFTER, DOWNGRADE_WINDOW). Empty disables
+	// downgrading.
+	DowngradePolicy string
+	DowngradeAfter  int
+	DowngradeWindow time.Duration
+	// MaxConcurrentCalls bounds the upstream calls in flight, with
+	// interactive calls ahead of batch ones (env MAX_CONCURRENT_CALLS; 0
+	// means no limit).
+	MaxConcurrentCalls int
+	// MaxQueuedCalls rejects calls once that many wait for a slot and
+	// QueueTimeout gives up on a longer wait (env MAX_QUEUED_CALLS,
+	// QUEUE_TIMEOUT); both need MaxConcurrentCalls and 0 means no limit.
+	MaxQueuedCalls int
+	QueueTimeout   time.Duration
+	// WebSearchToolType is the web search tool type sent to OpenAI, and
+	// WebSearchToolModels overrides it per model as model=type pairs, with
+	// a trailing * matching a prefix (env WEB_SEARCH_TOOL_TYPE,
+	// WEB_SEARCH_TOOL_MODELS).
+	WebSearchToolType   string
+	WebSearchToolModels string
+	// CitationStyle renders citations as inline links, footnotes or apa
+	// references unless a call picks a style (env CITATION_STYLE; empty
+	// keeps answers as the model wrote them).
+	CitationStyle string
+	// AnswerPageSize pages MCP answers longer than that many characters
+	// (env ANSWER_PAGE_SIZE; 0 returns answers whole).
+	AnswerPageSize int
+	// Provider is the default backend (env PROVIDER); AZURE_OPENAI=true
+	// selects azure when PROVIDER is unset.
+	Provider string
+	// ProviderKeys maps each provider to its API key, read from the
+	// provider's own variable (OPENAI_API_KEY, OPENROUTER_API_KEY, ...).
+	ProviderKeys map[string]string
+	// Azure locates the Azure OpenAI resource (env AZURE_OPENAI_ENDPOINT,
+	// AZURE_OPENAI_API_VERSION, AZURE_OPENAI_DEPLOYMENT).
+	Azure azureSettings
+	// Ollama locates the local Ollama server (env OLLAMA_HOST, OLLAMA_MODEL).
+	Ollama ollamaSettings
 }
 
 // MCPConfig holds configuration for the MCP server
@@ -102,6 +440,31 @@ type MCPConfig struct {
 	AuthEnabled   bool
 	AuthSecretKey string
 	Heartbeat     time.Duration
+	// AdminTools exposes the purge tool (-admin-tools).
+	AdminTools bool
+	// ReadOnly disables everything that changes server state: history is
+	// not recorded and admin tools are not exposed (-read-only).
+	ReadOnly bool
+	// Dashboard serves the web dashboard under /dashboard/ on the HTTP
+	// transport (-dashboard).
+	Dashboard bool
+	// Webhooks serves POST /hooks/query on the HTTP transport (-webhooks);
+	// WebhookSecret signs the callbacks (env WEBHOOK_SECRET), and the
+	// comma-separated WebhookAllowedHosts may receive them even on private
+	// addresses (env WEBHOOK_ALLOWED_HOSTS).
+	Webhooks            bool
+	WebhookSecret       string
+	WebhookAllowedHosts string
+	// API serves the synchronous POST /api/search endpoint on the HTTP
+	// transport (-api); APITimeout bounds each answer (-api-timeout).
+	API        bool
+	APITimeout time.Duration
+	// PIDFile, when set, records the HTTP server's PID once it listens
+	// (-pid-file; see writePIDFile).
+	PIDFile string
+	// AddrFile, when set, records the address the HTTP server listens on
+	// as JSON (-addr-file; see listenAddr).
+	AddrFile string
 }
 
 // loadEnvConfig reads environment variables
@@ -126,14 +489,181 @@ func loadEnvConfig() (EnvConfig, error) {
 		}
 	}
 
+	if v := os.Getenv("CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.CacheTTL = d
+		}
+	}
+
+	if v := os.Getenv("CACHE_STALE_WHILE_REVALIDATE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.CacheStaleFor = d
+		}
+	}
+
+	cfg.CacheDir = os.Getenv("CACHE_DIR")
+	cfg.StorePassphrase = os.Getenv("STORE_PASSPHRASE")
+	cfg.StoreKeychain = envBool("STORE_KEYCHAIN")
+
+	cfg.SemanticCache = os.Getenv("SEMANTIC_CACHE")
+	cfg.EmbeddingModel = os.Getenv("EMBEDDING_MODEL")
+	if v := os.Getenv("SEMANTIC_CACHE_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.SemanticThreshold = f
+		}
+	}
+
+	cfg.NoStore = envBool("NO_STORE")
+
+	if v := os.Getenv("OFFLINE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.Offline = b
+		}
+	}
+
+	cfg.IdempotencyKeys = envBool("IDEMPOTENCY_KEYS")
+	cfg.Features = os.Getenv("FEATURES")
+
+	if v := os.Getenv("MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxRetries = n
+			cfg.HasMaxRetries = true
+		}
+	}
+
+	if v := os.Getenv("RETRY_BASE_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.RetryBaseDelay = d
+		}
+	}
+
+	cfg.PostProcess = PostProcessConfig{
+		TrimTrailing:     envBool("ANSWER_TRIM_TRAILING"),
+		CollapseBlank:    envBool("ANSWER_COLLAPSE_BLANK_LINES"),
+		StripBoilerplate: envBool("ANSWER_STRIP_BOILERPLATE"),
+		StripPatterns:    os.Getenv("ANSWER_STRIP_PATTERNS"),
+	}
+
+	cfg.GlossaryFile = os.Getenv("GLOSSARY_FILE")
+	cfg.AttachDir = os.Getenv("ATTACH_DIR")
+	cfg.History = envBool("HISTORY")
+	cfg.HistoryEmbeddings = os.Getenv("HISTORY_EMBEDDINGS")
+	cfg.HistorySnapshots = envBool("HISTORY_SNAPSHOTS")
+	cfg.RunArtifacts = envBool("RUN_ARTIFACTS")
+	cfg.ShowUsage = envBool("SHOW_USAGE")
+	cfg.ComplianceLog = os.Getenv("COMPLIANCE_LOG")
+	cfg.UserLocation = os.Getenv("USER_LOCATION")
+	cfg.Gateway = os.Getenv("GATEWAY")
+	cfg.GatewayURL = os.Getenv("GATEWAY_URL")
+	cfg.GatewayHeaders = os.Getenv("GATEWAY_HEADERS")
+	cfg.ExtraHeaders = os.Getenv("EXTRA_HEADERS")
+	cfg.PluginsFile = os.Getenv("PLUGINS_FILE")
+	cfg.ProxyURL = os.Getenv("PROXY_URL")
+	cfg.CACertFile = os.Getenv("CA_CERT_FILE")
+	cfg.HookPreQuery = os.Getenv("HOOK_PRE_QUERY")
+	cfg.HookPostAnswer = os.Getenv("HOOK_POST_ANSWER")
+	if v := os.Getenv("HOOK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.HookTimeout = d
+		}
+	}
+	cfg.TLSInsecureSkipVerify = envBool("TLS_INSECURE_SKIP_VERIFY")
+	if v := os.Getenv("TLS_HANDSHAKE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.TLSHandshakeTimeout = d
+		}
+	}
+	if v := os.Getenv("RESPONSE_HEADER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ResponseHeaderTimeout = d
+		}
+	}
+	cfg.GzipRequests = envBool("GZIP_REQUESTS")
+	cfg.WasmPlugins = os.Getenv("WASM_PLUGINS")
+	cfg.DowngradePolicy = os.Getenv("DOWNGRADE_POLICY")
+	if v := os.Getenv("DOWNGRADE_AFTER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.DowngradeAfter = n
+		}
+	}
+	if v := os.Getenv("DOWNGRADE_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.DowngradeWindow = d
+		}
+	}
+	if v := os.Getenv("MAX_CONCURRENT_CALLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxConcurrentCalls = n
+		}
+	}
+	if v := os.Getenv("MAX_QUEUED_CALLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxQueuedCalls = n
+		}
+	}
+	if v := os.Getenv("QUEUE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.QueueTimeout = d
+		}
+	}
+	cfg.WebSearchToolType = os.Getenv("WEB_SEARCH_TOOL_TYPE")
+	cfg.WebSearchToolModels = os.Getenv("WEB_SEARCH_TOOL_MODELS")
+	cfg.CitationStyle = os.Getenv("CITATION_STYLE")
+	if v := os.Getenv("ANSWER_PAGE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AnswerPageSize = n
+		}
+	}
+	cfg.NoProxy = cmp.Or(os.Getenv("NO_PROXY"), os.Getenv("no_proxy"))
+
+	cfg.Azure = azureSettings{
+		Endpoint:   os.Getenv("AZURE_OPENAI_ENDPOINT"),
+		APIVersion: os.Getenv("AZURE_OPENAI_API_VERSION"),
+		Deployment: os.Getenv("AZURE_OPENAI_DEPLOYMENT"),
+	}
+	cfg.Ollama = ollamaSettings{
+		Host:  os.Getenv("OLLAMA_HOST"),
+		Model: os.Getenv("OLLAMA_MODEL"),
+	}
+
+	cfg.Provider = os.Getenv("PROVIDER")
+	if cfg.Provider == "" {
+		cfg.Provider = providerOpenAI
+		if envBool("AZURE_OPENAI") {
+			cfg.Provider = providerAzure
+		}
+	}
+	pr, err := lookupProvider(cfg.Provider)
+	if err != nil {
+		return EnvConfig{}, err
+	}
+	cfg.Provider = pr.name
+	cfg.ProviderKeys = make(map[string]string)
+	for name, p := range providers {
+		if v := os.Getenv(p.keyEnv); p.keyEnv != "" && v != "" {
+			cfg.ProviderKeys[name] = v
+		}
+	}
+
 	cfg.APIKey = os.Getenv("OPENAI_API_KEY")
-	if cfg.APIKey == "" {
+	// Offline mode never talks to the API, so it can run without a key.
+	if pr.keyEnv != "" && cfg.ProviderKeys[pr.name] == "" && !cfg.Offline {
 		return EnvConfig{}, ErrNoAPIKey
 	}
+	if pr.name == providerAzure && cfg.Azure.Endpoint == "" && !cfg.Offline {
+		return EnvConfig{}, fmt.Errorf("AZURE_OPENAI_ENDPOINT is required for the azure provider")
+	}
 
 	return cfg, nil
 }
 
+// envBool reports whether the named variable holds a true boolean value;
+// unset or unparsable values count as false.
+func envBool(name string) bool {
+	b, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && b
+}
+
 // getTimeoutForEffort returns the appropriate timeout based on reasoning effort level
 func getTimeoutForEffort(effort string) time.Duration {
 	switch effort {
@@ -166,6 +696,207 @@ func validateEffort(effort string) string {
 	}
 }
 
+// validateSearchContextSize accepts the web search tool's context sizes. An
+// empty or unknown value yields "" so the API default (medium) applies.
+func validateSearchContextSize(size string) string {
+	switch size {
+	case "low", "medium", "high":
+		return size
+	default:
+		return ""
+	}
+}
+
+// validateTruncation accepts the Responses API truncation strategies. An
+// empty or unknown value yields "" so truncationFor picks the default.
+func validateTruncation(truncation string) string {
+	switch truncation {
+	case "auto", "disabled":
+		return truncation
+	default:
+		return ""
+	}
+}
+
+// truncationFor returns the truncation strategy to send. Unset, it is
+// "auto" for calls chained with previous_response_id, so a long chain drops
+// its oldest turns instead of failing with a context overflow, and left to
+// the API default (disabled) otherwise.
+func truncationFor(truncation, previousResponseID string) string {
+	if truncation == "" && previousResponseID != "" {
+		return "auto"
+	}
+	return truncation
+}
+
+// parseUserLocation parses a USER_LOCATION value of comma-separated
+// key=value pairs (country, city, region, timezone). An empty value yields
+// nil, meaning no location hint is sent.
+func parseUserLocation(s string) (*UserLocation, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("USER_LOCATION: expected key=value, got %q", strings.TrimSpace(pair))
+		}
+		fields[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return newUserLocation(fields)
+}
+
+// newUserLocation validates location fields from USER_LOCATION or the MCP
+// user_location argument. Country must be a two-letter ISO 3166-1 code.
+func newUserLocation(fields map[string]string) (*UserLocation, error) {
+	loc := &UserLocation{Type: "approximate"}
+	for k, v := range fields {
+		switch k {
+		case "country":
+			loc.Country = strings.ToUpper(v)
+		case "city":
+			loc.City = v
+		case "region":
+			loc.Region = v
+		case "timezone":
+			loc.Timezone = v
+		default:
+			return nil, fmt.Errorf("user location: unknown field %q (use country, city, region, timezone)", k)
+		}
+	}
+	if loc.Country != "" && (len(loc.Country) != 2 ||
+		strings.IndexFunc(loc.Country, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0) {
+		return nil, fmt.Errorf("user location: country must be a two-letter ISO code, got %q", loc.Country)
+	}
+	if *loc == (UserLocation{Type: "approximate"}) {
+		return nil, nil
+	}
+	return loc, nil
+}
+
+// normalizeDomains cleans a domain allow-list: entries are lower-cased and
+// stripped of scheme, path and "www." so "https://www.arxiv.org/list" and
+// "arxiv.org" collapse to one entry. Empty entries and duplicates are dropped.
+func normalizeDomains(domains []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if i := strings.Index(d, "://"); i >= 0 {
+			d = d[i+3:]
+		}
+		if i := strings.IndexAny(d, "/?#"); i >= 0 {
+			d = d[:i]
+		}
+		d = strings.TrimPrefix(d, "www.")
+		d = strings.Trim(d, ".")
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+// splitDomains parses the comma-separated -domains flag.
+func splitDomains(s string) []string {
+	return normalizeDomainFilter(strings.Split(s, ","))
+}
+
+// normalizeDomainFilter cleans a search domain filter like normalizeDomains,
+// keeping the leading "-" that marks a domain to exclude rather than allow.
+func normalizeDomainFilter(filter []string) []string {
+	allow, block := splitDomainFilter(filter)
+	for _, d := range block {
+		allow = append(allow, "-"+d)
+	}
+	return allow
+}
+
+// splitDomainFilter separates a domain filter into the domains to search
+// and those to exclude, which carry a leading "-".
+func splitDomainFilter(filter []string) (allow, block []string) {
+	for _, d := range filter {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(d), "-"); ok {
+			block = append(block, rest)
+		} else {
+			allow = append(allow, d)
+		}
+	}
+	return normalizeDomains(allow), normalizeDomains(block)
+}
+
+// hostedTools builds the extra hosted tools for a request: code_interpreter
+// when codeInterpreter is set and file_search over vectorStoreIDs when any
+// are given. Blank and duplicate store IDs are dropped.
+func hostedTools(codeInterpreter bool, vectorStoreIDs []string) []reqTool {
+	var tools []reqTool
+	if codeInterpreter {
+		tools = append(tools, reqTool{Type: toolCodeInterpreter, Container: &reqContainer{Type: "auto"}})
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range vectorStoreIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		tools = append(tools, reqTool{Type: toolFileSearch, VectorStoreIDs: ids})
+	}
+	return tools
+}
+
+// minOutputTokens is the smallest max_output_tokens the API accepts.
+const minOutputTokens = 16
+
+// validateMaxOutputTokens normalizes an output token cap: zero or negative
+// means no cap, and small values are raised to the API minimum.
+func validateMaxOutputTokens(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return max(n, minOutputTokens)
+}
+
+// isReasoningModel reports whether model belongs to a reasoning family
+// (gpt-5*, o-series). A "vendor/" prefix, as used by OpenRouter, is ignored.
+func isReasoningModel(model string) bool {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, prefix := range []string{"gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSampling checks temperature (0-2) and top_p (0-1] and rejects them
+// for reasoning models, which only accept sampling parameters when reasoning
+// is disabled with effort "none".
+func validateSampling(model, effort string, temperature, topP *float64) error {
+	if temperature == nil && topP == nil {
+		return nil
+	}
+	if temperature != nil && (*temperature < 0 || *temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", *temperature)
+	}
+	if topP != nil && (*topP <= 0 || *topP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1, got %g", *topP)
+	}
+	if isReasoningModel(model) && effort != "none" {
+		return fmt.Errorf("%w (model %s, effort %s)", ErrSamplingUnsupported, model, effort)
+	}
+	return nil
+}
+
 // validateVerbosity ensures the verbosity level is valid
 func validateVerbosity(verbosity string) string {
 	switch verbosity {
@@ -182,15 +913,25 @@ func validateVerbosity(verbosity string) string {
 // Using a struct avoids a long positional parameter list and makes call sites
 // readable without per-argument comments.
 type MCPConfigParams struct {
-	APIKey        string
-	BaseURL       string
-	Transport     string
-	Port          string
-	Host          string
-	Verbose       bool
-	AuthEnabled   bool
-	AuthSecretKey string
-	Heartbeat     time.Duration
+	APIKey              string
+	BaseURL             string
+	Transport           string
+	Port                string
+	Host                string
+	Verbose             bool
+	AuthEnabled         bool
+	AuthSecretKey       string
+	Heartbeat           time.Duration
+	AdminTools          bool
+	ReadOnly            bool
+	Dashboard           bool
+	Webhooks            bool
+	WebhookSecret       string
+	WebhookAllowedHosts string
+	API                 bool
+	APITimeout          time.Duration
+	PIDFile             string
+	AddrFile            string
 }
 
 // parseMCPConfig creates MCPConfig from the supplied parameters, applying
@@ -220,5 +961,16 @@ func parseMCPConfig(p MCPConfigParams) MCPConfig {
 		AuthEnabled:   p.AuthEnabled,
 		AuthSecretKey: p.AuthSecretKey,
 		Heartbeat:     p.Heartbeat,
+		// Read-only wins over -admin-tools.
+		AdminTools:          p.AdminTools && !p.ReadOnly,
+		ReadOnly:            p.ReadOnly,
+		Dashboard:           p.Dashboard,
+		Webhooks:            p.Webhooks,
+		WebhookSecret:       p.WebhookSecret,
+		WebhookAllowedHosts: p.WebhookAllowedHosts,
+		API:                 p.API,
+		APITimeout:          p.APITimeout,
+		PIDFile:             p.PIDFile,
+		AddrFile:            p.AddrFile,
 	}
 }
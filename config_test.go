@@ -93,6 +93,14 @@ func TestLoadEnvConfig_Table(t *testing.T) {
 				hasTimeout: false,
 			},
 		},
+		{
+			name: "offline_allows_missing_api_key",
+			env: map[string]string{
+				"OPENAI_API_KEY": "",
+				"OFFLINE":        "1",
+			},
+			want: want{},
+		},
 		{
 			name: "question_model_effort_read_through",
 			env: map[string]string{
@@ -119,6 +127,73 @@ func TestLoadEnvConfig_Table(t *testing.T) {
 		t.Setenv("EFFORT", "")
 		t.Setenv("SHOW_ALL", "")
 		t.Setenv("TIMEOUT", "")
+		t.Setenv("CACHE_TTL", "")
+		t.Setenv("CACHE_STALE_WHILE_REVALIDATE", "")
+		t.Setenv("CACHE_DIR", "")
+		t.Setenv("STORE_PASSPHRASE", "")
+		t.Setenv("STORE_KEYCHAIN", "")
+		t.Setenv("SEMANTIC_CACHE", "")
+		t.Setenv("SEMANTIC_CACHE_THRESHOLD", "")
+		t.Setenv("EMBEDDING_MODEL", "")
+		t.Setenv("OFFLINE", "")
+		t.Setenv("IDEMPOTENCY_KEYS", "")
+		t.Setenv("FEATURES", "")
+		t.Setenv("MAX_RETRIES", "")
+		t.Setenv("RETRY_BASE_DELAY", "")
+		t.Setenv("ANSWER_TRIM_TRAILING", "")
+		t.Setenv("ANSWER_COLLAPSE_BLANK_LINES", "")
+		t.Setenv("ANSWER_STRIP_BOILERPLATE", "")
+		t.Setenv("ANSWER_STRIP_PATTERNS", "")
+		t.Setenv("USER_LOCATION", "")
+		t.Setenv("GLOSSARY_FILE", "")
+		t.Setenv("HISTORY", "")
+		t.Setenv("HISTORY_EMBEDDINGS", "")
+		t.Setenv("HISTORY_SNAPSHOTS", "")
+		t.Setenv("RUN_ARTIFACTS", "")
+		t.Setenv("COMPLIANCE_LOG", "")
+		t.Setenv("GATEWAY", "")
+		t.Setenv("GATEWAY_URL", "")
+		t.Setenv("GATEWAY_HEADERS", "")
+		t.Setenv("EXTRA_HEADERS", "")
+		t.Setenv("PLUGINS_FILE", "")
+		t.Setenv("PROXY_URL", "")
+		t.Setenv("CA_CERT_FILE", "")
+		t.Setenv("HOOK_PRE_QUERY", "")
+		t.Setenv("HOOK_POST_ANSWER", "")
+		t.Setenv("HOOK_TIMEOUT", "")
+		t.Setenv("TLS_INSECURE_SKIP_VERIFY", "")
+		t.Setenv("TLS_HANDSHAKE_TIMEOUT", "")
+		t.Setenv("RESPONSE_HEADER_TIMEOUT", "")
+		t.Setenv("GZIP_REQUESTS", "")
+		t.Setenv("WASM_PLUGINS", "")
+		t.Setenv("MAX_CONCURRENT_CALLS", "")
+		t.Setenv("MAX_QUEUED_CALLS", "")
+		t.Setenv("QUEUE_TIMEOUT", "")
+		t.Setenv("ANSWER_PAGE_SIZE", "")
+		t.Setenv("WEB_SEARCH_TOOL_TYPE", "")
+		t.Setenv("WEB_SEARCH_TOOL_MODELS", "")
+		t.Setenv("CITATION_STYLE", "")
+		t.Setenv("NO_STORE", "")
+		t.Setenv("DOWNGRADE_POLICY", "")
+		t.Setenv("DOWNGRADE_AFTER", "")
+		t.Setenv("DOWNGRADE_WINDOW", "")
+		t.Setenv("NO_PROXY", "")
+		t.Setenv("no_proxy", "")
+		t.Setenv("PROVIDER", "")
+		t.Setenv("OPENROUTER_API_KEY", "")
+		t.Setenv("XAI_API_KEY", "")
+		t.Setenv("MISTRAL_API_KEY", "")
+		t.Setenv("DEEPSEEK_API_KEY", "")
+		t.Setenv("ANTHROPIC_API_KEY", "")
+		t.Setenv("GEMINI_API_KEY", "")
+		t.Setenv("PERPLEXITY_API_KEY", "")
+		t.Setenv("AZURE_OPENAI", "")
+		t.Setenv("AZURE_OPENAI_API_KEY", "")
+		t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+		t.Setenv("AZURE_OPENAI_API_VERSION", "")
+		t.Setenv("AZURE_OPENAI_DEPLOYMENT", "")
+		t.Setenv("OLLAMA_HOST", "")
+		t.Setenv("OLLAMA_MODEL", "")
 	}
 
 	for _, tt := range tests {
@@ -299,3 +374,84 @@ func TestValidateVerbosity(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSearchContextSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"low", "low"},
+		{"medium", "medium"},
+		{"high", "high"},
+		{"", ""},
+		{"huge", ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+			if got := validateSearchContextSize(tt.in); got != tt.want {
+				t.Errorf("validateSearchContextSize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUserLocation(t *testing.T) {
+	t.Parallel()
+
+	loc, err := parseUserLocation(" country=gb, city=London ,timezone=Europe/London")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := UserLocation{Type: "approximate", Country: "GB", City: "London", Timezone: "Europe/London"}
+	if loc == nil || *loc != want {
+		t.Errorf("parseUserLocation() = %+v, want %+v", loc, want)
+	}
+
+	if loc, err := parseUserLocation(""); loc != nil || err != nil {
+		t.Errorf("parseUserLocation(\"\") = %+v, %v; want nil, nil", loc, err)
+	}
+
+	for _, bad := range []string{"London", "country=GBR", "planet=Earth", "country=G1"} {
+		if _, err := parseUserLocation(bad); err == nil {
+			t.Errorf("parseUserLocation(%q) expected error", bad)
+		}
+	}
+}
+
+func TestValidateSampling(t *testing.T) {
+	t.Parallel()
+
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name        string
+		model       string
+		effort      string
+		temperature *float64
+		topP        *float64
+		wantErr     bool
+		unsupported bool
+	}{
+		{name: "unset", model: "gpt-5.4-mini", effort: "high"},
+		{name: "non_reasoning_model", model: "gpt-4.1", effort: "medium", temperature: f(0.2), topP: f(0.9)},
+		{name: "reasoning_effort_none", model: "gpt-5.4", effort: "none", temperature: f(0)},
+		{name: "reasoning_model", model: "gpt-5.4-mini", effort: "low", temperature: f(0.2), wantErr: true, unsupported: true},
+		{name: "vendor_prefixed_reasoning", model: "openai/o3", effort: "medium", topP: f(0.5), wantErr: true, unsupported: true},
+		{name: "temperature_range", model: "gpt-4.1", temperature: f(2.5), wantErr: true},
+		{name: "top_p_zero", model: "gpt-4.1", topP: f(0), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateSampling(tt.model, tt.effort, tt.temperature, tt.topP)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateSampling() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrSamplingUnsupported) != tt.unsupported {
+				t.Errorf("errors.Is(ErrSamplingUnsupported) = %v, want %v", !tt.unsupported, tt.unsupported)
+			}
+		})
+	}
+}
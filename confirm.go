@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// modelPrice is a list price in US dollars per million tokens.
+type modelPrice struct {
+	input, output float64
+}
+
+// modelPrices are the list prices -confirm and -show-usage estimate with,
+// matched by model name prefix (longest first). They are a guide, not a bill:
+// providers change prices and -confirm's token counts are guesses.
+var modelPrices = []struct {
+	prefix string
+	price  modelPrice
+}{
+	{"gpt-5.4-nano", modelPrice{0.05, 0.40}},
+	{"gpt-5.4-mini", modelPrice{0.25, 2.00}},
+	{"gpt-5.4", modelPrice{1.25, 10.00}},
+	{"gpt-5-nano", modelPrice{0.05, 0.40}},
+	{"gpt-5-mini", modelPrice{0.25, 2.00}},
+	{"gpt-5", modelPrice{1.25, 10.00}},
+}
+
+// webSearchCallPrice is the per-call fee of the hosted web search tool.
+const webSearchCallPrice = 0.01
+
+// expectedOutputTokens is a typical output size, reasoning included, for
+// each effort level.
+var expectedOutputTokens = map[string]int{
+	"none":   800,
+	"low":    2000,
+	"medium": 5000,
+	"high":   12000,
+	"xhigh":  25000,
+}
+
+// webSearchInputTokens approximates the search results added to the input
+// for each search_context_size ("" is the API default, medium).
+var webSearchInputTokens = map[string]int{
+	"low":    4000,
+	"":       8000,
+	"medium": 8000,
+	"high":   16000,
+}
+
+// costEstimate is a rough price of one call.
+type costEstimate struct {
+	InputTokens  int
+	OutputTokens int
+	// USD is the estimated price; Known is false when the model has no list
+	// price, in which case only the token counts are meaningful.
+	USD   float64
+	Known bool
+}
+
+// estimateCost guesses the price of answering wa with model.
+func estimateCost(model string, wa webSearchArgs) costEstimate {
+	est := costEstimate{
+		InputTokens:  len(wa.query)/4 + 200,
+		OutputTokens: expectedOutputTokens[wa.effort],
+	}
+	if wa.useWebSearch {
+		est.InputTokens += webSearchInputTokens[wa.searchContextSize]
+	}
+	if wa.maxOutputTokens > 0 {
+		est.OutputTokens = min(est.OutputTokens, wa.maxOutputTokens)
+	}
+	return est.priced(model, wa.useWebSearch)
+}
+
+// priced fills in the price of the estimated tokens on model.
+func (e costEstimate) priced(model string, webSearch bool) costEstimate {
+	if price, ok := priceFor(model); ok {
+		e.USD = (float64(e.InputTokens)*price.input + float64(e.OutputTokens)*price.output) / 1e6
+		if webSearch {
+			e.USD += webSearchCallPrice
+		}
+		e.Known = true
+	}
+	return e
+}
+
+// priceFor returns the list price of model, per million tokens.
+func priceFor(model string) (modelPrice, bool) {
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.price, true
+		}
+	}
+	return modelPrice{}, false
+}
+
+// add sums two estimates; the sum has a price only when both do. The zero
+// estimate is the identity.
+func (e costEstimate) add(o costEstimate) costEstimate {
+	if e == (costEstimate{}) {
+		return o
+	}
+	return costEstimate{
+		InputTokens:  e.InputTokens + o.InputTokens,
+		OutputTokens: e.OutputTokens + o.OutputTokens,
+		USD:          e.USD + o.USD,
+		Known:        e.Known && o.Known,
+	}
+}
+
+func (e costEstimate) String() string {
+	tokens := fmt.Sprintf("~%d input + ~%d output tokens", e.InputTokens, e.OutputTokens)
+	if !e.Known {
+		return tokens + " (no list price for this model)"
+	}
+	return fmt.Sprintf("~$%.4f (%s)", e.USD, tokens)
+}
+
+// confirmCall prints the resolved parameters and the estimate to w and
+// reads the answer from r; only "y" or "yes" confirms.
+func confirmCall(w io.Writer, r io.Reader, params [][2]string, est costEstimate) bool {
+	fmt.Fprintln(w, "About to send:")
+	for _, kv := range params {
+		fmt.Fprintf(w, "  %-16s %s\n", kv[0]+":", kv[1])
+	}
+	fmt.Fprintf(w, "  %-16s %s\n", "estimated cost:", est)
+	fmt.Fprint(w, "Proceed? [y/N] ")
+	line, _ := bufio.NewReader(r).ReadString('\n') //nolint:errcheck // EOF means no
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmInput is where -confirm reads the reply: stdin, or the terminal
+// when stdin is piped, since the pipe carries the question.
+func confirmInput() io.Reader {
+	if !isTerminal(os.Stdin) {
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			return tty
+		}
+	}
+	return os.Stdin
+}
+
+// callParams lists the effective parameters of a CLI call for -confirm.
+func callParams(pr *provider, wa webSearchArgs, timeout string) [][2]string {
+	params := [][2]string{
+		{"provider", pr.name},
+		{"model", pr.modelFor(wa.model)},
+		{"effort", wa.effort},
+		{"verbosity", wa.verbosity},
+		{"web search", fmt.Sprint(wa.useWebSearch)},
+		{"timeout", timeout},
+	}
+	if wa.maxOutputTokens > 0 {
+		params = append(params, [2]string{"max tokens", fmt.Sprint(wa.maxOutputTokens)})
+	}
+	return params
+}
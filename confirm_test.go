@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	t.Parallel()
+
+	wa := webSearchArgs{query: "What changed in the EU AI Act this year?", effort: "low", useWebSearch: true}
+	est := estimateCost(modelMini, wa)
+	if !est.Known || est.OutputTokens != 2000 || est.InputTokens < 8000 {
+		t.Fatalf("estimate = %+v", est)
+	}
+	if est.USD <= webSearchCallPrice || est.USD > 0.05 {
+		t.Errorf("USD = %f, want a few cents at most", est.USD)
+	}
+	if deep := estimateCost(modelMini, webSearchArgs{query: wa.query, effort: "xhigh", useWebSearch: true}); deep.USD <= est.USD {
+		t.Errorf("xhigh estimate %f should exceed low %f", deep.USD, est.USD)
+	}
+	if capped := estimateCost(modelMini, webSearchArgs{effort: "high", maxOutputTokens: 100}); capped.OutputTokens != 100 {
+		t.Errorf("max_output_tokens not applied: %+v", capped)
+	}
+
+	unknown := estimateCost("llama3.2", wa)
+	if unknown.Known || !strings.Contains(unknown.String(), "no list price") {
+		t.Errorf("unknown model estimate = %+v (%s)", unknown, unknown)
+	}
+	if sum := est.add(unknown); sum.Known || sum.OutputTokens != 4000 {
+		t.Errorf("sum = %+v", sum)
+	}
+	if sum := (costEstimate{}).add(est); sum != est {
+		t.Errorf("zero + est = %+v, want %+v", sum, est)
+	}
+}
+
+func TestConfirmCall(t *testing.T) {
+	t.Parallel()
+
+	params := [][2]string{{"model", "gpt-5.4-mini"}, {"effort", "high"}}
+	est := estimateCost(modelMini, webSearchArgs{effort: "high"})
+	for input, want := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		var out strings.Builder
+		if got := confirmCall(&out, strings.NewReader(input), params, est); got != want {
+			t.Errorf("input %q: confirmed = %v, want %v", input, got, want)
+		}
+		if !strings.Contains(out.String(), "gpt-5.4-mini") || !strings.Contains(out.String(), "~$") {
+			t.Errorf("prompt = %q", out.String())
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxConversationMessages bounds the earlier turns one call may carry.
+const maxConversationMessages = 100
+
+// Message is an earlier turn of a conversation the caller keeps itself. The
+// turns are sent ahead of the query, so a whole conversation goes up in one
+// call without relying on previous_response_id.
+type Message struct {
+	// Role is "user" or "assistant"; system guidance belongs in
+	// Instructions.
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// parseMessages validates a messages tool argument: a list of objects with
+// a role and a non-empty content.
+func parseMessages(raw any) ([]Message, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, errors.New("messages must be a list of {role, content} objects")
+	}
+	if len(items) > maxConversationMessages {
+		return nil, fmt.Errorf("messages has %d entries; the limit is %d", len(items), maxConversationMessages)
+	}
+	messages := make([]Message, 0, len(items))
+	for i, item := range items {
+		obj, _ := item.(map[string]any)       //nolint:errcheck
+		role, _ := obj["role"].(string)       //nolint:errcheck
+		content, _ := obj["content"].(string) //nolint:errcheck
+		m := Message{Role: strings.ToLower(strings.TrimSpace(role)), Content: content}
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (m Message) validate() error {
+	if m.Role != "user" && m.Role != "assistant" {
+		return fmt.Errorf("role %q is not user or assistant", m.Role)
+	}
+	if strings.TrimSpace(m.Content) == "" {
+		return errors.New("empty content")
+	}
+	return nil
+}
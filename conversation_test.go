@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMessages(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseMessages([]any{
+		map[string]any{"role": "user", "content": "Who won in 2022?"},
+		map[string]any{"role": " Assistant ", "content": "Argentina."},
+	})
+	want := []Message{{Role: "user", Content: "Who won in 2022?"}, {Role: "assistant", Content: "Argentina."}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMessages = %+v, %v; want %+v", got, err, want)
+	}
+	if got, err := parseMessages(nil); got != nil || err != nil {
+		t.Errorf("parseMessages(nil) = %+v, %v", got, err)
+	}
+
+	for name, raw := range map[string]any{
+		"not a list":    "hello",
+		"system role":   []any{map[string]any{"role": "system", "content": "be brief"}},
+		"empty content": []any{map[string]any{"role": "user", "content": " "}},
+		"not an object": []any{"hello"},
+		"too many":      make([]any, maxConversationMessages+1),
+	} {
+		if _, err := parseMessages(raw); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCallAPI_Messages(t *testing.T) {
+	t.Parallel()
+
+	var input []map[string]any
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw struct {
+			Input []map[string]any `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		input = raw.Input
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	_, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:   "k",
+		BaseURL:  base,
+		Query:    "And in 2018?",
+		Messages: []Message{{Role: "user", Content: "Who won in 2022?"}, {Role: "assistant", Content: "Argentina."}},
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []map[string]any{
+		{"role": "user", "content": "Who won in 2022?"},
+		{"role": "assistant", "content": "Argentina."},
+		{"role": "user", "content": "And in 2018?"},
+	}
+	if !reflect.DeepEqual(input, want) {
+		t.Errorf("input = %v, want %v", input, want)
+	}
+}
+
+func TestProviderHistory(t *testing.T) {
+	t.Parallel()
+
+	p := CallAPIParams{Query: "q2", Messages: []Message{{Role: "user", Content: "q1"}, {Role: "assistant", Content: "a1"}}}
+
+	var roles []string
+	for _, c := range geminiContents(p) {
+		roles = append(roles, c.Role)
+	}
+	if want := []string{"user", "model", "user"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("gemini roles = %v, want %v", roles, want)
+	}
+
+	roles = nil
+	for _, m := range anthropicMessages(p) {
+		roles = append(roles, m.Role)
+	}
+	if want := []string{"user", "assistant", "user"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("anthropic roles = %v, want %v", roles, want)
+	}
+
+	p.Instructions = "be brief"
+	req := newChatRequest(p)
+	var contents []string
+	for _, m := range req.Messages {
+		contents = append(contents, m.Role+":"+m.Content)
+	}
+	if want := []string{"system:be brief", "user:q1", "assistant:a1", "user:q2"}; !reflect.DeepEqual(contents, want) {
+		t.Errorf("chat messages = %v, want %v", contents, want)
+	}
+
+	if key := cacheKeyFor(webSearchArgs{query: "q2", messages: p.Messages}); key != "" {
+		t.Errorf("cacheKeyFor a conversation = %q, want uncacheable", key)
+	}
+}
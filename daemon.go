@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// daemonStartWait is how long "answer mcp -daemon" waits for the server
+	// to write its PID file before giving up.
+	daemonStartWait = 5 * time.Second
+	// daemonStopWait is how long "answer mcp stop" waits for the server to
+	// exit; it covers httpShutdownTimeout.
+	daemonStopWait = httpShutdownTimeout + 5*time.Second
+)
+
+// defaultPIDFile and defaultDaemonLog are in the data directory.
+func defaultPIDFile() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate PID file: %w", err)
+	}
+	return filepath.Join(dir, "mcp.pid"), nil
+}
+
+func defaultDaemonLog() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate log file: %w", err)
+	}
+	return filepath.Join(dir, "mcp.log"), nil
+}
+
+// readPIDFile returns the PID recorded in path.
+func readPIDFile(path string) (int, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("PID file %s is damaged", path)
+	}
+	return pid, nil
+}
+
+// runningPID returns the PID in path when that process is alive. A PID
+// file left by a server that crashed reports 0.
+func runningPID(path string) (int, error) {
+	pid, err := readPIDFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !processAlive(pid) {
+		return 0, nil
+	}
+	return pid, nil
+}
+
+// writePIDFile records this process in path, refusing when another live
+// server owns it.
+func writePIDFile(path string) error {
+	pid, err := runningPID(path)
+	if err != nil {
+		return err
+	}
+	if pid != 0 && pid != os.Getpid() {
+		return fmt.Errorf("MCP server already running (pid %d, %s)", pid, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create PID file dir: %w", err)
+	}
+	return writeFileAtomic(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// removePIDFile removes path if it still names this process.
+func removePIDFile(path string) {
+	if pid, err := readPIDFile(path); err == nil && pid == os.Getpid() {
+		if err := os.Remove(path); err != nil {
+			Warn("Failed to remove PID file", "path", path, "error", err)
+		}
+	}
+}
+
+// withoutDaemonFlag returns the "answer mcp" arguments without -daemon, for
+// the server started in the background.
+func withoutDaemonFlag(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(out, args[i:]...)
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && name == "daemon" {
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// startDaemon starts "answer mcp" with args in the background, detached
+// from the terminal, its output appended to logFile. It returns once the
+// server has written pidFile, or with an error when it exits first. The
+// server records its address in addrFile.
+func startDaemon(args []string, pidFile, addrFile, logFile string) (int, error) {
+	if pid, err := runningPID(pidFile); err != nil {
+		return 0, err
+	} else if pid != 0 {
+		return 0, fmt.Errorf("MCP server already running (pid %d)", pid)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("locate executable: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0o700); err != nil {
+		return 0, fmt.Errorf("create log dir: %w", err)
+	}
+	logOut, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("open log file: %w", err)
+	}
+	defer logOut.Close()
+
+	cmd := exec.Command(exe, append([]string{"mcp"}, append(withoutDaemonFlag(args), "-pid-file", pidFile, "-addr-file", addrFile)...)...)
+	cmd.Stdout, cmd.Stderr = logOut, logOut
+	if cmd.SysProcAttr, err = detachedProcAttr(); err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start server: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(daemonStartWait)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return 0, fmt.Errorf("server exited at startup (%v); see %s", err, logFile)
+		case <-deadline:
+			return cmd.Process.Pid, fmt.Errorf("server (pid %d) did not write %s within %s; see %s", cmd.Process.Pid, pidFile, daemonStartWait, logFile)
+		case <-tick.C:
+			if pid, err := readPIDFile(pidFile); err == nil && pid == cmd.Process.Pid {
+				return pid, nil
+			}
+		}
+	}
+}
+
+// stopDaemon stops the server recorded in pidFile and waits for it to
+// exit. It reports false when no server was running.
+func stopDaemon(pidFile string) (bool, error) {
+	pid, err := runningPID(pidFile)
+	if err != nil || pid == 0 {
+		// A stale file would only confuse the next status.
+		if err == nil {
+			_ = os.Remove(pidFile) //nolint:errcheck // may not exist
+		}
+		return false, err
+	}
+	if err := terminateProcess(pid); err != nil {
+		return true, fmt.Errorf("stop pid %d: %w", pid, err)
+	}
+	for deadline := time.Now().Add(daemonStopWait); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if !processAlive(pid) {
+			_ = os.Remove(pidFile) //nolint:errcheck // the server may have removed it
+			return true, nil
+		}
+	}
+	return true, fmt.Errorf("pid %d still running after %s", pid, daemonStopWait)
+}
+
+// runMCPControl implements "answer mcp stop" and "answer mcp status". Status
+// exits 0 when the server runs and 3 when it does not, as init scripts
+// expect.
+func runMCPControl(cmd string, args []string) {
+	fs := flag.NewFlagSet("mcp "+cmd, flag.ExitOnError)
+	pidFile := fs.String("pid-file", "", "PID file of the server (default $XDG_DATA_HOME/websearch/mcp.pid)")
+	addrFile := fs.String("addr-file", "", "With status, address file of the server (default $XDG_DATA_HOME/websearch/mcp.addr.json)")
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	path := *pidFile
+	if path == "" {
+		var err error
+		if path, err = defaultPIDFile(); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	switch cmd {
+	case "stop":
+		running, err := stopDaemon(path)
+		switch {
+		case err != nil:
+			fail(1, err.Error())
+		case !running:
+			fmt.Println("MCP server is not running")
+		default:
+			fmt.Println("MCP server stopped")
+		}
+	case "status":
+		pid, err := runningPID(path)
+		if err != nil {
+			fail(1, err.Error())
+		}
+		if pid == 0 {
+			fmt.Println("MCP server is not running")
+			os.Exit(3)
+		}
+		fmt.Printf("MCP server is running (pid %d, %s)\n", pid, path)
+		addrPath := *addrFile
+		if addrPath == "" {
+			addrPath, _ = defaultAddrFile() //nolint:errcheck // the address is optional
+		}
+		// An address file of another run would point at the wrong port.
+		if a, err := readAddrFile(addrPath); err == nil && a.PID == pid {
+			a.announce(os.Stdout)
+		}
+	}
+}
+
+// runDaemon implements "answer mcp -daemon": it starts the server in the
+// background and reports where it runs.
+func runDaemon(args []string, pidFile, addrFile, logFile string) {
+	var err error
+	if pidFile == "" {
+		if pidFile, err = defaultPIDFile(); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	if addrFile == "" {
+		if addrFile, err = defaultAddrFile(); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	if logFile == "" {
+		if logFile, err = defaultDaemonLog(); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	// The server may not share the working directory's meaning of relative
+	// paths with "answer mcp stop".
+	if pidFile, err = filepath.Abs(pidFile); err != nil {
+		fail(2, err.Error())
+	}
+	if addrFile, err = filepath.Abs(addrFile); err != nil {
+		fail(2, err.Error())
+	}
+	pid, err := startDaemon(args, pidFile, addrFile, logFile)
+	if err != nil {
+		fail(1, err.Error())
+	}
+	fmt.Printf("MCP server started (pid %d)\n", pid)
+	if a, err := readAddrFile(addrFile); err == nil {
+		a.announce(os.Stdout)
+	}
+	fmt.Printf("PID file: %s\nLog: %s\n", pidFile, logFile)
+}
@@ -0,0 +1,34 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// detachedProcAttr reports that -daemon needs a Unix system; elsewhere run
+// "answer mcp" under the platform's service manager.
+func detachedProcAttr() (*syscall.SysProcAttr, error) {
+	return nil, errors.New("-daemon is not supported on this platform; run answer mcp as a service instead")
+}
+
+// processAlive reports whether pid exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release() //nolint:errcheck
+	return true
+}
+
+// terminateProcess ends pid; there is no SIGTERM to shut down cleanly.
+func terminateProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestWithoutDaemonFlag(t *testing.T) {
+	t.Parallel()
+
+	got := withoutDaemonFlag([]string{"-t", "http", "-daemon", "--daemon=true", "-port", "9000", "--", "-daemon"})
+	want := []string{"-t", "http", "-port", "9000", "--", "-daemon"}
+	if !slices.Equal(got, want) {
+		t.Errorf("withoutDaemonFlag = %q, want %q", got, want)
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "run", "mcp.pid")
+	if pid, err := runningPID(path); pid != 0 || err != nil {
+		t.Fatalf("missing file = %d, %v", pid, err)
+	}
+	if err := writePIDFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := runningPID(path); pid != os.Getpid() || err != nil {
+		t.Errorf("runningPID = %d, %v; want %d", pid, err, os.Getpid())
+	}
+	removePIDFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("PID file still there: %v", err)
+	}
+
+	// A file left by a crashed server is stale and may be taken over.
+	if err := os.WriteFile(path, []byte(strconv.Itoa(1<<30)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := runningPID(path); pid != 0 || err != nil {
+		t.Errorf("stale file = %d, %v", pid, err)
+	}
+	if running, err := stopDaemon(path); running || err != nil {
+		t.Errorf("stopDaemon(stale) = %v, %v", running, err)
+	}
+	if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runningPID(path); err == nil {
+		t.Error("damaged PID file accepted")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Limits of the in-memory dashboard activity log.
+const (
+	maxDashboardQueries = 200
+	dashboardHours      = 24
+)
+
+// maxHTTPRequestBytes caps the JSON bodies of the plain HTTP endpoints.
+const maxHTTPRequestBytes = 1 << 20
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// dashboardQuery is one answered (or failed) query as shown on the dashboard.
+type dashboardQuery struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user,omitempty"`
+	Query      string    `json:"query"`
+	Model      string    `json:"model,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Cached     bool      `json:"cached,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Tokens     int       `json:"tokens,omitempty"`
+}
+
+// dashboardHour aggregates the queries started within one clock hour.
+type dashboardHour struct {
+	Hour    time.Time `json:"hour"`
+	Queries int       `json:"queries"`
+	Cached  int       `json:"cached"`
+	Failed  int       `json:"failed"`
+	Tokens  int       `json:"tokens"`
+}
+
+// dashboardStats is the payload of /dashboard/api/stats.
+type dashboardStats struct {
+	Since   time.Time        `json:"since"`
+	Totals  dashboardHour    `json:"totals"`
+	Hours   []dashboardHour  `json:"hours"`
+	Cache   dashboardCache   `json:"cache"`
+	Queries []dashboardQuery `json:"queries"`
+}
+
+// dashboardCache summarises the answer cache.
+type dashboardCache struct {
+	Enabled bool    `json:"enabled"`
+	Entries int     `json:"entries"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// dashboard records recent activity for the HTTP dashboard. The log lives
+// in memory only and starts empty on every restart.
+type dashboard struct {
+	mu      sync.Mutex
+	since   time.Time
+	queries []dashboardQuery
+	hours   []dashboardHour
+	totals  dashboardHour
+	now     func() time.Time
+}
+
+// activeDashboard is the process-wide activity log. It stays nil (and
+// records nothing) unless the HTTP transport runs with -dashboard.
+var activeDashboard *dashboard
+
+func newDashboard() *dashboard {
+	return &dashboard{since: time.Now().UTC(), now: time.Now}
+}
+
+// record logs one query made by the user in ctx.
+func (d *dashboard) record(ctx context.Context, query string, result *WebSearchResult, err error, took time.Duration) {
+	if d == nil {
+		return
+	}
+	_, username := getUserInfo(ctx)
+	q := dashboardQuery{User: username, Query: truncateForLog(query), DurationMS: took.Milliseconds()}
+	switch {
+	case err != nil:
+		q.Error = err.Error()
+	case result != nil:
+		q.Model, q.Provider, q.Cached, q.Success, q.Error = result.Model, result.Provider, result.Cached, result.Success, result.Error
+		if result.Usage != nil && !result.Cached {
+			q.Tokens = result.Usage.TotalTokens
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q.Time = d.now().UTC()
+	d.queries = append(d.queries, q)
+	if len(d.queries) > maxDashboardQueries {
+		d.queries = slices.Delete(d.queries, 0, len(d.queries)-maxDashboardQueries)
+	}
+
+	hour := q.Time.Truncate(time.Hour)
+	if n := len(d.hours); n == 0 || !d.hours[n-1].Hour.Equal(hour) {
+		d.hours = append(d.hours, dashboardHour{Hour: hour})
+	}
+	cutoff := hour.Add(-(dashboardHours - 1) * time.Hour)
+	d.hours = slices.DeleteFunc(d.hours, func(h dashboardHour) bool { return h.Hour.Before(cutoff) })
+	for _, h := range []*dashboardHour{&d.hours[len(d.hours)-1], &d.totals} {
+		h.Queries++
+		h.Tokens += q.Tokens
+		if q.Cached {
+			h.Cached++
+		}
+		if !q.Success {
+			h.Failed++
+		}
+	}
+}
+
+// stats returns the dashboard payload: the last dashboardHours hours with
+// empty hours filled in, oldest first, and the recent queries, newest
+// first.
+func (d *dashboard) stats() dashboardStats {
+	s := dashboardStats{Cache: dashboardCache{Enabled: answerCache != nil, Entries: answerCache.len()}}
+	if d == nil {
+		return s
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s.Since, s.Totals = d.since, d.totals
+	if d.totals.Queries > 0 {
+		s.Cache.HitRate = float64(d.totals.Cached) / float64(d.totals.Queries)
+	}
+	latest := d.now().UTC().Truncate(time.Hour)
+	s.Hours = make([]dashboardHour, dashboardHours)
+	for i := range s.Hours {
+		s.Hours[i].Hour = latest.Add(-time.Duration(dashboardHours-1-i) * time.Hour)
+	}
+	for _, h := range d.hours {
+		if i := dashboardHours - 1 - int(latest.Sub(h.Hour)/time.Hour); i >= 0 && i < dashboardHours {
+			s.Hours[i] = h
+		}
+	}
+	s.Queries = slices.Clone(d.queries)
+	slices.Reverse(s.Queries)
+	return s
+}
+
+// queryRequest is a question posted over plain HTTP, to the dashboard or
+// the webhook endpoint.
+type queryRequest struct {
+	Query     string `json:"query"`
+	Model     string `json:"model,omitempty"`
+	Effort    string `json:"effort,omitempty"`
+	WebSearch *bool  `json:"web_search,omitempty"`
+}
+
+// toolArgs maps the request onto gpt_websearch arguments.
+func (q queryRequest) toolArgs() map[string]any {
+	args := map[string]any{"query": q.Query}
+	if q.Model != "" {
+		args["model"] = q.Model
+	}
+	if q.Effort != "" {
+		args["reasoning_effort"] = q.Effort
+	}
+	if q.WebSearch != nil {
+		args["web_search"] = *q.WebSearch
+	}
+	return args
+}
+
+// handler serves the dashboard page and its JSON API. The page itself holds
+// no data; the API endpoints are wrapped with auth (when enabled), so the
+// page asks for a token and sends it with every call.
+func (d *dashboard) handler(cfg MCPConfig) http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /dashboard/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeHTTPJSON(w, http.StatusOK, d.stats())
+	})
+	api.HandleFunc("POST /dashboard/api/query", func(w http.ResponseWriter, r *http.Request) {
+		var req queryRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHTTPRequestBytes)).Decode(&req); err != nil {
+			writeHTTPJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+			return
+		}
+		started := time.Now()
+		result, err := HandleWebSearch(r.Context(), cfg.APIKey, cfg.BaseURL, req.toolArgs())
+		d.record(r.Context(), req.Query, result, err, time.Since(started))
+		if err != nil {
+			writeHTTPJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		writeHTTPJSON(w, http.StatusOK, result)
+	})
+
+	var apiHandler http.Handler = api
+	if cfg.AuthEnabled {
+		apiHandler = newAuthHTTPMiddleware([]byte(cfg.AuthSecretKey), api)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/dashboard/api/", apiHandler)
+	mux.HandleFunc("GET /dashboard/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+		_, _ = w.Write(dashboardPage) //nolint:errcheck // best-effort page write
+	})
+	return mux
+}
+
+// writeHTTPJSON writes v as a JSON response with the given status.
+func writeHTTPJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v) //nolint:errcheck // best-effort response
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDashboard_RecordAndStats(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	d := newDashboard()
+	d.now = func() time.Time { return now }
+
+	ctx := context.WithValue(t.Context(), userInfoKey, userInfo{Username: "ana"})
+	d.record(ctx, "old question", &WebSearchResult{Success: true}, nil, time.Second)
+	now = now.Add(30 * time.Hour)
+	d.record(ctx, "first", &WebSearchResult{Success: true, Model: "gpt-5.4-mini", Usage: &apiUsage{TotalTokens: 120}}, nil, 2*time.Second)
+	d.record(t.Context(), "second", &WebSearchResult{Success: true, Cached: true, Usage: &apiUsage{TotalTokens: 120}}, nil, time.Millisecond)
+	now = now.Add(time.Hour)
+	d.record(t.Context(), "third", nil, errors.New("upstream down"), time.Second)
+
+	s := d.stats()
+	if s.Totals.Queries != 4 || s.Totals.Cached != 1 || s.Totals.Failed != 1 || s.Totals.Tokens != 120 {
+		t.Errorf("totals = %+v", s.Totals)
+	}
+	if s.Cache.HitRate != 0.25 {
+		t.Errorf("hit rate = %v, want 0.25", s.Cache.HitRate)
+	}
+	if len(s.Hours) != dashboardHours {
+		t.Fatalf("hours = %d, want %d", len(s.Hours), dashboardHours)
+	}
+	// The query from 31 hours ago has aged out of the chart.
+	if last, prev := s.Hours[dashboardHours-1], s.Hours[dashboardHours-2]; last.Queries != 1 || last.Failed != 1 || prev.Queries != 2 || prev.Tokens != 120 {
+		t.Errorf("last hours = %+v, %+v", prev, last)
+	}
+	if !s.Hours[dashboardHours-1].Hour.Equal(now.Truncate(time.Hour)) || s.Hours[0].Queries != 0 {
+		t.Errorf("hours = %+v", s.Hours)
+	}
+	if len(s.Queries) != 4 || s.Queries[0].Query != "third" || s.Queries[0].Error != "upstream down" || s.Queries[2].User != "ana" || s.Queries[2].DurationMS != 2000 {
+		t.Errorf("queries = %+v", s.Queries)
+	}
+
+	var nilDashboard *dashboard
+	nilDashboard.record(t.Context(), "ignored", nil, nil, 0)
+	if s := nilDashboard.stats(); len(s.Queries) != 0 {
+		t.Errorf("nil dashboard stats = %+v", s)
+	}
+}
+
+func TestDashboard_Handler(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_1", "status": "completed", "model": "gpt-5.4-mini", "usage": map[string]any{"total_tokens": 42},
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "Forty-two."}}}},
+		})
+	})
+	const secret = "dashboard-test-secret"
+	d := newDashboard()
+	srv := httptest.NewServer(d.handler(MCPConfig{APIKey: "k", BaseURL: base, AuthEnabled: true, AuthSecretKey: secret}))
+	t.Cleanup(srv.Close)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Username: "ana",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "gemini-mcp",
+			Audience:  jwt.ClaimStrings{"gemini-mcp-user"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequestWithContext(t.Context(), method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() }) //nolint:errcheck
+		return resp
+	}
+
+	// The page is a static shell; the data behind it needs a token.
+	if resp := do(http.MethodGet, "/dashboard/", "", ""); resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("page: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp := do(http.MethodGet, "/dashboard/api/stats", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("stats without token: status %d, want 401", resp.StatusCode)
+	}
+
+	resp := do(http.MethodPost, "/dashboard/api/query", token, `{"query":"What is the answer?","effort":"low"}`)
+	var result WebSearchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.StatusCode != http.StatusOK || result.Answer != "Forty-two." {
+		t.Fatalf("query: status %d, result %+v, %v", resp.StatusCode, result, err)
+	}
+
+	var stats dashboardStats
+	resp = do(http.MethodGet, "/dashboard/api/stats", token, "")
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Queries) != 1 || stats.Queries[0].User != "ana" || stats.Queries[0].Tokens != 42 || !stats.Queries[0].Success {
+		t.Errorf("recorded queries = %+v", stats.Queries)
+	}
+
+	var buf bytes.Buffer
+	buf.ReadFrom(do(http.MethodPost, "/dashboard/api/query", token, "{").Body) //nolint:errcheck
+	if !strings.Contains(buf.String(), "invalid JSON body") {
+		t.Errorf("bad body response = %s", buf.String())
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Downgrade steps a DOWNGRADE_POLICY can list.
+const (
+	downgradeEffort  = "effort"
+	downgradeModel   = "model"
+	downgradeContext = "context"
+)
+
+const (
+	// defaultDowngradeAfter is how many 429s within the window trigger the
+	// downgrade unless DOWNGRADE_AFTER is set.
+	defaultDowngradeAfter = 3
+	// defaultDowngradeWindow is the window unless DOWNGRADE_WINDOW is set.
+	defaultDowngradeWindow = time.Minute
+)
+
+// effortLevels are the effort levels from cheapest to most expensive.
+var effortLevels = []string{"none", "low", "medium", "high", "xhigh"}
+
+// cheaperModels maps a model onto the next cheaper one of its family.
+var cheaperModels = map[string]string{
+	modelFull:    modelMini,
+	modelMini:    modelNano,
+	"gpt-5":      "gpt-5-mini",
+	"gpt-5-mini": "gpt-5-nano",
+}
+
+// downgrader makes new searches cheaper while a provider keeps answering
+// 429 (env DOWNGRADE_POLICY): once DOWNGRADE_AFTER rate-limit responses
+// arrive within DOWNGRADE_WINDOW, each step of the policy is applied to new
+// searches until a full window passes without one. A nil *downgrader never
+// downgrades.
+type downgrader struct {
+	steps  []string
+	after  int
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// limited holds the recent 429 times per provider.
+	limited map[string][]time.Time
+}
+
+// activeDowngrade holds the downgrade policy; set at startup.
+var activeDowngrade *downgrader
+
+// newDowngrader parses a comma-separated policy of effort, model and
+// context steps. It returns nil when the policy is empty.
+func newDowngrader(policy string, after int, window time.Duration) (*downgrader, error) {
+	var steps []string
+	for _, s := range strings.Split(policy, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "":
+			continue
+		case downgradeEffort, downgradeModel, downgradeContext:
+			if !slices.Contains(steps, s) {
+				steps = append(steps, s)
+			}
+		default:
+			return nil, fmt.Errorf("DOWNGRADE_POLICY: unknown step %q (want effort, model or context)", s)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	return &downgrader{
+		steps:   steps,
+		after:   cmp.Or(max(after, 0), defaultDowngradeAfter),
+		window:  cmp.Or(max(window, 0), defaultDowngradeWindow),
+		now:     time.Now,
+		limited: make(map[string][]time.Time),
+	}, nil
+}
+
+// recordRateLimit notes a 429 from provider.
+func (d *downgrader) recordRateLimit(provider string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	recent := append(d.recent(provider, now), now)
+	d.limited[provider] = recent
+	if len(recent) == d.after {
+		Warn("Sustained rate limiting; downgrading new requests", "provider", provider, "steps", strings.Join(d.steps, ","), "window", d.window)
+	}
+}
+
+// recent drops the 429s older than the window. Callers hold d.mu.
+func (d *downgrader) recent(provider string, now time.Time) []time.Time {
+	times := d.limited[provider]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > d.window {
+		i++
+	}
+	return times[i:]
+}
+
+// active reports whether requests to provider are being downgraded.
+func (d *downgrader) active(provider string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	recent := d.recent(provider, d.now())
+	d.limited[provider] = recent
+	return len(recent) >= d.after
+}
+
+// apply returns wa made cheaper for provider, and one note per change, when
+// the provider is rate limiting; otherwise wa is returned unchanged.
+func (d *downgrader) apply(provider string, wa webSearchArgs) (webSearchArgs, []string) {
+	if !d.active(provider) {
+		return wa, nil
+	}
+	var notes []string
+	for _, step := range d.steps {
+		switch step {
+		case downgradeEffort:
+			if i := slices.Index(effortLevels, wa.effort); i > 0 {
+				notes = append(notes, fmt.Sprintf("effort: %s -> %s", wa.effort, effortLevels[i-1]))
+				wa.effort = effortLevels[i-1]
+			}
+		case downgradeModel:
+			if cheaper, ok := cheaperModels[wa.model]; ok {
+				notes = append(notes, fmt.Sprintf("model: %s -> %s", wa.model, cheaper))
+				wa.model = cheaper
+			}
+		case downgradeContext:
+			if wa.useWebSearch && wa.searchContextSize != "low" {
+				notes = append(notes, fmt.Sprintf("search_context_size: %s -> low", cmp.Or(wa.searchContextSize, "medium")))
+				wa.searchContextSize = "low"
+			}
+		}
+	}
+	return wa, notes
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDowngrader(t *testing.T) {
+	t.Parallel()
+
+	if d, err := newDowngrader("", 0, 0); d != nil || err != nil {
+		t.Errorf("empty policy = %v, %v; want disabled", d, err)
+	}
+	if _, err := newDowngrader("effort,cheaper", 0, 0); err == nil {
+		t.Error("expected an error for an unknown step")
+	}
+
+	d, err := newDowngrader("effort, model,context", 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return clock }
+	wa := webSearchArgs{model: modelFull, effort: "high", useWebSearch: true}
+
+	d.recordRateLimit("openai")
+	if got, notes := d.apply("openai", wa); notes != nil || got.model != modelFull {
+		t.Errorf("one 429 downgraded the request: %v", notes)
+	}
+	clock = clock.Add(30 * time.Second)
+	d.recordRateLimit("openai")
+	got, notes := d.apply("openai", wa)
+	want := []string{"effort: high -> medium", "model: gpt-5.4 -> gpt-5.4-mini", "search_context_size: medium -> low"}
+	if !slices.Equal(notes, want) || got.effort != "medium" || got.model != modelMini || got.searchContextSize != "low" {
+		t.Errorf("apply = %+v, %q; want %q", got, notes, want)
+	}
+	if _, notes := d.apply("xai", wa); notes != nil {
+		t.Errorf("other providers are not rate limited, got %q", notes)
+	}
+
+	// The floor of each step is left alone.
+	floor := webSearchArgs{model: "custom-model", effort: "none", searchContextSize: "low", useWebSearch: true}
+	if _, notes := d.apply("openai", floor); notes != nil {
+		t.Errorf("nothing left to downgrade, got %q", notes)
+	}
+
+	// A window without 429s ends the downgrade.
+	clock = clock.Add(61 * time.Second)
+	if _, notes := d.apply("openai", wa); notes != nil {
+		t.Errorf("downgrade outlived the window: %q", notes)
+	}
+}
+
+func TestRunWebSearch_DowngradesUnderRateLimiting(t *testing.T) {
+	orig := activeDowngrade
+	t.Cleanup(func() { activeDowngrade = orig })
+	d, err := newDowngrader("effort,model", 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeDowngrade = d
+
+	var calls atomic.Int32
+	var lastModel string
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			writeJSON(t, w, http.StatusTooManyRequests, map[string]any{"error": map[string]any{"message": "slow down"}})
+			return
+		}
+		lastModel = body.Model
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "ok"}}}},
+		})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{"query": "q", "model": modelFull, "reasoning_effort": "high"})
+	// The first search is retried past the 429 at the requested settings.
+	if first, err := runWebSearch(context.Background(), "k", base, wa); err != nil || first.Degraded != nil {
+		t.Fatalf("first search = %+v, %v", first, err)
+	}
+	result, err := runWebSearch(context.Background(), "k", base, wa)
+	if err != nil {
+		t.Fatalf("second search: %v", err)
+	}
+	if lastModel != modelMini || result.Effort != "medium" || result.RequestedModel != modelFull || result.RequestedEffort != "high" {
+		t.Errorf("sent model %s, result %+v", lastModel, result)
+	}
+	if len(result.Degraded) != 2 {
+		t.Errorf("degraded = %q, want the effort and model notes", result.Degraded)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Doctor check outcomes. Only a failure makes "answer doctor" exit non-zero.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+)
+
+// maxClockSkew is the clock difference to the provider that doctor warns
+// about; JWT expiry and TLS certificate checks start failing around it.
+const maxClockSkew = time.Minute
+
+// doctorCheck is one line of the doctor report.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// doctorReport is what "answer doctor" prints: enough about the setup to
+// attach to a support request, without the API key.
+type doctorReport struct {
+	Version  string        `json:"version"`
+	Go       string        `json:"go"`
+	Platform string        `json:"platform"`
+	Provider string        `json:"provider"`
+	Endpoint string        `json:"endpoint"`
+	Model    string        `json:"model"`
+	Key      string        `json:"key"`
+	Proxy    string        `json:"proxy"`
+	Checks   []doctorCheck `json:"checks"`
+}
+
+func (r *doctorReport) add(name, status, detail string) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Detail: detail})
+}
+
+func (r *doctorReport) failed() bool {
+	return slices.ContainsFunc(r.Checks, func(c doctorCheck) bool { return c.Status == doctorFail })
+}
+
+// runDoctor implements "answer doctor": it checks the configuration, the
+// local paths and the provider connection, and prints a report.
+func runDoctor(args []string) {
+	if err := selectProfile(args); err != nil {
+		fail(2, err.Error())
+	}
+	envCfg, cfgErr := loadEnvConfig()
+
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.String("profile", activeProfile.Name, "check a named profile (env PROFILE)")
+	baseURL := fs.String("base", cmp.Or(activeProfile.BaseURL, defaultBaseURL), "API endpoint")
+	model := fs.String("model", cmp.Or(envCfg.Model, defaultModel), "model to look for (env MODEL)")
+	providerName := fs.String("provider", envCfg.Provider, "backend provider: "+strings.Join(providerNames(), ", ")+" (env PROVIDER)")
+	timeout := fs.Duration("timeout", 15*time.Second, "timeout of the provider check")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer doctor [flags]\n\nChecks configuration, local paths, network, API key, model and clock, and prints a report for support requests.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	pr, err := lookupProvider(*providerName)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	r := &doctorReport{
+		Version:  serverVersion,
+		Go:       runtime.Version(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		Provider: pr.name,
+		Model:    pr.modelFor(*model),
+	}
+	switch {
+	case cfgErr != nil:
+		r.add("config", doctorFail, cfgErr.Error())
+	default:
+		if err := applyRuntimeConfig(envCfg); err != nil {
+			r.add("config", doctorFail, err.Error())
+		} else {
+			r.add("config", doctorOK, "environment and config files parse")
+		}
+	}
+	checkProfilesFile(r)
+	checkPaths(r, envCfg)
+
+	endpoint := pr.endpointFor(resolveBaseURL(*baseURL))
+	key := pr.keyFor(envCfg.APIKey)
+	r.Endpoint, r.Key = endpoint, maskKey(key)
+	r.Proxy = proxyFor(endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	checkUpstream(ctx, r, pr, endpoint, key)
+
+	if *asJSON {
+		raw, _ := json.MarshalIndent(r, "", "  ") //nolint:errcheck
+		fmt.Println(string(raw))
+	} else {
+		printDoctorReport(os.Stdout, r)
+	}
+	if r.failed() {
+		os.Exit(1)
+	}
+}
+
+func printDoctorReport(w io.Writer, r *doctorReport) {
+	fmt.Fprintf(w, "answer %s (%s, %s)\n", r.Version, r.Go, r.Platform)
+	fmt.Fprintf(w, "provider %s, model %s\nendpoint %s\nkey      %s\nproxy    %s\n\n", r.Provider, r.Model, cmp.Or(r.Endpoint, "-"), r.Key, r.Proxy)
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "%-4s  %-13s %s\n", c.Status, c.Name, c.Detail)
+	}
+}
+
+// checkProfilesFile parses the profiles file when there is one; selecting a
+// profile only reads it when one is named.
+func checkProfilesFile(r *doctorReport) {
+	path, err := profilesPath()
+	if err != nil {
+		r.add("profiles", doctorWarn, err.Error())
+		return
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		r.add("profiles", doctorSkip, "no profiles file")
+		return
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		r.add("profiles", doctorFail, err.Error())
+		return
+	}
+	detail := fmt.Sprintf("%d profiles in %s", len(profiles), path)
+	if activeProfile.Name != "" {
+		detail += ", using " + activeProfile.Name
+	}
+	r.add("profiles", doctorOK, detail)
+}
+
+// checkPaths checks that the directories the configuration writes to can be
+// written. Those of disabled features are skipped.
+func checkPaths(r *doctorReport, envCfg EnvConfig) {
+	pathOf := func(f func() (string, error)) string {
+		p, err := f()
+		if err != nil {
+			return ""
+		}
+		return p
+	}
+	for _, c := range []struct {
+		name    string
+		dir     string
+		enabled bool
+	}{
+		{"cache dir", envCfg.CacheDir, envCfg.CacheDir != ""},
+		{"history", filepath.Dir(pathOf(historyPath)), envCfg.History},
+		{"runs dir", pathOf(runsDir), envCfg.RunArtifacts},
+		{"compliance", filepath.Dir(envCfg.ComplianceLog), envCfg.ComplianceLog != ""},
+		{"sessions dir", pathOf(sessionsDir), true},
+		{"state", filepath.Dir(pathOf(lastResponsePath)), true},
+	} {
+		switch {
+		case !c.enabled:
+			r.add(c.name, doctorSkip, "not enabled")
+		case c.dir == "" || c.dir == ".":
+			r.add(c.name, doctorWarn, "cannot locate the directory")
+		default:
+			if err := checkWritable(c.dir); err != nil {
+				r.add(c.name, doctorFail, err.Error())
+			} else {
+				r.add(c.name, doctorOK, c.dir+" is writable")
+			}
+		}
+	}
+}
+
+// checkWritable creates dir if needed and writes and removes a file in it.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return err
+	}
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
+
+// checkUpstream lists the provider's models with the configured key: one
+// cheap authenticated call that shows whether the endpoint is reachable,
+// the key is accepted and the model is available, and whose Date header
+// gives the clock skew.
+func checkUpstream(ctx context.Context, r *doctorReport, pr *provider, endpoint, key string) {
+	if pr.name == providerOllama {
+		models, err := listOllamaModels(ctx, os.Getenv("OLLAMA_HOST"))
+		if err != nil {
+			r.add("network", doctorFail, err.Error())
+			return
+		}
+		r.add("network", doctorOK, "Ollama server reachable")
+		r.add("api key", doctorSkip, "Ollama needs no key")
+		model := r.Model
+		if slices.ContainsFunc(models, func(m ollamaModelInfo) bool { return m.Name == model || strings.TrimSuffix(m.Name, ":latest") == model }) {
+			r.add("model", doctorOK, model+" is installed")
+		} else {
+			r.add("model", doctorFail, model+" is not installed; run ollama pull "+model)
+		}
+		return
+	}
+	url := modelsURL(pr, endpoint)
+	if url == "" {
+		r.add("network", doctorSkip, "no models endpoint to check for "+pr.name)
+		return
+	}
+	if key == "" && pr.keyEnv != "" {
+		r.add("api key", doctorFail, pr.keyEnv+" is not set")
+		return
+	}
+
+	p := CallAPIParams{APIKey: key, keyHeader: pr.keyHeader, RequestID: newRequestID()}
+	req, err := newAuthRequest(ctx, http.MethodGet, url, nil, p)
+	if err != nil {
+		r.add("network", doctorFail, err.Error())
+		return
+	}
+	if pr.name == providerAnthropic {
+		req.Header.Set("anthropic-version", anthropicVersion)
+	}
+	started := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		r.add("network", doctorFail, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	received := time.Now()
+	r.add("network", doctorOK, fmt.Sprintf("%s answered in %s", req.URL.Host, received.Sub(started).Round(time.Millisecond)))
+	checkClock(r, resp.Header.Get("Date"), started.Add(received.Sub(started)/2))
+
+	body, err := readAPIResponse(resp)
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		r.add("api key", doctorFail, "rejected: "+err.Error())
+		return
+	case err != nil:
+		r.add("api key", doctorWarn, "could not verify: "+err.Error())
+		return
+	}
+	r.add("api key", doctorOK, "accepted")
+	ids, err := parseModelIDs(body)
+	switch {
+	case err != nil:
+		r.add("model", doctorWarn, "could not read the model list: "+err.Error())
+	case slices.Contains(ids, r.Model):
+		r.add("model", doctorOK, r.Model+" is available")
+	default:
+		r.add("model", doctorWarn, fmt.Sprintf("%s is not among the %d models listed for this key", r.Model, len(ids)))
+	}
+}
+
+// checkClock compares the server's Date header with the local time at the
+// middle of the round trip.
+func checkClock(r *doctorReport, date string, local time.Time) {
+	server, err := http.ParseTime(date)
+	if err != nil {
+		r.add("clock", doctorSkip, "the provider sent no Date header")
+		return
+	}
+	// Date has whole seconds, so smaller differences mean nothing.
+	skew := local.Sub(server).Round(time.Second)
+	if skew.Abs() > maxClockSkew {
+		r.add("clock", doctorWarn, fmt.Sprintf("local clock is %s off the provider's; sync it (NTP)", skew))
+		return
+	}
+	r.add("clock", doctorOK, fmt.Sprintf("within %s of the provider", max(skew.Abs(), time.Second)))
+}
+
+// modelsURL returns the model list endpoint next to the provider's
+// endpoint, or "" for providers without one.
+func modelsURL(pr *provider, endpoint string) string {
+	switch pr.name {
+	case providerGemini:
+		return geminiModelsURL + "?pageSize=1000"
+	case providerAzure, providerPerplexity:
+		return ""
+	}
+	for _, suffix := range []string{"/responses", "/chat/completions", "/messages"} {
+		if base, ok := strings.CutSuffix(endpoint, suffix); ok {
+			if pr.name == providerAnthropic {
+				return base + "/models?limit=1000"
+			}
+			return base + "/models"
+		}
+	}
+	return ""
+}
+
+// parseModelIDs reads a model list: OpenAI-style data[].id, or Gemini's
+// models[].name.
+func parseModelIDs(body []byte) ([]string, error) {
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(list.Data)+len(list.Models))
+	for _, m := range list.Data {
+		ids = append(ids, m.ID)
+	}
+	for _, m := range list.Models {
+		ids = append(ids, strings.TrimPrefix(m.Name, "models/"))
+	}
+	return ids, nil
+}
+
+// maskKey shows just enough of key to tell keys apart.
+func maskKey(key string) string {
+	if key == "" {
+		return "(none)"
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + "..." + key[len(key)-4:]
+}
+
+// proxyFor describes the proxy used to reach endpoint.
+func proxyFor(endpoint string) string {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "-"
+	}
+	u, err := http.ProxyFromEnvironment(req)
+	switch {
+	case err != nil:
+		return "invalid proxy setting: " + err.Error()
+	case u == nil:
+		return "none"
+	}
+	u.User = nil
+	return u.String()
+}
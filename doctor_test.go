@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckUpstream(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("path = %s, want /v1/models", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer good" {
+			writeJSON(t, w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"message": "Incorrect API key provided"}})
+			return
+		}
+		w.Header().Set("Date", time.Now().Add(-3*time.Minute).UTC().Format(http.TimeFormat))
+		writeJSON(t, w, http.StatusOK, map[string]any{"data": []map[string]any{{"id": "gpt-5.4"}, {"id": "gpt-5.4-mini"}}})
+	})
+	pr := providers[providerOpenAI]
+	statuses := func(r *doctorReport) map[string]string {
+		m := make(map[string]string)
+		for _, c := range r.Checks {
+			m[c.Name] = c.Status
+		}
+		return m
+	}
+
+	r := &doctorReport{Model: "gpt-5.4-mini"}
+	checkUpstream(context.Background(), r, pr, base+"/v1/responses", "good")
+	got := statuses(r)
+	if got["network"] != doctorOK || got["api key"] != doctorOK || got["model"] != doctorOK || got["clock"] != doctorWarn {
+		t.Errorf("checks = %+v", r.Checks)
+	}
+
+	r = &doctorReport{Model: "gpt-9"}
+	checkUpstream(context.Background(), r, pr, base+"/v1/responses", "good")
+	if got := statuses(r); got["model"] != doctorWarn {
+		t.Errorf("unlisted model: checks = %+v", r.Checks)
+	}
+
+	r = &doctorReport{Model: "gpt-5.4-mini"}
+	checkUpstream(context.Background(), r, pr, base+"/v1/responses", "bad")
+	if got := statuses(r); got["api key"] != doctorFail || !r.failed() {
+		t.Errorf("bad key: checks = %+v", r.Checks)
+	}
+
+	r = &doctorReport{}
+	checkUpstream(context.Background(), r, pr, base+"/v1/responses", "")
+	if got := statuses(r); got["api key"] != doctorFail {
+		t.Errorf("no key: checks = %+v", r.Checks)
+	}
+}
+
+func TestModelsURL(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]string{
+		providerOpenAI:     "https://api.openai.com/v1/models",
+		"deepseek":         "https://api.deepseek.com/models",
+		providerAnthropic:  "https://api.anthropic.com/v1/models?limit=1000",
+		providerPerplexity: "",
+	} {
+		pr := providers[name]
+		if got := modelsURL(pr, pr.defaultURL); got != want {
+			t.Errorf("modelsURL(%s) = %q, want %q", name, got, want)
+		}
+	}
+	if got := maskKey("sk-proj-abcdefgh1234"); got != "sk-...1234" {
+		t.Errorf("maskKey = %q", got)
+	}
+}
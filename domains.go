@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Reputation tiers of a cited domain.
+const (
+	reputationTrusted = "trusted"
+	reputationNeutral = "neutral"
+	reputationLow     = "low"
+	reputationBlocked = "blocked"
+)
+
+// defaultReputation is the shipped reputation list: primary sources,
+// standards bodies and wire services rank as trusted, user-generated and
+// content-farm sites as low, and sites that only republish scraped
+// Q&A pages are blocked. An entry starting with "." matches every domain
+// under that suffix. User overrides (see domainOverrides) win.
+var defaultReputation = map[string]string{
+	".gov":           reputationTrusted,
+	".edu":           reputationTrusted,
+	".mil":           reputationTrusted,
+	".int":           reputationTrusted,
+	".gov.uk":        reputationTrusted,
+	".ac.uk":         reputationTrusted,
+	"europa.eu":      reputationTrusted,
+	"un.org":         reputationTrusted,
+	"oecd.org":       reputationTrusted,
+	"worldbank.org":  reputationTrusted,
+	"imf.org":        reputationTrusted,
+	"nature.com":     reputationTrusted,
+	"science.org":    reputationTrusted,
+	"thelancet.com":  reputationTrusted,
+	"nejm.org":       reputationTrusted,
+	"bmj.com":        reputationTrusted,
+	"cell.com":       reputationTrusted,
+	"pnas.org":       reputationTrusted,
+	"arxiv.org":      reputationTrusted,
+	"doi.org":        reputationTrusted,
+	"acm.org":        reputationTrusted,
+	"ieee.org":       reputationTrusted,
+	"ietf.org":       reputationTrusted,
+	"rfc-editor.org": reputationTrusted,
+	"w3.org":         reputationTrusted,
+	"iso.org":        reputationTrusted,
+	"reuters.com":    reputationTrusted,
+	"apnews.com":     reputationTrusted,
+	"pinterest.com":  reputationLow,
+	"quora.com":      reputationLow,
+	"answers.com":    reputationLow,
+	"ehow.com":       reputationLow,
+	"wikihow.com":    reputationLow,
+	"scribd.com":     reputationLow,
+	"coursehero.com": reputationLow,
+	"chegg.com":      reputationLow,
+
+	// Mirrors of scraped Stack Exchange answers.
+	"stackoom.com":      reputationBlocked,
+	"programmerall.com": reputationBlocked,
+	"newbedev.com":      reputationBlocked,
+	"itectec.com":       reputationBlocked,
+	"stackovernet.com":  reputationBlocked,
+	"coderoad.ru":       reputationBlocked,
+}
+
+// domainOverrides are the user's allow and block lists, kept in
+// DOMAINS_FILE and maintained with "answer domains". Allowed domains rank
+// as trusted; blocked ones are dropped from citations and search filters.
+type domainOverrides struct {
+	Allow []string `json:"allow,omitempty"`
+	Block []string `json:"block,omitempty"`
+}
+
+// activeDomains holds the user overrides; loaded at startup.
+var activeDomains *domainOverrides
+
+// domainsPath returns DOMAINS_FILE, or domains.json under
+// $XDG_CONFIG_HOME (default ~/.config)/websearch.
+func domainsPath() (string, error) {
+	if p := os.Getenv("DOMAINS_FILE"); p != "" {
+		return p, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", fmt.Errorf("locate domains file: %w", err)
+	}
+	return filepath.Join(dir, "domains.json"), nil
+}
+
+// configDir returns the directory of the user's config files,
+// $XDG_CONFIG_HOME (default ~/.config)/websearch.
+func configDir() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "websearch"), nil
+}
+
+// loadDomainOverrides reads the overrides at path; a missing file means
+// none.
+func loadDomainOverrides(path string) (*domainOverrides, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &domainOverrides{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read domains file: %w", err)
+	}
+	var o domainOverrides
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, fmt.Errorf("parse domains file %s: %w", path, err)
+	}
+	o.Allow, o.Block = normalizeDomains(o.Allow), normalizeDomains(o.Block)
+	return &o, nil
+}
+
+// save writes the overrides to path, creating its directory.
+func (o *domainOverrides) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	raw, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write domains file: %w", err)
+	}
+	return nil
+}
+
+// set moves domains onto the allow or block list; with neither, it removes
+// their overrides.
+func (o *domainOverrides) set(domains []string, allow, block bool) {
+	domains = normalizeDomains(domains)
+	drop := func(list []string) []string {
+		return slices.DeleteFunc(list, func(d string) bool { return slices.Contains(domains, d) })
+	}
+	o.Allow, o.Block = drop(o.Allow), drop(o.Block)
+	switch {
+	case allow:
+		o.Allow = append(o.Allow, domains...)
+	case block:
+		o.Block = append(o.Block, domains...)
+	}
+	sort.Strings(o.Allow)
+	sort.Strings(o.Block)
+}
+
+// reputation ranks host: the user's overrides first, then the longest
+// matching entry of defaultReputation, else neutral. A nil receiver uses
+// the defaults alone.
+func (o *domainOverrides) reputation(host string) string {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	if o != nil {
+		for _, d := range o.Block {
+			if domainMatches(host, d) {
+				return reputationBlocked
+			}
+		}
+		for _, d := range o.Allow {
+			if domainMatches(host, d) {
+				return reputationTrusted
+			}
+		}
+	}
+	tier, longest := reputationNeutral, 0
+	for d, t := range defaultReputation {
+		if domainMatches(host, d) && len(d) > longest {
+			tier, longest = t, len(d)
+		}
+	}
+	return tier
+}
+
+// domainMatches reports whether host is domain or one of its subdomains; a
+// domain starting with "." matches by suffix only.
+func domainMatches(host, domain string) bool {
+	if strings.HasPrefix(domain, ".") {
+		return strings.HasSuffix(host, domain)
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// scoreCitations labels each citation of answer with its domain's
+// reputation and drops those from blocked domains, with their markers in
+// answer (see withoutCitations).
+func (o *domainOverrides) scoreCitations(answer string, citations []Citation) (string, []Citation) {
+	scored := make([]Citation, len(citations))
+	for i, c := range citations {
+		c.Reputation = o.reputation(citationHost(c.URL))
+		scored[i] = c
+	}
+	return withoutCitations(answer, scored, func(c Citation) bool { return c.Reputation == reputationBlocked })
+}
+
+// withoutDomains drops the citations of pages under domains, the ones a
+// search excluded, and their markers in answer.
+func withoutDomains(answer string, citations []Citation, domains []string) (string, []Citation) {
+	if len(domains) == 0 {
+		return answer, citations
+	}
+	return withoutCitations(answer, citations, func(c Citation) bool {
+		return underDomains(citationHost(c.URL), domains)
+	})
+}
+
+// underDomains reports whether host is one of domains or below one.
+func underDomains(host string, domains []string) bool {
+	return slices.ContainsFunc(domains, func(d string) bool { return domainMatches(host, d) })
+}
+
+// withoutBlocked drops blocked domains from a search filter.
+func (o *domainOverrides) withoutBlocked(domains []string) []string {
+	return slices.DeleteFunc(slices.Clone(domains), func(d string) bool { return o.reputation(d) == reputationBlocked })
+}
+
+// citationHost returns the host name of a cited URL.
+func citationHost(rawURL string) string {
+	if d := normalizeDomains([]string{rawURL}); len(d) > 0 {
+		host, _, _ := strings.Cut(d[0], ":")
+		return host
+	}
+	return ""
+}
+
+// runDomains implements "answer domains": list the reputation list and
+// maintain the user's allow and block overrides.
+func runDomains(args []string) {
+	fs := flag.NewFlagSet("domains", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the list as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer domains [list | allow <domain...> | block <domain...> | remove <domain...> | check <domain>] [-json]")
+		fs.PrintDefaults()
+	}
+	cmd := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	path, err := domainsPath()
+	if err != nil {
+		fail(2, err.Error())
+	}
+	o, err := loadDomainOverrides(path)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	switch cmd {
+	case "list":
+		printDomainList(o, *asJSON)
+	case "check":
+		if len(positional) == 0 {
+			fail(2, "usage: answer domains check <domain>")
+		}
+		for _, d := range normalizeDomains(positional) {
+			fmt.Printf("%s\t%s\n", d, o.reputation(d))
+		}
+	case "allow", "block", "remove":
+		if len(normalizeDomains(positional)) == 0 {
+			fail(2, "usage: answer domains "+cmd+" <domain...>")
+		}
+		o.set(positional, cmd == "allow", cmd == "block")
+		if err := o.save(path); err != nil {
+			fail(1, err.Error())
+		}
+		fmt.Printf("Saved %s (%d allowed, %d blocked)\n", path, len(o.Allow), len(o.Block))
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// printDomainList prints the user overrides followed by the defaults they
+// do not override.
+func printDomainList(o *domainOverrides, asJSON bool) {
+	type entry struct {
+		Domain     string `json:"domain"`
+		Reputation string `json:"reputation"`
+		Source     string `json:"source"`
+	}
+	var entries []entry
+	for _, d := range o.Block {
+		entries = append(entries, entry{d, reputationBlocked, "user"})
+	}
+	for _, d := range o.Allow {
+		entries = append(entries, entry{d, reputationTrusted, "user"})
+	}
+	defaults := make([]string, 0, len(defaultReputation))
+	for d := range defaultReputation {
+		if !slices.Contains(o.Allow, d) && !slices.Contains(o.Block, d) {
+			defaults = append(defaults, d)
+		}
+	}
+	sort.Slice(defaults, func(i, j int) bool {
+		if a, b := defaultReputation[defaults[i]], defaultReputation[defaults[j]]; a != b {
+			return a > b // trusted, low, then blocked
+		}
+		return defaults[i] < defaults[j]
+	})
+	for _, d := range defaults {
+		entries = append(entries, entry{d, defaultReputation[d], "default"})
+	}
+
+	if asJSON {
+		raw, _ := json.MarshalIndent(entries, "", "  ") //nolint:errcheck
+		fmt.Println(string(raw))
+		return
+	}
+	for _, e := range entries {
+		fmt.Printf("%-20s %-8s %s\n", e.Domain, e.Reputation, e.Source)
+	}
+}
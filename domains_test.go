@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDomainReputation(t *testing.T) {
+	t.Parallel()
+
+	var defaults *domainOverrides
+	for host, want := range map[string]string{
+		"www.nih.gov":          reputationTrusted,
+		"data.europa.eu":       reputationTrusted,
+		"arxiv.org":            reputationTrusted,
+		"notarxiv.org":         reputationNeutral,
+		"www.pinterest.com":    reputationLow,
+		"example.com":          reputationNeutral,
+		"research.example.edu": reputationTrusted,
+		"stackoom.com":         reputationBlocked,
+	} {
+		if got := defaults.reputation(host); got != want {
+			t.Errorf("reputation(%q) = %q, want %q", host, got, want)
+		}
+	}
+
+	o := &domainOverrides{}
+	o.set([]string{"https://www.Quora.com/foo", "example.com"}, true, false)
+	o.set([]string{"spam.example.com", "pinterest.com"}, false, true)
+	if !slices.Equal(o.Allow, []string{"example.com", "quora.com"}) || !slices.Equal(o.Block, []string{"pinterest.com", "spam.example.com"}) {
+		t.Fatalf("overrides = %+v", o)
+	}
+	for host, want := range map[string]string{
+		"quora.com":            reputationTrusted,
+		"www.example.com":      reputationTrusted,
+		"cdn.spam.example.com": reputationBlocked,
+		"pinterest.com":        reputationBlocked,
+	} {
+		if got := o.reputation(host); got != want {
+			t.Errorf("overridden reputation(%q) = %q, want %q", host, got, want)
+		}
+	}
+
+	// Blocking an allowed domain moves it; remove clears it.
+	o.set([]string{"quora.com"}, false, true)
+	o.set([]string{"pinterest.com"}, false, false)
+	if !slices.Equal(o.Allow, []string{"example.com"}) || !slices.Equal(o.Block, []string{"quora.com", "spam.example.com"}) {
+		t.Errorf("after block and remove = %+v", o)
+	}
+}
+
+func TestDomainOverrides_SaveLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "websearch", "domains.json")
+	o, err := loadDomainOverrides(path)
+	if err != nil || len(o.Allow)+len(o.Block) != 0 {
+		t.Fatalf("missing file = %+v, %v", o, err)
+	}
+	o.set([]string{"nature.com"}, true, false)
+	o.set([]string{"content-farm.example"}, false, true)
+	if err := o.save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := loadDomainOverrides(path)
+	if err != nil || !slices.Equal(got.Allow, o.Allow) || !slices.Equal(got.Block, o.Block) {
+		t.Errorf("reloaded = %+v, %v", got, err)
+	}
+
+	answer := "Nature says so [1]. A farm agrees [2]. So does Quora [3]."
+	span := func(marker string) *textSpan {
+		i, j := strings.Index(answer, marker), strings.Index(answer, marker)+len(marker)
+		return &textSpan{StartByte: i, EndByte: j, StartRune: i, EndRune: j, StartUTF16: i, EndUTF16: j}
+	}
+	text, cited := got.scoreCitations(answer, []Citation{
+		{URL: "https://www.nature.com/articles/x", Span: span("[1]")},
+		{URL: "https://content-farm.example/top-10", Span: span("[2]")},
+		{URL: "https://quora.com/q", Span: span("[3]")},
+	})
+	if len(cited) != 2 || cited[0].Reputation != reputationTrusted || cited[1].Reputation != reputationLow {
+		t.Errorf("scoreCitations = %+v", cited)
+	}
+	if want := "Nature says so [1]. A farm agrees. So does Quora [3]."; text != want {
+		t.Errorf("answer = %q, want %q", text, want)
+	}
+	if s := cited[1].Span; s == nil || text[s.StartByte:s.EndByte] != "[3]" || s.StartRune != strings.Index(text, "[3]") {
+		t.Errorf("Quora span = %+v, not shifted onto its marker", s)
+	}
+	if d := got.withoutBlocked([]string{"content-farm.example", "nature.com"}); !slices.Equal(d, []string{"nature.com"}) {
+		t.Errorf("withoutBlocked = %q", d)
+	}
+}
+
+func TestCallAPI_AllDomainsBlocked(t *testing.T) {
+	old := activeDomains
+	activeDomains = &domainOverrides{Block: []string{"spam.example"}}
+	t.Cleanup(func() { activeDomains = old })
+
+	_, err := CallAPI(t.Context(), CallAPIParams{APIKey: "k", BaseURL: "http://127.0.0.1:1", Query: "q", AllowedDomains: []string{"spam.example"}})
+	if !errors.Is(err, ErrDomainsBlocked) {
+		t.Errorf("err = %v, want ErrDomainsBlocked", err)
+	}
+}
+
+func TestRunWebSearch_ExcludedDomains(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if f := body.Tools[0].Filters; f == nil || !slices.Equal(f.AllowedDomains, []string{"arxiv.org"}) {
+			t.Errorf("filters = %+v, want only the allowed domain", f)
+		}
+		if !strings.Contains(body.Instructions, "reddit.com") {
+			t.Errorf("instructions = %q, want the excluded domain", body.Instructions)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+				"type": "output_text", "text": "Paper [1] and thread [2].",
+				"annotations": []map[string]any{
+					{"type": "url_citation", "url": "https://arxiv.org/abs/1", "title": "Paper", "start_index": 6, "end_index": 9},
+					{"type": "url_citation", "url": "https://old.reddit.com/r/x", "title": "Thread", "start_index": 21, "end_index": 24},
+				},
+			}}}},
+		})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{"query": "q", "domains": []any{"arxiv.org", "-https://www.Reddit.com/r", "-"}})
+	if !slices.Equal(wa.domains, []string{"arxiv.org", "-reddit.com"}) {
+		t.Fatalf("domains = %q", wa.domains)
+	}
+	result, err := runWebSearch(context.Background(), "k", base, wa)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Citations) != 1 || result.Citations[0].URL != "https://arxiv.org/abs/1" {
+		t.Errorf("citations = %+v, want the excluded domain dropped", result.Citations)
+	}
+	if result.Answer != "Paper [1] and thread." {
+		t.Errorf("answer = %q, want the excluded marker removed", result.Answer)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	minEnsembleProviders = 2
+	maxEnsembleProviders = 3
+)
+
+// ensembleMergePrompt asks the synthesis model to combine the provider
+// answers and call out where they disagree.
+const ensembleMergePrompt = `You are given answers to the same question from several AI search providers.
+Write one merged answer that keeps every claim the answers agree on.
+Then add a section titled "Disagreements" listing each point where the answers conflict,
+naming which provider said what. Write "None" in that section if they agree.
+
+Question: %s
+
+%s`
+
+// EnsembleResult is the combined outcome of an ensemble query.
+type EnsembleResult struct {
+	Query     string            `json:"query"`
+	Providers []string          `json:"providers"`
+	Answers   []WebSearchResult `json:"answers"`
+	// Merged is the synthesis with disagreements highlighted; only set when
+	// merging was requested and at least two providers answered.
+	Merged string `json:"merged,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ensembleProviders validates a provider list for an ensemble query. An empty
+// list selects every provider with a configured key, up to the maximum.
+func ensembleProviders(requested []string, fallbackKey string) ([]string, error) {
+	if len(requested) == 0 {
+		for _, name := range providerNames() {
+			if providers[name].keyFor(fallbackKey) != "" && len(requested) < maxEnsembleProviders {
+				requested = append(requested, name)
+			}
+		}
+	}
+
+	var out []string
+	seen := make(map[string]bool)
+	for _, name := range requested {
+		pr, err := lookupProvider(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if seen[pr.name] {
+			continue
+		}
+		if pr.keyEnv != "" && pr.keyFor(fallbackKey) == "" {
+			return nil, fmt.Errorf("provider %s has no API key (set %s)", pr.name, pr.keyEnv)
+		}
+		seen[pr.name] = true
+		out = append(out, pr.name)
+	}
+	if len(out) < minEnsembleProviders || len(out) > maxEnsembleProviders {
+		return nil, fmt.Errorf("ensemble needs %d-%d distinct providers with API keys, got %d", minEnsembleProviders, maxEnsembleProviders, len(out))
+	}
+	return out, nil
+}
+
+// runEnsemble sends the same question to each provider concurrently and, when
+// merge is set, synthesizes the answers with the default provider.
+func runEnsemble(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, names []string, merge bool) (*EnsembleResult, error) {
+	result := &EnsembleResult{Query: wa.query, Providers: names, Answers: make([]WebSearchResult, len(names))}
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pa := wa
+			pa.provider = name
+			// Identical ensembles, or a plain search of the same question with
+			// one of the providers, share a single upstream call.
+			r, err := coalescedWebSearch(ctx, apiKey, baseURL, pa, cacheKeyFor(pa))
+			if err != nil {
+				r = &WebSearchResult{Query: wa.query, Error: err.Error(), RequestedModel: wa.model, RequestedEffort: wa.effort}
+			}
+			result.Answers[i] = *r
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !merge {
+		return result, nil
+	}
+
+	var sb strings.Builder
+	answered := 0
+	for i, r := range result.Answers {
+		if !r.Success {
+			continue
+		}
+		answered++
+		fmt.Fprintf(&sb, "--- Answer from %s ---\n%s\n\n", names[i], r.Answer)
+	}
+	if answered < minEnsembleProviders {
+		result.Error = fmt.Sprintf("only %d provider(s) answered; nothing to merge", answered)
+		return result, nil
+	}
+
+	merged, err := synthesize(ctx, apiKey, baseURL, wa, fmt.Sprintf(ensembleMergePrompt, wa.query, sb.String()))
+	if err != nil {
+		result.Error = "merge failed: " + err.Error()
+		return result, nil
+	}
+	result.Merged = merged
+	return result, nil
+}
+
+// synthesize runs a follow-up prompt without web search on the default
+// provider and returns the processed answer text.
+func synthesize(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, prompt string) (string, error) {
+	return synthesizeOn(ctx, defaultProvider, apiKey, baseURL, wa, prompt)
+}
+
+// synthesizeOn is synthesize on the named provider, for passes over an
+// answer that should stay with the backend that gave it.
+func synthesizeOn(ctx context.Context, provider, apiKey, baseURL string, wa webSearchArgs, prompt string) (string, error) {
+	pr, err := lookupProvider(provider)
+	if err != nil {
+		return "", err
+	}
+	apiResp, err := CallAPI(ctx, CallAPIParams{
+		Provider:  pr.name,
+		APIKey:    pr.keyFor(apiKey),
+		BaseURL:   pr.endpointFor(baseURL),
+		Query:     prompt,
+		Model:     wa.model,
+		Effort:    wa.effort,
+		Verbosity: wa.verbosity,
+		Timeout:   getTimeoutForEffort(wa.effort),
+		Retry:     retryPolicy,
+	})
+	if err != nil {
+		return "", err
+	}
+	text := answerPipeline.apply(ExtractAnswer(apiResp).Text)
+	if text == "" {
+		return "", fmt.Errorf("no answer found in response")
+	}
+	return text, nil
+}
+
+// newGptEnsembleTool builds the gpt_ensemble tool definition.
+func newGptEnsembleTool() mcp.Tool {
+	return mcp.NewTool("gpt_ensemble",
+		mcp.WithDescription("Ask the same question to 2-3 providers concurrently and return the answers side by side, "+
+			"or a merged answer with disagreements highlighted"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The search query or question to ask"),
+		),
+		mcp.WithArray("providers",
+			mcp.Description("Optional: 2-3 providers to ask (default: every provider with a configured key, up to 3)"),
+			mcp.WithStringItems(mcp.Enum(providerNames()...)),
+		),
+		mcp.WithBoolean("merge",
+			mcp.DefaultBool(false),
+			mcp.Description("Merge the answers into one, listing disagreements (default: side by side)"),
+		),
+		mcp.WithString("reasoning_effort",
+			mcp.DefaultString(toolEffort),
+			mcp.Description("Reasoning effort level: none, low, medium, high, or xhigh"),
+			mcp.Enum("none", "low", "medium", "high", "xhigh"),
+		),
+		mcp.WithBoolean("web_search",
+			mcp.DefaultBool(true),
+			mcp.Description("Use web search where the provider supports it (default: true)"),
+		),
+		mcp.WithSchemaAdditionalProperties(false),
+		mcp.WithOutputSchema[EnsembleResult](),
+	)
+}
+
+// ensembleHandler returns the handler for the gpt_ensemble tool.
+func ensembleHandler(apiKey, baseURL string) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := request.RequireString("query")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		names, err := ensembleProviders(request.GetStringSlice("providers", nil), apiKey)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		wa := extractWebSearchArgs(map[string]interface{}{
+			"query":            query,
+			"reasoning_effort": request.GetString("reasoning_effort", toolEffort),
+			"web_search":       request.GetBool("web_search", true),
+		})
+
+		logToClient(ctx, mcp.LoggingLevelInfo, "ensemble", fmt.Sprintf(
+			"Executing ensemble search: query='%s', providers=%v", truncateForLog(query), names))
+
+		result, err := runEnsemble(ctx, apiKey, baseURL, wa, names, request.GetBool("merge", false))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
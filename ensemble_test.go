@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEnsembleProviders(t *testing.T) {
+	providerKeys = map[string]string{"xai": "xk", "deepseek": "dk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	got, err := ensembleProviders([]string{"openai", " XAI", "openai"}, "ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ",") != "openai,xai" {
+		t.Errorf("providers = %v, want deduplicated openai,xai", got)
+	}
+
+	if got, err := ensembleProviders(nil, ""); err != nil || strings.Join(got, ",") != "deepseek,xai" {
+		t.Errorf("default providers = %v, %v; want those with keys", got, err)
+	}
+	if _, err := ensembleProviders([]string{"openai"}, "ok"); err == nil {
+		t.Error("expected error for a single provider")
+	}
+	if _, err := ensembleProviders([]string{"openai", "mistral"}, "ok"); err == nil {
+		t.Error("expected error for a provider without a key")
+	}
+}
+
+func TestRunEnsemble_Merge(t *testing.T) {
+	var mergePrompt string
+	_, openaiBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		text := "Paris has 2.1 million people."
+		if strings.Contains(body.Input, "Disagreements") {
+			mergePrompt = body.Input
+			if len(body.Tools) != 0 {
+				t.Error("merge pass should not use web search")
+			}
+			text = "Paris has about 2.1 million people.\n\nDisagreements: population figure."
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": text}}}},
+		})
+	})
+	_, deepseekBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":      "ds",
+			"choices": []map[string]any{{"message": map[string]any{"content": "Paris has 2.2 million people."}}},
+		})
+	})
+
+	orig := providers["deepseek"].defaultURL
+	providers["deepseek"].defaultURL = deepseekBase
+	t.Cleanup(func() { providers["deepseek"].defaultURL = orig })
+	providerKeys = map[string]string{"deepseek": "dk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	wa := extractWebSearchArgs(map[string]any{"query": "population of Paris", "reasoning_effort": "low"})
+	result, err := runEnsemble(context.Background(), "ok", openaiBase, wa, []string{"openai", "deepseek"}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Answers) != 2 || result.Answers[0].Provider != "openai" || result.Answers[1].Provider != "deepseek" {
+		t.Fatalf("answers = %+v", result.Answers)
+	}
+	if !strings.Contains(result.Merged, "Disagreements") {
+		t.Errorf("merged = %q", result.Merged)
+	}
+	if !strings.Contains(mergePrompt, "2.1 million") || !strings.Contains(mergePrompt, "2.2 million") {
+		t.Errorf("merge prompt should include both answers, got %q", mergePrompt)
+	}
+}
@@ -1,28 +1,199 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"time"
 )
 
 var (
 	// Configuration errors
 	ErrNoAPIKey = errors.New("OPENAI_API_KEY environment variable is required")
+
+	// ErrOffline is returned instead of making an upstream call while
+	// OFFLINE is enabled; only cached answers can be served.
+	ErrOffline = errors.New("offline mode (OFFLINE=1): upstream API calls are disabled")
+
+	// ErrStreamIncomplete is returned when a response stream ends without a
+	// terminal response.completed / response.incomplete event.
+	ErrStreamIncomplete = errors.New("response stream ended before completion")
+
+	// ErrReasoningOnly marks responses that contain reasoning items but no
+	// message, typically because max_output_tokens ran out mid-reasoning.
+	ErrReasoningOnly = errors.New("response contains only reasoning, no answer")
+
+	// ErrSamplingUnsupported is returned when temperature or top_p is set for
+	// a reasoning model that rejects them.
+	ErrSamplingUnsupported = errors.New("temperature/top_p are not supported by reasoning models unless reasoning effort is none")
+
+	// ErrHistoryNotFound is returned for an unknown history entry ID.
+	ErrHistoryNotFound = errors.New("history entry not found")
+
+	// ErrStoreLocked is returned when an encrypted store is opened without
+	// a key.
+	ErrStoreLocked = errors.New("store is encrypted: set STORE_PASSPHRASE or STORE_KEYCHAIN")
+
+	// ErrStoreKey is returned when an encrypted store does not decrypt.
+	ErrStoreKey = errors.New("cannot decrypt store: wrong passphrase or key, or the file is damaged")
+
+	// ErrNoStoreContinuation is returned when a call asks to continue an
+	// earlier response while response storage is off; continuations need
+	// the stored response.
+	ErrNoStoreContinuation = errors.New("previous_response_id cannot be used with store=false (NO_STORE): continuing a conversation needs stored responses")
+
+	// ErrNoLastResponse is returned by -continue when no earlier answer was
+	// recorded.
+	ErrNoLastResponse = errors.New("no earlier answer to continue; ask a question without -continue first")
+
+	// ErrSessionNotFound is returned for an unknown session name.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrDomainsBlocked is returned when every domain a search is restricted
+	// to is on the user's block list ("answer domains block").
+	ErrDomainsBlocked = errors.New("all requested domains are blocked; see answer domains list")
+
+	// ErrNoContinuation is returned when a provider without stored
+	// responses is asked to continue one (previous_response_id).
+	ErrNoContinuation = errors.New("previous_response_id is not supported: the provider keeps no stored responses")
+
+	// ErrNoDomainFilter is returned when a provider's web search cannot be
+	// restricted to the requested domains.
+	ErrNoDomainFilter = errors.New("domains cannot restrict this provider's web search; drop domains or use another provider")
+
+	// ErrNoSecondOpinion is returned when verify would ask the same
+	// provider and model that gave the original answer.
+	ErrNoSecondOpinion = errors.New("verify needs a different provider or model for the second opinion: pass provider or model, or set another provider's API key")
+
+	// ErrAttachDisabled is returned when an MCP client names a server file
+	// while ATTACH_DIR is unset.
+	ErrAttachDisabled = errors.New("server files cannot be attached: set ATTACH_DIR on the server to allow files below it")
+
+	// ErrAttachOutsideRoot is returned for a client-supplied path that does
+	// not resolve to a file below ATTACH_DIR.
+	ErrAttachOutsideRoot = errors.New("path is outside ATTACH_DIR")
+
+	// ErrCallbackPrivate is returned for a webhook callback URL whose host
+	// resolves to a private, loopback or link-local address and is not in
+	// WEBHOOK_ALLOWED_HOSTS.
+	ErrCallbackPrivate = errors.New("callback_url resolves to a private address; list the host in WEBHOOK_ALLOWED_HOSTS to allow it")
+
+	// ErrUnknownProfile is returned when -profile or PROFILE names a profile
+	// the profiles file does not define.
+	ErrUnknownProfile = errors.New("unknown profile")
 )
 
+// maxErrorBodyRunes bounds how much of an upstream error body is repeated in
+// APIError.Error(); the full body stays available in APIError.Body.
+const maxErrorBodyRunes = 2000
+
 // APIError represents an error from the OpenAI API
 type APIError struct {
 	StatusCode int
 	Body       string
+	// Message is the upstream error message when Body is a recognised error
+	// envelope; Error() then shows it instead of the raw body.
+	Message string
+	// RetryAfter is the delay the server asked for via the Retry-After
+	// header (zero when absent).
+	RetryAfter time.Duration
+	// Waited is the total time CallAPI spent backing off between attempts
+	// before giving up.
+	Waited time.Duration
+	// RequestID is the X-Client-Request-Id of the call; UpstreamRequestID
+	// is the provider's own ID from its X-Request-Id (or Request-Id) response
+	// header.
+	RequestID         string
+	UpstreamRequestID string
 }
 
 func (e *APIError) Error() string {
-	return fmt.Sprintf("API error: status=%d body=%s", e.StatusCode, e.Body)
+	msg := fmt.Sprintf("API error: status=%d body=%s", e.StatusCode, truncateRunes(e.Body, maxErrorBodyRunes))
+	if e.Message != "" {
+		msg = fmt.Sprintf("API error: status=%d message=%s", e.StatusCode, truncateRunes(e.Message, maxErrorBodyRunes))
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" retry_after=%s", e.RetryAfter)
+	}
+	if e.Waited > 0 {
+		msg += fmt.Sprintf(" waited=%s", e.Waited)
+	}
+	if e.RequestID != "" {
+		msg += " request_id=" + e.RequestID
+	}
+	if e.UpstreamRequestID != "" {
+		msg += " upstream_request_id=" + e.UpstreamRequestID
+	}
+	return msg
+}
+
+// ReasoningOnlyError reports a reasoning-only response together with the
+// tokens it consumed. It matches ErrReasoningOnly via errors.Is.
+type ReasoningOnlyError struct {
+	Reason          string
+	OutputTokens    int
+	ReasoningTokens int
+}
+
+func (e *ReasoningOnlyError) Error() string {
+	reason := e.Reason
+	if reason == "" {
+		reason = "unknown"
+	}
+	return fmt.Sprintf("%v (reason=%s, output_tokens=%d, reasoning_tokens=%d); raise max_output_tokens or lower reasoning effort",
+		ErrReasoningOnly, reason, e.OutputTokens, e.ReasoningTokens)
+}
+
+func (e *ReasoningOnlyError) Is(target error) bool { return target == ErrReasoningOnly }
+
+// LimitError reports a call that was rejected or held back by a limit: a
+// full or slow call queue (MAX_QUEUED_CALLS, QUEUE_TIMEOUT) or upstream rate
+// limiting. Tool handlers return it as structured content so agents can
+// schedule a retry instead of hammering.
+type LimitError struct {
+	// Reason is queue_full, queue_timeout or rate_limited.
+	Reason string `json:"reason"`
+	Lane   string `json:"lane"`
+	// QueueDepth is the number of calls waiting for a slot; InFlight the
+	// number holding one.
+	QueueDepth int `json:"queue_depth"`
+	InFlight   int `json:"in_flight"`
+	// EstimatedWait is how long a new call would wait for a slot now.
+	EstimatedWait time.Duration `json:"-"`
+	// RetryAfter is when a retry is worth trying.
+	RetryAfter time.Duration `json:"-"`
+	// Message repeats the underlying error.
+	Message string `json:"error"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s (queue_depth=%d, in_flight=%d, estimated_wait=%s, retry_after=%s)",
+		e.Reason, e.Message, e.QueueDepth, e.InFlight, e.EstimatedWait, e.RetryAfter)
+}
+
+// MarshalJSON adds the durations both as text and in whole seconds.
+func (e *LimitError) MarshalJSON() ([]byte, error) {
+	type plain LimitError
+	return json.Marshal(struct {
+		*plain
+		EstimatedWait        string `json:"estimated_wait"`
+		EstimatedWaitSeconds int    `json:"estimated_wait_seconds"`
+		RetryAfter           string `json:"retry_after"`
+		RetryAfterSeconds    int    `json:"retry_after_seconds"`
+	}{
+		plain:                (*plain)(e),
+		EstimatedWait:        e.EstimatedWait.String(),
+		EstimatedWaitSeconds: int(math.Ceil(e.EstimatedWait.Seconds())),
+		RetryAfter:           e.RetryAfter.String(),
+		RetryAfterSeconds:    int(math.Ceil(e.RetryAfter.Seconds())),
+	})
 }
 
 // fail prints to stderr and exits non-zero.
 func fail(code int, msg string) {
 	fmt.Fprintf(os.Stderr, "%s\n", msg)
+	activeRun.finish(errors.New(msg))
 	os.Exit(code)
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// feature names a subsystem that can be switched on or off per deployment
+// (env FEATURES) without a separate build.
+type feature string
+
+const (
+	// featureStreaming lets CallAPI stream responses over SSE when the
+	// caller asks for deltas. When it is off, the answer is delivered as a
+	// single delta once the call completes.
+	featureStreaming feature = "streaming"
+	// featureSemanticCache allows the near-duplicate query cache
+	// (SEMANTIC_CACHE). When it is off, SEMANTIC_CACHE is ignored.
+	featureSemanticCache feature = "semantic_cache"
+)
+
+// featureDefaults lists the known features and whether each is on when
+// FEATURES does not mention it. Experimental features are added here
+// switched off, so they ship dark until a deployment enables them.
+var featureDefaults = map[feature]bool{
+	featureStreaming:     true,
+	featureSemanticCache: true,
+}
+
+// featureSet holds explicit feature settings; features it does not mention
+// keep their default. A nil featureSet has all defaults.
+type featureSet map[feature]bool
+
+// activeFeatures is the feature set; set at startup from FEATURES.
+var activeFeatures featureSet
+
+// parseFeatures parses a comma-separated feature list: "name" switches a
+// feature on and "-name" switches it off. Unknown names are rejected so a
+// typo does not silently leave a feature in its default state.
+func parseFeatures(spec string) (featureSet, error) {
+	fs := featureSet{}
+	for _, item := range strings.Split(spec, ",") {
+		name := strings.TrimSpace(item)
+		if name == "" {
+			continue
+		}
+		on := !strings.HasPrefix(name, "-")
+		f := feature(strings.TrimPrefix(name, "-"))
+		if _, ok := featureDefaults[f]; !ok {
+			return nil, fmt.Errorf("FEATURES: unknown feature %q (supported: %s)", f, strings.Join(featureNames(), ", "))
+		}
+		fs[f] = on
+	}
+	return fs, nil
+}
+
+// enabled reports whether f is on.
+func (fs featureSet) enabled(f feature) bool {
+	if on, ok := fs[f]; ok {
+		return on
+	}
+	return featureDefaults[f]
+}
+
+// enabledNames returns the names of the features that are on, sorted.
+func (fs featureSet) enabledNames() []string {
+	var names []string
+	for f := range featureDefaults {
+		if fs.enabled(f) {
+			names = append(names, string(f))
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func featureNames() []string {
+	names := make([]string, 0, len(featureDefaults))
+	for f := range featureDefaults {
+		names = append(names, string(f))
+	}
+	slices.Sort(names)
+	return names
+}
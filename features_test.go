@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseFeatures(t *testing.T) {
+	t.Parallel()
+
+	fs, err := parseFeatures(" -streaming , semantic_cache,")
+	if err != nil {
+		t.Fatalf("parseFeatures: %v", err)
+	}
+	if fs.enabled(featureStreaming) || !fs.enabled(featureSemanticCache) {
+		t.Errorf("features = %v", fs)
+	}
+	if got := fs.enabledNames(); !slices.Equal(got, []string{"semantic_cache"}) {
+		t.Errorf("enabledNames = %v", got)
+	}
+	var none featureSet
+	if !none.enabled(featureStreaming) {
+		t.Error("nil feature set should use the defaults")
+	}
+	if _, err := parseFeatures("streeming"); err == nil {
+		t.Error("expected error for an unknown feature")
+	}
+}
+
+func TestCallAPI_StreamingFeatureOff(t *testing.T) {
+	activeFeatures = featureSet{featureStreaming: false}
+	t.Cleanup(func() { activeFeatures = nil })
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody requestBody
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if reqBody.Stream {
+			t.Error("request asked for a stream with the streaming feature off")
+		}
+		_, _ = w.Write([]byte(`{"id":"resp_1","output":[{"type":"message","content":[{"type":"output_text","text":"Hello"}]}]}`))
+	})
+
+	var deltas []string
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: 2 * time.Second,
+		OnDelta: func(d string) { deltas = append(deltas, d) },
+	}); err != nil {
+		t.Fatalf("CallAPI: %v", err)
+	}
+	if !slices.Equal(deltas, []string{"Hello"}) {
+		t.Errorf("deltas = %q, want the whole answer once", deltas)
+	}
+}
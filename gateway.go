@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// gatewayPreset describes how an OpenAI-compatible gateway differs from the
+// OpenAI API: where its Responses endpoint lives and which headers it expects.
+type gatewayPreset struct {
+	// defaultURL is used when GATEWAY_URL is unset.
+	defaultURL string
+	// path is appended to the gateway URL to reach the Responses endpoint.
+	path string
+	// headers are sent on every request; values are expanded with os.ExpandEnv
+	// so credentials stay in their own variables.
+	headers map[string]string
+}
+
+// gatewayPresets are the supported GATEWAY values.
+var gatewayPresets = map[string]gatewayPreset{
+	"litellm": {
+		defaultURL: "http://localhost:4000",
+		path:       "/v1/responses",
+	},
+	"helicone": {
+		defaultURL: "https://oai.helicone.ai",
+		path:       "/v1/responses",
+		headers:    map[string]string{"Helicone-Auth": "Bearer ${HELICONE_API_KEY}"},
+	},
+	"portkey": {
+		defaultURL: "https://api.portkey.ai",
+		path:       "/v1/responses",
+		headers: map[string]string{
+			"x-portkey-api-key":  "${PORTKEY_API_KEY}",
+			"x-portkey-provider": "openai",
+		},
+	},
+}
+
+// gateway is a resolved gateway configuration. A nil *gateway means requests
+// go straight to the configured base URL with no extra headers.
+type gateway struct {
+	name     string
+	endpoint string
+	headers  http.Header
+}
+
+// activeGateway is the gateway selected by GATEWAY / GATEWAY_HEADERS.
+var activeGateway *gateway
+
+// extraHeaders are sent on every outbound API request, with or without a
+// gateway; set at startup from EXTRA_HEADERS.
+var extraHeaders http.Header
+
+// newGateway resolves a preset name, an optional gateway URL overriding the
+// preset's, and extra "key=value" header pairs. It returns nil when nothing
+// is configured.
+func newGateway(name, gatewayURL, extraHeaders string) (*gateway, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" && strings.TrimSpace(extraHeaders) == "" {
+		return nil, nil
+	}
+
+	g := &gateway{name: name, headers: make(http.Header)}
+	if name != "" {
+		preset, ok := gatewayPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown GATEWAY %q (supported: %s)", name, strings.Join(gatewayNames(), ", "))
+		}
+		base := strings.TrimRight(strings.TrimSpace(gatewayURL), "/")
+		if base == "" {
+			base = preset.defaultURL
+		}
+		g.endpoint = base + preset.path
+		for k, v := range preset.headers {
+			g.headers.Set(k, os.ExpandEnv(v))
+		}
+	}
+
+	extra, err := parseHeaderPairs(extraHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("GATEWAY_HEADERS: %w", err)
+	}
+	for k, v := range extra {
+		g.headers[k] = v
+	}
+	return g, nil
+}
+
+// parseHeaderPairs parses comma-separated "Name=value" pairs into headers.
+func parseHeaderPairs(s string) (http.Header, error) {
+	h := make(http.Header)
+	if strings.TrimSpace(s) == "" {
+		return h, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected Name=value, got %q", strings.TrimSpace(pair))
+		}
+		h.Set(k, strings.TrimSpace(v))
+	}
+	return h, nil
+}
+
+func gatewayNames() []string {
+	names := make([]string, 0, len(gatewayPresets))
+	for name := range gatewayPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveBaseURL routes requests through the active gateway when the caller
+// left the endpoint at its OpenAI default; an explicit -base always wins.
+func resolveBaseURL(baseURL string) string {
+	if activeGateway != nil && activeGateway.endpoint != "" && baseURL == defaultBaseURL {
+		return activeGateway.endpoint
+	}
+	return baseURL
+}
+
+// apply adds the gateway headers to an outbound request.
+func (g *gateway) apply(req *http.Request) {
+	if g == nil {
+		return
+	}
+	for k, v := range g.headers {
+		req.Header[k] = v
+	}
+}
+
+// applyOutboundHeaders adds the gateway headers and EXTRA_HEADERS to an
+// outbound API request. EXTRA_HEADERS are applied last, so they can replace
+// any header set before, Authorization included.
+func applyOutboundHeaders(req *http.Request) {
+	activeGateway.apply(req)
+	for k, v := range extraHeaders {
+		req.Header[k] = v
+	}
+}
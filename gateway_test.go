@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewGateway(t *testing.T) {
+	t.Setenv("PORTKEY_API_KEY", "pk-123")
+
+	if g, err := newGateway("", "", ""); g != nil || err != nil {
+		t.Fatalf("newGateway(empty) = %+v, %v; want nil, nil", g, err)
+	}
+
+	g, err := newGateway("Portkey", "", "x-trace=abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.endpoint != "https://api.portkey.ai/v1/responses" {
+		t.Errorf("endpoint = %q", g.endpoint)
+	}
+	if got := g.headers.Get("x-portkey-api-key"); got != "pk-123" {
+		t.Errorf("x-portkey-api-key = %q, want expanded env value", got)
+	}
+	if got := g.headers.Get("X-Trace"); got != "abc" {
+		t.Errorf("X-Trace = %q", got)
+	}
+
+	g, err = newGateway("litellm", "http://proxy.internal:4000/", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.endpoint != "http://proxy.internal:4000/v1/responses" {
+		t.Errorf("endpoint = %q", g.endpoint)
+	}
+
+	if _, err := newGateway("nope", "", ""); err == nil {
+		t.Error("expected error for unknown gateway")
+	}
+	if _, err := newGateway("", "", "no-equals"); err == nil {
+		t.Error("expected error for malformed GATEWAY_HEADERS")
+	}
+}
+
+func TestCallAPI_GatewayHeadersAndLenientResponse(t *testing.T) {
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Helicone-Auth"); got != "Bearer hk" {
+			t.Errorf("Helicone-Auth = %q", got)
+		}
+		// No model, reasoning or item type, and a plain "text" part.
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_gw",
+			"output": []map[string]any{
+				{"content": []map[string]any{{"type": "text", "text": "via gateway"}}},
+			},
+		})
+	})
+
+	t.Setenv("HELICONE_API_KEY", "hk")
+	gw, err := newGateway("helicone", base, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	activeGateway = gw
+	t.Cleanup(func() { activeGateway = nil })
+
+	if got := resolveBaseURL(defaultBaseURL); got != base+"/v1/responses" {
+		t.Errorf("resolveBaseURL(default) = %q", got)
+	}
+	if got := resolveBaseURL("https://example.com/v1/responses"); got != "https://example.com/v1/responses" {
+		t.Errorf("explicit base should win, got %q", got)
+	}
+
+	result, err := runWebSearch(context.Background(), "k", resolveBaseURL(defaultBaseURL), webSearchArgs{
+		query:  "q",
+		model:  modelMini,
+		effort: "low",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success || result.Answer != "via gateway" {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Model != modelMini || result.Effort != "low" {
+		t.Errorf("missing fields should fall back to requested values, got model=%q effort=%q", result.Model, result.Effort)
+	}
+}
+
+func TestCallAPI_ExtraHeaders(t *testing.T) {
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Corp-Route"); got != "eu" {
+			t.Errorf("X-Corp-Route = %q", got)
+		}
+		if got := r.Header.Get("X-Trace"); got != "extra" {
+			t.Errorf("X-Trace = %q, want EXTRA_HEADERS to win over the gateway", got)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_x", "output": []any{}})
+	})
+
+	gw, err := newGateway("", "", "X-Trace=gateway")
+	if err != nil {
+		t.Fatalf("newGateway: %v", err)
+	}
+	activeGateway = gw
+	if extraHeaders, err = parseHeaderPairs("X-Corp-Route=eu, X-Trace=extra"); err != nil {
+		t.Fatalf("parseHeaderPairs: %v", err)
+	}
+	t.Cleanup(func() { activeGateway, extraHeaders = nil, nil })
+
+	if _, err := CallAPI(context.Background(), CallAPIParams{APIKey: "k", BaseURL: base, Query: "q", Timeout: time.Second}); err != nil {
+		t.Fatalf("CallAPI: %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	providerGemini = "gemini"
+	// geminiModelsURL is the collection the per-model generateContent
+	// endpoint hangs off.
+	geminiModelsURL = "https://generativelanguage.googleapis.com/v1beta/models"
+)
+
+// geminiThinkingBudget maps reasoning effort onto a thinking budget. Zero
+// turns thinking off on models that allow it.
+var geminiThinkingBudget = map[string]int{
+	"none":   0,
+	"low":    1024,
+	"medium": 8192,
+	"high":   24576,
+	"xhigh":  32768,
+}
+
+var geminiModel = vendorModel("gemini-2.5-flash", "gemini-")
+
+// generateContent request structures.
+type geminiRequest struct {
+	Contents          []geminiContent  `json:"contents"`
+	SystemInstruction *geminiContent   `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool     `json:"tools,omitempty"`
+	GenerationConfig  *geminiGenConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text       string          `json:"text,omitempty"`
+	Thought    bool            `json:"thought,omitempty"`
+	InlineData *geminiBlob     `json:"inlineData,omitempty"`
+	FileData   *geminiFileData `json:"fileData,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiTool struct {
+	GoogleSearch *struct{} `json:"googleSearch,omitempty"`
+}
+
+type geminiGenConfig struct {
+	Temperature     *float64              `json:"temperature,omitempty"`
+	TopP            *float64              `json:"topP,omitempty"`
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	ThinkingBudget  int  `json:"thinkingBudget"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+type geminiResponse struct {
+	ResponseID   string `json:"responseId"`
+	ModelVersion string `json:"modelVersion"`
+	Candidates   []struct {
+		Content           geminiContent            `json:"content"`
+		FinishReason      string                   `json:"finishReason"`
+		GroundingMetadata *geminiGroundingMetadata `json:"groundingMetadata"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// geminiGroundingMetadata describes the searches behind an answer: the
+// sources (chunks) and which byte ranges of the text each supports.
+type geminiGroundingMetadata struct {
+	WebSearchQueries []string `json:"webSearchQueries"`
+	GroundingChunks  []struct {
+		Web *struct {
+			URI   string `json:"uri"`
+			Title string `json:"title"`
+		} `json:"web"`
+	} `json:"groundingChunks"`
+	GroundingSupports []struct {
+		Segment struct {
+			StartIndex int `json:"startIndex"`
+			EndIndex   int `json:"endIndex"`
+		} `json:"segment"`
+		GroundingChunkIndices []int `json:"groundingChunkIndices"`
+	} `json:"groundingSupports"`
+}
+
+// geminiEndpoint returns the generateContent URL for model. A base that
+// already names a method is used as given.
+func geminiEndpoint(base, model string) string {
+	if base == "" {
+		base = geminiModelsURL
+	}
+	if strings.Contains(base, ":generateContent") {
+		return base
+	}
+	return strings.TrimRight(base, "/") + "/" + url.PathEscape(model) + ":generateContent"
+}
+
+// checkGemini lists what Search grounding cannot enforce: allowed domains
+// are only asked of the model, and there is no search context size.
+func checkGemini(p CallAPIParams) ([]string, error) {
+	if !p.UseWebSearch {
+		return nil, nil
+	}
+	var ignored []string
+	if len(p.AllowedDomains) > 0 {
+		ignored = append(ignored, "domains")
+	}
+	if p.SearchContextSize != "" {
+		ignored = append(ignored, "search_context_size")
+	}
+	return ignored, nil
+}
+
+// sendGemini performs one generateContent call with Google Search grounding.
+// Gemini has no domain filter or user location for search, so those are
+// passed to the model as instructions.
+func sendGemini(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	system := p.Instructions
+	if p.UseWebSearch && len(p.AllowedDomains) > 0 {
+		system += "\n\nOnly use sources from these domains: " + strings.Join(p.AllowedDomains, ", ") + "."
+	}
+	if loc := p.UserLocation; p.UseWebSearch && loc != nil {
+		if where := joinNonEmpty(", ", loc.City, loc.Region, loc.Country); where != "" {
+			system += "\n\nThe user is located in " + where + "."
+		}
+	}
+	if p.OutputSchema != nil {
+		// Structured output cannot be combined with search grounding; ask
+		// for the schema instead and rely on runWebSearch's validation.
+		system += "\n\nReply with a single JSON document, and nothing else, matching this JSON Schema:\n" + string(p.OutputSchema.raw)
+	}
+
+	body := geminiRequest{
+		Contents: geminiContents(p),
+		GenerationConfig: &geminiGenConfig{
+			Temperature:     p.Temperature,
+			TopP:            p.TopP,
+			MaxOutputTokens: p.MaxOutputTokens,
+		},
+	}
+	if system = strings.TrimSpace(system); system != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+	if p.UseWebSearch {
+		body.Tools = []geminiTool{{GoogleSearch: &struct{}{}}}
+	}
+	if budget, ok := geminiThinkingBudget[p.Effort]; ok {
+		body.GenerationConfig.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: budget, IncludeThoughts: budget > 0}
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	p.BaseURL = geminiEndpoint(p.BaseURL, p.Model)
+	req, err := newAPIRequest(ctx, p, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var gr geminiResponse
+	if err := json.Unmarshal(bodyBytes, &gr); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	return gr.toAPIResponse(), nil
+}
+
+// geminiContents builds the earlier turns, where Gemini calls the assistant
+// "model", followed by the user turn.
+func geminiContents(p CallAPIParams) []geminiContent {
+	contents := make([]geminiContent, 0, len(p.Messages)+1)
+	for _, m := range p.Messages {
+		role := m.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: []geminiPart{{Text: m.Content}}})
+	}
+	return append(contents, geminiContent{Role: "user", Parts: geminiParts(p)})
+}
+
+// geminiParts builds the user turn: the question, then documents and images.
+func geminiParts(p CallAPIParams) []geminiPart {
+	parts := []geminiPart{{Text: p.Query}}
+	for _, f := range p.Files {
+		if f.Data == "" {
+			parts = append(parts, geminiPart{Text: f.inlineText()})
+			continue
+		}
+		mimeType, data := splitDataURL(f.Data)
+		parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: mimeType, Data: data}})
+	}
+	for _, img := range p.Images {
+		if mimeType, data := splitDataURL(img); data != "" {
+			parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: mimeType, Data: data}})
+			continue
+		}
+		parts = append(parts, geminiPart{FileData: &geminiFileData{FileURI: img}})
+	}
+	return parts
+}
+
+// joinNonEmpty joins the non-empty values with sep.
+func joinNonEmpty(sep string, values ...string) string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+// toAPIResponse maps the first candidate onto the Responses API shape. The
+// grounding supports become url_citation annotations: their segment offsets
+// are UTF-8 byte offsets into the joined answer parts and are converted to
+// the rune offsets the Responses API uses. Search queries become
+// web_search_call items, with the grounding chunks as their sources, and
+// thought parts a reasoning item.
+func (r *geminiResponse) toAPIResponse() *apiResponse {
+	ar := &apiResponse{ID: r.ResponseID, Model: r.ModelVersion, Status: "completed"}
+	if u := r.UsageMetadata; u != nil {
+		ar.Usage = &apiUsage{
+			InputTokens:  u.PromptTokenCount,
+			OutputTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+			TotalTokens:  u.TotalTokenCount,
+		}
+	}
+	if len(r.Candidates) == 0 {
+		return ar
+	}
+	c := r.Candidates[0]
+	if c.FinishReason == "MAX_TOKENS" {
+		ar.Status = "incomplete"
+		ar.IncompleteDetails = &incompleteDetails{Reason: "max_output_tokens"}
+	}
+
+	var text, thoughts strings.Builder
+	for _, part := range c.Content.Parts {
+		if part.Thought {
+			thoughts.WriteString(part.Text)
+		} else {
+			text.WriteString(part.Text)
+		}
+	}
+	if thoughts.Len() > 0 {
+		ar.Output = append(ar.Output, respItem{Type: "reasoning", Summary: []respSummary{{Type: "summary_text", Text: thoughts.String()}}})
+	}
+
+	answer := text.String()
+	content := respContent{Type: "output_text", Text: answer}
+	if gm := c.GroundingMetadata; gm != nil {
+		// The grounding chunks are the pages all the queries returned
+		// together; they go on the first search.
+		var sources []respSource
+		for _, chunk := range gm.GroundingChunks {
+			if chunk.Web != nil {
+				sources = append(sources, respSource{Type: "url", URL: chunk.Web.URI, Title: chunk.Web.Title})
+			}
+		}
+		queries := gm.WebSearchQueries
+		if len(queries) == 0 && len(sources) > 0 {
+			queries = []string{""}
+		}
+		for i, q := range queries {
+			action := &respAction{Type: "search", Query: q}
+			if i == 0 {
+				action.Sources = sources
+			}
+			ar.Output = append(ar.Output, respItem{Type: "web_search_call", Status: "completed", Action: action})
+		}
+		runeAt := func(b int) int { return utf8.RuneCountInString(answer[:min(max(b, 0), len(answer))]) }
+		for _, s := range gm.GroundingSupports {
+			start, end := runeAt(s.Segment.StartIndex), runeAt(s.Segment.EndIndex)
+			for _, i := range s.GroundingChunkIndices {
+				if i < 0 || i >= len(gm.GroundingChunks) || gm.GroundingChunks[i].Web == nil {
+					continue
+				}
+				web := gm.GroundingChunks[i].Web
+				content.Annotations = append(content.Annotations, respAnnotation{
+					Type: "url_citation", URL: web.URI, Title: web.Title, StartIndex: start, EndIndex: end,
+				})
+			}
+		}
+	}
+	ar.Output = append(ar.Output, respItem{Type: "message", Content: []respContent{content}})
+	return ar
+}
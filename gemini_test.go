@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallAPI_Gemini(t *testing.T) {
+	answer := "Café prices rose. Rent fell."
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "gk" || r.Header.Get("Authorization") != "" {
+			t.Errorf("auth headers = %v, want x-goog-api-key only", r.Header)
+		}
+		if r.URL.Path != "/models/gemini-2.5-flash:generateContent" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var body geminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(body.Tools) != 1 || body.Tools[0].GoogleSearch == nil {
+			t.Errorf("tools = %+v, want googleSearch", body.Tools)
+		}
+		if tc := body.GenerationConfig.ThinkingConfig; tc == nil || tc.ThinkingBudget != 1024 || !tc.IncludeThoughts {
+			t.Errorf("thinkingConfig = %+v", tc)
+		}
+		if body.SystemInstruction == nil || !strings.Contains(body.SystemInstruction.Parts[0].Text, "ons.gov.uk") {
+			t.Errorf("systemInstruction = %+v, want domain hint", body.SystemInstruction)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"responseId":   "g1",
+			"modelVersion": "gemini-2.5-flash",
+			"candidates": []map[string]any{{
+				"content": map[string]any{"role": "model", "parts": []map[string]any{
+					{"text": "compare sources", "thought": true},
+					{"text": answer},
+				}},
+				"finishReason": "STOP",
+				"groundingMetadata": map[string]any{
+					"webSearchQueries": []string{"uk cafe prices"},
+					"groundingChunks": []map[string]any{
+						{"web": map[string]any{"uri": "https://ons.gov.uk/a", "title": "ons.gov.uk"}},
+						{"web": map[string]any{"uri": "https://ons.gov.uk/b", "title": "ons.gov.uk"}},
+					},
+					"groundingSupports": []map[string]any{
+						// Byte offsets: "é" is two bytes.
+						{"segment": map[string]any{"startIndex": 19, "endIndex": 29}, "groundingChunkIndices": []int{1, 7}},
+					},
+				},
+			}},
+			"usageMetadata": map[string]any{"promptTokenCount": 8, "candidatesTokenCount": 6, "thoughtsTokenCount": 4, "totalTokenCount": 18},
+		})
+	})
+
+	resp, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:       providerGemini,
+		APIKey:         "gk",
+		BaseURL:        base + "/models",
+		Query:          "uk cafe prices?",
+		Model:          "gemini-2.5-flash",
+		Effort:         "low",
+		UseWebSearch:   true,
+		AllowedDomains: []string{"ons.gov.uk"},
+		Timeout:        time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Ignored) != 1 || resp.Ignored[0] != "domains" {
+		t.Errorf("ignored = %q, want the domains grounding cannot enforce", resp.Ignored)
+	}
+	ea := ExtractAnswer(resp)
+	if ea.Text != answer {
+		t.Errorf("answer = %q", ea.Text)
+	}
+	if len(ea.Citations) != 1 || ea.Citations[0].URL != "https://ons.gov.uk/b" {
+		t.Fatalf("citations = %+v", ea.Citations)
+	}
+	if s := ea.Citations[0].Span; s == nil || ea.Text[s.StartByte:s.EndByte] != "Rent fell." {
+		t.Errorf("citation span = %+v", s)
+	}
+	if len(ea.ToolCalls) != 1 || ea.ToolCalls[0].Action.Query != "uk cafe prices" || len(ea.ToolCalls[0].Action.Sources) != 2 {
+		t.Errorf("tool calls = %+v", ea.ToolCalls)
+	}
+	if len(ea.Reasoning) != 1 || resp.Usage.OutputTokens != 10 {
+		t.Errorf("reasoning = %v, usage = %+v", ea.Reasoning, resp.Usage)
+	}
+}
+
+func TestGeminiEndpoint(t *testing.T) {
+	t.Parallel()
+
+	if got := geminiEndpoint("", "gemini-2.5-pro"); got != geminiModelsURL+"/gemini-2.5-pro:generateContent" {
+		t.Errorf("default endpoint = %q", got)
+	}
+	custom := "https://proxy.example/v1beta/models/gemini-2.5-pro:generateContent"
+	if got := geminiEndpoint(custom, "other"); got != custom {
+		t.Errorf("explicit endpoint = %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// glossaryEntry is a preferred term and the variants that must be rewritten
+// to it.
+type glossaryEntry struct {
+	term     string
+	variants []string
+	re       *regexp.Regexp
+}
+
+// glossary enforces preferred terminology: it is described to the model in
+// the request instructions and applied to the answer afterwards, since
+// models do not follow such instructions reliably. A nil *glossary does
+// nothing.
+type glossary struct {
+	entries []glossaryEntry
+}
+
+// activeGlossary is the glossary loaded from GLOSSARY_FILE, if any.
+var activeGlossary *glossary
+
+// loadGlossary reads a glossary file: a JSON object mapping each preferred
+// term to the variants (synonyms, misspellings, source-language words) that
+// should be replaced by it, e.g. {"Kubernetes": ["k8s", "kube"]}. An empty
+// path yields nil.
+func loadGlossary(path string) (*glossary, error) {
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read glossary: %w", err)
+	}
+	var terms map[string][]string
+	if err := json.Unmarshal(raw, &terms); err != nil {
+		return nil, fmt.Errorf("GLOSSARY_FILE must be a JSON object of term -> [variants]: %w", err)
+	}
+	return newGlossary(terms)
+}
+
+// newGlossary compiles the term map. Terms are kept in sorted order so the
+// instructions, and therefore prompt caching, are stable.
+func newGlossary(terms map[string][]string) (*glossary, error) {
+	names := make([]string, 0, len(terms))
+	for term := range terms {
+		if strings.TrimSpace(term) != "" {
+			names = append(names, term)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+
+	g := &glossary{}
+	for _, term := range names {
+		e := glossaryEntry{term: strings.TrimSpace(term)}
+		var alts []string
+		for _, v := range terms[term] {
+			v = strings.TrimSpace(v)
+			if v == "" || v == e.term {
+				continue
+			}
+			e.variants = append(e.variants, v)
+			alts = append(alts, wordBounded(v))
+		}
+		if len(alts) > 0 {
+			re, err := regexp.Compile("(?i)" + strings.Join(alts, "|"))
+			if err != nil {
+				return nil, fmt.Errorf("glossary term %q: %w", e.term, err)
+			}
+			e.re = re
+		}
+		g.entries = append(g.entries, e)
+	}
+	return g, nil
+}
+
+// wordBounded quotes v and anchors it at word boundaries where its edges are
+// word characters, so "kube" does not match inside "kubectl". RE2's \b only
+// knows ASCII word characters, so non-ASCII edges are left unanchored.
+func wordBounded(v string) string {
+	expr := regexp.QuoteMeta(v)
+	first, _ := utf8.DecodeRuneInString(v)
+	last, _ := utf8.DecodeLastRuneInString(v)
+	if isWordRune(first) {
+		expr = `\b` + expr
+	}
+	if isWordRune(last) {
+		expr += `\b`
+	}
+	return expr
+}
+
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// instructions describes the glossary for the model.
+func (g *glossary) instructions() string {
+	if g == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Use the following terminology exactly as written:\n")
+	for _, e := range g.entries {
+		fmt.Fprintf(&sb, "- %s", e.term)
+		if len(e.variants) > 0 {
+			fmt.Fprintf(&sb, " (not %s)", strings.Join(e.variants, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// glossaryKeepRe matches what apply leaves alone: the code and URLs a
+// translation keeps (protectedRe) and Markdown link targets, which may be
+// relative paths.
+var glossaryKeepRe = regexp.MustCompile(protectedRe.String() + `|\]\([^)\n]*\)`)
+
+// apply rewrites every variant in text to its preferred term, outside code
+// and links. The answer's citation spans no longer fit once it changes, so
+// callers drop them (see withoutSpans).
+func (g *glossary) apply(text string) string {
+	if g == nil {
+		return text
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range glossaryKeepRe.FindAllStringIndex(text, -1) {
+		sb.WriteString(g.replace(text[last:m[0]]))
+		sb.WriteString(text[m[0]:m[1]])
+		last = m[1]
+	}
+	sb.WriteString(g.replace(text[last:]))
+	return sb.String()
+}
+
+// replace rewrites the variants in prose.
+func (g *glossary) replace(text string) string {
+	for _, e := range g.entries {
+		if e.re != nil {
+			text = e.re.ReplaceAllLiteralString(text, e.term)
+		}
+	}
+	return text
+}
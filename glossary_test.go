@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlossary_Apply(t *testing.T) {
+	t.Parallel()
+
+	g, err := newGlossary(map[string][]string{
+		"Kubernetes":     {"k8s", "kube"},
+		"Straße":         {"Strasse"},
+		"C++":            {"cpp"},
+		"   ":            {"ignored"},
+		"MCP server":     {"mcp-server", "MCP server"},
+		"no variants ok": nil,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := g.apply("Run K8s with kubectl; KUBE is fine. Strasse 5. Learn cpp on an mcp-server.")
+	want := "Run Kubernetes with kubectl; Kubernetes is fine. Straße 5. Learn C++ on an MCP server."
+	if got != want {
+		t.Errorf("apply:\n got %q\nwant %q", got, want)
+	}
+
+	wantRules := "Use the following terminology exactly as written:\n" +
+		"- C++ (not cpp)\n- Kubernetes (not k8s, kube)\n- MCP server (not mcp-server)\n- Straße (not Strasse)\n- no variants ok"
+	if rules := g.instructions(); rules != wantRules {
+		t.Errorf("instructions:\n got %q\nwant %q", rules, wantRules)
+	}
+
+	// Code, URLs and link targets keep the variants.
+	got = g.apply("Use k8s: `kubectl get k8s`, see [k8s docs](docs/k8s.md) or https://k8s.io/cpp.\n```\ncpp main.cpp\n```\ncpp")
+	want = "Use Kubernetes: `kubectl get k8s`, see [Kubernetes docs](docs/k8s.md) or https://k8s.io/cpp.\n```\ncpp main.cpp\n```\nC++"
+	if got != want {
+		t.Errorf("apply with code and links:\n got %q\nwant %q", got, want)
+	}
+
+	var nilGlossary *glossary
+	if nilGlossary.apply("k8s") != "k8s" || nilGlossary.instructions() != "" {
+		t.Error("nil glossary should be a no-op")
+	}
+}
+
+func TestLoadGlossary(t *testing.T) {
+	t.Parallel()
+
+	if g, err := loadGlossary(""); g != nil || err != nil {
+		t.Errorf("empty path = %v, %v; want nil, nil", g, err)
+	}
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`["k8s"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadGlossary(bad); err == nil || !strings.Contains(err.Error(), "GLOSSARY_FILE") {
+		t.Errorf("expected format error, got %v", err)
+	}
+	if _, err := loadGlossary(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestCallAPI_SendsGlossaryInstructions(t *testing.T) {
+	g, err := newGlossary(map[string][]string{"Kubernetes": {"k8s"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	activeGlossary = g
+	t.Cleanup(func() { activeGlossary = nil })
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if !strings.Contains(body.Instructions, "Kubernetes (not k8s)") {
+			t.Errorf("instructions = %q", body.Instructions)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+	if _, err := CallAPI(context.Background(), CallAPIParams{APIKey: "k", BaseURL: base, Query: "q", Timeout: time.Second}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
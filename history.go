@@ -0,0 +1,656 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// historySchema creates the entries table and an external-content FTS5
+// index over questions, answers and tags, kept in sync by triggers.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	id          INTEGER PRIMARY KEY,
+	created_at  TEXT NOT NULL,
+	query       TEXT NOT NULL,
+	answer      TEXT NOT NULL,
+	model       TEXT NOT NULL DEFAULT '',
+	provider    TEXT NOT NULL DEFAULT '',
+	response_id TEXT NOT NULL DEFAULT '',
+	tags        TEXT NOT NULL DEFAULT '',
+	content_hash TEXT NOT NULL DEFAULT ''
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS entries_fts USING fts5(
+	query, answer, tags, content='entries', content_rowid='id'
+);
+CREATE TRIGGER IF NOT EXISTS entries_ai AFTER INSERT ON entries BEGIN
+	INSERT INTO entries_fts(rowid, query, answer, tags) VALUES (new.id, new.query, new.answer, new.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS entries_ad AFTER DELETE ON entries BEGIN
+	INSERT INTO entries_fts(entries_fts, rowid, query, answer, tags) VALUES ('delete', old.id, old.query, old.answer, old.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS entries_au AFTER UPDATE ON entries BEGIN
+	INSERT INTO entries_fts(entries_fts, rowid, query, answer, tags) VALUES ('delete', old.id, old.query, old.answer, old.tags);
+	INSERT INTO entries_fts(rowid, query, answer, tags) VALUES (new.id, new.query, new.answer, new.tags);
+END;`
+
+const defaultHistoryLimit = 20
+
+// HistoryEntry is one recorded question and answer.
+type HistoryEntry struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Query      string    `json:"query"`
+	Answer     string    `json:"answer"`
+	Model      string    `json:"model,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	ResponseID string    `json:"response_id,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	// ContentHash identifies the answer text (see contentHash); record
+	// fills it in.
+	ContentHash string `json:"content_hash,omitempty"`
+	// Snippet is the highlighted match; only set by search.
+	Snippet string `json:"snippet,omitempty"`
+	// Sources are the cited URLs, archived on record when snapshots are
+	// enabled; they are not loaded back (see listSnapshots).
+	Sources []string `json:"-"`
+}
+
+// historyStore is a local SQLite log of answered questions. A nil
+// *historyStore records nothing.
+type historyStore struct {
+	db  *sql.DB
+	now func() time.Time
+	// embed, when set, embeds new entries for recall (see useEmbeddings);
+	// embedder names it and apiKey authenticates it.
+	embed    embedFunc
+	embedder string
+	apiKey   string
+	// snapshots archives the cited pages of new entries (see useSnapshots);
+	// dead pages are looked up in the Wayback Machine at waybackURL.
+	snapshots  bool
+	waybackURL string
+	// asyncSnapshots archives in the background; archiving tracks those
+	// still running (see snapshotInBackground).
+	asyncSnapshots bool
+	archiving      sync.WaitGroup
+	// sealer, when set, keeps the database encrypted at path: it is loaded
+	// into memory and written back after every change (see write).
+	sealer    *storeSealer
+	path      string
+	persistMu sync.Mutex
+	// synced is the hash of the file as last loaded or saved; another
+	// process has changed it when the hash differs (see write).
+	synced [32]byte
+}
+
+// answerHistory records CLI and MCP answers; nil unless HISTORY is enabled.
+var answerHistory *historyStore
+
+// historyPath returns HISTORY_DB, or history.db under $XDG_DATA_HOME
+// (default ~/.local/share)/websearch.
+func historyPath() (string, error) {
+	if p := os.Getenv("HISTORY_DB"); p != "" {
+		return p, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate history: %w", err)
+	}
+	return filepath.Join(dir, "history.db"), nil
+}
+
+// dataDir is where local state lives: $XDG_DATA_HOME/websearch, or
+// ~/.local/share/websearch.
+func dataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "websearch"), nil
+}
+
+// openHistory opens (creating if needed) the history database at path. With
+// activeSealer set the database is kept encrypted (see openSealedHistory).
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create history dir: %w", err)
+	}
+	if activeSealer != nil {
+		return openSealedHistory(path, activeSealer)
+	}
+	if sealed, err := fileIsSealed(path); err != nil || sealed {
+		return nil, fmt.Errorf("open history: %w", cmp.Or(err, ErrStoreLocked))
+	}
+	db, err := openHistoryDB(path)
+	if err != nil {
+		return nil, err
+	}
+	return &historyStore{db: db, now: time.Now}, nil
+}
+
+// openHistoryDB opens the SQLite database at path (":memory:" for an
+// in-memory one) and creates the schema.
+func openHistoryDB(path string) (*sql.DB, error) {
+	// Foreign keys are off by default in SQLite; embeddings rely on them to
+	// follow deleted entries.
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	// SQLite serializes writers; a single connection avoids SQLITE_BUSY
+	// between concurrent MCP requests. An in-memory database also lives
+	// only as long as its connection.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, fmt.Errorf("init history: %w", err)
+	}
+	if err := migrateHistory(db); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, err
+	}
+	return db, nil
+}
+
+// historyColumns are the entries columns added after the first release,
+// with their definitions, so older databases can be brought up to date.
+var historyColumns = []struct{ name, def string }{
+	{"content_hash", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrateHistory adds the historyColumns an older database lacks.
+func migrateHistory(db *sql.DB) error {
+	for _, c := range historyColumns {
+		var exists bool
+		if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('entries') WHERE name = ?`, c.name).Scan(&exists); err != nil {
+			return fmt.Errorf("migrate history: %w", err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE entries ADD COLUMN ` + c.name + ` ` + c.def); err != nil {
+			return fmt.Errorf("migrate history: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close releases the database. An encrypted one has been saved by every
+// write already.
+func (h *historyStore) Close() error {
+	if h == nil {
+		return nil
+	}
+	h.archiving.Wait()
+	return h.db.Close()
+}
+
+// record stores a successful answer and returns its ID.
+func (h *historyStore) record(ctx context.Context, e HistoryEntry) (int64, error) {
+	if h == nil {
+		return 0, nil
+	}
+	// The embedding may take an API call, so it is made before write takes
+	// the file lock.
+	var vec []float64
+	if h.embed != nil {
+		var err error
+		if vec, err = h.embed(ctx, h.apiKey, e.Query); err != nil {
+			Warn("Failed to embed history entry; run \"answer history reindex\" later", "error", err)
+		}
+	}
+	var id int64
+	err := h.write(func() error {
+		res, err := h.db.ExecContext(ctx,
+			`INSERT INTO entries (created_at, query, answer, model, provider, response_id, tags, content_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			h.now().UTC().Format(time.RFC3339), e.Query, e.Answer, e.Model, e.Provider, e.ResponseID, joinTags(e.Tags), contentHash(e.Answer))
+		if err != nil {
+			return fmt.Errorf("record history: %w", err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		if vec != nil {
+			if err := h.storeEmbedding(ctx, id, vec); err != nil {
+				Warn("Failed to store history embedding; run \"answer history reindex\" later", "id", id, "error", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if h.snapshots {
+		archive := func(ctx context.Context) {
+			if err := h.snapshotSources(ctx, id, e.Sources); err != nil {
+				Warn("Failed to archive cited sources", "id", id, "error", err)
+			}
+		}
+		if h.asyncSnapshots {
+			h.archiving.Go(func() { archive(context.WithoutCancel(ctx)) })
+		} else {
+			archive(ctx)
+		}
+	}
+	return id, nil
+}
+
+// recordResult logs a web search result; failures are only warned about so
+// history never breaks answering.
+func (h *historyStore) recordResult(ctx context.Context, r *WebSearchResult) {
+	if h == nil || r == nil || !r.Success || r.Cached || r.Answer == "" {
+		// Source lists without an answer (synthesis off) are not kept.
+		return
+	}
+	if _, err := h.record(ctx, HistoryEntry{Query: r.Query, Answer: r.Answer, Model: r.Model, Provider: r.Provider, ResponseID: r.ID, Sources: citationURLs(r.Citations)}); err != nil {
+		Warn("Failed to record history", "error", err)
+	}
+}
+
+// get returns the entry with the given ID.
+func (h *historyStore) get(ctx context.Context, id int64) (HistoryEntry, error) {
+	row := h.db.QueryRowContext(ctx, `SELECT id, created_at, query, answer, model, provider, response_id, tags, content_hash FROM entries WHERE id = ?`, id)
+	e, err := scanEntry(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, fmt.Errorf("%w: #%d", ErrHistoryNotFound, id)
+	}
+	return e, err
+}
+
+// tag adds tags to an entry, or removes them when remove is set.
+func (h *historyStore) tag(ctx context.Context, id int64, tags []string, remove bool) ([]string, error) {
+	var updated []string
+	err := h.write(func() error {
+		e, err := h.get(ctx, id)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]bool)
+		for _, t := range e.Tags {
+			current[t] = true
+		}
+		for _, t := range normalizeTags(tags) {
+			current[t] = !remove
+		}
+		for t, keep := range current {
+			if keep {
+				updated = append(updated, t)
+			}
+		}
+		updated = normalizeTags(updated)
+		if _, err := h.db.ExecContext(ctx, `UPDATE entries SET tags = ? WHERE id = ?`, joinTags(updated), id); err != nil {
+			return fmt.Errorf("tag history: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// list returns the newest entries, optionally only those carrying tag.
+func (h *historyStore) list(ctx context.Context, tag string, limit int) ([]HistoryEntry, error) {
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT id, created_at, query, answer, model, provider, response_id, tags, content_hash FROM entries
+		 WHERE (? = '' OR tags LIKE ? ESCAPE '\') ORDER BY id DESC LIMIT ?`,
+		tag, tagPattern(tag), historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("list history: %w", err)
+	}
+	return scanEntries(rows, false)
+}
+
+// search runs a full-text query over questions, answers and tags, best
+// matches first. Every word must match; quoting is handled here so user
+// input never reaches FTS5 query syntax.
+func (h *historyStore) search(ctx context.Context, query, tag string, limit int) ([]HistoryEntry, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, fmt.Errorf("search terms are required")
+	}
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT e.id, e.created_at, e.query, e.answer, e.model, e.provider, e.response_id, e.tags, e.content_hash,
+		        snippet(entries_fts, 1, '[', ']', '…', 12)
+		 FROM entries_fts JOIN entries e ON e.id = entries_fts.rowid
+		 WHERE entries_fts MATCH ? AND (? = '' OR e.tags LIKE ? ESCAPE '\')
+		 ORDER BY bm25(entries_fts) LIMIT ?`,
+		match, tag, tagPattern(tag), historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("search history: %w", err)
+	}
+	return scanEntries(rows, true)
+}
+
+// asOf returns the entries whose question matches query, nearest to at
+// first, for audits of what was answered at a given time. Every word of
+// query must appear in the question.
+func (h *historyStore) asOf(ctx context.Context, query string, at time.Time, tag string, limit int) ([]HistoryEntry, error) {
+	words := strings.Fields(ftsQuery(query))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("a question is required (-q)")
+	}
+	for i, w := range words {
+		words[i] = "query : " + w
+	}
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT e.id, e.created_at, e.query, e.answer, e.model, e.provider, e.response_id, e.tags, e.content_hash
+		 FROM entries_fts JOIN entries e ON e.id = entries_fts.rowid
+		 WHERE entries_fts MATCH ? AND (? = '' OR e.tags LIKE ? ESCAPE '\')
+		 ORDER BY abs(julianday(e.created_at) - julianday(?)), bm25(entries_fts) LIMIT ?`,
+		strings.Join(words, " "), tag, tagPattern(tag), at.UTC().Format(time.RFC3339), historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("search history: %w", err)
+	}
+	return scanEntries(rows, false)
+}
+
+// parseAsOf reads an as-of date: a day (2025-03-01, taken as its end in
+// local time) or an RFC 3339 timestamp.
+func parseAsOf(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (want 2025-03-01 or an RFC 3339 time)", s)
+	}
+	return day.Add(24*time.Hour - time.Second), nil
+}
+
+func scanEntries(rows *sql.Rows, withSnippet bool) ([]HistoryEntry, error) {
+	defer rows.Close() //nolint:errcheck
+	var out []HistoryEntry
+	for rows.Next() {
+		var snippet string
+		e, err := scanEntry(func(dest ...any) error {
+			if withSnippet {
+				dest = append(dest, &snippet)
+			}
+			return rows.Scan(dest...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.Snippet = snippet
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func scanEntry(scan func(dest ...any) error) (HistoryEntry, error) {
+	var e HistoryEntry
+	var created, tags string
+	if err := scan(&e.ID, &created, &e.Query, &e.Answer, &e.Model, &e.Provider, &e.ResponseID, &tags, &e.ContentHash); err != nil {
+		return e, err
+	}
+	e.CreatedAt, _ = time.Parse(time.RFC3339, created) //nolint:errcheck
+	e.Tags = strings.Fields(tags)
+	return e, nil
+}
+
+// normalizeTags lowercases tags, joins inner spaces with '-', and returns
+// them sorted without duplicates.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range tags {
+		t = strings.Join(strings.Fields(strings.ToLower(t)), "-")
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// joinTags stores tags space-padded so tagPattern can match whole tags.
+func joinTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " " + strings.Join(tags, " ") + " "
+}
+
+func tagPattern(tag string) string {
+	tags := normalizeTags([]string{tag})
+	if len(tags) == 0 {
+		return ""
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(tags[0])
+	return "% " + escaped + " %"
+}
+
+// ftsQuery turns free text into an FTS5 query requiring every word.
+func ftsQuery(q string) string {
+	words := strings.Fields(q)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func historyLimit(n int) int {
+	if n <= 0 {
+		return defaultHistoryLimit
+	}
+	return n
+}
+
+// parseInterspersed parses the flags of a subcommand, which may follow its
+// positional arguments (answer history search k8s -n 5), and returns the
+// positional ones. Everything after "--" is positional, so search terms
+// may start with "-".
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) > 0 {
+			positional = append(positional, rest[0])
+			rest = rest[1:]
+		}
+		args = rest
+	}
+	return positional, nil
+}
+
+// runHistory implements "answer history": list, search, show, tag and untag
+// entries in the history database.
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	limit := fs.Int("n", defaultHistoryLimit, "maximum number of entries to show")
+	tag := fs.String("tag", "", "only show entries carrying this tag")
+	asJSON := fs.Bool("json", false, "print entries as JSON")
+	rawHTML := fs.Bool("html", false, "with source, print the archived page instead of its text")
+	question := fs.String("q", "", "with as-of, the question to look up")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer history [list | search [--] <terms> | show <id> | as-of <date> -q <question> | tag <id> <tags...> | untag <id> <tags...> | sources <id> | source <id> <n> | reindex] [-n N] [-tag T] [-json] [-html]")
+		fs.PrintDefaults()
+	}
+
+	cmd := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	path, err := historyPath()
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if activeSealer, err = storeSealerFromEnv(); err != nil {
+		fail(2, err.Error())
+	}
+	h, err := openHistory(path)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	defer h.Close() //nolint:errcheck
+	ctx := context.Background()
+	if err := h.useEmbeddings(os.Getenv("HISTORY_EMBEDDINGS"), resolveBaseURL(defaultBaseURL), os.Getenv("EMBEDDING_MODEL"), os.Getenv("OPENAI_API_KEY")); err != nil {
+		fail(2, err.Error())
+	}
+	if err := h.useSnapshots(true); err != nil {
+		fail(2, err.Error())
+	}
+
+	var entries []HistoryEntry
+	switch cmd {
+	case "list":
+		entries, err = h.list(ctx, *tag, *limit)
+	case "search":
+		entries, err = h.search(ctx, strings.Join(positional, " "), *tag, *limit)
+	case "as-of":
+		if len(positional) == 0 {
+			fail(2, "usage: answer history as-of <date> -q <question>")
+		}
+		at, dateErr := parseAsOf(positional[0])
+		if dateErr != nil {
+			fail(2, dateErr.Error())
+		}
+		// The nearest answer alone unless -n asks for more.
+		n := 1
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "n" {
+				n = *limit
+			}
+		})
+		entries, err = h.asOf(ctx, cmp.Or(*question, strings.Join(positional[1:], " ")), at, *tag, n)
+	case "show":
+		var e HistoryEntry
+		if e, err = h.get(ctx, historyID(positional)); err == nil {
+			entries = []HistoryEntry{e}
+		}
+	case "reindex":
+		var n int
+		if n, err = h.reindex(ctx); err == nil {
+			fmt.Printf("Indexed %d entries\n", n)
+			return
+		}
+	case "sources":
+		var snaps []Snapshot
+		if snaps, err = h.listSnapshots(ctx, historyID(positional)); err == nil {
+			printSnapshots(snaps, *asJSON)
+			return
+		}
+	case "source":
+		if len(positional) < 2 {
+			fail(2, "usage: answer history source <id> <n> [-html]")
+		}
+		n, convErr := strconv.Atoi(positional[1])
+		if convErr != nil || n < 1 {
+			fail(2, fmt.Sprintf("invalid source number %q", positional[1]))
+		}
+		var s Snapshot
+		if s, err = h.snapshot(ctx, historyID(positional), n); err == nil {
+			if s.ArchivedURL != "" {
+				fmt.Fprintf(os.Stderr, "archived content: %s was gone, showing %s\n", s.URL, s.ArchivedURL)
+			}
+			if *rawHTML {
+				os.Stdout.Write(s.Body) //nolint:errcheck
+			} else {
+				fmt.Println(s.Text)
+			}
+			return
+		}
+	case "tag", "untag":
+		if len(positional) < 2 {
+			fail(2, "usage: answer history "+cmd+" <id> <tags...>")
+		}
+		var tags []string
+		if tags, err = h.tag(ctx, historyID(positional), positional[1:], cmd == "untag"); err == nil {
+			fmt.Printf("#%s tags: %s\n", positional[0], strings.Join(tags, ", "))
+			return
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(1, err.Error())
+	}
+	if cmd == "as-of" && len(entries) == 0 {
+		fail(1, "no recorded answer to that question")
+	}
+
+	if *asJSON {
+		raw, _ := json.MarshalIndent(entries, "", "  ") //nolint:errcheck
+		fmt.Println(string(raw))
+		return
+	}
+	for _, e := range entries {
+		printHistoryEntry(e, cmd == "show" || cmd == "as-of")
+	}
+}
+
+func historyID(positional []string) int64 {
+	if len(positional) == 0 {
+		fail(2, "an entry ID is required")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(positional[0], "#"), 10, 64)
+	if err != nil {
+		fail(2, fmt.Sprintf("invalid entry ID %q", positional[0]))
+	}
+	return id
+}
+
+func printSnapshots(snaps []Snapshot, asJSON bool) {
+	if asJSON {
+		raw, _ := json.MarshalIndent(snaps, "", "  ") //nolint:errcheck
+		fmt.Println(string(raw))
+		return
+	}
+	for i, s := range snaps {
+		state := fmt.Sprintf("%d, %d bytes", s.Status, s.Size)
+		if s.Error != "" {
+			state = s.Error
+		}
+		if s.ArchivedURL != "" {
+			state = "gone, archived copy " + s.ArchivedURL + ", " + state
+		}
+		fmt.Printf("%d. %s\n   %s (%s)\n", i+1, s.URL, s.FetchedAt.Local().Format("2006-01-02 15:04"), state)
+	}
+}
+
+func printHistoryEntry(e HistoryEntry, full bool) {
+	tags := ""
+	if len(e.Tags) > 0 {
+		tags = "  [" + strings.Join(e.Tags, ", ") + "]"
+	}
+	fmt.Printf("#%d  %s%s\n  %s\n", e.ID, e.CreatedAt.Local().Format("2006-01-02 15:04"), tags, e.Query)
+	switch {
+	case full:
+		fmt.Printf("\n%s\n", e.Answer)
+	case e.Snippet != "":
+		fmt.Printf("  %s\n", strings.Join(strings.Fields(e.Snippet), " "))
+	}
+}
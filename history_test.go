@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHistory(t *testing.T) *historyStore {
+	t.Helper()
+	h, err := openHistory(filepath.Join(t.TempDir(), "sub", "history.db"))
+	if err != nil {
+		t.Fatalf("openHistory: %v", err)
+	}
+	t.Cleanup(func() { h.Close() }) //nolint:errcheck
+	return h
+}
+
+func TestHistory_TagAndSearch(t *testing.T) {
+	t.Parallel()
+	h := newTestHistory(t)
+	ctx := context.Background()
+
+	ingress, err := h.record(ctx, HistoryEntry{Query: "How does a Kubernetes ingress work?", Answer: "An ingress routes external HTTP traffic to services.", Provider: "openai"})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if _, err := h.record(ctx, HistoryEntry{Query: "Best sourdough hydration?", Answer: "Around 75% is a good start."}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	tags, err := h.tag(ctx, ingress, []string{"K8s", "networking", "k8s", " my_tag "}, false)
+	if err != nil || strings.Join(tags, ",") != "k8s,my_tag,networking" {
+		t.Fatalf("tag = %v, %v", tags, err)
+	}
+	if tags, err = h.tag(ctx, ingress, []string{"networking"}, true); err != nil || strings.Join(tags, ",") != "k8s,my_tag" {
+		t.Fatalf("untag = %v, %v", tags, err)
+	}
+
+	found, err := h.search(ctx, "kubernetes ingress", "", 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != ingress || !strings.Contains(found[0].Snippet, "[ingress]") {
+		t.Fatalf("search = %+v", found)
+	}
+	if found, err := h.search(ctx, "ingress", "sourdough", 0); err != nil || len(found) != 0 {
+		t.Errorf("tag-filtered search = %+v, %v; want none", found, err)
+	}
+	// FTS5 operators and quotes in user input are treated as plain words.
+	if _, err := h.search(ctx, `"ingress AND NEAR(`, "", 0); err != nil {
+		t.Errorf("search with FTS syntax: %v", err)
+	}
+
+	listed, err := h.list(ctx, "my_tag", 0)
+	if err != nil || len(listed) != 1 || listed[0].ID != ingress {
+		t.Errorf("list by tag = %+v, %v", listed, err)
+	}
+	if all, err := h.list(ctx, "", 0); err != nil || len(all) != 2 || all[0].Query != "Best sourdough hydration?" {
+		t.Errorf("list = %+v, %v; want newest first", all, err)
+	}
+
+	if _, err := h.get(ctx, 99); !errors.Is(err, ErrHistoryNotFound) {
+		t.Errorf("get unknown = %v, want ErrHistoryNotFound", err)
+	}
+}
+
+func TestHistoryPath(t *testing.T) {
+	t.Setenv("HISTORY_DB", "")
+	t.Setenv("XDG_DATA_HOME", "/data")
+	if p, err := historyPath(); err != nil || p != filepath.Join("/data", "websearch", "history.db") {
+		t.Errorf("historyPath = %q, %v", p, err)
+	}
+	t.Setenv("HISTORY_DB", "/tmp/h.db")
+	if p, _ := historyPath(); p != "/tmp/h.db" {
+		t.Errorf("HISTORY_DB override = %q", p)
+	}
+}
+
+func TestHistory_ContentHashMigration(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	// A database from before content_hash existed.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(strings.Replace(historySchema, ",\n\tcontent_hash TEXT NOT NULL DEFAULT ''", "", 1)); err != nil {
+		t.Fatalf("old schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO entries (created_at, query, answer) VALUES ('2025-01-01T00:00:00Z', 'old', 'old answer')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close() //nolint:errcheck
+
+	h, err := openHistory(path)
+	if err != nil {
+		t.Fatalf("openHistory on an old database: %v", err)
+	}
+	t.Cleanup(func() { h.Close() }) //nolint:errcheck
+	ctx := context.Background()
+	id, err := h.record(ctx, HistoryEntry{Query: "new", Answer: "Same  content,\nnew ID."})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	e, err := h.get(ctx, id)
+	if err != nil || e.ContentHash != contentHash("Same content, new ID.") || e.ContentHash == "" {
+		t.Errorf("entry = %+v, %v; want the answer's content hash", e, err)
+	}
+	if old, err := h.get(ctx, 1); err != nil || old.ContentHash != "" {
+		t.Errorf("old entry = %+v, %v", old, err)
+	}
+}
+
+func TestHistory_AsOf(t *testing.T) {
+	t.Parallel()
+	h := newTestHistory(t)
+	ctx := context.Background()
+
+	record := func(day, query, answer string) {
+		t.Helper()
+		at, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.now = func() time.Time { return at }
+		if _, err := h.record(ctx, HistoryEntry{Query: query, Answer: answer}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	record("2025-01-10", "What is the latest Kubernetes version?", "1.32")
+	record("2025-04-23", "Latest kubernetes version?", "1.33")
+	record("2025-02-27", "Kubernetes ingress versus gateway", "Gateway API is the latest option.")
+	record("2025-08-27", "What is the latest Kubernetes version?", "1.34")
+
+	at, err := parseAsOf("2025-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := h.asOf(ctx, "kubernetes latest version", at, "", 0)
+	if err != nil {
+		t.Fatalf("asOf: %v", err)
+	}
+	var answers []string
+	for _, e := range got {
+		answers = append(answers, e.Answer)
+	}
+	// The ingress entry mentions "latest" only in its answer.
+	if strings.Join(answers, ",") != "1.32,1.33,1.34" {
+		t.Errorf("asOf answers = %v, want nearest first", answers)
+	}
+
+	if _, err := parseAsOf("2025-03-01T12:00:00Z"); err != nil {
+		t.Errorf("parseAsOf RFC 3339: %v", err)
+	}
+	if _, err := parseAsOf("March 1st"); err == nil {
+		t.Error("parseAsOf accepted a malformed date")
+	}
+	if _, err := h.asOf(ctx, " ", at, "", 0); err == nil {
+		t.Error("asOf without a question: expected an error")
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+		n    int
+	}{
+		{args: []string{"k8s", "-n", "5", "ingress"}, want: []string{"k8s", "ingress"}, n: 5},
+		{args: []string{"-n", "3", "--", "-v", "flag", "-n", "7"}, want: []string{"-v", "flag", "-n", "7"}, n: 3},
+		{args: []string{"grep", "--", "-r"}, want: []string{"grep", "-r"}, n: 10},
+	} {
+		fs := flag.NewFlagSet("history", flag.ContinueOnError)
+		n := fs.Int("n", 10, "")
+		got, err := parseInterspersed(fs, tt.args)
+		if err != nil || !slices.Equal(got, tt.want) || *n != tt.n {
+			t.Errorf("parseInterspersed(%q) = %q, -n %d, %v; want %q, -n %d", tt.args, got, *n, err, tt.want, tt.n)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultHookTimeout bounds one hook run unless HOOK_TIMEOUT is set.
+	defaultHookTimeout = 10 * time.Second
+	// maxHookEnvText bounds the query or answer copied into the hook
+	// environment; the full text is always on stdin.
+	maxHookEnvText = 32 << 10
+	// maxHookStderr bounds the stderr repeated in a hook error.
+	maxHookStderr = 2000
+)
+
+// hooks are shell commands run around every upstream call (env
+// HOOK_PRE_QUERY, HOOK_POST_ANSWER). A nil *hooks runs nothing.
+type hooks struct {
+	preQuery   string
+	postAnswer string
+	timeout    time.Duration
+}
+
+// activeHooks holds the hook commands; set at startup.
+var activeHooks *hooks
+
+// newHooks returns nil when neither hook is configured.
+func newHooks(preQuery, postAnswer string, timeout time.Duration) *hooks {
+	preQuery, postAnswer = strings.TrimSpace(preQuery), strings.TrimSpace(postAnswer)
+	if preQuery == "" && postAnswer == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	return &hooks{preQuery: preQuery, postAnswer: postAnswer, timeout: timeout}
+}
+
+// beforeQuery runs the pre-query hook. A non-zero exit vetoes the call, so
+// the hook can enforce local policy as well as log.
+func (h *hooks) beforeQuery(ctx context.Context, p CallAPIParams) error {
+	if h == nil || h.preQuery == "" {
+		return nil
+	}
+	if err := h.run(ctx, "pre_query", h.preQuery, p.Query, hookCallEnv(p)); err != nil {
+		return fmt.Errorf("pre-query hook rejected the query: %w", err)
+	}
+	return nil
+}
+
+// afterAnswer runs the post-answer hook for a successful call. Failures are
+// logged; the answer is returned regardless.
+func (h *hooks) afterAnswer(ctx context.Context, p CallAPIParams, ar *apiResponse) {
+	if h == nil || h.postAnswer == "" {
+		return
+	}
+	extracted := ExtractAnswer(ar)
+	env := append(hookCallEnv(p),
+		"WEBSEARCH_ANSWER="+truncateBytes(extracted.Text, maxHookEnvText),
+		"WEBSEARCH_RESPONSE_ID="+ar.ID,
+		"WEBSEARCH_CITATIONS="+strings.Join(citationURLs(extracted.Citations), "\n"),
+	)
+	if ar.Model != "" {
+		// The model that answered; later entries override hookCallEnv's.
+		env = append(env, "WEBSEARCH_MODEL="+ar.Model)
+	}
+	if ar.Usage != nil {
+		env = append(env,
+			"WEBSEARCH_INPUT_TOKENS="+strconv.Itoa(ar.Usage.InputTokens),
+			"WEBSEARCH_OUTPUT_TOKENS="+strconv.Itoa(ar.Usage.OutputTokens),
+		)
+	}
+	if err := h.run(ctx, "post_answer", h.postAnswer, extracted.Text, env); err != nil {
+		Warn("Post-answer hook failed", "request_id", p.RequestID, "error", err)
+	}
+}
+
+// hookCallEnv describes the call to a hook.
+func hookCallEnv(p CallAPIParams) []string {
+	return []string{
+		"WEBSEARCH_QUERY=" + truncateBytes(p.Query, maxHookEnvText),
+		"WEBSEARCH_PROVIDER=" + p.Provider,
+		"WEBSEARCH_MODEL=" + p.Model,
+		"WEBSEARCH_EFFORT=" + p.Effort,
+		"WEBSEARCH_REQUEST_ID=" + p.RequestID,
+		"WEBSEARCH_WEB_SEARCH=" + strconv.FormatBool(p.UseWebSearch),
+	}
+}
+
+// run executes command through the shell with stdin on its standard input
+// and env added to the environment.
+func (h *hooks) run(ctx context.Context, name, command, stdin string, env []string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(append(os.Environ(), "WEBSEARCH_HOOK="+name), env...)
+	cmd.WaitDelay = time.Second
+	stderr := &cappedBuffer{limit: maxHookStderr}
+	cmd.Stdout, cmd.Stderr = io.Discard, stderr
+
+	Debug("Running hook", "hook", name)
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", h.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return fmt.Errorf("%s hook: %w: %s", name, err, msg)
+		}
+		return fmt.Errorf("%s hook: %w", name, err)
+	}
+	return nil
+}
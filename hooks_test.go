@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	preLog, postLog := filepath.Join(dir, "pre.log"), filepath.Join(dir, "post.log")
+	activeHooks = newHooks(
+		`case "$WEBSEARCH_QUERY" in *forbidden*) echo "not allowed" >&2; exit 1;; esac; echo "$WEBSEARCH_HOOK $WEBSEARCH_REQUEST_ID $WEBSEARCH_QUERY" >> `+preLog,
+		`{ echo "$WEBSEARCH_HOOK $WEBSEARCH_RESPONSE_ID $WEBSEARCH_MODEL $WEBSEARCH_CITATIONS"; cat; } >> `+postLog,
+		5*time.Second,
+	)
+	t.Cleanup(func() { activeHooks = nil })
+
+	calls := 0
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "resp_h",
+			"model": "m1",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+				"type": "output_text", "text": "the answer",
+				"annotations": []map[string]any{{"type": "url_citation", "url": "https://example.com/a"}},
+			}}}},
+		})
+	})
+	call := func(query string) error {
+		_, err := CallAPI(context.Background(), CallAPIParams{APIKey: "k", BaseURL: base, Query: query, RequestID: "ws-1", Timeout: 2 * time.Second})
+		return err
+	}
+
+	if err := call("what is new"); err != nil {
+		t.Fatalf("CallAPI: %v", err)
+	}
+	pre, _ := os.ReadFile(preLog)   //nolint:errcheck
+	post, _ := os.ReadFile(postLog) //nolint:errcheck
+	if got := string(pre); got != "pre_query ws-1 what is new\n" {
+		t.Errorf("pre-query hook saw %q", got)
+	}
+	if got := string(post); got != "post_answer resp_h m1 https://example.com/a\nthe answer" {
+		t.Errorf("post-answer hook saw %q", got)
+	}
+
+	err := call("something forbidden")
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("vetoed query err = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("upstream calls = %d, want the vetoed query not sent", calls)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultAPITimeout is how long /api/search waits for an answer unless
+// -api-timeout says otherwise.
+const defaultAPITimeout = 2 * time.Minute
+
+// apiSearchRequest is the body of POST /api/search.
+type apiSearchRequest struct {
+	queryRequest
+	CitationStyle string `json:"citation_style,omitempty"`
+	// TimeoutSeconds shortens the server's response timeout for this call.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// apiSearchResponse is the reply of /api/search. Every field is present in
+// every reply, successful or not, so workflow tools can map them once.
+type apiSearchResponse struct {
+	OK        bool        `json:"ok"`
+	Query     string      `json:"query"`
+	Answer    string      `json:"answer"`
+	Sources   []apiSource `json:"sources"`
+	Model     string      `json:"model"`
+	ID        string      `json:"id"`
+	Cached    bool        `json:"cached"`
+	ElapsedMS int64       `json:"elapsed_ms"`
+	Error     string      `json:"error"`
+}
+
+// apiSource is one distinct cited page.
+type apiSource struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// searchAPI answers questions synchronously over plain JSON for low-code
+// workflow tools that speak neither MCP nor SSE.
+type searchAPI struct {
+	apiKey  string
+	baseURL string
+	timeout time.Duration
+}
+
+func newSearchAPI(cfg MCPConfig) *searchAPI {
+	timeout := cfg.APITimeout
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+	return &searchAPI{apiKey: cfg.APIKey, baseURL: cfg.BaseURL, timeout: timeout}
+}
+
+// ServeHTTP answers POST /api/search. The status is 200 with an answer, 400
+// for a bad request, 415 for a body that is not JSON, 504 when the answer
+// took longer than the timeout and 502 when the search failed. Requiring
+// POST with a JSON content type means a web page cannot make a visitor's
+// browser run paid searches: a cross-site form or GET cannot send it.
+func (a *searchAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	resp := apiSearchResponse{Sources: []apiSource{}}
+	reply := func(status int) {
+		resp.ElapsedMS = time.Since(started).Milliseconds()
+		writeHTTPJSON(w, status, resp)
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		resp.Error = "use POST"
+		reply(http.StatusMethodNotAllowed)
+		return
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		resp.Error = "Content-Type must be application/json"
+		reply(http.StatusUnsupportedMediaType)
+		return
+	}
+	req, err := parseAPISearchRequest(w, r)
+	resp.Query = req.Query
+	if err != nil {
+		resp.Error = err.Error()
+		reply(http.StatusBadRequest)
+		return
+	}
+
+	timeout := a.timeout
+	if t := time.Duration(req.TimeoutSeconds) * time.Second; t > 0 && t < timeout {
+		timeout = t
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	result, err := HandleWebSearch(ctx, a.apiKey, a.baseURL, req.toolArgs())
+	activeDashboard.record(ctx, req.Query, result, err, time.Since(started))
+	failed := err != nil || !result.Success
+	switch {
+	case failed && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		resp.Error = fmt.Sprintf("no answer within %s", timeout)
+		reply(http.StatusGatewayTimeout)
+		return
+	case err != nil:
+		resp.Error = err.Error()
+		reply(http.StatusBadGateway)
+		return
+	case !result.Success:
+		resp.Error = result.Error
+		reply(http.StatusBadGateway)
+		return
+	}
+
+	result = styleCitations(result, req.CitationStyle)
+	resp.OK, resp.Answer, resp.Model, resp.ID, resp.Cached = true, result.Answer, result.Model, result.ID, result.Cached
+	resp.Sources = distinctSources(result.Citations)
+	reply(http.StatusOK)
+}
+
+// distinctSources lists each cited page once, in citation order.
+func distinctSources(citations []Citation) []apiSource {
+	sources := []apiSource{}
+	seen := make(map[string]bool)
+	for _, c := range citations {
+		if !seen[c.URL] {
+			seen[c.URL] = true
+			sources = append(sources, apiSource{URL: c.URL, Title: c.Title})
+		}
+	}
+	return sources
+}
+
+// parseAPISearchRequest reads the JSON body and validates it.
+func parseAPISearchRequest(w http.ResponseWriter, r *http.Request) (apiSearchRequest, error) {
+	var req apiSearchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHTTPRequestBytes)).Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return req, errors.New("query is required")
+	}
+	style, err := parseCitationStyle(req.CitationStyle)
+	req.CitationStyle = style
+	return req, err
+}
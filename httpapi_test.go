@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchAPI(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input any `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		if strings.Contains(body.Input.(string), "slow") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_1", "status": "completed", "model": "gpt-5.4-mini",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+				"type": "output_text", "text": "Canberra.",
+				"annotations": []map[string]any{
+					{"type": "url_citation", "url": "https://example.gov.au/capital", "title": "Capital", "start_index": 0, "end_index": 8},
+					{"type": "url_citation", "url": "https://example.gov.au/capital", "title": "Capital", "start_index": 0, "end_index": 8},
+				},
+			}}}},
+		})
+	})
+	srv := httptest.NewServer(newSearchAPI(MCPConfig{APIKey: "k", BaseURL: base, APITimeout: 200 * time.Millisecond}))
+	t.Cleanup(srv.Close)
+
+	post := func(contentType, body string) (int, apiSearchResponse, map[string]any) {
+		t.Helper()
+		resp, err := http.Post(srv.URL, contentType, strings.NewReader(body)) //nolint:noctx
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var raw map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := json.Marshal(raw) //nolint:errcheck
+		var out apiSearchResponse
+		_ = json.Unmarshal(b, &out) //nolint:errcheck
+		return resp.StatusCode, out, raw
+	}
+
+	status, out, raw := post("application/json", `{"query":"Capital of Australia?","effort":"low"}`)
+	if status != http.StatusOK || !out.OK || out.Answer != "Canberra." || out.Model != "gpt-5.4-mini" || out.ID != "resp_1" {
+		t.Errorf("status %d, reply %+v", status, out)
+	}
+	if len(out.Sources) != 1 || out.Sources[0].Title != "Capital" {
+		t.Errorf("sources = %+v, want the one distinct page", out.Sources)
+	}
+	for _, key := range []string{"ok", "query", "answer", "sources", "model", "id", "cached", "elapsed_ms", "error"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("reply lacks %q", key)
+		}
+	}
+
+	if status, out, _ := post("application/json; charset=utf-8", `{"query":"slow question"}`); status != http.StatusGatewayTimeout || out.OK || !strings.Contains(out.Error, "no answer within") {
+		t.Errorf("slow answer: status %d, reply %+v", status, out)
+	}
+	if status, out, raw := post("application/json", `{}`); status != http.StatusBadRequest || out.Error == "" || raw["sources"] == nil {
+		t.Errorf("missing query: status %d, reply %v", status, raw)
+	}
+	// Browsers send these cross-site without asking the server first.
+	if status, _, _ := post("text/plain", `{"query":"Capital of Australia?"}`); status != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain body: status %d, want 415", status)
+	}
+	resp, err := http.Get(srv.URL + "?q=Capital") //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader(`{"query":"Capital of Australia?","citation_style":"footnotes"}`)) //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || !strings.Contains(out.Answer, "Sources:") {
+		t.Errorf("POST with footnotes: %+v, %v", out, err)
+	}
+}
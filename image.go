@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxImageBytes bounds local images sent inline; the API rejects larger
+// payloads anyway.
+const maxImageBytes = 20 << 20
+
+// loadImage resolves an image reference for CallAPIParams.Images. http(s)
+// and data: URLs pass through unchanged; anything else is read as a local
+// file and inlined as a base64 data URL.
+func loadImage(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || isImageURL(ref) {
+		return ref, nil
+	}
+
+	info, err := os.Stat(ref)
+	if err != nil {
+		return "", fmt.Errorf("read image: %w", err)
+	}
+	if info.Size() > maxImageBytes {
+		return "", fmt.Errorf("image %s is %d bytes; the limit is %d", ref, info.Size(), maxImageBytes)
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", fmt.Errorf("read image: %w", err)
+	}
+	mime := http.DetectContentType(data)
+	if !strings.HasPrefix(mime, "image/") {
+		return "", fmt.Errorf("%s is not an image (detected %s)", ref, mime)
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// loadClientImage is loadImage for an MCP client's reference: URLs pass
+// through, and a file path must resolve below attachRoot like an attachment.
+func loadClientImage(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || isImageURL(ref) {
+		return ref, nil
+	}
+	path, err := resolveAttachPath(attachRoot, ref)
+	if err != nil {
+		return "", fmt.Errorf("image: %w", err)
+	}
+	return loadImage(path)
+}
+
+// isImageURL reports whether ref is an http(s) or data:image URL rather
+// than a file path.
+func isImageURL(ref string) bool {
+	lower := strings.ToLower(ref)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "data:image/")
+}
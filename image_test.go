@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough of a PNG file for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestLoadImage(t *testing.T) {
+	t.Parallel()
+
+	for _, ref := range []string{"https://example.com/cat.png", "data:image/png;base64,AAAA"} {
+		if got, err := loadImage(ref); err != nil || got != ref {
+			t.Errorf("loadImage(%q) = %q, %v; want passthrough", ref, got, err)
+		}
+	}
+
+	dir := t.TempDir()
+	png := filepath.Join(dir, "chart.png")
+	if err := os.WriteFile(png, pngHeader, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadImage(png)
+	if err != nil || !strings.HasPrefix(got, "data:image/png;base64,iVBORw0K") {
+		t.Errorf("loadImage(file) = %q, %v; want PNG data URL", got, err)
+	}
+
+	txt := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(txt, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadImage(txt); err == nil || !strings.Contains(err.Error(), "not an image") {
+		t.Errorf("expected non-image error, got %v", err)
+	}
+	if _, err := loadImage(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoadClientImage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cat.png"), pngHeader, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	prev := attachRoot
+	t.Cleanup(func() { attachRoot = prev })
+
+	attachRoot = ""
+	if got, err := loadClientImage("https://example.com/cat.png"); err != nil || got != "https://example.com/cat.png" {
+		t.Errorf("URL = %q, %v", got, err)
+	}
+	if _, err := loadClientImage(filepath.Join(dir, "cat.png")); !errors.Is(err, ErrAttachDisabled) {
+		t.Errorf("path without ATTACH_DIR err = %v, want ErrAttachDisabled", err)
+	}
+
+	attachRoot = dir
+	if got, err := loadClientImage("cat.png"); err != nil || !strings.HasPrefix(got, "data:image/png;base64,") {
+		t.Errorf("path in ATTACH_DIR = %q, %v", got, err)
+	}
+	if _, err := loadClientImage("../cat.png"); !errors.Is(err, ErrAttachOutsideRoot) {
+		t.Errorf("path outside ATTACH_DIR err = %v, want ErrAttachOutsideRoot", err)
+	}
+}
+
+func TestCallAPI_SendsImageInput(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input []inputMessage `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(body.Input) != 1 || body.Input[0].Role != "user" || len(body.Input[0].Content) != 2 {
+			t.Fatalf("input = %+v", body.Input)
+		}
+		parts := body.Input[0].Content
+		if parts[0].Type != "input_text" || parts[0].Text != "what is this?" {
+			t.Errorf("text part = %+v", parts[0])
+		}
+		if parts[1].Type != "input_image" || parts[1].ImageURL != "https://example.com/cat.png" {
+			t.Errorf("image part = %+v", parts[1])
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "what is this?",
+		Images:  []string{"https://example.com/cat.png"},
+		Timeout: time.Second,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestChatMessage_ImageParts(t *testing.T) {
+	t.Parallel()
+
+	raw, err := json.Marshal(chatMessage{Role: "user", Content: "what is this?", Images: []string{"data:image/png;base64,AAAA"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}`
+	if string(raw) != want {
+		t.Errorf("got  %s\nwant %s", raw, want)
+	}
+	if raw, _ := json.Marshal(chatMessage{Role: "user", Content: "hi"}); string(raw) != `{"role":"user","content":"hi"}` {
+		t.Errorf("text-only message = %s", raw)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// inflightSearches coalesces identical concurrent searches so that only one
+// upstream call is made per key and every waiter receives its result.
+var inflightSearches singleflight.Group
+
+// coalescedWebSearch runs runWebSearch at most once per key across concurrent
+// callers: tool calls, ensemble members and background cache refreshes.
+// Each caller still honors its own context: a waiter that gives up returns
+// its own context error, and if the leader's call was cancelled while a
+// waiter is still interested, the waiter retries on its own behalf. An
+// empty key disables coalescing.
+func coalescedWebSearch(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, key string) (*WebSearchResult, error) {
+	if key == "" {
+		return runWebSearch(ctx, apiKey, baseURL, wa)
+	}
+
+	ch := inflightSearches.DoChan(key, func() (interface{}, error) {
+		return runWebSearch(ctx, apiKey, baseURL, wa)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			if errors.Is(r.Err, context.Canceled) && ctx.Err() == nil {
+				return runWebSearch(ctx, apiKey, baseURL, wa)
+			}
+			return nil, r.Err
+		}
+		if r.Shared {
+			Debug("Coalesced identical in-flight search", "query", wa.query)
+		}
+		// Hand each caller its own copy so later mutation stays local.
+		result := *r.Val.(*WebSearchResult)
+		return &result, nil
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandleWebSearch_CoalescesConcurrentIdenticalQueries(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	release := make(chan struct{})
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "resp_shared",
+			"model": "m",
+			"output": []map[string]any{
+				{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "shared"}}},
+			},
+		})
+	})
+
+	const callers = 5
+	args := map[string]interface{}{"query": "coalesce me", "reasoning_effort": "low"}
+	results := make([]*WebSearchResult, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := HandleWebSearch(context.Background(), "k", base, args)
+			if err != nil {
+				t.Errorf("caller %d: %v", i, err)
+				return
+			}
+			results[i] = res
+		}(i)
+	}
+
+	// Give every caller time to join the in-flight call before releasing it.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("upstream hits = %d, want 1", got)
+	}
+	for i, res := range results {
+		if res == nil || res.Answer != "shared" {
+			t.Errorf("caller %d got %+v", i, res)
+		}
+	}
+}
+
+func TestCoalescedWebSearch_WaiterHonorsOwnContext(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			close(started)
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp_slow",
+			"model":  "m",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "slow"}}}},
+		})
+	})
+
+	wa := webSearchArgs{query: "slow", model: "m", effort: "low", verbosity: "low"}
+	type outcome struct {
+		res *WebSearchResult
+		err error
+	}
+	leader, patient := make(chan outcome, 1), make(chan outcome, 1)
+	go func() {
+		res, err := coalescedWebSearch(context.Background(), "k", base, wa, "slow-key")
+		leader <- outcome{res, err}
+	}()
+	<-started
+
+	// The leader's upstream call is blocked: both waiters join it.
+	go func() {
+		res, err := coalescedWebSearch(context.Background(), "k", base, wa, "slow-key")
+		patient <- outcome{res, err}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := coalescedWebSearch(ctx, "k", base, wa, "slow-key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("impatient waiter: err = %v, want its own deadline", err)
+	}
+	close(release)
+
+	for name, ch := range map[string]chan outcome{"leader": leader, "patient waiter": patient} {
+		if o := <-ch; o.err != nil || o.res == nil || o.res.Answer != "slow" {
+			t.Errorf("%s got %+v, %v", name, o.res, o.err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("upstream hits = %d, want 1", got)
+	}
+}
+
+func TestRunEnsemble_CoalescesWithConcurrentCalls(t *testing.T) {
+	var openaiHits, deepseekHits atomic.Int32
+	release := make(chan struct{})
+	_, openaiBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		openaiHits.Add(1)
+		<-release
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "openai"}}}},
+		})
+	})
+	_, deepseekBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		deepseekHits.Add(1)
+		<-release
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":      "ds",
+			"choices": []map[string]any{{"message": map[string]any{"content": "deepseek"}}},
+		})
+	})
+	orig := providers["deepseek"].defaultURL
+	providers["deepseek"].defaultURL = deepseekBase
+	t.Cleanup(func() { providers["deepseek"].defaultURL = orig })
+	providerKeys = map[string]string{"deepseek": "dk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	args := map[string]any{"query": "coalesce the ensemble", "reasoning_effort": "low"}
+	wa := extractWebSearchArgs(args)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := runEnsemble(context.Background(), "ok", openaiBase, wa, []string{"openai", "deepseek"}, false); err != nil {
+				t.Errorf("runEnsemble: %v", err)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if res, err := HandleWebSearch(context.Background(), "ok", openaiBase, args); err != nil || res.Answer != "openai" {
+			t.Errorf("HandleWebSearch = %+v, %v", res, err)
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if openaiHits.Load() != 1 || deepseekHits.Load() != 1 {
+		t.Errorf("upstream hits: openai %d, deepseek %d; want 1 each", openaiHits.Load(), deepseekHits.Load())
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// lane is the priority class of an upstream call. Interactive calls (a
+// tool call or CLI question someone is waiting on) go before batch calls.
+type lane int
+
+const (
+	laneInteractive lane = iota
+	laneBatch
+)
+
+func (l lane) String() string {
+	if l == laneBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+type laneKey struct{}
+
+// withLane marks the calls made with ctx as belonging to l.
+func withLane(ctx context.Context, l lane) context.Context {
+	return context.WithValue(ctx, laneKey{}, l)
+}
+
+// laneFrom returns the lane of ctx; unmarked calls are interactive.
+func laneFrom(ctx context.Context) lane {
+	l, _ := ctx.Value(laneKey{}).(lane)
+	return l
+}
+
+// callPool bounds the upstream calls in flight (env MAX_CONCURRENT_CALLS).
+// Waiting interactive calls always get the next free slot before waiting
+// batch calls, and batch calls never hold the last slot, so a background
+// batch cannot starve an agent waiting on an answer. A nil *callPool admits
+// every call at once.
+type callPool struct {
+	// maxQueue rejects calls once that many are waiting and maxWait gives
+	// up on a wait that long (env MAX_QUEUED_CALLS, QUEUE_TIMEOUT); zero
+	// means no limit.
+	maxQueue int
+	maxWait  time.Duration
+
+	mu         sync.Mutex
+	size       int
+	inUse      int
+	batchInUse int
+	// waiting holds the FIFO queue of each lane; closing a channel hands
+	// the slot to its waiter.
+	waiting [2][]chan struct{}
+	// avgCall is a moving average of how long calls hold a slot, for wait
+	// estimates.
+	avgCall time.Duration
+}
+
+// activePool limits upstream calls; set at startup from MAX_CONCURRENT_CALLS.
+var activePool *callPool
+
+// newCallPool returns nil when size is not positive (no limit).
+func newCallPool(size, maxQueue int, maxWait time.Duration) *callPool {
+	if size <= 0 {
+		return nil
+	}
+	return &callPool{size: size, maxQueue: max(maxQueue, 0), maxWait: max(maxWait, 0)}
+}
+
+// acquire waits for a slot in lane l and returns the function that gives it
+// back. It fails when ctx ends first, and with a *LimitError when the queue
+// is full or the wait exceeds maxWait.
+func (p *callPool) acquire(ctx context.Context, l lane) (release func(), err error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	p.mu.Lock()
+	if p.queued(l) == 0 && p.canStart(l) {
+		p.start(l)
+		p.mu.Unlock()
+		return p.releaser(l), nil
+	}
+	if p.maxQueue > 0 && len(p.waiting[laneInteractive])+len(p.waiting[laneBatch]) >= p.maxQueue {
+		err := p.limitError("queue_full", l, fmt.Sprintf("%d calls already waiting for an upstream slot", p.maxQueue))
+		p.mu.Unlock()
+		return nil, err
+	}
+	ready := make(chan struct{})
+	p.waiting[l] = append(p.waiting[l], ready)
+	p.mu.Unlock()
+	Debug("Waiting for an upstream call slot", "lane", l)
+
+	var timeout <-chan time.Time
+	if p.maxWait > 0 {
+		timer := time.NewTimer(p.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+		return p.releaser(l), nil
+	case <-ctx.Done():
+		return nil, p.abandon(l, ready, func() error { return ctx.Err() })
+	case <-timeout:
+		return nil, p.abandon(l, ready, func() error {
+			return p.limitError("queue_timeout", l, fmt.Sprintf("no upstream slot within %s", p.maxWait))
+		})
+	}
+}
+
+// abandon takes a waiter out of the queue and returns the error made by
+// cause. A slot handed over meanwhile is passed on.
+func (p *callPool) abandon(l lane, ready chan struct{}, cause func() error) error {
+	p.mu.Lock()
+	if i := slices.Index(p.waiting[l], ready); i >= 0 {
+		p.waiting[l] = slices.Delete(p.waiting[l], i, i+1)
+		err := cause()
+		p.mu.Unlock()
+		return err
+	}
+	err := cause()
+	p.mu.Unlock()
+	p.release(l, 0)
+	return err
+}
+
+// health reports the queue as seen by a new call in l: calls waiting,
+// calls in flight, and the estimated wait for a slot.
+func (p *callPool) health(l lane) (queued, inFlight int, wait time.Duration) {
+	if p == nil {
+		return 0, 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.waiting[laneInteractive]) + len(p.waiting[laneBatch]), p.inUse, p.estimatedWait(l)
+}
+
+// estimatedWait guesses how long a new call in l waits for a slot: the
+// calls ahead of it, served size at a time. Callers hold p.mu.
+func (p *callPool) estimatedWait(l lane) time.Duration {
+	if p.queued(l) == 0 && p.canStart(l) {
+		return 0
+	}
+	perCall := cmp.Or(p.avgCall, expectedEffortDuration[defaultEffort])
+	return perCall * time.Duration(p.queued(l)/p.size+1)
+}
+
+// limitError describes the queue for a call in l. Callers hold p.mu.
+func (p *callPool) limitError(reason string, l lane, msg string) *LimitError {
+	wait := p.estimatedWait(l)
+	return &LimitError{
+		Reason:        reason,
+		Lane:          l.String(),
+		QueueDepth:    len(p.waiting[laneInteractive]) + len(p.waiting[laneBatch]),
+		InFlight:      p.inUse,
+		EstimatedWait: wait,
+		RetryAfter:    wait,
+		Message:       msg,
+	}
+}
+
+// queued counts the waiters l must let go first: its own lane's, plus the
+// interactive lane's for a batch call.
+func (p *callPool) queued(l lane) int {
+	if l == laneBatch {
+		return len(p.waiting[laneInteractive]) + len(p.waiting[laneBatch])
+	}
+	return len(p.waiting[laneInteractive])
+}
+
+// canStart reports whether a call in l fits; batch calls leave one slot
+// free for interactive ones unless the pool has a single slot.
+func (p *callPool) canStart(l lane) bool {
+	if p.inUse >= p.size {
+		return false
+	}
+	return l == laneInteractive || p.batchInUse < max(p.size-1, 1)
+}
+
+func (p *callPool) start(l lane) {
+	p.inUse++
+	if l == laneBatch {
+		p.batchInUse++
+	}
+}
+
+func (p *callPool) releaser(l lane) func() {
+	var once sync.Once
+	start := time.Now()
+	return func() { once.Do(func() { p.release(l, time.Since(start)) }) }
+}
+
+// release frees a slot in l that was held for took (zero when unused) and
+// hands free slots to the waiters, interactive ones first.
+func (p *callPool) release(l lane, took time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inUse--
+	if l == laneBatch {
+		p.batchInUse--
+	}
+	if took > 0 {
+		if p.avgCall == 0 {
+			p.avgCall = took
+		} else {
+			p.avgCall += (took - p.avgCall) / 5
+		}
+	}
+	for _, next := range []lane{laneInteractive, laneBatch} {
+		for len(p.waiting[next]) > 0 && p.canStart(next) {
+			ready := p.waiting[next][0]
+			p.waiting[next] = p.waiting[next][1:]
+			p.start(next)
+			close(ready)
+		}
+	}
+}
+
+// defaultRateLimitRetryAfter is the retry hint for a 429 that came without
+// a Retry-After header.
+const defaultRateLimitRetryAfter = 30 * time.Second
+
+// limitErrorFor returns the *LimitError behind err, describing an upstream
+// 429 as one too, or nil for other errors.
+func limitErrorFor(l lane, err error) *LimitError {
+	var le *LimitError
+	if errors.As(err, &le) {
+		return le
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	queued, inFlight, wait := activePool.health(l)
+	return &LimitError{
+		Reason:        "rate_limited",
+		Lane:          l.String(),
+		QueueDepth:    queued,
+		InFlight:      inFlight,
+		EstimatedWait: wait,
+		RetryAfter:    max(cmp.Or(apiErr.RetryAfter, defaultRateLimitRetryAfter), wait),
+		Message:       apiErr.Error(),
+	}
+}
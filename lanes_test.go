@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCallPool_InteractiveFirst(t *testing.T) {
+	t.Parallel()
+
+	p := newCallPool(2, 0, 0)
+	ctx := context.Background()
+	acquire := func(l lane) <-chan func() {
+		got := make(chan func(), 1)
+		go func() {
+			release, err := p.acquire(ctx, l)
+			if err != nil {
+				t.Errorf("acquire(%s): %v", l, err)
+			}
+			got <- release
+		}()
+		return got
+	}
+	waitFor := func(got <-chan func(), what string) func() {
+		t.Helper()
+		select {
+		case release := <-got:
+			return release
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s did not get a slot", what)
+			return nil
+		}
+	}
+	stillWaiting := func(got <-chan func(), what string) {
+		t.Helper()
+		select {
+		case <-got:
+			t.Fatalf("%s got a slot early", what)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	releaseBatch := waitFor(acquire(laneBatch), "first batch call")
+	// The last free slot is kept for interactive calls.
+	secondBatch := acquire(laneBatch)
+	stillWaiting(secondBatch, "second batch call")
+	releaseInteractive := waitFor(acquire(laneInteractive), "interactive call")
+
+	// The pool is full; an interactive call queued after the batch call
+	// still gets the next slot.
+	lateInteractive := acquire(laneInteractive)
+	stillWaiting(lateInteractive, "queued interactive call")
+	releaseBatch()
+	releaseLate := waitFor(lateInteractive, "queued interactive call")
+	stillWaiting(secondBatch, "second batch call")
+
+	releaseInteractive()
+	releaseInteractive() // releasing twice is harmless
+	waitFor(secondBatch, "second batch call")()
+	releaseLate()
+
+	if p.inUse != 0 || p.batchInUse != 0 {
+		t.Errorf("in use = %d (batch %d) after every release, want 0", p.inUse, p.batchInUse)
+	}
+}
+
+func TestCallPool_CancelWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	p := newCallPool(1, 0, 0)
+	release, err := p.acquire(context.Background(), laneInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.acquire(ctx, laneBatch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+	release()
+	if len(p.waiting[laneBatch]) != 0 || p.inUse != 0 {
+		t.Errorf("cancelled waiter left behind: waiting=%d inUse=%d", len(p.waiting[laneBatch]), p.inUse)
+	}
+
+	var unlimited *callPool
+	if release, err := unlimited.acquire(context.Background(), laneBatch); err != nil || release == nil {
+		t.Errorf("nil pool acquire = %v", err)
+	}
+	if laneFrom(withLane(context.Background(), laneBatch)) != laneBatch || laneFrom(context.Background()) != laneInteractive {
+		t.Error("lane not carried by the context")
+	}
+}
+
+func TestCallPool_LimitErrors(t *testing.T) {
+	t.Parallel()
+
+	p := newCallPool(1, 1, 30*time.Millisecond)
+	p.avgCall = 10 * time.Second
+	release, err := p.acquire(context.Background(), laneInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	waited := make(chan error, 1)
+	go func() {
+		_, err := p.acquire(context.Background(), laneInteractive)
+		waited <- err
+	}()
+	// Wait until the second call is queued, then overflow the queue.
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		p.mu.Lock()
+		n := len(p.waiting[laneInteractive])
+		p.mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// A batch call waits behind the queued interactive call: two average calls.
+	var le *LimitError
+	if _, err := p.acquire(context.Background(), laneBatch); !errors.As(err, &le) || le.Reason != "queue_full" {
+		t.Fatalf("overflow err = %v, want queue_full", err)
+	}
+	if le.QueueDepth != 1 || le.InFlight != 1 || le.Lane != "batch" || le.EstimatedWait != 20*time.Second || le.RetryAfter != le.EstimatedWait {
+		t.Errorf("queue_full = %+v", le)
+	}
+
+	if err := <-waited; !errors.As(err, &le) || le.Reason != "queue_timeout" {
+		t.Fatalf("slow wait err = %v, want queue_timeout", err)
+	}
+	if len(p.waiting[laneInteractive]) != 0 {
+		t.Error("timed-out waiter left in the queue")
+	}
+}
+
+func TestToolErrorResult(t *testing.T) {
+	t.Parallel()
+
+	rateLimited := fmt.Errorf("search: %w", &APIError{StatusCode: http.StatusTooManyRequests, Message: "slow down", RetryAfter: 7 * time.Second})
+	result := toolErrorResult(context.Background(), rateLimited)
+	if !result.IsError {
+		t.Fatal("result is not an error")
+	}
+	raw, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["reason"] != "rate_limited" || got["retry_after_seconds"] != float64(7) || got["retry_after"] != "7s" || got["lane"] != "interactive" {
+		t.Errorf("structured error = %s", raw)
+	}
+
+	plain := toolErrorResult(context.Background(), errors.New("bad request"))
+	if !plain.IsError || plain.StructuredContent != nil {
+		t.Errorf("plain error = %+v, want text only", plain)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lastResponse is the CLI's most recent stored answer, kept so the next run
+// can follow up on it with -continue. The question is not kept: the file is
+// not sealed, and the history holds it for those who record one.
+type lastResponse struct {
+	ID       string    `json:"id"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// lastResponsePath returns LAST_RESPONSE_FILE, or last_response.json in the
+// data directory.
+func lastResponsePath() (string, error) {
+	if p := os.Getenv("LAST_RESPONSE_FILE"); p != "" {
+		return p, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate last response file: %w", err)
+	}
+	return filepath.Join(dir, "last_response.json"), nil
+}
+
+// chainsResponses reports whether provider answers can be continued with
+// previous_response_id; other providers keep no server-side conversation.
+func chainsResponses(provider string) bool {
+	return provider == providerOpenAI || provider == providerAzure
+}
+
+// saveLastResponse records r as the answer -continue follows up on.
+func saveLastResponse(r lastResponse) error {
+	path, err := lastResponsePath()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	return writeFileAtomic(path, raw, 0o600)
+}
+
+// loadLastResponse returns the recorded answer, or ErrNoLastResponse when
+// there is none.
+func loadLastResponse() (lastResponse, error) {
+	var r lastResponse
+	path, err := lastResponsePath()
+	if err != nil {
+		return r, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, ErrNoLastResponse
+	}
+	if err != nil {
+		return r, fmt.Errorf("read last response: %w", err)
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return r, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if r.ID == "" {
+		return r, ErrNoLastResponse
+	}
+	return r, nil
+}
+
+// purgeLastResponse deletes the recorded answer when it is older than the
+// cutoff, and reports whether it did or, in a dry run, would.
+func purgeLastResponse(before time.Time, dryRun bool) (bool, error) {
+	last, err := loadLastResponse()
+	if errors.Is(err, ErrNoLastResponse) || (err == nil && !last.Time.Before(before)) {
+		return false, nil
+	}
+	if err != nil || dryRun {
+		return err == nil, err
+	}
+	path, err := lastResponsePath()
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("purge last response: %w", err)
+	}
+	return true, nil
+}
+
+// continueFrom resolves -continue: the ID of the last answer, which must
+// have come from provider, since a response ID means nothing to another
+// backend.
+func continueFrom(provider string) (string, error) {
+	if !chainsResponses(provider) {
+		return "", fmt.Errorf("-continue needs a provider with stored responses (openai or azure), not %s", provider)
+	}
+	last, err := loadLastResponse()
+	if err != nil {
+		return "", err
+	}
+	if last.Provider != provider {
+		return "", fmt.Errorf("-continue: the last answer came from %s, not %s", last.Provider, provider)
+	}
+	return last.ID, nil
+}
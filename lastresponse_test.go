@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContinueFrom(t *testing.T) {
+	t.Setenv("LAST_RESPONSE_FILE", filepath.Join(t.TempDir(), "state", "last_response.json"))
+
+	if _, err := continueFrom(providerOpenAI); !errors.Is(err, ErrNoLastResponse) {
+		t.Fatalf("without a record: err = %v, want ErrNoLastResponse", err)
+	}
+
+	rememberLastResponse(cliArgs{question: "q", noStore: true}, providerOpenAI, WebSearchResult{ID: "resp_unstored"})
+	rememberLastResponse(cliArgs{question: "q"}, providerAnthropic, WebSearchResult{ID: "msg_1"})
+	if _, err := loadLastResponse(); !errors.Is(err, ErrNoLastResponse) {
+		t.Errorf("unstored or unchainable answer was recorded: %v", err)
+	}
+
+	rememberLastResponse(cliArgs{question: "capital of France?"}, providerOpenAI, WebSearchResult{ID: "resp_1", Model: "gpt-5.4-mini"})
+	id, err := continueFrom(providerOpenAI)
+	if err != nil || id != "resp_1" {
+		t.Errorf("continueFrom = %q, %v; want resp_1", id, err)
+	}
+	if last, err := loadLastResponse(); err != nil || last.Model != "gpt-5.4-mini" || last.Time.IsZero() {
+		t.Errorf("last = %+v, %v", last, err)
+	}
+	if _, err := continueFrom(providerAzure); err == nil {
+		t.Error("continued an OpenAI answer on Azure")
+	}
+	if _, err := continueFrom(providerGemini); err == nil {
+		t.Error("continued on a provider without stored responses")
+	}
+}
+
+func TestPurgeLastResponse(t *testing.T) {
+	t.Setenv("LAST_RESPONSE_FILE", filepath.Join(t.TempDir(), "last_response.json"))
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if purged, err := purgeLastResponse(cutoff, false); err != nil || purged {
+		t.Fatalf("without a record = %t, %v", purged, err)
+	}
+	if err := saveLastResponse(lastResponse{ID: "resp_1", Provider: providerOpenAI, Time: cutoff}); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := purgeLastResponse(cutoff, false); err != nil || purged {
+		t.Fatalf("record at the cutoff = %t, %v; want kept", purged, err)
+	}
+	if err := saveLastResponse(lastResponse{ID: "resp_1", Provider: providerOpenAI, Time: cutoff.Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := purgeLastResponse(cutoff, true); err != nil || !purged {
+		t.Fatalf("dry run = %t, %v; want true", purged, err)
+	}
+	if purged, err := purgeLastResponse(cutoff, false); err != nil || !purged {
+		t.Fatalf("purge = %t, %v; want true", purged, err)
+	}
+	if _, err := loadLastResponse(); !errors.Is(err, ErrNoLastResponse) {
+		t.Errorf("after purge: %v, want ErrNoLastResponse", err)
+	}
+}
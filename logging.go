@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/mark3labs/mcp-go/util"
 )
@@ -12,7 +14,8 @@ import (
 // Centralized structured logger using slog with dynamic level control.
 
 var (
-	logger   *slog.Logger
+	// logger is swapped by teeLog while other goroutines log.
+	logger   atomic.Pointer[slog.Logger]
 	levelVar slog.LevelVar
 	once     sync.Once
 )
@@ -26,12 +29,24 @@ func initLogger(verbose bool) {
 			levelVar.Set(slog.LevelInfo)
 		}
 		handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: &levelVar})
-		logger = slog.New(handler)
+		logger.Store(slog.New(handler))
 	})
 }
 
+// teeLog copies log records to w as well as stderr; nil stops copying.
+func teeLog(w io.Writer) {
+	ensureLogger()
+	var out io.Writer = os.Stderr
+	if w != nil {
+		out = io.MultiWriter(os.Stderr, w)
+	}
+	logger.Store(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: &levelVar})))
+}
+
 // setVerbose updates the log level at runtime to debug when true, otherwise info.
 func setVerbose(verbose bool) {
+	// The first use of the logger would reset the level.
+	ensureLogger()
 	if verbose {
 		levelVar.Set(slog.LevelDebug)
 	} else {
@@ -39,35 +54,46 @@ func setVerbose(verbose bool) {
 	}
 }
 
+// setQuiet keeps only errors, for CLI runs that want the answer alone.
+func setQuiet() {
+	ensureLogger()
+	levelVar.Set(slog.LevelError)
+}
+
+// debugEnabled reports whether debug records are logged, so callers can
+// skip collecting what only verbose logging shows.
+func debugEnabled() bool {
+	return levelVar.Level() <= slog.LevelDebug
+}
+
 // Debug logs at debug level with optional structured key/value pairs.
 func Debug(msg string, args ...any) {
 	ensureLogger()
-	logger.Debug(msg, args...)
+	logger.Load().Debug(msg, args...)
 }
 
 // Info logs at info level with optional structured key/value pairs.
 func Info(msg string, args ...any) {
 	ensureLogger()
-	logger.Info(msg, args...)
+	logger.Load().Info(msg, args...)
 }
 
 // Warn logs at warn level with optional structured key/value pairs.
 func Warn(msg string, args ...any) {
 	ensureLogger()
-	logger.Warn(msg, args...)
+	logger.Load().Warn(msg, args...)
 }
 
 // Error logs at error level with optional structured key/value pairs.
 func Error(msg string, args ...any) {
 	ensureLogger()
-	logger.Error(msg, args...)
+	logger.Load().Error(msg, args...)
 }
 
 func ensureLogger() {
-	if logger == nil {
-		// Default initialize to info level if not set up explicitly.
-		initLogger(false)
-	}
+	// Default initialize to info level if not set up explicitly. Going
+	// through once also orders the first use across goroutines.
+	initLogger(false)
 }
 
 // mcpLogAdapter bridges mcp-go's util.Logger interface to our slog setup.
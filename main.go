@@ -1,11 +1,14 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -17,24 +20,92 @@ func main() {
 		runMCPMode()
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		runHistory(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "models" {
+		runModels(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		runPurge(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compliance" {
+		runCompliance(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "runs" {
+		runRuns(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "chat" {
+		runChat(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rpc" {
+		runRPC(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "domains" {
+		runDomains(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sessions" {
+		runSessions(os.Args[2:])
+		return
+	}
 
 	// Original CLI mode
 	runCLI()
 }
 
 func runMCPMode() {
+	if len(os.Args) > 2 && (os.Args[2] == "stop" || os.Args[2] == "status") {
+		runMCPControl(os.Args[2], os.Args[3:])
+		return
+	}
+
+	// A profile must be applied before the flag defaults and the environment
+	// are read.
+	if err := selectProfile(os.Args[2:]); err != nil {
+		Error("Failed to load profile", "error", err)
+		os.Exit(1)
+	}
+
 	// Create a new flag set for MCP subcommand
 	mcpFlags := flag.NewFlagSet("mcp", flag.ExitOnError)
 
 	var (
 		transport   = mcpFlags.String("t", "stdio", "Transport type (stdio or http)")
-		port        = mcpFlags.String("port", "8080", "HTTP server port")
+		port        = mcpFlags.String("port", "8080", "HTTP server port (0 picks a free port; see the MCP_URL line or -addr-file)")
 		host        = mcpFlags.String("host", "127.0.0.1", "HTTP server host (default: 127.0.0.1)")
-		baseURL     = mcpFlags.String("base", defaultBaseURL, "API base URL")
+		baseURL     = mcpFlags.String("base", cmp.Or(activeProfile.BaseURL, defaultBaseURL), "API base URL")
+		schemaVer   = mcpFlags.Int("schema-version", resultSchemaVersion, "Version of the gpt_websearch and get_response result shape to send; pin an earlier one for clients built against it")
+		_           = mcpFlags.String("profile", activeProfile.Name, "Named profile from the profiles file: model, effort, verbosity, provider, base URL and API key (env PROFILE)")
 		verbose     = mcpFlags.Bool("verbose", false, "Enable verbose logging")
 		authEnabled = mcpFlags.Bool("auth-enabled", false, "Enable JWT authentication for HTTP transport (requires GEMINI_AUTH_SECRET_KEY env var)")
 		heartbeat   = mcpFlags.Duration("heartbeat", 30*time.Second,
 			"SSE heartbeat interval for HTTP transport (0 to disable); keeps long-running requests alive through proxies")
+		adminTools = mcpFlags.Bool("admin-tools", false, "Expose administrative tools (purge) to MCP clients")
+		readOnly   = mcpFlags.Bool("read-only", false, "Disable state changes: no history recording and no admin tools; only search tools are exposed")
+		dashboard  = mcpFlags.Bool("dashboard", false, "Serve a web dashboard at /dashboard/ on the HTTP transport (recent queries, usage, cache stats; requires -auth-enabled)")
+		api        = mcpFlags.Bool("api", false, "Serve a synchronous JSON endpoint, POST /api/search, on the HTTP transport for workflow tools")
+		apiTimeout = mcpFlags.Duration("api-timeout", defaultAPITimeout, "With -api, how long a request waits for its answer before a 504")
+		daemon     = mcpFlags.Bool("daemon", false, "Run the HTTP server in the background, logging to -log-file; stop it with 'answer mcp stop'")
+		pidFile    = mcpFlags.String("pid-file", "", "Write the server's PID to this file (default with -daemon: $XDG_DATA_HOME/websearch/mcp.pid)")
+		addrFile   = mcpFlags.String("addr-file", "", "Write the address the server listens on to this file as JSON (default with -daemon: $XDG_DATA_HOME/websearch/mcp.addr.json)")
+		logFile    = mcpFlags.String("log-file", "", "With -daemon, append the server's log to this file (default $XDG_DATA_HOME/websearch/mcp.log)")
+		webhooks   = mcpFlags.Bool("webhooks", false, "Serve POST /hooks/query on the HTTP transport: answer in the background and POST the result to a callback URL (requires -auth-enabled)")
 	)
 
 	// Also support long form for transport
@@ -57,6 +128,11 @@ func runMCPMode() {
 	// Honor -verbose for logger level
 	setVerbose(*verbose)
 
+	if err := setResultSchema(*schemaVer); err != nil {
+		Error("Invalid -schema-version", "error", err)
+		os.Exit(1)
+	}
+
 	// Load environment config
 	envCfg, err := loadEnvConfig()
 	if err != nil {
@@ -70,19 +146,101 @@ func runMCPMode() {
 		Error("GEMINI_AUTH_SECRET_KEY must be set when --auth-enabled is used")
 		os.Exit(1)
 	}
+	if *webhooks && (!*authEnabled || *transport != "http") {
+		Error("-webhooks needs the HTTP transport and -auth-enabled")
+		os.Exit(1)
+	}
+	// The dashboard shows everyone's queries and runs new ones.
+	if *dashboard && (!*authEnabled || *transport != "http") {
+		Error("-dashboard needs the HTTP transport and -auth-enabled")
+		os.Exit(1)
+	}
+	if *daemon {
+		if *transport != "http" {
+			Error("-daemon needs the HTTP transport (-t http)")
+			os.Exit(1)
+		}
+		runDaemon(os.Args[2:], *pidFile, *addrFile, *logFile)
+		return
+	}
 
 	// Create server configuration using the config helper
 	cfg := parseMCPConfig(MCPConfigParams{
-		APIKey:        envCfg.APIKey,
-		BaseURL:       *baseURL,
-		Transport:     *transport,
-		Port:          *port,
-		Host:          *host,
-		Verbose:       *verbose,
-		AuthEnabled:   *authEnabled,
-		AuthSecretKey: authSecretKey,
-		Heartbeat:     *heartbeat,
+		APIKey:              envCfg.APIKey,
+		BaseURL:             *baseURL,
+		Transport:           *transport,
+		Port:                *port,
+		Host:                *host,
+		Verbose:             *verbose,
+		AuthEnabled:         *authEnabled,
+		AuthSecretKey:       authSecretKey,
+		Heartbeat:           *heartbeat,
+		AdminTools:          *adminTools,
+		ReadOnly:            *readOnly,
+		Dashboard:           *dashboard,
+		Webhooks:            *webhooks,
+		WebhookSecret:       os.Getenv("WEBHOOK_SECRET"),
+		WebhookAllowedHosts: os.Getenv("WEBHOOK_ALLOWED_HOSTS"),
+		API:                 *api,
+		APITimeout:          *apiTimeout,
+		PIDFile:             *pidFile,
+		AddrFile:            *addrFile,
 	})
+	if cfg.ReadOnly {
+		if *adminTools {
+			Warn("Ignoring -admin-tools in read-only mode")
+		}
+		envCfg.History = false
+		Info("Read-only mode: history recording and admin tools are disabled")
+	}
+
+	if err := applyRuntimeConfig(envCfg); err != nil {
+		Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	cfg.BaseURL = resolveBaseURL(cfg.BaseURL)
+	if activeGateway != nil {
+		Info("Routing API calls through gateway", "gateway", activeGateway.name, "endpoint", cfg.BaseURL)
+	}
+	if offlineMode {
+		Warn("Offline mode: upstream API calls are disabled; serving cached answers only")
+	}
+
+	if answerCache != nil {
+		Info("Answer cache enabled", "ttl", envCfg.CacheTTL, "stale_while_revalidate", envCfg.CacheStaleFor, "dir", envCfg.CacheDir)
+	}
+	if envCfg.SemanticCache != "" && !activeFeatures.enabled(featureSemanticCache) {
+		Info("Semantic cache disabled by FEATURES", "mode", envCfg.SemanticCache)
+		envCfg.SemanticCache = ""
+	}
+	embed, err := newEmbedder(envCfg.SemanticCache, cfg.BaseURL, envCfg.EmbeddingModel)
+	if err != nil {
+		Error("Invalid semantic cache configuration", "error", err)
+		os.Exit(1)
+	}
+	querySemanticCache = newSemanticCache(embed, envCfg.SemanticThreshold, envCfg.CacheTTL)
+	if querySemanticCache != nil {
+		Info("Semantic cache enabled", "mode", envCfg.SemanticCache, "threshold", querySemanticCache.threshold)
+	}
+
+	if mcpPlugins, err = loadPlugins(envCfg.PluginsFile); err != nil {
+		Error("Invalid plugin configuration", "error", err)
+		os.Exit(1)
+	}
+	switch {
+	case len(mcpPlugins) > 0 && cfg.ReadOnly:
+		Warn("Ignoring PLUGINS_FILE in read-only mode", "plugins", len(mcpPlugins))
+	case len(mcpPlugins) > 0:
+		Info("Plugin tools enabled", "plugins", len(mcpPlugins), "file", envCfg.PluginsFile)
+	}
+
+	if cfg.API && cfg.Transport != "http" {
+		Warn("Ignoring -api: it needs the HTTP transport")
+	}
+	if cfg.Dashboard {
+		activeDashboard = newDashboard()
+	}
+	answerHistory.snapshotInBackground()
 
 	// Create and run MCP server
 	mcpServer := NewMCPServer(cfg)
@@ -103,6 +261,121 @@ func runMCPMode() {
 		Error("Unknown transport (use 'stdio' or 'http')", "transport", cfg.Transport)
 		os.Exit(1)
 	}
+	// Let background archives of cited pages finish.
+	answerHistory.Close() //nolint:errcheck
+}
+
+// applyRuntimeConfig installs the process-wide settings shared by CLI and MCP
+// mode: offline switch, retry policy, outbound proxy, feature flags, web
+// search location hint, gateway, provider selection, glossary, history and
+// answer post-processing.
+func applyRuntimeConfig(envCfg EnvConfig) error {
+	offlineMode = envCfg.Offline
+	attachRoot = envCfg.AttachDir
+	idempotencyKeys = envCfg.IdempotencyKeys
+	noStoreDefault = envCfg.NoStore
+	gzipRequests = envCfg.GzipRequests
+	applyRetryConfig(envCfg)
+	if err := configureHTTPClient(envCfg); err != nil {
+		return err
+	}
+	activeHooks = newHooks(envCfg.HookPreQuery, envCfg.HookPostAnswer, envCfg.HookTimeout)
+	activePool = newCallPool(envCfg.MaxConcurrentCalls, envCfg.MaxQueuedCalls, envCfg.QueueTimeout)
+	activePager = newAnswerPager(envCfg.AnswerPageSize)
+	if path, err := domainsPath(); err == nil {
+		if activeDomains, err = loadDomainOverrides(path); err != nil {
+			return err
+		}
+	}
+
+	features, err := parseFeatures(envCfg.Features)
+	if err != nil {
+		return err
+	}
+	activeFeatures = features
+	if activeDowngrade, err = newDowngrader(envCfg.DowngradePolicy, envCfg.DowngradeAfter, envCfg.DowngradeWindow); err != nil {
+		return err
+	}
+
+	if webSearchTools, err = parseSearchToolTypes(envCfg.WebSearchToolType, envCfg.WebSearchToolModels); err != nil {
+		return err
+	}
+	if defaultCitationStyle, err = parseCitationStyle(envCfg.CitationStyle); err != nil {
+		return fmt.Errorf("CITATION_STYLE: %w", err)
+	}
+
+	loc, err := parseUserLocation(envCfg.UserLocation)
+	if err != nil {
+		return err
+	}
+	defaultUserLocation = loc
+
+	if activeSealer, err = newStoreSealer(envCfg.StorePassphrase, envCfg.StoreKeychain); err != nil {
+		return err
+	}
+
+	gw, err := newGateway(envCfg.Gateway, envCfg.GatewayURL, envCfg.GatewayHeaders)
+	if err != nil {
+		return err
+	}
+	activeGateway = gw
+	if extraHeaders, err = parseHeaderPairs(envCfg.ExtraHeaders); err != nil {
+		return fmt.Errorf("EXTRA_HEADERS: %w", err)
+	}
+
+	if envCfg.ComplianceLog != "" {
+		cl, err := openComplianceLog(envCfg.ComplianceLog)
+		if err != nil {
+			return err
+		}
+		activeCompliance = cl
+	}
+
+	// Answer cache is opt-in; nil keeps it disabled.
+	answerCache = newResponseCache(envCfg.CacheTTL, envCfg.CacheStaleFor)
+	if err := answerCache.useDisk(envCfg.CacheDir, activeSealer); err != nil {
+		return err
+	}
+
+	defaultProvider = envCfg.Provider
+	providerKeys = envCfg.ProviderKeys
+	azure = envCfg.Azure
+	ollama = envCfg.Ollama
+
+	g, err := loadGlossary(envCfg.GlossaryFile)
+	if err != nil {
+		return err
+	}
+	activeGlossary = g
+	envCfg.PostProcess.Glossary = g
+
+	if activeWasm, err = loadWasmPlugins(context.Background(), envCfg.WasmPlugins); err != nil {
+		return err
+	}
+	envCfg.PostProcess.Plugins = activeWasm
+
+	if envCfg.History {
+		path, err := historyPath()
+		if err != nil {
+			return err
+		}
+		if answerHistory, err = openHistory(path); err != nil {
+			return err
+		}
+		if err := answerHistory.useEmbeddings(envCfg.HistoryEmbeddings, resolveBaseURL(defaultBaseURL), envCfg.EmbeddingModel, envCfg.APIKey); err != nil {
+			return err
+		}
+		if err := answerHistory.useSnapshots(envCfg.HistorySnapshots); err != nil {
+			return err
+		}
+	}
+
+	pipeline, err := newPostProcessor(envCfg.PostProcess)
+	if err != nil {
+		return err
+	}
+	answerPipeline = pipeline
+	return nil
 }
 
 // cliArgs holds the resolved command-line + environment configuration for runCLI.
@@ -113,9 +386,48 @@ type cliArgs struct {
 	verbosity      string
 	question       string
 	promptCacheKey string
+	searchContext  string
+	domains        []string
+	provider       string
+	maxTokens      int
+	ensemble       string
+	merge          bool
+	temperature    *float64
+	topP           *float64
+	schemaPath     string
+	tools          []reqTool
+	translateTo    string
+	image          string
+	files          []string
+	recall         bool
+	related        bool
 	timeout        time.Duration
 	useWebSearch   bool
 	showAll        bool
+	noCache        bool
+	noStore        bool
+	batch          string
+	batchWorkers   int
+	synthesize     bool
+	citationStyle  string
+	manifest       string
+	get            string
+	confirm        bool
+	output         string
+	stream         bool
+	noSynthesis    bool
+	showUsage      bool
+	quiet          bool
+	// previousResponseID chains the question to an earlier answer; with
+	// continueLast it is resolved from the last answer once the provider
+	// is known.
+	previousResponseID string
+	continueLast       bool
+	// session names the research thread the question continues and the
+	// answer is added to.
+	session string
+	// stdin is piped text sent as context with the question.
+	stdin string
 }
 
 func parseCLIArgs(envCfg EnvConfig) cliArgs {
@@ -128,10 +440,11 @@ func parseCLIArgs(envCfg EnvConfig) cliArgs {
 		defaultEffortVal = envCfg.Effort
 	}
 
-	baseURL := flag.String("base", defaultBaseURL, "API endpoint")
+	flag.String("profile", activeProfile.Name, "named profile from the profiles file (env PROFILE)")
+	baseURL := flag.String("base", cmp.Or(activeProfile.BaseURL, defaultBaseURL), "API endpoint")
 	model := flag.String("model", defaultModelVal, "model (env MODEL)")
 	effort := flag.String("effort", defaultEffortVal, "effort (env EFFORT)")
-	verbosity := flag.String("verbosity", defaultVerbosity, "response verbosity (low, medium, high)")
+	verbosity := flag.String("verbosity", cmp.Or(activeProfile.Verbosity, defaultVerbosity), "response verbosity (low, medium, high)")
 	webSearch := flag.Bool("web-search", true, "use web search (default: true)")
 	defaultTimeout := getTimeoutForEffort(defaultEffortVal)
 	if envCfg.HasTimeout {
@@ -139,31 +452,164 @@ func parseCLIArgs(envCfg EnvConfig) cliArgs {
 	}
 	timeout := flag.Duration("timeout", defaultTimeout, "HTTP timeout (env TIMEOUT)")
 	showAll := flag.Bool("show-all", envCfg.HasShowAll && envCfg.ShowAll, "print raw JSON response (env SHOW_ALL)")
+	searchContext := flag.String("search-context", "", "web search context size: low, medium, high (default: API default)")
+	provider := flag.String("provider", envCfg.Provider, "backend provider: "+strings.Join(providerNames(), ", ")+" (env PROVIDER)")
+	maxTokens := flag.Int("max-output-tokens", 0, "cap on output tokens, reasoning included (0 = model default)")
+	temperature := flag.Float64("temperature", 0, "sampling temperature 0-2 (non-reasoning models, or effort none)")
+	topP := flag.Float64("top-p", 0, "nucleus sampling top_p in (0,1] (non-reasoning models, or effort none)")
+	citationStyle := flag.String("citation-style", cmp.Or(defaultCitationStyle, citeFootnotes), "render citations as footnotes, inline, apa or none (env CITATION_STYLE)")
+	noCitations := flag.Bool("no-citations", false, "print the answer without citation markers or a sources list (same as -citation-style none)")
+	manifest := flag.String("manifest", "", "write a JSON provenance manifest of the answer (model, parameters, times, hashed sources) to this file")
+	output := flag.String("o", outputText, "output format: text, json (answer, citations, model, id and usage as one JSON object) or md")
+	schemaPath := flag.String("schema", "", "JSON Schema file; the answer is requested as JSON and validated against it")
+	var files []string
+	flag.Func("file", "document (txt, md, pdf) to send as context; repeatable", func(s string) error {
+		files = append(files, s)
+		return nil
+	})
+	recall := flag.Bool("recall", false, "add relevant earlier findings from history to the question (needs HISTORY and HISTORY_EMBEDDINGS)")
+	related := flag.Bool("related", false, "suggest follow-up questions after the answer (one extra call to a cheap model)")
+	image := flag.String("image", "", "image file or URL to ask about together with the question")
+	translateTo := flag.String("translate-to", "", "translate the answer into this language with a cheap second pass (e.g. German)")
+	ensemble := flag.String("ensemble", "", "ask 2-3 comma-separated providers concurrently, e.g. openai,xai")
+	merge := flag.Bool("merge", false, "with -ensemble, merge the answers and list disagreements")
+	codeInterpreter := flag.Bool("code-interpreter", false, "let the model run Python in a hosted code_interpreter sandbox")
+	vectorStores := flag.String("vector-stores", "", "comma-separated vector store IDs searched with the file_search tool")
+	domains := flag.String("domains", "", "comma-separated domains to restrict web search to (e.g. arxiv.org,nih.gov); a leading - excludes one (e.g. -reddit.com)")
+	noStore := flag.Bool("no-store", envCfg.NoStore, "ask OpenAI not to store the response (store=false; env NO_STORE)")
+	noCache := flag.Bool("no-cache", false, "ignore cached answers (CACHE_TTL) and query upstream; the new answer is still cached")
+	batch := flag.String("batch", "", "file of questions (plain lines, .jsonl or .yaml items); answers are printed as JSON lines in file order")
+	synthesize := flag.Bool("synthesize", false, "with -batch, merge the answers into one cited report and print it instead of the JSON lines")
+	batchWorkers := flag.Int("batch-workers", defaultBatchWorkers, fmt.Sprintf("with -batch, questions answered concurrently (max %d)", maxBatchWorkers))
+	get := flag.String("get", "", "print a stored OpenAI response by ID (e.g. resp_abc123) and its sources, without asking again")
+	stream := flag.Bool("stream", isTerminal(os.Stdout), "print the answer as it arrives (default when stdout is a terminal)")
+	quiet := flag.Bool("quiet", false, "print only the answer: no warnings, info logs or batch progress on stderr")
+	verbose := flag.Bool("verbose", false, "log each step to stderr: request built and sent, retries, time to first byte")
+	showUsage := flag.Bool("show-usage", envCfg.ShowUsage, "print tokens, reasoning tokens, estimated cost and wall-clock time after the answer (env SHOW_USAGE)")
+	confirm := flag.Bool("confirm", false, "show the effective parameters and an estimated cost, and ask before sending")
+	synthesis := flag.Bool("synthesis", true, "false prints only the retrieved sources (title, URL and snippet where available) without writing an answer")
+	continueLast := flag.Bool("continue", false, "follow up on the last answer (passes its response ID as previous_response_id)")
+	flag.BoolVar(continueLast, "last", false, "same as -continue")
+	sessionName := flag.String("session", "", "ask within a named session: follow up on its last answer and add this one (see answer sessions)")
+	previousID := flag.String("previous-response-id", "", "follow up on an earlier answer by response ID (e.g. resp_abc123)")
 	cacheKey := flag.String("cache-key", os.Getenv("PROMPT_CACHE_KEY"), "OpenAI prompt_cache_key (env PROMPT_CACHE_KEY); leave empty for server default")
 
 	var questionVal string
 	flag.StringVar(&questionVal, "q", envCfg.Question, "question prompt (env QUESTION)")
 	flag.StringVar(&questionVal, "question", envCfg.Question, "same as -q (env QUESTION)")
 	flag.Parse()
+	switch {
+	case *quiet && *verbose:
+		fail(2, "use only one of -quiet and -verbose")
+	case *quiet:
+		setQuiet()
+	case *verbose:
+		setVerbose(true)
+	}
 
 	q := resolveQuestion(questionVal)
+	// Piped input is the question, or with a question the context for it:
+	// cat error.log | answer "what causes this?"
+	var stdin string
+	if *batch == "" && *get == "" {
+		text, err := readPipedStdin(os.Stdin)
+		if err != nil {
+			fail(2, err.Error())
+		}
+		if text != "" && !flagWasSet("q") && !flagWasSet("question") && flag.NArg() == 0 {
+			q = text
+		} else {
+			stdin = text
+		}
+	}
 	*effort = validateEffort(*effort)
 	*verbosity = validateVerbosity(*verbosity)
 	if !envCfg.HasTimeout && !flagWasSet("timeout") {
 		*timeout = getTimeoutForEffort(*effort)
 	}
+	style, err := parseCitationStyle(*citationStyle)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if *noCitations {
+		style = citeNone
+	}
+	format, err := parseOutputFormat(*output)
+	if err != nil {
+		fail(2, err.Error())
+	}
 
-	return cliArgs{
-		baseURL:        *baseURL,
+	args := cliArgs{
+		baseURL:        resolveBaseURL(*baseURL),
 		model:          *model,
 		effort:         *effort,
 		verbosity:      *verbosity,
 		question:       q,
 		promptCacheKey: *cacheKey,
+		searchContext:  validateSearchContextSize(*searchContext),
+		domains:        splitDomains(*domains),
+		provider:       *provider,
+		maxTokens:      validateMaxOutputTokens(*maxTokens),
+		ensemble:       *ensemble,
+		merge:          *merge,
+		schemaPath:     *schemaPath,
+		tools:          hostedTools(*codeInterpreter, strings.Split(*vectorStores, ",")),
+		translateTo:    strings.TrimSpace(*translateTo),
+		image:          *image,
+		files:          files,
+		recall:         *recall,
+		related:        *related,
 		timeout:        *timeout,
 		useWebSearch:   *webSearch,
 		showAll:        *showAll,
+		noCache:        *noCache,
+		noStore:        *noStore,
+		batch:          *batch,
+		batchWorkers:   *batchWorkers,
+		synthesize:     *synthesize,
+		citationStyle:  style,
+		manifest:       *manifest,
+		stdin:          stdin,
+		get:            strings.TrimSpace(*get),
+		confirm:        *confirm,
+		output:         format,
+		stream:         *stream,
+		noSynthesis:    !*synthesis,
+		showUsage:      *showUsage,
+		quiet:          *quiet,
+
+		previousResponseID: strings.TrimSpace(*previousID),
+		continueLast:       *continueLast,
+		session:            strings.TrimSpace(*sessionName),
+	}
+	chained := 0
+	for _, set := range []bool{args.continueLast, args.previousResponseID != "", args.session != ""} {
+		if set {
+			chained++
+		}
+	}
+	if chained > 1 {
+		fail(2, "use only one of -continue, -previous-response-id and -session")
 	}
+	if chained > 0 && (args.ensemble != "" || args.batch != "" || args.get != "") {
+		fail(2, "-continue, -previous-response-id and -session cannot be combined with -ensemble, -batch or -get")
+	}
+	if args.noSynthesis && (args.ensemble != "" || args.batch != "") {
+		fail(2, "-synthesis=false cannot be combined with -ensemble or -batch")
+	}
+	if reason := args.streamConflict(); args.stream && reason != "" {
+		if flagWasSet("stream") {
+			Warn("Not streaming the answer", "reason", reason)
+		}
+		args.stream = false
+	}
+	if flagWasSet("temperature") {
+		args.temperature = temperature
+	}
+	if flagWasSet("top-p") {
+		args.topP = topP
+	}
+	return args
 }
 
 func resolveQuestion(questionVal string) string {
@@ -187,31 +633,198 @@ func flagWasSet(name string) bool {
 }
 
 func runCLI() {
+	if err := selectProfile(os.Args[1:]); err != nil {
+		fail(2, err.Error())
+	}
 	envCfg, err := loadEnvConfig()
 	if err != nil {
 		fail(2, err.Error())
 	}
 
+	if err := applyRuntimeConfig(envCfg); err != nil {
+		fail(2, err.Error())
+	}
 	args := parseCLIArgs(envCfg)
+	if args.batch != "" {
+		runCLIBatch(envCfg, args)
+		return
+	}
+	if args.get != "" {
+		runCLIGet(envCfg, args)
+		return
+	}
 	if args.question == "" {
 		fail(2, "please provide a question to ask (use -q flag or positional argument)")
 	}
+	if envCfg.RunArtifacts {
+		if activeRun, err = startRun("answer", args.question); err != nil {
+			fail(2, err.Error())
+		}
+		defer activeRun.finish(nil)
+		activeRun.saveJSON("request.json", map[string]any{
+			"question":   args.question,
+			"provider":   args.provider,
+			"model":      args.model,
+			"effort":     args.effort,
+			"verbosity":  args.verbosity,
+			"web_search": args.useWebSearch,
+			"domains":    args.domains,
+			"ensemble":   args.ensemble,
+		})
+	}
 
-	ctx := context.Background()
-	apiResp, err := CallAPI(ctx, CallAPIParams{
-		APIKey:         envCfg.APIKey,
-		BaseURL:        args.baseURL,
-		Query:          args.question,
-		Model:          args.model,
-		Effort:         args.effort,
-		Verbosity:      args.verbosity,
-		PromptCacheKey: resolvePromptCacheKey(ctx, args.promptCacheKey),
-		Timeout:        args.timeout,
-		UseWebSearch:   args.useWebSearch,
-	})
+	if args.ensemble != "" {
+		runCLIEnsemble(envCfg, args)
+		return
+	}
+
+	pr, err := lookupProvider(args.provider)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if args.continueLast {
+		if args.previousResponseID, err = continueFrom(pr.name); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	if args.session != "" {
+		if args.previousResponseID, err = sessionContinuation(args.session, pr.name, args.noStore || noStoreDefault); err != nil {
+			fail(2, err.Error())
+		}
+	}
+	var schema *outputSchema
+	if args.schemaPath != "" {
+		if schema, err = loadOutputSchema(args.schemaPath); err != nil {
+			fail(2, err.Error())
+		}
+		if args.translateTo != "" {
+			fail(2, "-translate-to cannot be combined with -schema")
+		}
+		// Markers would break the JSON answer.
+		args.citationStyle = ""
+	}
+	image, err := loadImage(args.image)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	var images []string
+	if image != "" {
+		images = []string{image}
+	}
+	files, err := loadAttachments(args.files)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if args.stdin != "" {
+		files = append(files, attachment{Name: "stdin", Text: args.stdin})
+	}
+	var instructions string
+	if args.recall {
+		if answerHistory == nil || answerHistory.embed == nil {
+			fail(2, "-recall needs HISTORY=true and HISTORY_EMBEDDINGS (local or openai)")
+		}
+		found, err := answerHistory.recall(context.Background(), args.question, recallLimit)
+		if err != nil {
+			Warn("Recall failed; asking without prior findings", "error", err)
+		}
+		instructions = recallInstructions(found)
+	}
+	if args.useWebSearch && !pr.webSearch {
+		Warn("Provider has no web search; answering from model knowledge", "provider", pr.name)
+		args.useWebSearch = false
+	}
+	if len(args.tools) > 0 && !pr.hostedTools {
+		Warn("Provider has no hosted tools; ignoring -code-interpreter/-vector-stores", "provider", pr.name)
+		args.tools = nil
+	}
+
+	ctx, started := context.Background(), time.Now()
+	// Only plain questions are cached: schema answers, attachments and
+	// recalled context are not part of the key, and -show-all wants the raw
+	// response.
+	var cacheKey string
+	if schema == nil && len(images) == 0 && len(files) == 0 && instructions == "" && !args.showAll {
+		cacheKey = cacheKeyFor(args.webSearchArgs(pr.name))
+	}
+	if !args.noCache {
+		if cached, state := answerCache.get(cacheKey); state == cacheFresh {
+			Info("Serving cached answer", "age", cached.CacheAge, "model", cached.Model)
+			activeRun.saveJSON("response.json", cached)
+			args.stream = false // nothing was streamed
+			rememberLastResponse(args, pr.name, cached)
+			printCLIAnswer(ctx, envCfg, args, cached, started)
+			args.printUsage(&runUsage{Cached: 1}, started)
+			return
+		}
+	}
+
+	if args.confirm {
+		wa := args.webSearchArgs(pr.name)
+		if !confirmCall(os.Stderr, confirmInput(), callParams(pr, wa, args.timeout.String()), estimateCost(pr.modelFor(wa.model), wa)) {
+			fail(1, "cancelled")
+		}
+	}
+
+	var onDelta func(string)
+	streamed := false
+	if args.stream {
+		onDelta = func(delta string) {
+			streamed = true
+			fmt.Print(delta)
+		}
+	}
+	allowDomains, blockDomains := splitDomainFilter(args.domains)
+	params := CallAPIParams{
+		Provider:           pr.name,
+		APIKey:             pr.keyFor(envCfg.APIKey),
+		BaseURL:            pr.endpointFor(args.baseURL),
+		Query:              args.question,
+		Model:              args.model,
+		Effort:             args.effort,
+		Verbosity:          args.verbosity,
+		PromptCacheKey:     resolvePromptCacheKey(ctx, args.promptCacheKey),
+		PreviousResponseID: args.previousResponseID,
+		NoStore:            args.noStore,
+		SearchContextSize:  args.searchContext,
+		UserLocation:       defaultUserLocation,
+		AllowedDomains:     allowDomains,
+		BlockedDomains:     blockDomains,
+		MaxOutputTokens:    args.maxTokens,
+		Temperature:        args.temperature,
+		TopP:               args.topP,
+		OutputSchema:       schema,
+		Tools:              args.tools,
+		Instructions:       instructions,
+		Images:             images,
+		Files:              files,
+		Timeout:            args.timeout,
+		UseWebSearch:       args.useWebSearch,
+		OnDelta:            onDelta,
+		Retry:              retryPolicy,
+	}
+	if args.noSynthesis {
+		result, err := retrieveSources(ctx, params, args.webSearchArgs(pr.name), nil)
+		if err != nil {
+			fail(2, err.Error())
+		}
+		if !result.Success {
+			fail(3, result.Error)
+		}
+		answerCache.set(cacheKey, *result)
+		printCLIAnswer(ctx, envCfg, args, *result, started)
+		var usage runUsage
+		usage.addResult(result)
+		args.printUsage(&usage, started)
+		return
+	}
+	apiResp, err := CallAPI(ctx, params)
+	if streamed {
+		fmt.Println()
+	}
 	if err != nil {
 		fail(2, err.Error())
 	}
+	activeRun.saveJSON("response.json", apiResp)
 
 	if args.showAll {
 		raw, _ := json.MarshalIndent(apiResp, "", "  ") //nolint:errcheck // Debug output, error ok to ignore
@@ -219,9 +832,235 @@ func runCLI() {
 		return
 	}
 
-	answer := ExtractAnswer(apiResp)
+	extracted := ExtractAnswer(apiResp)
+	extracted.Text, extracted.Citations = withoutDomains(extracted.Text, extracted.Citations, blockDomains)
+	answer := extracted.Text
+	if schema == nil {
+		answer = answerPipeline.apply(answer)
+	}
 	if answer == "" {
+		if err := checkReasoningOnly(apiResp); err != nil {
+			fail(3, err.Error())
+		}
 		fail(3, "no answer found in response")
 	}
-	fmt.Println(answer)
+	if err := schema.validate(answer); err != nil {
+		fmt.Println(answer)
+		fail(3, err.Error())
+	}
+	entryID, err := answerHistory.record(ctx, HistoryEntry{Query: args.question, Answer: answer, Model: apiResp.Model, Provider: pr.name, ResponseID: apiResp.ID, Sources: citationURLs(extracted.Citations)})
+	if err != nil {
+		Warn("Failed to record history", "error", err)
+	} else {
+		activeRun.savePages(ctx, answerHistory, entryID)
+	}
+	citations := extracted.Citations
+	if answer != extracted.Text {
+		citations = withoutSpans(citations)
+	}
+	result := WebSearchResult{
+		Success:            true,
+		Answer:             answer,
+		Query:              args.question,
+		Model:              apiResp.Model,
+		Effort:             args.effort,
+		ID:                 apiResp.ID,
+		RequestedModel:     args.model,
+		WebSearchUsed:      args.useWebSearch,
+		Provider:           pr.name,
+		RequestID:          apiResp.RequestID,
+		Citations:          citations,
+		ContentHash:        contentHash(answer),
+		Usage:              apiResp.Usage,
+		PreviousResponseID: args.previousResponseID,
+	}
+	answerCache.set(cacheKey, result)
+	rememberLastResponse(args, pr.name, result)
+	printCLIAnswer(ctx, envCfg, args, result, started)
+	var usage runUsage
+	usage.add(apiResp.Model, apiResp.Usage, countWebSearches(apiResp))
+	args.printUsage(&usage, started)
+}
+
+// rememberLastResponse records result for the next -continue and adds it to
+// the -session. Unstored answers cannot be continued, so they leave the
+// records alone.
+func rememberLastResponse(args cliArgs, provider string, result WebSearchResult) {
+	if result.ID == "" || args.noStore || noStoreDefault || !chainsResponses(provider) {
+		return
+	}
+	now := time.Now().UTC()
+	last := lastResponse{ID: result.ID, Provider: provider, Model: result.Model, Time: now}
+	if err := saveLastResponse(last); err != nil {
+		Warn("Failed to record the last response for -continue", "error", err)
+	}
+	if args.session != "" {
+		turn := sessionTurn{ResponseID: result.ID, Query: args.question, Model: result.Model, Time: now}
+		if err := appendSessionTurn(args.session, provider, turn); err != nil {
+			Warn("Failed to add the answer to the session", "session", args.session, "error", err)
+		}
+	}
+}
+
+// printCLIAnswer renders the citations, translates the answer if asked and
+// prints it, then writes the -manifest and, with -related, the follow-up
+// suggestions. A streamed answer is already on screen; with -o json or md
+// everything is printed together at the end.
+func printCLIAnswer(ctx context.Context, envCfg EnvConfig, args cliArgs, result WebSearchResult, started time.Time) {
+	if args.noSynthesis {
+		// There is no answer to style, translate or follow up on.
+		activeRun.saveJSON("sources.json", result.Sources)
+		if err := writeCLISources(os.Stdout, args.output, &result); err != nil {
+			fail(1, err.Error())
+		}
+		return
+	}
+	answer := renderCitations(result.Answer, result.Citations, args.citationStyle)
+	if args.stream {
+		// The streamed text is on screen already and cannot take footnote
+		// markers, so only the sources follow it.
+		answer = result.Answer
+		if args.citationStyle == citeFootnotes && len(result.Citations) > 0 {
+			sources := footnoteSources(result.Citations)
+			answer += "\n\n" + sources
+			fmt.Printf("\n%s\n", sources)
+		}
+	}
+	if args.translateTo != "" {
+		translated, err := translateAnswer(ctx, result.Provider, envCfg.APIKey, args.baseURL, answer, args.translateTo)
+		if err != nil {
+			Warn("Translation failed; printing the original answer", "error", err)
+		} else {
+			answer = translated
+		}
+	}
+	activeRun.save("answer.md", []byte(answer+"\n"))
+	if args.output == outputText && !args.stream {
+		fmt.Println(answer)
+	}
+	if args.manifest != "" {
+		result.Answer = answer
+		m := newManifest(ctx, &result, args.webSearchArgs(result.Provider), started)
+		if err := writeManifest(args.manifest, m); err != nil {
+			Warn("Failed to write the provenance manifest", "error", err)
+		}
+		activeRun.saveJSON("manifest.json", m)
+	}
+	var related []string
+	if args.related {
+		var err error
+		if related, err = suggestRelated(ctx, result.Provider, envCfg.APIKey, args.baseURL, args.question, answer); err != nil {
+			Warn("Could not suggest related questions", "error", err)
+		}
+	}
+	if args.output != outputText {
+		if err := writeCLIOutput(os.Stdout, args.output, newCLIOutput(&result, answer, related), args.citationStyle); err != nil {
+			fail(1, err.Error())
+		}
+		return
+	}
+	printRelated(os.Stdout, related)
+}
+
+// printRelated lists follow-up suggestions below the answer.
+func printRelated(w io.Writer, questions []string) {
+	if len(questions) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nRelated questions:")
+	for _, q := range questions {
+		fmt.Fprintf(w, "- %s\n", q)
+	}
+}
+
+// webSearchArgs maps the CLI arguments onto the tool arguments, so CLI
+// answers share cache keys with the MCP server.
+func (a cliArgs) webSearchArgs(provider string) webSearchArgs {
+	return webSearchArgs{
+		query:              a.question,
+		model:              a.model,
+		effort:             a.effort,
+		verbosity:          a.verbosity,
+		searchContextSize:  a.searchContext,
+		userLocation:       defaultUserLocation,
+		domains:            a.domains,
+		provider:           provider,
+		maxOutputTokens:    a.maxTokens,
+		temperature:        a.temperature,
+		topP:               a.topP,
+		tools:              a.tools,
+		useWebSearch:       a.useWebSearch,
+		noSynthesis:        a.noSynthesis,
+		noStore:            a.noStore,
+		previousResponseID: a.previousResponseID,
+	}
+}
+
+// runCLIEnsemble answers the question with several providers and prints the
+// answers one after another, or the merged synthesis with -merge.
+func runCLIEnsemble(envCfg EnvConfig, args cliArgs) {
+	names, err := ensembleProviders(strings.Split(args.ensemble, ","), envCfg.APIKey)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	wa := args.webSearchArgs("")
+	wa.promptCacheKey = args.promptCacheKey
+	if args.confirm {
+		var est costEstimate
+		models := make([]string, len(names))
+		for i, name := range names {
+			pr := providers[name]
+			models[i] = pr.modelFor(wa.model)
+			est = est.add(estimateCost(models[i], wa))
+		}
+		if args.merge {
+			// The merge pass runs on the default provider without search.
+			est = est.add(estimateCost(wa.model, webSearchArgs{query: wa.query, effort: wa.effort}))
+		}
+		params := [][2]string{
+			{"providers", strings.Join(names, ", ")},
+			{"models", strings.Join(models, ", ")},
+			{"effort", wa.effort},
+			{"verbosity", wa.verbosity},
+			{"web search", fmt.Sprint(wa.useWebSearch)},
+			{"merge", fmt.Sprint(args.merge)},
+		}
+		if !confirmCall(os.Stderr, confirmInput(), params, est) {
+			fail(1, "cancelled")
+		}
+	}
+	started := time.Now()
+	result, err := runEnsemble(context.Background(), envCfg.APIKey, args.baseURL, wa, names, args.merge)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	activeRun.saveJSON("ensemble.json", result)
+
+	if args.showAll {
+		raw, _ := json.MarshalIndent(result, "", "  ") //nolint:errcheck // Debug output, error ok to ignore
+		fmt.Println(string(raw))
+		return
+	}
+	var usage runUsage
+	for i := range result.Answers {
+		usage.addResult(&result.Answers[i])
+	}
+	if result.Merged != "" {
+		fmt.Println(result.Merged)
+		args.printUsage(&usage, started)
+		return
+	}
+	for i, r := range result.Answers {
+		fmt.Printf("## %s\n\n", names[i])
+		if r.Success {
+			fmt.Println(r.Answer)
+		} else {
+			fmt.Println("error: " + r.Error)
+		}
+		fmt.Println()
+	}
+	args.printUsage(&usage, started)
+	if result.Error != "" {
+		fail(3, result.Error)
+	}
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -21,6 +23,21 @@ func logToClient(ctx context.Context, level mcp.LoggingLevel, source, message st
 	}
 }
 
+// builtinTools are the server's own tools; purge only with admin tools.
+func builtinTools(cfg MCPConfig) []server.ServerTool {
+	tools := []server.ServerTool{
+		{Tool: newGptWebsearchTool(), Handler: webSearchHandler(cfg.APIKey, cfg.BaseURL)},
+		{Tool: newGptEnsembleTool(), Handler: ensembleHandler(cfg.APIKey, cfg.BaseURL)},
+		{Tool: newBatchWebsearchTool(), Handler: batchHandler(cfg.APIKey, cfg.BaseURL)},
+		{Tool: newVerifyTool(), Handler: verifyHandler(cfg.APIKey, cfg.BaseURL)},
+		{Tool: newGetResponseTool(), Handler: getResponseHandler(cfg.APIKey, cfg.BaseURL)},
+	}
+	if cfg.AdminTools {
+		tools = append(tools, server.ServerTool{Tool: newPurgeTool(), Handler: purgeHandler()})
+	}
+	return tools
+}
+
 // NewMCPServer creates and configures an MCP server with tools, resources, and prompts
 func NewMCPServer(cfg MCPConfig) *server.MCPServer {
 	// Create MCP server with capabilities
@@ -38,8 +55,14 @@ func NewMCPServer(cfg MCPConfig) *server.MCPServer {
 		server.WithOutputSchemaValidation(),
 	)
 
-	// Add web search tool
-	mcpServer.AddTool(newGptWebsearchTool(), webSearchHandler(cfg.APIKey, cfg.BaseURL))
+	// Add web search tools
+	mcpServer.AddTools(builtinTools(cfg)...)
+	// Plugins can do anything, so read-only mode leaves them out.
+	if !cfg.ReadOnly {
+		for _, p := range mcpPlugins {
+			mcpServer.AddTool(p.tool(), pluginHandler(p))
+		}
+	}
 
 	// Add server info resource
 	mcpServer.AddResource(
@@ -49,7 +72,7 @@ func NewMCPServer(cfg MCPConfig) *server.MCPServer {
 			mcp.WithResourceDescription("Information about the GPT Web Search MCP server"),
 			mcp.WithMIMEType("text/plain"),
 		),
-		serverInfoHandler(cfg.BaseURL),
+		serverInfoHandler(cfg.BaseURL, cfg.ReadOnly),
 	)
 
 	// Add models list resource
@@ -63,6 +86,9 @@ func NewMCPServer(cfg MCPConfig) *server.MCPServer {
 		modelsHandler(),
 	)
 
+	// Long answers are paged through this template (see ANSWER_PAGE_SIZE).
+	mcpServer.AddResourceTemplate(newAnswerPageTemplate(), answerPageHandler())
+
 	// Add intelligent web search prompt
 	mcpServer.AddPrompt(
 		mcp.NewPrompt("web_search",
@@ -89,22 +115,35 @@ func newGptWebsearchTool() mcp.Tool {
 			mcp.Description("The search query or question to ask"),
 		),
 		mcp.WithString("model",
-			mcp.DefaultString(defaultModel),
+			mcp.DefaultString(toolModel),
 			mcp.Description("The GPT model to use (default: gpt-5.4-mini)"),
 		),
 		mcp.WithString("reasoning_effort",
-			mcp.DefaultString(defaultEffort),
+			mcp.DefaultString(toolEffort),
 			mcp.Description("Reasoning effort level: none (90s), low (3min), medium (5min), high (10min), or xhigh (15min timeout)"),
 			mcp.Enum("none", "low", "medium", "high", "xhigh"),
 		),
 		mcp.WithString("verbosity",
-			mcp.DefaultString(defaultVerbosity),
+			mcp.DefaultString(toolVerbosity),
 			mcp.Description("Response verbosity level: low (concise), medium (balanced), or high (detailed with explanations)"),
 			mcp.Enum("low", "medium", "high"),
 		),
 		mcp.WithString("previous_response_id",
 			mcp.Description("Optional: Previous response ID for conversation continuity - improves performance by avoiding re-reasoning"),
 		),
+		mcp.WithArray("messages",
+			mcp.Description("Optional: earlier turns of a conversation you keep yourself, oldest first, sent before "+
+				"the query. An alternative to previous_response_id that works with every provider; answers are not cached."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"role":    map[string]any{"type": "string", "enum": []string{"user", "assistant"}},
+					"content": map[string]any{"type": "string"},
+				},
+				"required":             []string{"role", "content"},
+				"additionalProperties": false,
+			}),
+		),
 		mcp.WithString("prompt_cache_key",
 			mcp.Description("Optional: OpenAI prompt_cache_key. Requests sharing the same prefix and key "+
 				"reuse the same cache shard. Leave empty to use the server default (per-user when "+
@@ -113,11 +152,165 @@ func newGptWebsearchTool() mcp.Tool {
 			mcp.DefaultBool(true),
 			mcp.Description("Use web search (default: true)"),
 		),
+		mcp.WithBoolean("synthesis",
+			mcp.DefaultBool(true),
+			mcp.Description("Optional: false skips writing an answer and returns only the retrieved pages in sources "+
+				"(url, title and, where the provider gives one, a snippet), for callers that read the sources themselves"),
+		),
+		withCitationStyle(),
+		mcp.WithBoolean("provenance",
+			mcp.Description("Optional: attach a provenance manifest (model, parameters, timestamps, cited sources "+
+				"fetched and hashed, generator version) to the result. Fetching the sources takes a few seconds."),
+		),
+		mcp.WithString("truncation",
+			mcp.Description("Optional: context overflow strategy. auto drops the oldest turns of a long "+
+				"previous_response_id chain to fit the context window; disabled fails instead. "+
+				"Defaults to auto when previous_response_id is set."),
+			mcp.Enum("auto", "disabled"),
+		),
+		mcp.WithString("search_context_size",
+			mcp.Description("Optional: how much web context the search tool retrieves: low (cheapest, fastest), "+
+				"medium (API default), or high (most thorough)"),
+			mcp.Enum("low", "medium", "high"),
+		),
+		mcp.WithObject("user_location",
+			mcp.Description("Optional: approximate location to localize results (e.g. weather, events near me). "+
+				"Overrides the server's USER_LOCATION default."),
+			mcp.Properties(map[string]any{
+				"country":  map[string]any{"type": "string", "description": "Two-letter ISO country code, e.g. GB"},
+				"city":     map[string]any{"type": "string", "description": "City name, e.g. London"},
+				"region":   map[string]any{"type": "string", "description": "Region or state, e.g. California"},
+				"timezone": map[string]any{"type": "string", "description": "IANA timezone, e.g. Europe/London"},
+			}),
+		),
+		mcp.WithArray("domains",
+			mcp.Description("Optional: restrict web search to these domains, e.g. [\"arxiv.org\", \"nih.gov\"]. "+
+				"Results from all other sites are excluded. A leading - excludes a domain instead, e.g. [\"-reddit.com\"]."),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("max_output_tokens",
+			mcp.Description("Optional: hard cap on output tokens, reasoning included (minimum 16). "+
+				"Answers that hit the cap are cut short; leave unset for the model default."),
+			mcp.Min(minOutputTokens),
+		),
+		mcp.WithNumber("temperature",
+			mcp.Description("Optional: sampling temperature 0-2. Reasoning models (gpt-5*, o-series) only accept it "+
+				"with reasoning_effort none."),
+			mcp.Min(0),
+			mcp.Max(2),
+		),
+		mcp.WithNumber("top_p",
+			mcp.Description("Optional: nucleus sampling top_p in (0, 1]. Same model restrictions as temperature."),
+			mcp.Min(0),
+			mcp.Max(1),
+		),
+		mcp.WithObject("output_schema",
+			mcp.Description("Optional: JSON Schema the answer must follow. The answer is returned as a JSON "+
+				"string and validated against the schema; a mismatch is reported as an error."),
+		),
+		mcp.WithString("image",
+			mcp.Description("Optional: image to ask about together with the query, as an https URL, a base64 "+
+				"data: URL, or the path of a file in the server's ATTACH_DIR"),
+		),
+		mcp.WithArray("files",
+			mcp.Description("Optional: paths of documents (txt, md, pdf) in the server's ATTACH_DIR to send "+
+				"as context with the query, e.g. a contract to check against current regulation; "+
+				"relative paths are taken from ATTACH_DIR"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithBoolean("code_interpreter",
+			mcp.Description("Optional: let the model run Python in a hosted sandbox for calculations "+
+				"or data analysis (OpenAI only)"),
+		),
+		mcp.WithArray("vector_store_ids",
+			mcp.Description("Optional: vector store IDs to search with the file_search tool alongside "+
+				"the web (OpenAI only)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithBoolean("related",
+			mcp.Description("Optional: suggest 3-5 follow-up questions, returned in related_questions, "+
+				"to guide further research (one extra call to a cheap model)"),
+		),
+		mcp.WithBoolean("no_cache",
+			mcp.Description("Optional: bypass cached answers and query upstream; the new answer replaces the cached one"),
+		),
+		mcp.WithBoolean("store",
+			mcp.Description("Optional: false asks OpenAI not to store the response (default: true unless the server sets NO_STORE). "+
+				"Unstored answers cannot be continued with previous_response_id"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("Optional: backend provider (default: server's PROVIDER setting). "+
+				"openrouter accepts vendor/model names such as anthropic/claude-sonnet-4; bare names map to openai/."),
+			mcp.Enum(providerNames()...),
+		),
+		mcp.WithBoolean("compact",
+			mcp.Description("Optional: return only answer, id and citations (url and title, each page once), "+
+				"leaving out the echoed request fields; for agents that put the result into a prompt. "+
+				"A failed search is reported as a tool error."),
+		),
 		mcp.WithSchemaAdditionalProperties(false),
-		mcp.WithOutputSchema[WebSearchResult](),
+		withResultOutputSchema(),
+		withCompactOutputBranch(),
 	)
 }
 
+// withCompactOutputBranch keeps the full result schema, required fields
+// included, and adds compactResult as the other branch of a oneOf. The
+// branches exclude each other: a compact result lacks the required fields
+// and a full one has fields compactResult does not allow. The full
+// properties stay at the top level too, for clients that only read those.
+func withCompactOutputBranch() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		var compact mcp.Tool
+		mcp.WithOutputSchema[compactResult]()(&compact)
+		schema := map[string]any{
+			"type":       "object",
+			"properties": t.OutputSchema.Properties,
+			"oneOf":      []mcp.ToolOutputSchema{t.OutputSchema, compact.OutputSchema},
+		}
+		t.RawOutputSchema, _ = json.Marshal(schema) //nolint:errcheck // generated schemas always marshal
+		t.OutputSchema = mcp.ToolOutputSchema{}
+	}
+}
+
+// compactResult is the gpt_websearch result with compact set: the answer,
+// its ID and sources, and the paging pointer of a long answer. It is a
+// subset of WebSearchResult, so it satisfies the tool's output schema.
+type compactResult struct {
+	SchemaVersion int            `json:"schema_version,omitempty"`
+	Answer        string         `json:"answer,omitempty"`
+	ID            string         `json:"id,omitempty"`
+	Citations     []Citation     `json:"citations,omitempty"`
+	Sources       []SearchSource `json:"sources,omitempty"`
+	AnswerPages   int            `json:"answer_pages,omitempty"`
+	NextPage      string         `json:"next_page,omitempty"`
+}
+
+// newCompactResult trims result to compactResult. Citations keep only their
+// URL and title, once per page: spans and reputation cost tokens that a
+// prompt rarely uses. Version 1 results have only the answer and ID.
+func newCompactResult(result *WebSearchResult) compactResult {
+	if resultSchema == 1 {
+		return compactResult{Answer: result.Answer, ID: result.ID}
+	}
+	out := compactResult{
+		SchemaVersion: resultSchemaVersion,
+		Answer:        result.Answer,
+		ID:            result.ID,
+		Sources:       result.Sources,
+		AnswerPages:   result.AnswerPages,
+		NextPage:      result.NextPage,
+	}
+	seen := make(map[string]bool)
+	for _, c := range result.Citations {
+		if !seen[c.URL] {
+			seen[c.URL] = true
+			out.Citations = append(out.Citations, Citation{URL: c.URL, Title: c.Title})
+		}
+	}
+	return out
+}
+
 // webSearchHandler returns a handler for the web search tool.
 // Authentication is enforced at the HTTP transport layer (newAuthHTTPMiddleware)
 // before this handler is ever reached; no auth logic is needed here.
@@ -137,17 +330,60 @@ func webSearchHandler(apiKey, baseURL string) func(context.Context, mcp.CallTool
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		model := request.GetString("model", defaultModel)
-		effort := request.GetString("reasoning_effort", defaultEffort)
-		verbosity := request.GetString("verbosity", defaultVerbosity)
+		model := request.GetString("model", toolModel)
+		effort := request.GetString("reasoning_effort", toolEffort)
+		verbosity := request.GetString("verbosity", toolVerbosity)
 		previousResponseID := request.GetString("previous_response_id", "")
+		messages, err := parseMessages(request.GetArguments()["messages"])
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		promptCacheKey := request.GetString("prompt_cache_key", "")
+		truncation := request.GetString("truncation", "")
+		provenance := request.GetBool("provenance", false)
+		style, err := citationStyleArg(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		webSearch := request.GetBool("web_search", true)
+		synthesis := request.GetBool("synthesis", true)
+		searchContextSize := request.GetString("search_context_size", "")
+		userLocation, _ := request.GetArguments()["user_location"].(map[string]any) //nolint:errcheck
+		domains := request.GetStringSlice("domains", nil)
+		provider := request.GetString("provider", "")
+		image, err := loadClientImage(request.GetString("image", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		files, err := loadClientAttachments(request.GetStringSlice("files", nil))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		codeInterpreter := request.GetBool("code_interpreter", false)
+		vectorStoreIDs := request.GetStringSlice("vector_store_ids", nil)
+		related := request.GetBool("related", false)
+		noCache := request.GetBool("no_cache", false)
+		compact := request.GetBool("compact", false)
+		store := request.GetBool("store", !noStoreDefault)
+		maxOutputTokens := request.GetInt("max_output_tokens", 0)
+		temperature := floatArg(request.GetArguments(), "temperature")
+		topP := floatArg(request.GetArguments(), "top_p")
+		var schema *outputSchema
+		if raw, ok := request.GetArguments()["output_schema"].(map[string]any); ok && len(raw) > 0 {
+			doc, err := json.Marshal(raw)
+			if err == nil {
+				schema, err = compileOutputSchema(doc)
+			}
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			style = "" // markers would break the JSON answer
+		}
 
 		// Log the search request
 		logToClient(ctx, mcp.LoggingLevelInfo, "web_search", fmt.Sprintf(
 			"Executing web search: query='%s', model='%s', effort='%s', verbosity='%s', web_search='%t'",
-			query, model, effort, verbosity, webSearch))
+			truncateForLog(query), model, effort, verbosity, webSearch))
 
 		// Call handler with properly extracted values
 		args := map[string]interface{}{
@@ -156,31 +392,81 @@ func webSearchHandler(apiKey, baseURL string) func(context.Context, mcp.CallTool
 			"reasoning_effort":     effort,
 			"verbosity":            verbosity,
 			"previous_response_id": previousResponseID,
+			"messages":             messages,
 			"prompt_cache_key":     promptCacheKey,
 			"web_search":           webSearch,
+			"synthesis":            synthesis,
+			"truncation":           truncation,
+			"search_context_size":  searchContextSize,
+			"user_location":        userLocation,
+			"domains":              domains,
+			"provider":             provider,
+			"max_output_tokens":    maxOutputTokens,
+			"temperature":          temperature,
+			"top_p":                topP,
+			"output_schema":        schema,
+			"image":                image,
+			"files":                files,
+			"code_interpreter":     codeInterpreter,
+			"vector_store_ids":     vectorStoreIDs,
+			"related":              related,
+			"no_cache":             noCache,
+			"store":                store,
 		}
 
+		started := time.Now()
 		result, err := HandleWebSearch(ctx, apiKey, baseURL, args)
+		activeDashboard.record(ctx, query, result, err, time.Since(started))
 		if err != nil {
 			logToClient(ctx, mcp.LoggingLevelError, "web_search", fmt.Sprintf("Web search failed: %v", err))
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolErrorResult(ctx, err), nil
+		}
+		result = styleCitations(result, style)
+		if provenance && result.Success {
+			withManifest := *result
+			withManifest.Provenance = newManifest(ctx, &withManifest, extractWebSearchArgs(args), started)
+			result = &withManifest
 		}
 
 		// Log success
 		logToClient(ctx, mcp.LoggingLevelInfo, "web_search", "Web search completed successfully")
 
+		if compact {
+			if !result.Success {
+				return mcp.NewToolResultError(result.Error), nil
+			}
+			return mcp.NewToolResultStructuredOnly(newCompactResult(activePager.page(result))), nil
+		}
 		// Return structured JSON content rather than a JSON string
-		return mcp.NewToolResultStructuredOnly(result), nil
+		return mcp.NewToolResultStructuredOnly(versionedResult(activePager.page(result))), nil
 	}
 }
 
+// toolErrorResult turns err into a tool error. A call rejected or held back
+// by a limit returns a structured LimitError with the queue health and a
+// retry hint; other errors are plain text.
+func toolErrorResult(ctx context.Context, err error) *mcp.CallToolResult {
+	le := limitErrorFor(laneFrom(ctx), err)
+	if le == nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	result := mcp.NewToolResultStructured(le, err.Error())
+	result.IsError = true
+	return result
+}
+
 // serverInfoHandler returns a handler for the server info resource
-func serverInfoHandler(baseURL string) func(context.Context, mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+func serverInfoHandler(baseURL string, readOnly bool) func(context.Context, mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 		// Log the resource access
 		logToClient(ctx, mcp.LoggingLevelDebug, "server_info", fmt.Sprintf("Server info resource accessed: URI=%s", request.Params.URI))
 
 		info := fmt.Sprintf("GPT Web Search MCP Server\nVersion: %s\nEndpoint: %s\n", serverVersion, baseURL)
+		if readOnly {
+			info += "Mode: read-only\n"
+		}
+		info += fmt.Sprintf("Result schema version: %d\n", resultSchema)
+		info += fmt.Sprintf("Features: %s\n", strings.Join(activeFeatures.enabledNames(), ", "))
 		return []mcp.ResourceContents{
 			mcp.TextResourceContents{
 				URI:      request.Params.URI,
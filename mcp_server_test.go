@@ -304,6 +304,7 @@ func TestGptWebsearch_ToolsList_SchemaShape(t *testing.T) {
 	}
 	checkEnum("reasoning_effort", []string{"none", "low", "medium", "high", "xhigh"})
 	checkEnum("verbosity", []string{"low", "medium", "high"})
+	checkEnum("search_context_size", []string{"low", "medium", "high"})
 
 	required, ok := in["required"].([]any)
 	if !ok {
@@ -464,6 +465,43 @@ func TestGptWebsearch_OutputSchemaValidation_PassesForValidResult(t *testing.T)
 	}
 }
 
+func TestGptWebsearch_Compact(t *testing.T) {
+	t.Parallel()
+
+	_, upstream := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_compact", "status": "completed", "model": modelMini,
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+				"type": "output_text", "text": "Paris is the capital of France.",
+				"annotations": []map[string]any{
+					{"type": "url_citation", "url": "https://example.fr/paris", "title": "Paris", "start_index": 0, "end_index": 5},
+					{"type": "url_citation", "url": "https://example.fr/paris", "title": "Paris", "start_index": 6, "end_index": 30},
+				},
+			}}}},
+		})
+	})
+	_, baseURL := newHTTPServerFromHandler(t, newStatelessMCPHandler(t, upstream))
+
+	resp := jsonrpcCall(t, baseURL+"/", "tools/call", 1, map[string]any{
+		"name":      "gpt_websearch",
+		"arguments": map[string]any{"query": "capital of France, compact", "compact": true, "no_cache": true},
+	})
+	res := jsonrpcResult(t, resp)
+	if isErr, _ := res["isError"].(bool); isErr {
+		t.Fatalf("unexpected isError=true: %v", res)
+	}
+	sc, ok := res["structuredContent"].(map[string]any)
+	if !ok {
+		t.Fatalf("structuredContent missing in result: %v", res)
+	}
+	if len(sc) != 4 || sc["answer"] != "Paris is the capital of France." || sc["id"] != "resp_compact" || sc["schema_version"] != float64(resultSchemaVersion) {
+		t.Errorf("structuredContent = %v, want only schema_version, answer, id and citations", sc)
+	}
+	if citations, _ := sc["citations"].([]any); len(citations) != 1 || len(citations[0].(map[string]any)) != 2 {
+		t.Errorf("citations = %v, want one with url and title", sc["citations"])
+	}
+}
+
 // TestMCPServer_HTTP_ProxiedPaths verifies the MCP handler responds to POST
 // initialize at any path — critical for reverse proxies (like nginx with
 // variable proxy_pass) that forward the original URI instead of rewriting it.
@@ -512,3 +550,51 @@ func TestMCPServer_HTTP_ProxiedPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestNewMCPServer_ReadOnlyHidesAdminTools(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		readOnly  bool
+		wantPurge bool
+	}{
+		{readOnly: false, wantPurge: true},
+		{readOnly: true, wantPurge: false},
+	} {
+		cfg := parseMCPConfig(MCPConfigParams{APIKey: "test-key", AdminTools: true, ReadOnly: tt.readOnly})
+		s := NewMCPServer(cfg)
+		if got := s.GetTool("purge") != nil; got != tt.wantPurge {
+			t.Errorf("read-only=%v: purge exposed = %v, want %v", tt.readOnly, got, tt.wantPurge)
+		}
+		if s.GetTool("gpt_websearch") == nil {
+			t.Errorf("read-only=%v: gpt_websearch missing", tt.readOnly)
+		}
+	}
+}
+
+func TestGptWebsearch_OutputSchemaBranches(t *testing.T) {
+	t.Parallel()
+
+	tool := newGptWebsearchTool()
+	var schema struct {
+		Type  string `json:"type"`
+		OneOf []struct {
+			Required             []string       `json:"required"`
+			Properties           map[string]any `json:"properties"`
+			AdditionalProperties *bool          `json:"additionalProperties"`
+		} `json:"oneOf"`
+	}
+	if err := json.Unmarshal(tool.RawOutputSchema, &schema); err != nil {
+		t.Fatalf("output schema: %v", err)
+	}
+	if schema.Type != "object" || len(schema.OneOf) != 2 {
+		t.Fatalf("output schema = %s", tool.RawOutputSchema)
+	}
+	full, compact := schema.OneOf[0], schema.OneOf[1]
+	if len(full.Required) == 0 || full.Properties["success"] == nil {
+		t.Errorf("full branch lost its required fields: %v", full.Required)
+	}
+	if compact.Properties["success"] != nil || compact.AdditionalProperties == nil || *compact.AdditionalProperties {
+		t.Errorf("compact branch = %+v", compact)
+	}
+}
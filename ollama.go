@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	providerOllama = "ollama"
+	// defaultOllamaHost is where a local Ollama server listens by default.
+	defaultOllamaHost = "http://localhost:11434"
+	// defaultOllamaModel is used when the request carries an OpenAI model
+	// name and OLLAMA_MODEL is unset.
+	defaultOllamaModel = "llama3.2"
+)
+
+// ollamaSettings locates the Ollama server. Ollama runs models locally and
+// has no web search, so every answer comes from model knowledge.
+type ollamaSettings struct {
+	// Host is the server address, e.g. http://localhost:11434.
+	Host string
+	// Model replaces OpenAI model names, which Ollama does not serve.
+	Model string
+}
+
+// ollama holds the Ollama settings; set from OLLAMA_HOST and OLLAMA_MODEL at
+// startup.
+var ollama ollamaSettings
+
+// ollamaModel keeps local model names (llama3.2, qwen3:8b, ...) and replaces
+// OpenAI names with the configured model, so switching the provider alone
+// is enough.
+func ollamaModel(model string) string {
+	if model != "" && !isOpenAIModel(model) {
+		return model
+	}
+	if ollama.Model != "" {
+		return ollama.Model
+	}
+	return defaultOllamaModel
+}
+
+// isOpenAIModel reports whether model is named like an OpenAI model.
+func isOpenAIModel(model string) bool {
+	for _, prefix := range []string{"gpt-", "chatgpt-", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ollamaEndpoint returns the URL of an Ollama API path such as /api/chat.
+// base may be the server address or any API URL on it; an empty base
+// selects the configured host. Like the ollama CLI, a host:port without a
+// scheme means http.
+func ollamaEndpoint(base, path string) string {
+	if base == "" {
+		base = ollama.Host
+	}
+	if base == "" {
+		base = defaultOllamaHost
+	}
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	if i := strings.Index(base, "/api/"); i >= 0 {
+		base = base[:i]
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+// /api/chat request structures.
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	// Format constrains the reply to a JSON Schema.
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options *ollamaOptions  `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	Thinking string `json:"thinking,omitempty"`
+	// Images are raw base64 images; Ollama does not fetch URLs.
+	Images []string `json:"images,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// sendOllama performs one non-streaming /api/chat call.
+func sendOllama(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	body := ollamaRequest{
+		Model: p.Model,
+	}
+	for _, m := range p.Messages {
+		body.Messages = append(body.Messages, ollamaMessage{Role: m.Role, Content: m.Content})
+	}
+	body.Messages = append(body.Messages, ollamaUserMessage(p))
+	if p.Instructions != "" {
+		body.Messages = append([]ollamaMessage{{Role: "system", Content: p.Instructions}}, body.Messages...)
+	}
+	if p.OutputSchema != nil {
+		body.Format = p.OutputSchema.raw
+	}
+	if p.Temperature != nil || p.TopP != nil || p.MaxOutputTokens > 0 {
+		body.Options = &ollamaOptions{Temperature: p.Temperature, TopP: p.TopP, NumPredict: p.MaxOutputTokens}
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	p.BaseURL = ollamaEndpoint(p.BaseURL, "/api/chat")
+	req, err := newAPIRequest(ctx, p, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		// Ollama reports errors as {"error": "..."}, e.g. an unknown model.
+		var apiErr *APIError
+		var or ollamaResponse
+		if errors.As(err, &apiErr) && json.Unmarshal([]byte(apiErr.Body), &or) == nil {
+			apiErr.Message = or.Error
+		}
+		return nil, err
+	}
+	var or ollamaResponse
+	if err := json.Unmarshal(bodyBytes, &or); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	return or.toAPIResponse(), nil
+}
+
+// ollamaUserMessage builds the user turn. Text documents are inlined;
+// Ollama reads neither PDFs nor image URLs, so those are named in the text
+// instead of being dropped silently.
+func ollamaUserMessage(p CallAPIParams) ollamaMessage {
+	m := ollamaMessage{Role: "user"}
+	parts := []string{p.Query}
+	for _, f := range p.Files {
+		if f.Data == "" {
+			parts = append(parts, f.inlineText())
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("(Attached document %q could not be read by this model.)", f.Name))
+	}
+	for _, img := range p.Images {
+		if _, data := splitDataURL(img); data != "" {
+			m.Images = append(m.Images, data)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("(Image %s could not be loaded by this model.)", img))
+	}
+	m.Content = strings.Join(parts, "\n\n")
+	return m
+}
+
+// toAPIResponse maps a chat reply onto the Responses API shape. Thinking
+// models return their reasoning separately or, on older servers, as a
+// leading <think> block; either becomes a reasoning item.
+func (r *ollamaResponse) toAPIResponse() *apiResponse {
+	ar := &apiResponse{Model: r.Model, Status: "completed"}
+	if r.PromptEvalCount > 0 || r.EvalCount > 0 {
+		ar.Usage = &apiUsage{
+			InputTokens:  r.PromptEvalCount,
+			OutputTokens: r.EvalCount,
+			TotalTokens:  r.PromptEvalCount + r.EvalCount,
+		}
+	}
+	if r.DoneReason == "length" {
+		ar.Status = "incomplete"
+		ar.IncompleteDetails = &incompleteDetails{Reason: "max_output_tokens"}
+	}
+	thinking, text := r.Message.Thinking, r.Message.Content
+	if rest, ok := strings.CutPrefix(strings.TrimLeft(text, " \n"), "<think>"); ok && thinking == "" {
+		if thought, answer, ok := strings.Cut(rest, "</think>"); ok {
+			thinking, text = thought, strings.TrimLeft(answer, " \n")
+		}
+	}
+	if thinking = strings.TrimSpace(thinking); thinking != "" {
+		ar.Output = append(ar.Output, respItem{Type: "reasoning", Summary: []respSummary{{Type: "summary_text", Text: thinking}}})
+	}
+	ar.Output = append(ar.Output, respItem{Type: "message", Content: []respContent{{Type: "output_text", Text: text}}})
+	return ar
+}
+
+// ollamaModelInfo is one locally installed model, as listed by /api/tags.
+type ollamaModelInfo struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// listOllamaModels returns the models installed on the Ollama server at
+// base (the configured host when empty).
+func listOllamaModels(ctx context.Context, base string) ([]ollamaModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ollamaEndpoint(base, "/api/tags"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list ollama models: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := readAPIResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var tags struct {
+		Models []ollamaModelInfo `json:"models"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	return tags.Models, nil
+}
+
+// runModels implements "answer models": it lists the models installed on the
+// local Ollama server, i.e. the names -provider ollama -model accepts.
+func runModels(args []string) {
+	fs := flag.NewFlagSet("models", flag.ExitOnError)
+	host := fs.String("host", os.Getenv("OLLAMA_HOST"), "Ollama server address (env OLLAMA_HOST, default "+defaultOllamaHost+")")
+	asJSON := fs.Bool("json", false, "print models as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer models [-host URL] [-json]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	models, err := listOllamaModels(ctx, *host)
+	if err != nil {
+		fail(3, err.Error())
+	}
+	if *asJSON {
+		raw, _ := json.MarshalIndent(models, "", "  ") //nolint:errcheck
+		fmt.Println(string(raw))
+		return
+	}
+	if len(models) == 0 {
+		fmt.Println("No models installed; pull one with `ollama pull " + defaultOllamaModel + "`.")
+		return
+	}
+	for _, m := range models {
+		fmt.Printf("%-32s %8s %-8s %6.1f GB\n", m.Name, m.Details.ParameterSize, m.Details.QuantizationLevel, float64(m.Size)/1e9)
+	}
+}
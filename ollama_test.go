@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallAPI_Ollama(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want none", got)
+		}
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.Model != defaultOllamaModel || req.Stream {
+			t.Errorf("model = %q, stream = %v", req.Model, req.Stream)
+		}
+		if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Content != "who?" {
+			t.Errorf("messages = %+v", req.Messages)
+		}
+		if req.Options == nil || req.Options.NumPredict != 64 {
+			t.Errorf("options = %+v", req.Options)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"model":             "llama3.2",
+			"message":           map[string]any{"role": "assistant", "content": "<think>recall</think>\nAda Lovelace."},
+			"done":              true,
+			"done_reason":       "stop",
+			"prompt_eval_count": 5,
+			"eval_count":        3,
+		})
+	})
+
+	ar, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:        providerOllama,
+		BaseURL:         base,
+		Query:           "who?",
+		Instructions:    "Be brief.",
+		Model:           defaultModel,
+		MaxOutputTokens: 64,
+		AllowedDomains:  []string{"go.dev"},
+		UserLocation:    &UserLocation{Type: "approximate", Country: "PL"},
+		Timeout:         time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ExtractAnswer(ar); got.Text != "Ada Lovelace." {
+		t.Errorf("answer = %q", got.Text)
+	}
+	if len(ar.Output) != 2 || ar.Output[0].Type != "reasoning" || ar.Output[0].Summary[0].Text != "recall" {
+		t.Errorf("output = %+v, want reasoning then message", ar.Output)
+	}
+	if ar.Usage == nil || ar.Usage.TotalTokens != 8 {
+		t.Errorf("usage = %+v", ar.Usage)
+	}
+	if len(ar.Ignored) != 2 || ar.Ignored[0] != "domains" || ar.Ignored[1] != "user_location" {
+		t.Errorf("ignored = %q, want domains and user_location", ar.Ignored)
+	}
+}
+
+func TestCallAPI_OllamaError(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusNotFound, map[string]any{"error": `model "qwen3:8b" not found, try pulling it first`})
+	})
+	_, err := CallAPI(context.Background(), CallAPIParams{
+		Provider: providerOllama,
+		BaseURL:  base + "/api/chat",
+		Query:    "who?",
+		Model:    "qwen3:8b",
+		Timeout:  time.Second,
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(apiErr.Message, "try pulling it first") {
+		t.Errorf("err = %v, want Ollama's message", err)
+	}
+}
+
+func TestListOllamaModels(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/tags" {
+			t.Errorf("%s %s", r.Method, r.URL.Path)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"models": []map[string]any{{
+			"name": "qwen3:8b", "size": 5200000000, "modified_at": "2025-06-01T10:00:00Z",
+			"details": map[string]any{"family": "qwen3", "parameter_size": "8.2B", "quantization_level": "Q4_K_M"},
+		}}})
+	})
+
+	models, err := listOllamaModels(context.Background(), base)
+	if err != nil {
+		t.Fatalf("listOllamaModels: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "qwen3:8b" || models[0].Details.ParameterSize != "8.2B" {
+		t.Errorf("models = %+v", models)
+	}
+}
+
+func TestOllamaEndpoint(t *testing.T) {
+	t.Parallel()
+
+	for base, want := range map[string]string{
+		"0.0.0.0:11434":                       "http://0.0.0.0:11434/api/chat",
+		"gpu-box:11434/":                      "http://gpu-box:11434/api/chat",
+		"https://ollama.example.com":          "https://ollama.example.com/api/chat",
+		"http://localhost:11434/api/generate": "http://localhost:11434/api/chat",
+		"":                                    defaultOllamaHost + "/api/chat",
+	} {
+		if got := ollamaEndpoint(base, "/api/chat"); got != want {
+			t.Errorf("ollamaEndpoint(%q) = %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestOllamaModel(t *testing.T) {
+	cases := map[string]string{
+		"":              defaultOllamaModel,
+		"gpt-5.4-mini":  defaultOllamaModel,
+		"o4-mini":       defaultOllamaModel,
+		"qwen3:8b":      "qwen3:8b",
+		"deepseek-r1":   "deepseek-r1",
+		"llama3.2:1b":   "llama3.2:1b",
+		"mistral-small": "mistral-small",
+	}
+	for in, want := range cases {
+		if got := ollamaModel(in); got != want {
+			t.Errorf("ollamaModel(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	ollama = ollamaSettings{Model: "gemma3"}
+	t.Cleanup(func() { ollama = ollamaSettings{} })
+	if got := ollamaModel(defaultModel); got != "gemma3" {
+		t.Errorf("ollamaModel with OLLAMA_MODEL = %q, want gemma3", got)
+	}
+}
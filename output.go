@@ -0,0 +1,184 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CLI output formats (-o).
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputMarkdown = "md"
+)
+
+// parseOutputFormat validates a -o value; empty means plain text.
+func parseOutputFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case "", outputText:
+		return outputText, nil
+	case outputJSON, outputMarkdown:
+		return f, nil
+	case "markdown":
+		return outputMarkdown, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (use text, json or md)", s)
+	}
+}
+
+// streamConflict names the option that needs the whole answer before
+// printing it, and so rules out -stream; it is empty when streaming works.
+func (a cliArgs) streamConflict() string {
+	switch {
+	case a.showAll:
+		return "-show-all prints the raw response"
+	case a.noSynthesis:
+		return "-synthesis=false prints sources, not an answer"
+	case a.output != outputText:
+		return "-o " + a.output + " prints the answer as a whole"
+	case a.schemaPath != "":
+		return "-schema validates the whole answer"
+	case a.citationStyle != "" && a.citationStyle != citeFootnotes:
+		// Footnote sources are listed after a streamed answer.
+		return "-citation-style " + a.citationStyle + " rewrites the answer"
+	case a.translateTo != "":
+		return "-translate-to rewrites the answer"
+	case answerPipeline != nil:
+		return "answer post-processing rewrites the answer"
+	}
+	return ""
+}
+
+// cliOutput is the shape printed by -o json. Every field is always present
+// so scripts can rely on it; new fields are only ever added.
+type cliOutput struct {
+	Query     string     `json:"query"`
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	Model     string     `json:"model"`
+	Provider  string     `json:"provider"`
+	ID        string     `json:"id"`
+	Usage     *apiUsage  `json:"usage"`
+	Cached    bool       `json:"cached"`
+	Related   []string   `json:"related_questions"`
+	// Sources are the search results of -synthesis=false, which has no
+	// answer.
+	Sources []SearchSource `json:"sources"`
+}
+
+// newCLIOutput describes result with its answer as printed, which may be
+// styled or translated; the citation spans are dropped when it differs.
+func newCLIOutput(result *WebSearchResult, answer string, related []string) cliOutput {
+	out := cliOutput{
+		Query:     result.Query,
+		Answer:    answer,
+		Citations: result.Citations,
+		Model:     result.Model,
+		Provider:  result.Provider,
+		ID:        result.ID,
+		Usage:     result.Usage,
+		Cached:    result.Cached,
+		Related:   related,
+		Sources:   result.Sources,
+	}
+	if answer != result.Answer {
+		out.Citations = withoutSpans(out.Citations)
+	}
+	if out.Citations == nil {
+		out.Citations = []Citation{}
+	}
+	if out.Related == nil {
+		out.Related = []string{}
+	}
+	if out.Sources == nil {
+		out.Sources = []SearchSource{}
+	}
+	return out
+}
+
+// writeCLIOutput prints out as indented JSON or as a Markdown document.
+// The Markdown lists the sources unless the answer already does (the
+// footnotes and apa citation styles) or they are suppressed (none); search
+// results without an answer are listed in its place.
+func writeCLIOutput(w io.Writer, format string, out cliOutput, citationStyle string) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	var sb strings.Builder
+	if out.Query != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", out.Query)
+	}
+	if out.Answer != "" {
+		fmt.Fprintf(&sb, "%s\n", strings.TrimSpace(out.Answer))
+	}
+	for i, s := range out.Sources {
+		fmt.Fprintf(&sb, "%d. [%s](%s)\n", i+1, cmp.Or(s.Title, citationHost(s.URL), s.URL), s.URL)
+		if snippet := strings.Join(strings.Fields(s.Snippet), " "); snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", snippet)
+		}
+	}
+	if citationStyle == "" || citationStyle == citeInline {
+		seen := make(map[string]bool)
+		n := 0
+		for _, c := range out.Citations {
+			if seen[c.URL] {
+				continue
+			}
+			seen[c.URL] = true
+			if n++; n == 1 {
+				sb.WriteString("\n## Sources\n\n")
+			}
+			fmt.Fprintf(&sb, "%d. [%s](%s)\n", n, cmp.Or(c.Title, citationHost(c.URL), c.URL), c.URL)
+		}
+	}
+	if len(out.Related) > 0 {
+		sb.WriteString("\n## Related questions\n\n")
+		for _, q := range out.Related {
+			fmt.Fprintf(&sb, "- %s\n", q)
+		}
+	}
+
+	meta := []string{"Model: " + out.Model}
+	if out.Provider != "" {
+		meta = append(meta, "Provider: "+out.Provider)
+	}
+	if out.ID != "" {
+		meta = append(meta, "ID: "+out.ID)
+	}
+	if out.Usage != nil {
+		meta = append(meta, fmt.Sprintf("Tokens: %d", out.Usage.TotalTokens))
+	}
+	if out.Cached {
+		meta = append(meta, "cached")
+	}
+	fmt.Fprintf(&sb, "\n---\n\n*%s*\n", strings.Join(meta, " · "))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeCLISources prints the search results of -synthesis=false: a numbered
+// list of titles, URLs and snippets, or with -o json and md the usual
+// structured output.
+func writeCLISources(w io.Writer, format string, result *WebSearchResult) error {
+	if format != outputText {
+		return writeCLIOutput(w, format, newCLIOutput(result, "", nil), citeNone)
+	}
+	var sb strings.Builder
+	for i, s := range result.Sources {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%d. %s\n   %s\n", i+1, cmp.Or(s.Title, citationHost(s.URL), s.URL), s.URL)
+		if snippet := strings.Join(strings.Fields(s.Snippet), " "); snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", snippet)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteCLIOutput(t *testing.T) {
+	t.Parallel()
+
+	result := &WebSearchResult{
+		Query: "What changed in Go 1.22?", Answer: "Loops changed.", Model: "gpt-5.4-mini", Provider: providerOpenAI, ID: "resp_1",
+		Usage: &apiUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		Citations: []Citation{
+			{URL: "https://go.dev/doc/go1.22", Title: "Go 1.22 Release Notes", Span: &textSpan{StartByte: 0, EndByte: 5}},
+			{URL: "https://go.dev/doc/go1.22"},
+			{URL: "https://example.com/loops"},
+		},
+	}
+
+	var sb strings.Builder
+	if err := writeCLIOutput(&sb, outputJSON, newCLIOutput(result, "Loops changed [1].", nil), citeFootnotes); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(sb.String()), &got); err != nil {
+		t.Fatalf("json output %q: %v", sb.String(), err)
+	}
+	for _, key := range []string{"query", "answer", "citations", "model", "provider", "id", "usage", "cached", "related_questions"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("json output lacks %q: %s", key, sb.String())
+		}
+	}
+	if strings.Contains(sb.String(), "start_byte") {
+		t.Errorf("spans kept although the answer was restyled: %s", sb.String())
+	}
+
+	// An answer without citations or usage keeps the same keys.
+	sb.Reset()
+	if err := writeCLIOutput(&sb, outputJSON, newCLIOutput(&WebSearchResult{Answer: "Hi."}, "Hi.", nil), ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), `"citations": []`) || !strings.Contains(sb.String(), `"usage": null`) {
+		t.Errorf("empty json output = %s", sb.String())
+	}
+
+	sb.Reset()
+	if err := writeCLIOutput(&sb, outputMarkdown, newCLIOutput(result, result.Answer, []string{"What about Go 1.23?"}), ""); err != nil {
+		t.Fatal(err)
+	}
+	want := "# What changed in Go 1.22?\n\nLoops changed.\n\n" +
+		"## Sources\n\n1. [Go 1.22 Release Notes](https://go.dev/doc/go1.22)\n2. [example.com](https://example.com/loops)\n\n" +
+		"## Related questions\n\n- What about Go 1.23?\n\n" +
+		"---\n\n*Model: gpt-5.4-mini · Provider: openai · ID: resp_1 · Tokens: 15*\n"
+	if sb.String() != want {
+		t.Errorf("markdown:\n got %q\nwant %q", sb.String(), want)
+	}
+
+	if _, err := parseOutputFormat("yaml"); err == nil {
+		t.Error("parseOutputFormat(yaml): expected an error")
+	}
+	if f, err := parseOutputFormat("Markdown"); err != nil || f != outputMarkdown {
+		t.Errorf("parseOutputFormat(Markdown) = %q, %v", f, err)
+	}
+}
+
+func TestStreamConflict(t *testing.T) {
+	t.Parallel()
+
+	for _, style := range []string{"", citeFootnotes} {
+		if reason := (cliArgs{output: outputText, citationStyle: style}).streamConflict(); reason != "" {
+			t.Errorf("citation style %q: conflict %q, want none", style, reason)
+		}
+	}
+	for _, args := range []cliArgs{
+		{output: outputJSON},
+		{output: outputText, showAll: true},
+		{output: outputText, schemaPath: "schema.json"},
+		{output: outputText, citationStyle: citeAPA},
+		{output: outputText, citationStyle: citeNone},
+		{output: outputText, translateTo: "German"},
+	} {
+		if args.streamConflict() == "" {
+			t.Errorf("%+v: expected a conflict with -stream", args)
+		}
+	}
+}
+
+func TestWriteCLISources(t *testing.T) {
+	t.Parallel()
+
+	result := &WebSearchResult{Query: "q", Model: "m", Sources: []SearchSource{
+		{URL: "https://who.int/measles", Title: "Measles", Snippet: "Highly\ncontagious."},
+		{URL: "https://example.com/a"},
+	}}
+	var text strings.Builder
+	if err := writeCLISources(&text, outputText, result); err != nil {
+		t.Fatal(err)
+	}
+	want := "1. Measles\n   https://who.int/measles\n   Highly contagious.\n\n2. example.com\n   https://example.com/a\n"
+	if text.String() != want {
+		t.Errorf("text = %q, want %q", text.String(), want)
+	}
+
+	var js strings.Builder
+	if err := writeCLISources(&js, outputJSON, result); err != nil {
+		t.Fatal(err)
+	}
+	var out cliOutput
+	if err := json.Unmarshal([]byte(js.String()), &out); err != nil || len(out.Sources) != 2 || out.Answer != "" {
+		t.Errorf("json = %s (%v)", js.String(), err)
+	}
+}
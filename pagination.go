@@ -0,0 +1,181 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// answerURIScheme prefixes the resource URIs of answer pages.
+	answerURIScheme = "answer://"
+	// maxPagedAnswers bounds the long answers kept for paging; the oldest
+	// is dropped first.
+	maxPagedAnswers = 256
+)
+
+// answerPager splits answers longer than size runes into pages (env
+// ANSWER_PAGE_SIZE). A tool result carries the first page and the URI of
+// the next; the rest is read as answer://{id}?page=N resources. A nil
+// *answerPager never pages.
+type answerPager struct {
+	size int
+
+	mu    sync.Mutex
+	pages map[string][]string
+	order []string
+}
+
+// activePager pages long MCP answers; set at startup from ANSWER_PAGE_SIZE.
+var activePager *answerPager
+
+// newAnswerPager returns nil when size is not positive (no paging).
+func newAnswerPager(size int) *answerPager {
+	if size <= 0 {
+		return nil
+	}
+	return &answerPager{size: size, pages: make(map[string][]string)}
+}
+
+// page returns result with its answer cut to the first page when it is
+// longer than a page; the full answer stays readable as resources. result
+// itself is not modified, since it may be shared with the cache. Version 1
+// results (see resultSchemaVersion) cannot point to the next page, so they
+// are not paged.
+func (p *answerPager) page(result *WebSearchResult) *WebSearchResult {
+	if p == nil || result == nil || resultSchema == 1 || utf8.RuneCountInString(result.Answer) <= p.size {
+		return result
+	}
+	pages := splitAnswer(result.Answer, p.size)
+	id := cmp.Or(result.ID, result.RequestID, newRequestID())
+
+	p.mu.Lock()
+	if _, ok := p.pages[id]; !ok {
+		if len(p.order) >= maxPagedAnswers {
+			delete(p.pages, p.order[0])
+			p.order = p.order[1:]
+		}
+		p.order = append(p.order, id)
+	}
+	p.pages[id] = pages
+	p.mu.Unlock()
+
+	paged := *result
+	paged.Answer = pages[0]
+	paged.AnswerPages = len(pages)
+	paged.NextPage = answerPageURI(id, 2)
+	return &paged
+}
+
+// get returns page n (1-based) of the answer stored under id and the number
+// of pages.
+func (p *answerPager) get(id string, n int) (string, int, error) {
+	if p == nil {
+		return "", 0, errors.New("answer paging is not enabled")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pages, ok := p.pages[id]
+	if !ok {
+		return "", 0, fmt.Errorf("no paged answer %q (it may have expired)", id)
+	}
+	if n < 1 || n > len(pages) {
+		return "", 0, fmt.Errorf("page %d out of range; answer %s has %d pages", n, id, len(pages))
+	}
+	return pages[n-1], len(pages), nil
+}
+
+// splitAnswer cuts text into pages of at most size runes, breaking at the
+// last paragraph, line or word boundary in the second half of each page so
+// sentences and Markdown blocks stay whole where possible.
+func splitAnswer(text string, size int) []string {
+	var pages []string
+	for utf8.RuneCountInString(text) > size {
+		// cut is the byte offset just past size runes.
+		cut := 0
+		for range size {
+			_, w := utf8.DecodeRuneInString(text[cut:])
+			cut += w
+		}
+		brk := cut
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			if i := strings.LastIndex(text[:cut], sep); i > 0 && i >= cut/2 {
+				brk = i + len(sep)
+				break
+			}
+		}
+		pages = append(pages, text[:brk])
+		text = text[brk:]
+	}
+	return append(pages, text)
+}
+
+// answerPageURI is the resource URI of page n of the answer stored as id.
+func answerPageURI(id string, n int) string {
+	return fmt.Sprintf("%s%s?page=%d", answerURIScheme, url.PathEscape(id), n)
+}
+
+// parseAnswerPageURI splits an answer:// URI into the answer ID and page;
+// the page defaults to 1.
+func parseAnswerPageURI(uri string) (id string, n int, err error) {
+	rest, ok := strings.CutPrefix(uri, answerURIScheme)
+	if !ok {
+		return "", 0, fmt.Errorf("not an answer URI: %s", uri)
+	}
+	rest, query, _ := strings.Cut(rest, "?")
+	if id, err = url.PathUnescape(rest); err != nil || id == "" {
+		return "", 0, fmt.Errorf("invalid answer URI: %s", uri)
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid answer URI: %s", uri)
+	}
+	n = 1
+	if v := values.Get("page"); v != "" {
+		if n, err = strconv.Atoi(v); err != nil {
+			return "", 0, fmt.Errorf("invalid page %q", v)
+		}
+	}
+	return id, n, nil
+}
+
+// newAnswerPageTemplate builds the resource template for answer pages.
+func newAnswerPageTemplate() mcp.ResourceTemplate {
+	return mcp.NewResourceTemplate(answerURIScheme+"{id}{?page}", "Answer page",
+		mcp.WithTemplateDescription("A page of a long gpt_websearch answer; the tool result's next_page gives the first URI to read"),
+		mcp.WithTemplateMIMEType("text/markdown"),
+	)
+}
+
+// answerPageHandler serves answer pages. A page that is not the last ends
+// with a line naming the URI of the next one.
+func answerPageHandler() func(context.Context, mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		id, n, err := parseAnswerPageURI(request.Params.URI)
+		if err != nil {
+			return nil, err
+		}
+		text, total, err := activePager.get(id, n)
+		if err != nil {
+			return nil, err
+		}
+		if n < total {
+			text += fmt.Sprintf("\n\n[Page %d of %d. Next: %s]", n, total, answerPageURI(id, n+1))
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "text/markdown",
+				Text:     text,
+			},
+		}, nil
+	}
+}
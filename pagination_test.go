@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitAnswer(t *testing.T) {
+	t.Parallel()
+
+	text := "First paragraph here.\n\nSecond paragraph, a little longer.\n\nThird één."
+	pages := splitAnswer(text, 30)
+	if strings.Join(pages, "") != text {
+		t.Fatalf("pages do not add up to the answer: %q", pages)
+	}
+	for i, p := range pages {
+		if n := utf8.RuneCountInString(p); n > 30 {
+			t.Errorf("page %d has %d runes", i+1, n)
+		}
+	}
+	if pages[0] != "First paragraph here.\n\n" {
+		t.Errorf("page 1 = %q, want a break at the paragraph", pages[0])
+	}
+
+	// Without any boundary the page is cut at the rune limit.
+	if got := splitAnswer("ééééé", 2); len(got) != 3 || got[0] != "éé" || got[2] != "é" {
+		t.Errorf("splitAnswer without boundaries = %q", got)
+	}
+}
+
+func TestParseAnswerPageURI(t *testing.T) {
+	t.Parallel()
+
+	if id, n, err := parseAnswerPageURI(answerPageURI("resp_1", 3)); err != nil || id != "resp_1" || n != 3 {
+		t.Errorf("round trip = %q, %d, %v", id, n, err)
+	}
+	if id, n, err := parseAnswerPageURI("answer://resp_1"); err != nil || id != "resp_1" || n != 1 {
+		t.Errorf("default page = %q, %d, %v", id, n, err)
+	}
+	for _, uri := range []string{"models://list", "answer://", "answer://resp_1?page=x"} {
+		if _, _, err := parseAnswerPageURI(uri); err == nil {
+			t.Errorf("%s: expected an error", uri)
+		}
+	}
+}
+
+func TestGptWebsearch_PagesLongAnswers(t *testing.T) {
+	old := activePager
+	activePager = newAnswerPager(40)
+	t.Cleanup(func() { activePager = old })
+
+	answer := strings.Repeat("A sentence about paging answers. ", 4)
+	_, upstream := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_paged", "model": "gpt-5.4-mini", "status": "completed",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": answer}}}},
+		})
+	})
+	srv, baseURL := newHTTPServerFromHandler(t, newStatelessMCPHandler(t, upstream))
+	_ = srv
+
+	res := jsonrpcResult(t, jsonrpcCall(t, baseURL+"/", "tools/call", 1, map[string]any{
+		"name":      "gpt_websearch",
+		"arguments": map[string]any{"query": "paging test question", "no_cache": true},
+	}))
+	sc, _ := res["structuredContent"].(map[string]any)
+	if sc["answer_pages"] != float64(4) || sc["next_page"] != "answer://resp_paged?page=2" {
+		t.Fatalf("structured result = %v", sc)
+	}
+	got, _ := sc["answer"].(string)
+
+	next, _ := sc["next_page"].(string)
+	for next != "" {
+		res := jsonrpcResult(t, jsonrpcCall(t, baseURL+"/", "resources/read", 2, map[string]any{"uri": next}))
+		contents, _ := res["contents"].([]any)
+		if len(contents) != 1 {
+			t.Fatalf("resources/read %s = %v", next, res)
+		}
+		text, _ := contents[0].(map[string]any)["text"].(string)
+		next = ""
+		if body, footer, ok := strings.Cut(text, "\n\n[Page "); ok {
+			text = body
+			_, next, _ = strings.Cut(strings.TrimSuffix(footer, "]"), "Next: ")
+		}
+		got += text
+	}
+	if got != answer {
+		t.Errorf("pages joined = %q, want %q", got, answer)
+	}
+}
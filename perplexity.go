@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const providerPerplexity = "perplexity"
+
+// perplexityModel keeps Sonar model names and replaces anything else with
+// the base Sonar model.
+var perplexityModel = vendorModel("sonar", "sonar")
+
+// sendPerplexity calls Perplexity's chat completions endpoint. Sonar models
+// search on every request, so web search is switched off explicitly rather
+// than enabled; the citations come back as a top-level URL list referenced
+// by [n] markers in the answer.
+func sendPerplexity(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	if p.SourcesOnly && p.UseWebSearch {
+		return searchPerplexity(ctx, p)
+	}
+	req := newChatRequest(p)
+	if !p.UseWebSearch {
+		req.DisableSearch = true
+		return doChatRequest(ctx, p, req, nil)
+	}
+	req.SearchDomainFilter = perplexityDomainFilter(p)
+	if p.SearchContextSize != "" || (p.UserLocation != nil && p.UserLocation.Country != "") {
+		req.WebSearchOptions = &chatWebSearchOptions{SearchContextSize: p.SearchContextSize}
+		if p.UserLocation != nil && p.UserLocation.Country != "" {
+			req.WebSearchOptions.UserLocation = &chatSearchLocation{Country: p.UserLocation.Country}
+		}
+	}
+	return doChatRequest(ctx, p, req, nil)
+}
+
+// checkPerplexity notes that a location hint without a country is ignored:
+// Sonar localizes by country alone.
+func checkPerplexity(p CallAPIParams) ([]string, error) {
+	if p.UseWebSearch && p.UserLocation != nil && p.UserLocation.Country == "" {
+		return []string{"user_location"}, nil
+	}
+	return nil, nil
+}
+
+// perplexityDomainFilter lists the allowed domains and the excluded ones,
+// which Perplexity takes with a leading "-".
+func perplexityDomainFilter(p CallAPIParams) []string {
+	filter := slices.Clone(p.AllowedDomains)
+	for _, d := range p.BlockedDomains {
+		filter = append(filter, "-"+d)
+	}
+	return filter
+}
+
+// perplexityMaxResults is how many results the Search API returns.
+const perplexityMaxResults = 10
+
+type perplexitySearchRequest struct {
+	Query              string   `json:"query"`
+	MaxResults         int      `json:"max_results"`
+	SearchDomainFilter []string `json:"search_domain_filter,omitempty"`
+	Country            string   `json:"country,omitempty"`
+}
+
+type perplexitySearchResponse struct {
+	ID      string `json:"id"`
+	Results []struct {
+		Title   string `json:"title"`
+		URL     string `json:"url"`
+		Snippet string `json:"snippet"`
+	} `json:"results"`
+}
+
+// searchPerplexity calls Perplexity's Search API, which returns ranked
+// results with snippets and no answer, next to the chat endpoint in
+// p.BaseURL. The results become a web_search_call item's sources.
+func searchPerplexity(ctx context.Context, p CallAPIParams) (*apiResponse, error) {
+	body := perplexitySearchRequest{Query: p.Query, MaxResults: perplexityMaxResults, SearchDomainFilter: perplexityDomainFilter(p)}
+	if p.UserLocation != nil {
+		body.Country = p.UserLocation.Country
+	}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	endpoint := strings.TrimSuffix(strings.TrimRight(p.BaseURL, "/"), "/chat/completions") + "/search"
+	req, err := newAuthRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(buf), p)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var sr perplexitySearchResponse
+	if err := json.Unmarshal(bodyBytes, &sr); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	action := &respAction{Type: "search", Query: p.Query}
+	for _, r := range sr.Results {
+		action.Sources = append(action.Sources, respSource{Type: "url", URL: r.URL, Title: r.Title, Snippet: r.Snippet})
+	}
+	return &apiResponse{
+		ID:     sr.ID,
+		Model:  p.Model,
+		Status: "completed",
+		Output: []respItem{{Type: "web_search_call", Status: "completed", Action: action}},
+	}, nil
+}
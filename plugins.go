@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultPluginTimeout bounds a plugin run when its spec sets none.
+	defaultPluginTimeout = time.Minute
+	// maxPluginOutput bounds the stdout kept from a plugin run.
+	maxPluginOutput = 4 << 20
+	// maxPluginStderr bounds the stderr repeated in a tool error.
+	maxPluginStderr = 2000
+)
+
+// pluginNamePattern matches the tool names MCP clients accept.
+var pluginNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// builtinToolNames returns the tools a plugin cannot replace: every tool
+// the server can register, admin tools included.
+func builtinToolNames() []string {
+	var names []string
+	for _, t := range builtinTools(MCPConfig{AdminTools: true}) {
+		names = append(names, t.Tool.Name)
+	}
+	return names
+}
+
+// pluginSpec is one external executable exposed as an MCP tool, as listed
+// in PLUGINS_FILE. The tool arguments are written to the command's stdin as
+// a JSON object and its stdout becomes the tool result.
+type pluginSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// InputSchema is the tool's JSON Schema; it defaults to an object with
+	// no declared properties.
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+	// Command is the executable and its arguments; it is run directly, not
+	// through a shell.
+	Command []string `json:"command"`
+	// Timeout is a Go duration (default 1m).
+	Timeout string `json:"timeout,omitempty"`
+
+	timeout time.Duration
+}
+
+// mcpPlugins are the plugin tools the MCP server registers; set at startup
+// from PLUGINS_FILE.
+var mcpPlugins []pluginSpec
+
+// loadPlugins reads and validates the plugin list at path. An empty path
+// means no plugins.
+func loadPlugins(path string) ([]pluginSpec, error) {
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read plugins: %w", err)
+	}
+	var specs []pluginSpec
+	if err := json.Unmarshal(raw, &specs); err != nil {
+		return nil, fmt.Errorf("PLUGINS_FILE must be a JSON array of tool specs: %w", err)
+	}
+	seen := make(map[string]bool, len(specs))
+	for i := range specs {
+		p := &specs[i]
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("PLUGINS_FILE entry %d: %w", i+1, err)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("PLUGINS_FILE entry %d: duplicate tool name %q", i+1, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return specs, nil
+}
+
+// validate checks a spec and fills in its defaults.
+func (p *pluginSpec) validate() error {
+	if !pluginNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("name %q must be 1-64 letters, digits, _ or -", p.Name)
+	}
+	if slices.Contains(builtinToolNames(), p.Name) {
+		return fmt.Errorf("name %q is a built-in tool", p.Name)
+	}
+	if len(p.Command) == 0 || p.Command[0] == "" {
+		return fmt.Errorf("tool %s: command is required", p.Name)
+	}
+	if len(p.InputSchema) == 0 {
+		p.InputSchema = json.RawMessage(`{"type":"object"}`)
+	}
+	var schema struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(p.InputSchema, &schema); err != nil || schema.Type != "object" {
+		return fmt.Errorf("tool %s: input_schema must be a JSON Schema object with type \"object\"", p.Name)
+	}
+	p.timeout = defaultPluginTimeout
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("tool %s: invalid timeout %q", p.Name, p.Timeout)
+		}
+		p.timeout = d
+	}
+	return nil
+}
+
+// tool returns the MCP definition of the plugin.
+func (p pluginSpec) tool() mcp.Tool {
+	return mcp.NewToolWithRawSchema(p.Name, p.Description, p.InputSchema)
+}
+
+// pluginHandler returns the handler that runs the plugin for each call.
+func pluginHandler(p pluginSpec) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		if args == nil {
+			args = map[string]any{}
+		}
+		input, err := json.Marshal(args)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("encode arguments: %v", err)), nil
+		}
+		logToClient(ctx, mcp.LoggingLevelDebug, "plugin", fmt.Sprintf("Running plugin %s: %s", p.Name, strings.Join(p.Command, " ")))
+		out, err := runPlugin(ctx, p, input)
+		if err != nil {
+			Warn("Plugin failed", "tool", p.Name, "error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(out), nil
+	}
+}
+
+// runPlugin runs the plugin command with input on stdin and returns its
+// stdout. A non-zero exit or timeout is an error that carries the start of
+// stderr.
+func runPlugin(ctx context.Context, p pluginSpec, input []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "WEBSEARCH_TOOL="+p.Name)
+	// Do not wait on pipes held open by the plugin's own children.
+	cmd.WaitDelay = time.Second
+	stdout := &cappedBuffer{limit: maxPluginOutput}
+	stderr := &cappedBuffer{limit: maxPluginStderr}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", p.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return "", fmt.Errorf("plugin %s: %w: %s", p.Name, err, msg)
+		}
+		return "", fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	if stdout.truncated {
+		return "", fmt.Errorf("plugin %s: output exceeds %d bytes", p.Name, maxPluginOutput)
+	}
+	return stdout.buf.String(), nil
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a runaway plugin cannot exhaust memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	t.Parallel()
+
+	write := func(body string) string {
+		path := filepath.Join(t.TempDir(), "plugins.json")
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	specs, err := loadPlugins(write(`[{"name":"wordcount","description":"Count words","command":["wc","-w"],"timeout":"5s"}]`))
+	if err != nil {
+		t.Fatalf("loadPlugins: %v", err)
+	}
+	if len(specs) != 1 || specs[0].timeout.Seconds() != 5 || string(specs[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("specs = %+v", specs)
+	}
+
+	for name, body := range map[string]string{
+		"builtin":   `[{"name":"verify","command":["x"]}]`,
+		"batch":     `[{"name":"batch_websearch","command":["x"]}]`,
+		"response":  `[{"name":"get_response","command":["x"]}]`,
+		"duplicate": `[{"name":"a","command":["x"]},{"name":"a","command":["y"]}]`,
+		"command":   `[{"name":"a"}]`,
+		"schema":    `[{"name":"a","command":["x"],"input_schema":{"type":"string"}}]`,
+		"timeout":   `[{"name":"a","command":["x"],"timeout":"soon"}]`,
+		"name":      `[{"name":"a b","command":["x"]}]`,
+	} {
+		if _, err := loadPlugins(write(body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestPluginHandler(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Parallel()
+
+	call := func(p pluginSpec, args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		if err := p.validate(); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+		req := mcp.CallToolRequest{}
+		req.Params.Name, req.Params.Arguments = p.Name, args
+		res, err := pluginHandler(p)(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		return res
+	}
+	text := func(res *mcp.CallToolResult) string {
+		return res.Content[0].(mcp.TextContent).Text
+	}
+
+	echo := pluginSpec{Name: "echo", Command: []string{"sh", "-c", `cat; printf " %s" "$WEBSEARCH_TOOL"`}}
+	if res := call(echo, map[string]any{"q": "hi"}); res.IsError || text(res) != `{"q":"hi"} echo` {
+		t.Errorf("echo result = %+v", res)
+	}
+
+	fail := pluginSpec{Name: "fail", Command: []string{"sh", "-c", "echo broken >&2; exit 3"}}
+	if res := call(fail, nil); !res.IsError || !strings.Contains(text(res), "broken") {
+		t.Errorf("failing plugin result = %+v", res)
+	}
+
+	slow := pluginSpec{Name: "slow", Command: []string{"sleep", "5"}, Timeout: "100ms"}
+	if res := call(slow, nil); !res.IsError || !strings.Contains(text(res), "timed out") {
+		t.Errorf("slow plugin result = %+v", res)
+	}
+}
+
+func TestNewMCPServer_Plugins(t *testing.T) {
+	mcpPlugins = []pluginSpec{{Name: "wordcount", Command: []string{"wc", "-w"}}}
+	t.Cleanup(func() { mcpPlugins = nil })
+
+	if NewMCPServer(MCPConfig{}).GetTool("wordcount") == nil {
+		t.Error("plugin tool not registered")
+	}
+	if NewMCPServer(MCPConfig{ReadOnly: true}).GetTool("wordcount") != nil {
+		t.Error("plugin tool registered in read-only mode")
+	}
+}
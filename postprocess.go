@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// boilerplatePatterns strip stock model phrasing that adds nothing to a
+// search answer. Enabled with ANSWER_STRIP_BOILERPLATE.
+var boilerplatePatterns = []string{
+	`(?im)^[ \t]*as of my (last )?(knowledge|training)( data)?( cutoff| update)?[^.,\n]*[.,][ \t]*`,
+	`(?i)\bas an ai (language )?model,?[ \t]*`,
+	`(?im)^[ \t]*(i hope this helps|let me know if you (have|need) (any )?(other|further|more)[^.!\n]*)[.!]?[ \t]*$`,
+}
+
+var (
+	trailingSpaceRe = regexp.MustCompile(`(?m)[ \t]+$`)
+	blankLinesRe    = regexp.MustCompile(`\n{3,}`)
+)
+
+// postProcessor cleans up answer text before it is returned to callers.
+// A nil *postProcessor leaves text untouched.
+type postProcessor struct {
+	trimTrailing  bool
+	collapseBlank bool
+	strip         []*regexp.Regexp
+	glossary      *glossary
+	plugins       *wasmPlugins
+}
+
+// answerPipeline is the post-processing applied to every answer; nil unless
+// one of the ANSWER_* options is configured.
+var answerPipeline *postProcessor
+
+// PostProcessConfig holds the raw post-processing options.
+type PostProcessConfig struct {
+	TrimTrailing     bool
+	CollapseBlank    bool
+	StripBoilerplate bool
+	// StripPatterns is a JSON array of extra regular expressions whose
+	// matches are removed from the answer.
+	StripPatterns string
+	// Glossary rewrites non-preferred terms; loaded from GLOSSARY_FILE.
+	Glossary *glossary
+	// Plugins are the WASM answer transformers from WASM_PLUGINS.
+	Plugins *wasmPlugins
+}
+
+// newPostProcessor compiles cfg, returning nil when no option is enabled.
+func newPostProcessor(cfg PostProcessConfig) (*postProcessor, error) {
+	var patterns []string
+	if cfg.StripBoilerplate {
+		patterns = append(patterns, boilerplatePatterns...)
+	}
+	if strings.TrimSpace(cfg.StripPatterns) != "" {
+		var extra []string
+		if err := json.Unmarshal([]byte(cfg.StripPatterns), &extra); err != nil {
+			return nil, fmt.Errorf("ANSWER_STRIP_PATTERNS must be a JSON array of regular expressions: %w", err)
+		}
+		patterns = append(patterns, extra...)
+	}
+
+	if !cfg.TrimTrailing && !cfg.CollapseBlank && len(patterns) == 0 && cfg.Glossary == nil && cfg.Plugins == nil {
+		return nil, nil
+	}
+
+	p := &postProcessor{trimTrailing: cfg.TrimTrailing, collapseBlank: cfg.CollapseBlank, glossary: cfg.Glossary, plugins: cfg.Plugins}
+	for _, expr := range patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid strip pattern %q: %w", expr, err)
+		}
+		p.strip = append(p.strip, re)
+	}
+	return p, nil
+}
+
+// apply runs the configured steps: pattern stripping first (it may leave
+// dangling whitespace), then whitespace normalization, then glossary terms,
+// then WASM plugins. A failing plugin is logged and its input kept.
+func (p *postProcessor) apply(text string) string {
+	if p == nil {
+		return text
+	}
+	for _, re := range p.strip {
+		text = re.ReplaceAllString(text, "")
+	}
+	if p.trimTrailing {
+		text = trailingSpaceRe.ReplaceAllString(text, "")
+	}
+	if p.collapseBlank {
+		text = blankLinesRe.ReplaceAllString(text, "\n\n")
+	}
+	text = p.glossary.apply(text)
+	if out, err := p.plugins.transform(context.Background(), wasmStageAnswer, text); err != nil {
+		Error("Answer plugin failed; answer left unchanged", "error", err)
+	} else {
+		text = out
+	}
+	return strings.TrimSpace(text)
+}
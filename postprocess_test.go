@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestPostProcessor_Apply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  PostProcessConfig
+		in   string
+		want string
+	}{
+		{
+			name: "trim_trailing",
+			cfg:  PostProcessConfig{TrimTrailing: true},
+			in:   "line one   \nline two\t\n",
+			want: "line one\nline two",
+		},
+		{
+			name: "collapse_blank_lines",
+			cfg:  PostProcessConfig{CollapseBlank: true},
+			in:   "para one\n\n\n\n\npara two",
+			want: "para one\n\npara two",
+		},
+		{
+			name: "strip_boilerplate",
+			cfg:  PostProcessConfig{StripBoilerplate: true, CollapseBlank: true},
+			in:   "As of my knowledge cutoff in 2024, Go 1.22 is current.\nGo 1.23 shipped.\n\nI hope this helps!",
+			want: "Go 1.22 is current.\nGo 1.23 shipped.",
+		},
+		{
+			name: "custom_patterns",
+			cfg:  PostProcessConfig{StripPatterns: `["\\s*\\(source: internal\\)"]`},
+			in:   "Answer text (source: internal).",
+			want: "Answer text.",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := newPostProcessor(tt.cfg)
+			if err != nil {
+				t.Fatalf("newPostProcessor: %v", err)
+			}
+			if got := p.apply(tt.in); got != tt.want {
+				t.Errorf("apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPostProcessor_DisabledAndInvalid(t *testing.T) {
+	t.Parallel()
+
+	p, err := newPostProcessor(PostProcessConfig{})
+	if err != nil || p != nil {
+		t.Fatalf("empty config should disable post-processing, got %v, %v", p, err)
+	}
+	if got := p.apply("  keep  \n\n\n"); got != "  keep  \n\n\n" {
+		t.Errorf("nil processor modified text: %q", got)
+	}
+
+	if _, err := newPostProcessor(PostProcessConfig{StripPatterns: "not json"}); err == nil {
+		t.Error("expected error for non-JSON patterns")
+	}
+	if _, err := newPostProcessor(PostProcessConfig{StripPatterns: `["("]`}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profile is a named bundle of settings from the profiles file, picked with
+// -profile or PROFILE, so switching setups does not mean juggling
+// environment variables. String values may refer to environment variables
+// as $NAME or ${NAME}, which keeps keys out of the file.
+type profile struct {
+	Name      string `yaml:"-"`
+	Model     string `yaml:"model"`
+	Effort    string `yaml:"effort"`
+	Verbosity string `yaml:"verbosity"`
+	Provider  string `yaml:"provider"`
+	// BaseURL is the endpoint: the -base default for OpenAI (e.g. a
+	// gateway), or the Azure OpenAI resource for the azure provider.
+	BaseURL string `yaml:"base_url"`
+	// APIKey is the key of the profile's provider.
+	APIKey        string `yaml:"api_key"`
+	CitationStyle string `yaml:"citation_style"`
+	// Env sets further environment variables, e.g. AZURE_OPENAI_DEPLOYMENT.
+	Env map[string]string `yaml:"env"`
+}
+
+// activeProfile is the profile in use; the zero value when none was picked.
+var activeProfile profile
+
+// MCP tool argument defaults. They are the built-in defaults unless the
+// server was started with a profile that sets them.
+var (
+	toolModel     = defaultModel
+	toolEffort    = defaultEffort
+	toolVerbosity = defaultVerbosity
+)
+
+// profilesPath returns PROFILES_FILE, or profiles.yaml under
+// $XDG_CONFIG_HOME (default ~/.config)/websearch.
+func profilesPath() (string, error) {
+	if p := os.Getenv("PROFILES_FILE"); p != "" {
+		return p, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", fmt.Errorf("locate profiles file: %w", err)
+	}
+	return filepath.Join(dir, "profiles.yaml"), nil
+}
+
+// loadProfiles reads the profiles file at path: a YAML (or JSON) mapping of
+// profile names to settings. Unknown settings are rejected, so a typo does
+// not silently fall back to a default.
+func loadProfiles(path string) (map[string]profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read profiles file: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	var profiles map[string]profile
+	if err := dec.Decode(&profiles); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse profiles file %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// selectProfile applies the profile named by -profile in args, or else by
+// PROFILE. It runs before the environment is read and the flags are
+// parsed, so the profile overrides environment variables while explicit
+// flags still override the profile.
+func selectProfile(args []string) error {
+	name := cmp.Or(profileArg(args), os.Getenv("PROFILE"))
+	if name == "" {
+		return nil
+	}
+	path, err := profilesPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%w: %q (profiles in %s: %s)", ErrUnknownProfile, name, path, strings.Join(names, ", "))
+	}
+	p.Name = name
+	if err := p.apply(); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	activeProfile = p
+	Debug("Using profile", "profile", name, "file", path)
+	return nil
+}
+
+// profileArg returns the value of a -profile flag in args, which are not
+// parsed yet; "--" ends the flags.
+func profileArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "-"), "=")
+		if name != "-profile" && name != "profile" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// apply expands the profile's environment references and exports its
+// settings as the environment variables they stand for. Verbosity and the
+// OpenAI base URL have no variable; they stay on activeProfile.
+func (p *profile) apply() error {
+	for _, s := range []*string{&p.Model, &p.Effort, &p.Verbosity, &p.Provider, &p.BaseURL, &p.APIKey, &p.CitationStyle} {
+		*s = os.ExpandEnv(*s)
+	}
+	pr, err := lookupProvider(cmp.Or(p.Provider, os.Getenv("PROVIDER")))
+	if err != nil {
+		return err
+	}
+	env := map[string]string{
+		"MODEL":          p.Model,
+		"EFFORT":         p.Effort,
+		"PROVIDER":       p.Provider,
+		"CITATION_STYLE": p.CitationStyle,
+	}
+	if p.APIKey != "" && pr.keyEnv != "" {
+		env[pr.keyEnv] = p.APIKey
+	}
+	if p.BaseURL != "" && pr.name == providerAzure {
+		env["AZURE_OPENAI_ENDPOINT"], p.BaseURL = p.BaseURL, ""
+	}
+	for k, v := range p.Env {
+		env[k] = os.ExpandEnv(v)
+	}
+	for k, v := range env {
+		if v == "" {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+
+	toolModel = cmp.Or(p.Model, defaultModel)
+	toolEffort = validateEffort(cmp.Or(p.Effort, defaultEffort))
+	toolVerbosity = validateVerbosity(cmp.Or(p.Verbosity, defaultVerbosity))
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileArg(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-profile", "cheap", "question"}, "cheap"},
+		{[]string{"--profile=deep"}, "deep"},
+		{[]string{"-t", "http", "-profile=work-azure"}, "work-azure"},
+		{[]string{"-model", "x", "question"}, ""},
+		{[]string{"--", "-profile", "cheap"}, ""},
+		{[]string{"-profile"}, ""},
+	} {
+		if got := profileArg(tc.args); got != tc.want {
+			t.Errorf("profileArg(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+// TestSelectProfile changes the environment and package defaults, so it
+// does not run in parallel.
+func TestSelectProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(path, []byte(`
+cheap:
+  model: gpt-5.4-nano
+  effort: low
+  verbosity: low
+work-azure:
+  provider: azure
+  base_url: https://work.openai.azure.com
+  api_key: $WORK_KEY
+  env:
+    AZURE_OPENAI_DEPLOYMENT: search
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PROFILES_FILE", path)
+	t.Setenv("PROFILE", "")
+	t.Setenv("WORK_KEY", "secret")
+	for _, name := range []string{"MODEL", "EFFORT", "PROVIDER", "CITATION_STYLE", "AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_API_KEY", "AZURE_OPENAI_DEPLOYMENT"} {
+		t.Setenv(name, os.Getenv(name))
+	}
+	t.Cleanup(func() {
+		activeProfile = profile{}
+		toolModel, toolEffort, toolVerbosity = defaultModel, defaultEffort, defaultVerbosity
+	})
+
+	if err := selectProfile([]string{"-profile", "cheap"}); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("MODEL") != "gpt-5.4-nano" || os.Getenv("EFFORT") != "low" {
+		t.Errorf("MODEL = %q, EFFORT = %q", os.Getenv("MODEL"), os.Getenv("EFFORT"))
+	}
+	wa := extractWebSearchArgs(map[string]any{"query": "q"})
+	if wa.model != "gpt-5.4-nano" || wa.effort != "low" || wa.verbosity != "low" {
+		t.Errorf("tool defaults = %q/%q/%q", wa.model, wa.effort, wa.verbosity)
+	}
+	if wa := extractWebSearchArgs(map[string]any{"query": "q", "model": "gpt-5.4"}); wa.model != "gpt-5.4" {
+		t.Errorf("explicit model = %q", wa.model)
+	}
+
+	t.Setenv("PROFILE", "work-azure")
+	if err := selectProfile(nil); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("AZURE_OPENAI_ENDPOINT") != "https://work.openai.azure.com" || os.Getenv("AZURE_OPENAI_API_KEY") != "secret" ||
+		os.Getenv("AZURE_OPENAI_DEPLOYMENT") != "search" || os.Getenv("PROVIDER") != "azure" {
+		t.Errorf("azure env not applied")
+	}
+	if activeProfile.Name != "work-azure" || activeProfile.BaseURL != "" {
+		t.Errorf("activeProfile = %+v", activeProfile)
+	}
+
+	if err := selectProfile([]string{"-profile=missing"}); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("unknown profile error = %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressBarWidth is the number of cells in the batch progress bar.
+	progressBarWidth = 20
+	// maxProgressQueryRunes bounds the question echoed on a progress line.
+	maxProgressQueryRunes = 60
+)
+
+// expectedEffortDuration is a typical answer time for each effort level. The
+// batch ETA starts from these and is corrected by the observed latencies as
+// items complete.
+var expectedEffortDuration = map[string]time.Duration{
+	"none":   5 * time.Second,
+	"low":    15 * time.Second,
+	"medium": 40 * time.Second,
+	"high":   90 * time.Second,
+	"xhigh":  3 * time.Minute,
+}
+
+// batchProgress reports batch progress to w (stderr in the CLI), one line
+// per finished item with the overall bar and an ETA. A nil *batchProgress
+// reports nothing.
+type batchProgress struct {
+	w       io.Writer
+	now     func() time.Time
+	workers int
+	queries []string
+
+	mu       sync.Mutex
+	start    time.Time
+	expected []time.Duration
+	done     []bool
+	finished int
+	failed   int
+	// observed and observedExpected are the actual and expected durations
+	// of the finished items; their ratio calibrates the ETA.
+	observed         time.Duration
+	observedExpected time.Duration
+}
+
+// newBatchProgress prepares progress reporting for items run by workers.
+func newBatchProgress(w io.Writer, items []webSearchArgs, workers int) *batchProgress {
+	p := &batchProgress{
+		w:        w,
+		now:      time.Now,
+		workers:  min(max(workers, 1), maxBatchWorkers),
+		queries:  make([]string, len(items)),
+		expected: make([]time.Duration, len(items)),
+		done:     make([]bool, len(items)),
+	}
+	for i, wa := range items {
+		p.queries[i] = wa.query
+		p.expected[i] = cmp.Or(expectedEffortDuration[wa.effort], expectedEffortDuration[defaultEffort])
+	}
+	return p
+}
+
+// begin starts the clock and prints the initial estimate.
+func (p *batchProgress) begin() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.start = p.now()
+	fmt.Fprintf(p.w, "Batch: %d questions, %d at a time, ETA %s\n", len(p.done), min(p.workers, len(p.done)), formatETA(p.eta()))
+}
+
+// itemDone records that item i finished with r after took.
+func (p *batchProgress) itemDone(i int, r WebSearchResult, took time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done[i] {
+		return
+	}
+	p.done[i] = true
+	p.finished++
+	status := "ok"
+	switch {
+	case !r.Success:
+		status = "failed"
+		p.failed++
+	case r.Cached:
+		// Cache hits say nothing about upstream latency.
+		status = "cached"
+	default:
+		p.observed += took
+		p.observedExpected += p.expected[i]
+	}
+
+	total := len(p.done)
+	line := fmt.Sprintf("%s %*d/%d  %-6s %7s  %s", progressBar(p.finished, total), len(fmt.Sprint(total)), p.finished, total,
+		status, took.Round(100*time.Millisecond), truncateRunes(strings.Join(strings.Fields(p.queries[i]), " "), maxProgressQueryRunes))
+	if p.finished < total {
+		line += "  ETA " + formatETA(p.eta())
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+// finish prints the totals.
+func (p *batchProgress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "Batch done: %d succeeded, %d failed in %s\n",
+		p.finished-p.failed, p.failed, p.now().Sub(p.start).Round(time.Second))
+}
+
+// eta estimates the time left: the expected durations of the unfinished
+// items, scaled by how the finished ones compared with their expectation,
+// spread over the workers. Callers hold p.mu.
+func (p *batchProgress) eta() time.Duration {
+	var remaining time.Duration
+	left := 0
+	for i, done := range p.done {
+		if !done {
+			remaining += p.expected[i]
+			left++
+		}
+	}
+	if left == 0 {
+		return 0
+	}
+	if p.observedExpected > 0 {
+		remaining = time.Duration(float64(remaining) * float64(p.observed) / float64(p.observedExpected))
+	}
+	return remaining / time.Duration(min(p.workers, left))
+}
+
+// progressBar draws done of total as a fixed-width bar.
+func progressBar(done, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * progressBarWidth / total
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled) + "]"
+}
+
+// formatETA rounds an estimate to what is worth showing.
+func formatETA(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(10 * time.Second).String()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchProgress(t *testing.T) {
+	t.Parallel()
+
+	items := make([]webSearchArgs, 4)
+	for i := range items {
+		items[i] = webSearchArgs{query: "question  " + string(rune('A'+i)), effort: "medium"}
+	}
+	var out bytes.Buffer
+	p := newBatchProgress(&out, items, 2)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return clock }
+
+	p.begin()
+	p.itemDone(0, WebSearchResult{Success: true}, 20*time.Second) // half the expected 40s
+	p.itemDone(1, WebSearchResult{Error: "boom"}, time.Second)
+	p.itemDone(2, WebSearchResult{Success: true, Cached: true}, 0)
+	p.itemDone(2, WebSearchResult{Success: true}, 0) // reported twice, counted once
+	p.itemDone(3, WebSearchResult{Success: true}, 25*time.Second)
+	clock = clock.Add(47 * time.Second)
+	p.finish()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"Batch: 4 questions, 2 at a time, ETA 1m20s",
+		"[#####---------------] 1/4  ok         20s  question A  ETA 30s",
+		"[##########----------] 2/4  failed      1s  question B  ETA 20s",
+		"[###############-----] 3/4  cached      0s  question C  ETA 20s",
+		"[####################] 4/4  ok         25s  question D",
+		"Batch done: 3 succeeded, 1 failed in 47s",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+
+	var nilProgress *batchProgress
+	nilProgress.begin()
+	nilProgress.itemDone(0, WebSearchResult{}, 0)
+	nilProgress.finish()
+}
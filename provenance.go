@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// manifestFormat identifies the layout of a provenance manifest.
+const manifestFormat = "answer-provenance/1"
+
+// Manifest is a machine-readable provenance record of one answer: who
+// produced it, with which parameters and when, and what the cited sources
+// contained at the time, so downstream systems can attach verifiable
+// metadata to the text.
+type Manifest struct {
+	Format string `json:"format"`
+	Query  string `json:"query"`
+	// AnswerSHA256 is the SHA-256 of the answer exactly as delivered;
+	// ContentHash is its whitespace-insensitive hash (see contentHash).
+	AnswerSHA256   string             `json:"answer_sha256"`
+	ContentHash    string             `json:"content_hash,omitempty"`
+	Provider       string             `json:"provider"`
+	Model          string             `json:"model"`
+	RequestedModel string             `json:"requested_model,omitempty"`
+	ResponseID     string             `json:"response_id,omitempty"`
+	RequestID      string             `json:"request_id,omitempty"`
+	Parameters     manifestParameters `json:"parameters"`
+	RequestedAt    time.Time          `json:"requested_at"`
+	CompletedAt    time.Time          `json:"completed_at"`
+	// Cached is set when the answer came from the cache rather than a new
+	// upstream call.
+	Cached    bool              `json:"cached,omitempty"`
+	Sources   []ManifestSource  `json:"sources"`
+	Generator manifestGenerator `json:"generator"`
+}
+
+// manifestParameters are the request options that shape an answer.
+type manifestParameters struct {
+	Effort            string   `json:"effort,omitempty"`
+	Verbosity         string   `json:"verbosity,omitempty"`
+	WebSearch         bool     `json:"web_search"`
+	SearchTool        string   `json:"search_tool,omitempty"`
+	SearchContextSize string   `json:"search_context_size,omitempty"`
+	Domains           []string `json:"domains,omitempty"`
+	MaxOutputTokens   int      `json:"max_output_tokens,omitempty"`
+	Temperature       *float64 `json:"temperature,omitempty"`
+	TopP              *float64 `json:"top_p,omitempty"`
+	Store             bool     `json:"store"`
+}
+
+// ManifestSource is a cited page as fetched when the manifest was made.
+// SHA256 hashes the page body; it is empty when the page could not be
+// fetched, and Error says why.
+type ManifestSource struct {
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	Reputation string    `json:"reputation,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Size       int       `json:"size,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// manifestGenerator names the software that produced the answer.
+type manifestGenerator struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Revision string `json:"revision,omitempty"`
+	Go       string `json:"go"`
+}
+
+// newManifest builds the manifest of a successful result. The cited pages
+// are fetched (up to maxSnapshotSources, within snapshotTimeout) to hash
+// what they contain now.
+func newManifest(ctx context.Context, result *WebSearchResult, wa webSearchArgs, requestedAt time.Time) *Manifest {
+	answerSum := sha256.Sum256([]byte(result.Answer))
+	m := &Manifest{
+		Format:         manifestFormat,
+		Query:          result.Query,
+		AnswerSHA256:   hex.EncodeToString(answerSum[:]),
+		ContentHash:    contentHash(result.Answer),
+		Provider:       result.Provider,
+		Model:          result.Model,
+		RequestedModel: result.RequestedModel,
+		ResponseID:     result.ID,
+		RequestID:      result.RequestID,
+		Parameters: manifestParameters{
+			Effort:            cmp.Or(result.Effort, wa.effort),
+			Verbosity:         wa.verbosity,
+			WebSearch:         result.WebSearchUsed,
+			SearchContextSize: wa.searchContextSize,
+			Domains:           wa.domains,
+			MaxOutputTokens:   wa.maxOutputTokens,
+			Temperature:       wa.temperature,
+			TopP:              wa.topP,
+			Store:             !wa.noStore && !noStoreDefault,
+		},
+		RequestedAt: requestedAt.UTC(),
+		CompletedAt: time.Now().UTC(),
+		Cached:      result.Cached,
+		Sources:     hashSources(ctx, result.Citations),
+		Generator:   buildGenerator(),
+	}
+	if m.Parameters.WebSearch && (result.Provider == providerOpenAI || result.Provider == providerAzure) {
+		m.Parameters.SearchTool = webSearchTools.typeFor(result.Model)
+		if len(wa.domains) > 0 {
+			m.Parameters.SearchTool = toolWebSearch
+		}
+	}
+	return m
+}
+
+// hashSources fetches each distinct cited page and hashes its body.
+func hashSources(ctx context.Context, citations []Citation) []ManifestSource {
+	byURL := make(map[string]Citation, len(citations))
+	for _, c := range citations {
+		if _, ok := byURL[c.URL]; !ok {
+			byURL[c.URL] = c
+		}
+	}
+	urls := snapshotURLs(citationURLs(citations))
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	sources := make([]ManifestSource, len(urls))
+	var g errgroup.Group
+	g.SetLimit(snapshotConcurrency)
+	for i, u := range urls {
+		g.Go(func() error {
+			page := fetchPage(ctx, u)
+			c := byURL[u]
+			src := ManifestSource{URL: u, Title: c.Title, Reputation: c.Reputation, Status: page.Status, FetchedAt: page.FetchedAt.UTC(), Error: page.Error}
+			if page.Body != nil {
+				sum := sha256.Sum256(page.Body)
+				src.SHA256, src.Size = hex.EncodeToString(sum[:]), page.Size
+			}
+			sources[i] = src
+			return nil
+		})
+	}
+	g.Wait() //nolint:errcheck // fetch errors are recorded per source
+	return sources
+}
+
+// buildGenerator reports this binary's module version and VCS revision as
+// recorded by the Go toolchain.
+func buildGenerator() manifestGenerator {
+	g := manifestGenerator{Name: serverName, Version: "(devel)", Go: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return g
+	}
+	if info.Main.Version != "" {
+		g.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			g.Revision = s.Value
+		}
+	}
+	return g
+}
+
+// writeManifest saves m as indented JSON at path.
+func writeManifest(path string, m *Manifest) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
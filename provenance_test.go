@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewManifest(t *testing.T) {
+	t.Parallel()
+
+	const page = "<html><body>Go 1.22 release notes</body></html>"
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/notes" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(page)) //nolint:errcheck
+	})
+
+	result := &WebSearchResult{
+		Success: true, Answer: "Go 1.22 changed loops.", Query: "What changed in Go 1.22?",
+		Model: "gpt-5.4-mini", Effort: "low", ID: "resp_1", Provider: providerOpenAI, WebSearchUsed: true,
+		Citations: []Citation{
+			{URL: base + "/notes", Title: "Release notes", Reputation: reputationNeutral},
+			{URL: base + "/notes", Title: "Release notes"},
+			{URL: "http://127.0.0.1:1/gone"},
+		},
+	}
+	started := time.Now().Add(-time.Second)
+	m := newManifest(t.Context(), result, webSearchArgs{verbosity: "medium", domains: []string{"go.dev"}}, started)
+
+	answerSum := sha256.Sum256([]byte(result.Answer))
+	if m.Format != manifestFormat || m.AnswerSHA256 != hex.EncodeToString(answerSum[:]) || m.ContentHash != contentHash(result.Answer) {
+		t.Errorf("manifest header = %+v", m)
+	}
+	if m.Parameters.Effort != "low" || m.Parameters.Verbosity != "medium" || m.Parameters.SearchTool != toolWebSearch || !m.Parameters.Store {
+		t.Errorf("parameters = %+v", m.Parameters)
+	}
+	if !m.RequestedAt.Equal(started) || m.CompletedAt.Before(m.RequestedAt) {
+		t.Errorf("times = %v .. %v", m.RequestedAt, m.CompletedAt)
+	}
+	if m.Generator.Name != serverName || m.Generator.Go == "" || m.Generator.Version == "" {
+		t.Errorf("generator = %+v", m.Generator)
+	}
+
+	if len(m.Sources) != 2 {
+		t.Fatalf("sources = %+v, want the two distinct URLs", m.Sources)
+	}
+	pageSum := sha256.Sum256([]byte(page))
+	if s := m.Sources[0]; s.SHA256 != hex.EncodeToString(pageSum[:]) || s.Size != len(page) || s.Status != http.StatusOK || s.Title != "Release notes" {
+		t.Errorf("fetched source = %+v", s)
+	}
+	if s := m.Sources[1]; s.SHA256 != "" || s.Error == "" {
+		t.Errorf("unreachable source = %+v, want an error and no hash", s)
+	}
+}
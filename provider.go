@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const providerOpenAI = "openai"
+
+// provider is an upstream backend. Each provider builds its own request and
+// normalizes the reply into an apiResponse, so answer extraction, citations,
+// caching and retries work the same regardless of where the answer came from.
+type provider struct {
+	name string
+	// defaultURL is the endpoint used when the caller did not supply one.
+	defaultURL string
+	// keyEnv names the environment variable holding the provider's API key.
+	keyEnv string
+	// keyHeader, when set, carries the raw key in that header instead of
+	// "Authorization: Bearer".
+	keyHeader string
+	// streams reports whether send honours OnDelta incrementally. For other
+	// providers CallAPI delivers the whole answer as a single delta.
+	streams bool
+	// webSearch reports whether the provider can search the web. Requests
+	// to providers without it are answered from model knowledge.
+	webSearch bool
+	// hostedTools reports whether the provider runs hosted tools such as
+	// code_interpreter and file_search; they are dropped for other providers.
+	hostedTools bool
+	// model maps a requested model name onto the provider's naming scheme.
+	model func(string) string
+	// check, when set, rejects parameters the provider cannot honor and
+	// lists those it ignores, which CallAPI reports instead of dropping
+	// them silently. Continuations are refused by CallAPI for every
+	// provider that does not chain responses (see chainsResponses).
+	check func(p CallAPIParams) (ignored []string, err error)
+	// send performs a single round trip; CallAPI wraps it with retries.
+	send func(ctx context.Context, p CallAPIParams, onDelta func(string)) (*apiResponse, error)
+}
+
+// providers are the backends selectable with PROVIDER / -provider or the
+// provider tool argument.
+var providers = map[string]*provider{
+	providerOpenAI: {
+		name:        providerOpenAI,
+		defaultURL:  defaultBaseURL,
+		keyEnv:      "OPENAI_API_KEY",
+		streams:     true,
+		webSearch:   true,
+		hostedTools: true,
+		send:        sendOpenAI,
+	},
+	"openrouter": {
+		name:       "openrouter",
+		defaultURL: "https://openrouter.ai/api/v1/chat/completions",
+		keyEnv:     "OPENROUTER_API_KEY",
+		model:      openRouterModel,
+		webSearch:  true,
+		check:      checkOpenRouter,
+		send:       sendOpenRouter,
+	},
+	"xai": {
+		name:       "xai",
+		defaultURL: "https://api.x.ai/v1/chat/completions",
+		keyEnv:     "XAI_API_KEY",
+		model:      xaiModel,
+		webSearch:  true,
+		check:      checkXAI,
+		send:       sendXAI,
+	},
+	providerAnthropic: {
+		name:       providerAnthropic,
+		defaultURL: "https://api.anthropic.com/v1/messages",
+		keyEnv:     "ANTHROPIC_API_KEY",
+		keyHeader:  "x-api-key",
+		model:      anthropicModel,
+		webSearch:  true,
+		check:      checkAnthropic,
+		send:       sendAnthropic,
+	},
+	providerPerplexity: {
+		name:       providerPerplexity,
+		defaultURL: "https://api.perplexity.ai/chat/completions",
+		keyEnv:     "PERPLEXITY_API_KEY",
+		model:      perplexityModel,
+		webSearch:  true,
+		check:      checkPerplexity,
+		send:       sendPerplexity,
+	},
+	providerGemini: {
+		name:       providerGemini,
+		defaultURL: geminiModelsURL,
+		keyEnv:     "GEMINI_API_KEY",
+		keyHeader:  "x-goog-api-key",
+		model:      geminiModel,
+		webSearch:  true,
+		check:      checkGemini,
+		send:       sendGemini,
+	},
+	providerAzure: {
+		name:        providerAzure,
+		keyEnv:      "AZURE_OPENAI_API_KEY",
+		keyHeader:   "api-key",
+		model:       azureModel,
+		streams:     true,
+		webSearch:   true,
+		hostedTools: true,
+		send:        sendAzure,
+	},
+	"mistral": {
+		name:       "mistral",
+		defaultURL: "https://api.mistral.ai/v1/chat/completions",
+		keyEnv:     "MISTRAL_API_KEY",
+		model:      vendorModel("mistral-small-latest", "mistral-", "magistral-", "ministral-", "codestral-", "open-mistral-"),
+		check:      checkNoSearch,
+		send:       sendChat,
+	},
+	providerOllama: {
+		name:  providerOllama,
+		model: ollamaModel,
+		check: checkNoSearch,
+		send:  sendOllama,
+	},
+	"deepseek": {
+		name:       "deepseek",
+		defaultURL: "https://api.deepseek.com/chat/completions",
+		keyEnv:     "DEEPSEEK_API_KEY",
+		model:      vendorModel("deepseek-chat", "deepseek-"),
+		check:      checkNoSearch,
+		send:       sendChat,
+	},
+}
+
+// defaultProvider is used when a request does not name one; set from PROVIDER.
+var defaultProvider = providerOpenAI
+
+// providerKeys holds the API key of every provider found in the environment,
+// keyed by provider name; set at startup so per-request provider selection
+// does not read the environment.
+var providerKeys = map[string]string{}
+
+// lookupProvider returns the named provider; an empty name selects OpenAI.
+func lookupProvider(name string) (*provider, error) {
+	if name == "" {
+		name = providerOpenAI
+	}
+	pr, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (supported: %s)", name, strings.Join(providerNames(), ", "))
+	}
+	return pr, nil
+}
+
+// providerNames lists the registered providers in a stable order.
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// endpointFor picks the URL for a call to pr: the caller's base URL applies
+// to OpenAI (it may point at a gateway), other providers use their own.
+func (pr *provider) endpointFor(baseURL string) string {
+	if pr.name == providerOpenAI && baseURL != "" {
+		return baseURL
+	}
+	return pr.defaultURL
+}
+
+// keyFor returns the API key for pr, falling back to fallback (the key the
+// server or CLI was started with) when no provider-specific key is set.
+func (pr *provider) keyFor(fallback string) string {
+	if key := providerKeys[pr.name]; key != "" {
+		return key
+	}
+	if pr.name == providerOpenAI {
+		return fallback
+	}
+	return ""
+}
+
+// modelFor maps a requested model onto the provider's naming.
+func (pr *provider) modelFor(model string) string {
+	if pr.model == nil {
+		return model
+	}
+	return pr.model(model)
+}
+
+// openRouterVendors maps bare model name prefixes onto OpenRouter's vendor
+// namespaces.
+var openRouterVendors = []struct{ prefix, vendor string }{
+	{"claude-", "anthropic"},
+	{"gemini-", "google"},
+	{"grok-", "x-ai"},
+	{"sonar", "perplexity"},
+	{"deepseek-", "deepseek"},
+	{"mistral-", "mistralai"},
+	{"magistral-", "mistralai"},
+}
+
+// openRouterModel adds the vendor prefix of OpenRouter's vendor/model naming
+// to bare model names (openai/ unless the name is recognised), so the same
+// -model value works across providers.
+func openRouterModel(model string) string {
+	if model == "" || strings.Contains(model, "/") {
+		return model
+	}
+	for _, v := range openRouterVendors {
+		if strings.HasPrefix(model, v.prefix) {
+			return v.vendor + "/" + model
+		}
+	}
+	return "openai/" + model
+}
+
+// checkOpenRouter refuses a search restricted to domains, which the web
+// plugin cannot honor without changing the answer's meaning, and notes
+// that the location hint is ignored.
+func checkOpenRouter(p CallAPIParams) ([]string, error) {
+	if len(p.AllowedDomains) > 0 && p.UseWebSearch {
+		return nil, fmt.Errorf("provider openrouter: %w", ErrNoDomainFilter)
+	}
+	if p.UserLocation != nil && p.UseWebSearch {
+		return []string{"user_location"}, nil
+	}
+	return nil, nil
+}
+
+// sendOpenRouter calls OpenRouter's chat completions endpoint. Web search is
+// enabled with its "web" plugin, and the attribution headers OpenRouter
+// expects identify this server.
+func sendOpenRouter(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	req := newChatRequest(p)
+	if p.Effort != "" && p.Effort != "none" {
+		req.Reasoning = &reqReasoning{Effort: p.Effort}
+	}
+	if p.UseWebSearch {
+		req.Plugins = []chatPlugin{{ID: "web"}}
+		if p.SearchContextSize != "" {
+			req.WebSearchOptions = &chatWebSearchOptions{SearchContextSize: p.SearchContextSize}
+		}
+	}
+	headers := map[string]string{
+		"HTTP-Referer": serverWebsiteURL,
+		"X-Title":      serverTitle,
+	}
+	return doChatRequest(ctx, p, req, headers)
+}
+
+// xaiDefaultModel is used when the request carries an OpenAI model name.
+const xaiDefaultModel = "grok-4"
+
+// xaiModel keeps Grok model names and replaces OpenAI defaults, so switching
+// the provider alone is enough.
+var xaiModel = vendorModel(xaiDefaultModel, "grok-")
+
+// vendorModel returns a model mapper that keeps names with one of the
+// vendor's prefixes and substitutes fallback for anything else (typically
+// the OpenAI default the request was built with).
+func vendorModel(fallback string, prefixes ...string) func(string) string {
+	return func(model string) string {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(model, prefix) {
+				return model
+			}
+		}
+		return fallback
+	}
+}
+
+// checkNoSearch lists the search parameters a provider without web search
+// ignores.
+func checkNoSearch(p CallAPIParams) ([]string, error) {
+	var ignored []string
+	if len(p.AllowedDomains) > 0 || len(p.BlockedDomains) > 0 {
+		ignored = append(ignored, "domains")
+	}
+	if p.UserLocation != nil {
+		ignored = append(ignored, "user_location")
+	}
+	if p.SearchContextSize != "" {
+		ignored = append(ignored, "search_context_size")
+	}
+	return ignored, nil
+}
+
+// sendChat calls a plain OpenAI-compatible chat completions endpoint with no
+// search options.
+func sendChat(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	return doChatRequest(ctx, p, newChatRequest(p), nil)
+}
+
+// xaiMaxWebsites is the most sites a Live Search web source can allow or
+// exclude.
+const xaiMaxWebsites = 5
+
+// checkXAI refuses a domain restriction longer than Live Search accepts
+// and lists what it ignores: the location hint without a country, and the
+// search context size.
+func checkXAI(p CallAPIParams) ([]string, error) {
+	if !p.UseWebSearch {
+		return nil, nil
+	}
+	if len(p.AllowedDomains) > xaiMaxWebsites {
+		return nil, fmt.Errorf("provider xai allows at most %d domains: %w", xaiMaxWebsites, ErrNoDomainFilter)
+	}
+	var ignored []string
+	if p.UserLocation != nil && p.UserLocation.Country == "" {
+		ignored = append(ignored, "user_location")
+	}
+	if p.SearchContextSize != "" {
+		ignored = append(ignored, "search_context_size")
+	}
+	return ignored, nil
+}
+
+// sendXAI calls xAI's chat completions endpoint. Web search maps onto Live
+// Search over web and X sources, with citations returned as URLs. Allowed
+// domains confine it to the web source; excluded ones, up to the first
+// xaiMaxWebsites, are left out of it.
+func sendXAI(ctx context.Context, p CallAPIParams, _ func(string)) (*apiResponse, error) {
+	req := newChatRequest(p)
+	if p.UseWebSearch {
+		web := xaiSource{Type: "web"}
+		if p.UserLocation != nil {
+			web.Country = p.UserLocation.Country
+		}
+		sources := []xaiSource{web, {Type: "x"}}
+		switch {
+		case len(p.AllowedDomains) > 0:
+			sources[0].AllowedWebsites = p.AllowedDomains
+			sources = sources[:1]
+		case len(p.BlockedDomains) > 0:
+			sources[0].ExcludedWebsites = p.BlockedDomains[:min(len(p.BlockedDomains), xaiMaxWebsites)]
+		}
+		req.SearchParameters = &xaiSearchParameters{
+			Mode:            "auto",
+			ReturnCitations: true,
+			Sources:         sources,
+		}
+	}
+	return doChatRequest(ctx, p, req, nil)
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupProvider(t *testing.T) {
+	t.Parallel()
+
+	pr, err := lookupProvider("")
+	if err != nil || pr.name != providerOpenAI {
+		t.Fatalf("lookupProvider(\"\") = %v, %v; want openai", pr, err)
+	}
+	if pr, err := lookupProvider("OpenRouter"); err != nil || pr.name != "openrouter" {
+		t.Errorf("lookupProvider(OpenRouter) = %v, %v", pr, err)
+	}
+	if _, err := lookupProvider("nope"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestOpenRouterModel(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"gpt-5.4-mini":              "openai/gpt-5.4-mini",
+		"anthropic/claude-sonnet-4": "anthropic/claude-sonnet-4",
+		"claude-sonnet-4.5":         "anthropic/claude-sonnet-4.5",
+		"gemini-2.5-pro":            "google/gemini-2.5-pro",
+		"":                          "",
+	}
+	for in, want := range cases {
+		if got := openRouterModel(in); got != want {
+			t.Errorf("openRouterModel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCallAPI_OpenRouter(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer or-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.Header.Get("HTTP-Referer") != serverWebsiteURL || r.Header.Get("X-Title") != serverTitle {
+			t.Errorf("missing attribution headers: %v", r.Header)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.Model != "openai/gpt-5.4-mini" {
+			t.Errorf("model = %q, want prefixed name", req.Model)
+		}
+		if len(req.Plugins) != 1 || req.Plugins[0].ID != "web" {
+			t.Errorf("plugins = %+v, want web plugin", req.Plugins)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Content != "who?" {
+			t.Errorf("messages = %+v", req.Messages)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "gen-1",
+			"model": "openai/gpt-5.4-mini",
+			"choices": []map[string]any{{
+				"finish_reason": "stop",
+				"message": map[string]any{
+					"content": "Ada Lovelace.",
+					"annotations": []map[string]any{{
+						"type": "url_citation",
+						"url_citation": map[string]any{
+							"url": "https://example.com/ada", "title": "Ada", "start_index": 0, "end_index": 12,
+						},
+					}},
+				},
+			}},
+			"usage": map[string]any{"prompt_tokens": 3, "completion_tokens": 4, "total_tokens": 7},
+		})
+	})
+
+	var deltas []string
+	ar, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:     "openrouter",
+		APIKey:       "or-key",
+		BaseURL:      base,
+		Query:        "who?",
+		Model:        "gpt-5.4-mini",
+		Timeout:      time.Second,
+		UseWebSearch: true,
+		OnDelta:      func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := ExtractAnswer(ar)
+	if got.Text != "Ada Lovelace." {
+		t.Errorf("answer = %q", got.Text)
+	}
+	if len(got.Citations) != 1 || got.Citations[0].URL != "https://example.com/ada" {
+		t.Errorf("citations = %+v", got.Citations)
+	}
+	if ar.Usage == nil || ar.Usage.TotalTokens != 7 {
+		t.Errorf("usage = %+v", ar.Usage)
+	}
+	if len(deltas) != 1 || deltas[0] != "Ada Lovelace." {
+		t.Errorf("non-streaming provider should deliver one delta, got %q", deltas)
+	}
+}
+
+func TestCallAPI_ContinuationNeedsStoredResponses(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range providerNames() {
+		if chainsResponses(name) {
+			continue
+		}
+		_, err := CallAPI(context.Background(), CallAPIParams{
+			Provider: name, APIKey: "k", BaseURL: "http://127.0.0.1:1", Query: "and then?", PreviousResponseID: "resp_1",
+		})
+		if !errors.Is(err, ErrNoContinuation) {
+			t.Errorf("%s: err = %v, want ErrNoContinuation", name, err)
+		}
+	}
+}
+
+func TestCallAPI_OpenRouterUnsupported(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":      "gen-2",
+			"model":   "openai/gpt-5.4-mini",
+			"choices": []map[string]any{{"finish_reason": "stop", "message": map[string]any{"content": "Sunny."}}},
+		})
+	})
+	params := CallAPIParams{
+		Provider:     "openrouter",
+		APIKey:       "or-key",
+		BaseURL:      base,
+		Query:        "weather?",
+		Model:        "gpt-5.4-mini",
+		Timeout:      time.Second,
+		UseWebSearch: true,
+	}
+
+	p := params
+	p.PreviousResponseID = "resp_1"
+	if _, err := CallAPI(context.Background(), p); !errors.Is(err, ErrNoContinuation) {
+		t.Errorf("previous_response_id: err = %v, want ErrNoContinuation", err)
+	}
+	p = params
+	p.AllowedDomains = []string{"example.com"}
+	if _, err := CallAPI(context.Background(), p); !errors.Is(err, ErrNoDomainFilter) {
+		t.Errorf("domains: err = %v, want ErrNoDomainFilter", err)
+	}
+	p = params
+	p.UserLocation = &UserLocation{Type: "approximate", City: "Warsaw"}
+	ar, err := CallAPI(context.Background(), p)
+	if err != nil {
+		t.Fatalf("user_location: %v", err)
+	}
+	if len(ar.Ignored) != 1 || ar.Ignored[0] != "user_location" {
+		t.Errorf("ignored = %q, want [user_location]", ar.Ignored)
+	}
+}
+
+func TestCallAPI_OpenRouterErrorEnvelope(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		status     int
+		body       map[string]any
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			name:       "http error",
+			status:     http.StatusPaymentRequired,
+			body:       map[string]any{"error": map[string]any{"code": 402, "message": "Insufficient credits"}},
+			wantStatus: http.StatusPaymentRequired,
+			wantMsg:    "message=Insufficient credits",
+		},
+		{
+			name:   "error with 200",
+			status: http.StatusOK,
+			body: map[string]any{"error": map[string]any{
+				"code": 502, "message": "upstream overloaded", "metadata": map[string]any{"provider_name": "Anthropic"},
+			}},
+			wantStatus: http.StatusBadGateway,
+			wantMsg:    "message=Anthropic: upstream overloaded",
+		},
+		{
+			name:   "choice error",
+			status: http.StatusOK,
+			body: map[string]any{"id": "gen-1", "choices": []map[string]any{{
+				"finish_reason": "error", "message": map[string]any{"content": ""},
+				"error": map[string]any{"code": "server_error", "message": "stream interrupted"},
+			}}},
+			wantStatus: http.StatusBadGateway,
+			wantMsg:    "message=stream interrupted",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, tc.status, tc.body)
+			})
+			_, err := CallAPI(context.Background(), CallAPIParams{
+				Provider: "openrouter",
+				APIKey:   "or-key",
+				BaseURL:  base,
+				Query:    "who?",
+				Model:    "gpt-5.4-mini",
+				Timeout:  time.Second,
+			})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.wantStatus || !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Errorf("err = %v, want status %d with %q", err, tc.wantStatus, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestChatResponse_LengthIsIncomplete(t *testing.T) {
+	t.Parallel()
+
+	cr := chatResponse{Choices: []chatChoice{{FinishReason: "length"}}}
+	ar := cr.toAPIResponse()
+	if ar.Status != "incomplete" || ar.IncompleteDetails == nil || ar.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("toAPIResponse() = %+v", ar)
+	}
+}
+
+func TestCallAPI_XAILiveSearch(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.Model != xaiDefaultModel {
+			t.Errorf("model = %q, want %q", req.Model, xaiDefaultModel)
+		}
+		if req.Reasoning != nil || req.Plugins != nil {
+			t.Errorf("unexpected OpenRouter fields: %+v", req)
+		}
+		sp := req.SearchParameters
+		if sp == nil || sp.Mode != "auto" || !sp.ReturnCitations || len(sp.Sources) != 2 {
+			t.Errorf("search_parameters = %+v", sp)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":        "xai-1",
+			"model":     "grok-4",
+			"choices":   []map[string]any{{"message": map[string]any{"content": "Trending now."}}},
+			"citations": []string{"https://x.com/post/1"},
+		})
+	})
+
+	ar, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:     "xai",
+		APIKey:       "xk",
+		BaseURL:      base,
+		Query:        "what is trending?",
+		Model:        defaultModel,
+		Effort:       "low",
+		Timeout:      time.Second,
+		UseWebSearch: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := ExtractAnswer(ar)
+	if got.Text != "Trending now." {
+		t.Errorf("answer = %q", got.Text)
+	}
+	if len(got.Citations) != 1 || got.Citations[0].URL != "https://x.com/post/1" {
+		t.Fatalf("citations = %+v", got.Citations)
+	}
+	if span := got.Citations[0].Span; span.StartRune != 13 || span.EndRune != 13 {
+		t.Errorf("offset-less citation should sit at the end, got %+v", span)
+	}
+}
+
+func TestCallAPI_XAISearchFilters(t *testing.T) {
+	t.Parallel()
+
+	var got *xaiSearchParameters
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got = req.SearchParameters
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "xai-2", "model": "grok-4",
+			"choices": []map[string]any{{"message": map[string]any{"content": "Filtered."}}},
+		})
+	})
+	params := CallAPIParams{Provider: "xai", APIKey: "xk", BaseURL: base, Query: "q", Timeout: time.Second, UseWebSearch: true}
+
+	p := params
+	p.AllowedDomains = []string{"arxiv.org"}
+	p.UserLocation = &UserLocation{Type: "approximate", Country: "PL"}
+	ar, err := CallAPI(context.Background(), p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []xaiSource{{Type: "web", AllowedWebsites: []string{"arxiv.org"}, Country: "PL"}}
+	if got == nil || len(got.Sources) != 1 || !slices.Equal(got.Sources[0].AllowedWebsites, want[0].AllowedWebsites) || got.Sources[0].Country != "PL" {
+		t.Errorf("sources = %+v, want %+v", got, want)
+	}
+	if len(ar.Ignored) != 0 {
+		t.Errorf("ignored = %q, want none", ar.Ignored)
+	}
+
+	p = params
+	p.BlockedDomains = []string{"reddit.com"}
+	p.SearchContextSize = "high"
+	p.UserLocation = &UserLocation{Type: "approximate", City: "Warsaw"}
+	if ar, err = CallAPI(context.Background(), p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Sources) != 2 || !slices.Equal(got.Sources[0].ExcludedWebsites, []string{"reddit.com"}) {
+		t.Errorf("sources = %+v, want web excluding reddit.com and x", got.Sources)
+	}
+	if !slices.Equal(ar.Ignored, []string{"user_location", "search_context_size"}) {
+		t.Errorf("ignored = %q", ar.Ignored)
+	}
+
+	p = params
+	p.AllowedDomains = []string{"a.com", "b.com", "c.com", "d.com", "e.com", "f.com"}
+	if _, err := CallAPI(context.Background(), p); !errors.Is(err, ErrNoDomainFilter) {
+		t.Errorf("six domains: err = %v, want ErrNoDomainFilter", err)
+	}
+}
+
+func TestCallAPI_Perplexity(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.Model != "sonar" || req.DisableSearch {
+			t.Errorf("model = %q, disable_search = %t", req.Model, req.DisableSearch)
+		}
+		if strings.Join(req.SearchDomainFilter, ",") != "who.int,-quora.com" {
+			t.Errorf("search_domain_filter = %v", req.SearchDomainFilter)
+		}
+		if o := req.WebSearchOptions; o == nil || o.UserLocation == nil || o.UserLocation.Country != "CH" {
+			t.Errorf("web_search_options = %+v", o)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":    "pplx-1",
+			"model": "sonar",
+			"choices": []map[string]any{{"message": map[string]any{
+				"content": "<think>check WHO</think>\nMeasles is rising[2]. Vaccines work[1][2].",
+			}}},
+			"citations":      []string{"https://who.int/a", "https://who.int/b", "https://who.int/c"},
+			"search_results": []map[string]any{{"title": "WHO B", "url": "https://who.int/b"}},
+		})
+	})
+
+	ar, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:       providerPerplexity,
+		APIKey:         "pk",
+		BaseURL:        base,
+		Query:          "measles?",
+		Model:          defaultModel,
+		AllowedDomains: []string{"who.int"},
+		BlockedDomains: []string{"quora.com"},
+		UserLocation:   &UserLocation{Type: "approximate", Country: "CH"},
+		Timeout:        time.Second,
+		UseWebSearch:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := ExtractAnswer(ar)
+	if got.Text != "Measles is rising[2]. Vaccines work[1][2]." || len(got.Reasoning) != 1 {
+		t.Errorf("answer = %q, reasoning = %q", got.Text, got.Reasoning)
+	}
+	if len(got.Citations) != 4 {
+		t.Fatalf("citations = %+v", got.Citations)
+	}
+	first := got.Citations[0]
+	if first.URL != "https://who.int/b" || first.Title != "WHO B" || got.Text[first.Span.StartByte:first.Span.EndByte] != "[2]" {
+		t.Errorf("first citation = %+v", first)
+	}
+	if last := got.Citations[3]; last.URL != "https://who.int/c" || last.Span.StartRune != len(got.Text) {
+		t.Errorf("unreferenced citation should sit at the end, got %+v", last)
+	}
+}
+
+func TestCheckPerplexity(t *testing.T) {
+	t.Parallel()
+
+	city := CallAPIParams{UseWebSearch: true, UserLocation: &UserLocation{Type: "approximate", City: "Zurich"}}
+	if ignored, err := checkPerplexity(city); err != nil || !slices.Equal(ignored, []string{"user_location"}) {
+		t.Errorf("city only = %q, %v", ignored, err)
+	}
+	city.UserLocation.Country = "CH"
+	if ignored, err := checkPerplexity(city); err != nil || ignored != nil {
+		t.Errorf("with country = %q, %v", ignored, err)
+	}
+}
+
+func TestVendorModel(t *testing.T) {
+	t.Parallel()
+
+	deepseek := providers["deepseek"]
+	if got := deepseek.modelFor(defaultModel); got != "deepseek-chat" {
+		t.Errorf("deepseek default = %q", got)
+	}
+	if got := deepseek.modelFor("deepseek-reasoner"); got != "deepseek-reasoner" {
+		t.Errorf("deepseek explicit = %q", got)
+	}
+	if got := providers["mistral"].modelFor("magistral-medium-latest"); got != "magistral-medium-latest" {
+		t.Errorf("mistral explicit = %q", got)
+	}
+}
+
+func TestRunWebSearch_ProviderWithoutSearch(t *testing.T) {
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		for _, k := range []string{"plugins", "search_parameters", "web_search_options", "tools"} {
+			if _, ok := raw[k]; ok {
+				t.Errorf("unexpected search field %q in %v", k, raw)
+			}
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":      "ds-1",
+			"choices": []map[string]any{{"message": map[string]any{"content": "From memory."}}},
+		})
+	})
+
+	orig := providers["deepseek"].defaultURL
+	providers["deepseek"].defaultURL = base
+	t.Cleanup(func() { providers["deepseek"].defaultURL = orig })
+	providerKeys = map[string]string{"deepseek": "dk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	result, err := runWebSearch(context.Background(), "", defaultBaseURL, webSearchArgs{
+		query:             "q",
+		model:             defaultModel,
+		effort:            "low",
+		provider:          "deepseek",
+		useWebSearch:      true,
+		domains:           []string{"arxiv.org"},
+		searchContextSize: "high",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success || result.Answer != "From memory." {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.WebSearchUsed {
+		t.Error("WebSearchUsed should be false for a provider without search")
+	}
+	if !slices.Equal(result.Ignored, []string{"domains", "search_context_size"}) {
+		t.Errorf("ignored = %q, want the search parameters", result.Ignored)
+	}
+}
+
+func TestAzureResponsesURL(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		base, deployment, version, want string
+	}{
+		{"https://res.openai.azure.com", "gpt-5-mini", "", "https://res.openai.azure.com/openai/deployments/gpt-5-mini/responses?api-version=" + defaultAzureAPIVersion},
+		{"https://res.openai.azure.com/", "prod", "2025-05-01-preview", "https://res.openai.azure.com/openai/deployments/prod/responses?api-version=2025-05-01-preview"},
+		{"https://res.openai.azure.com/openai/responses?api-version=preview", "prod", "", "https://res.openai.azure.com/openai/responses?api-version=preview"},
+	}
+	for _, tc := range cases {
+		got, err := azureResponsesURL(tc.base, tc.deployment, tc.version)
+		if err != nil || got != tc.want {
+			t.Errorf("azureResponsesURL(%q, %q) = %q, %v; want %q", tc.base, tc.deployment, got, err, tc.want)
+		}
+	}
+	if _, err := azureResponsesURL("", "prod", ""); err == nil {
+		t.Error("expected error without endpoint")
+	}
+}
+
+func TestCallAPI_Azure(t *testing.T) {
+	azure = azureSettings{APIVersion: "2025-04-01-preview", Deployment: "answers-prod"}
+	t.Cleanup(func() { azure = azureSettings{} })
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "az-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("auth headers = %v, want api-key only", r.Header)
+		}
+		if r.URL.Path != "/openai/deployments/answers-prod/responses" || r.URL.Query().Get("api-version") != "2025-04-01-preview" {
+			t.Errorf("url = %s", r.URL)
+		}
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if body.Model != "answers-prod" {
+			t.Errorf("model = %q, want deployment name", body.Model)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp"})
+	})
+	azure.Endpoint = base
+
+	if _, err := CallAPI(context.Background(), CallAPIParams{
+		Provider: providerAzure,
+		APIKey:   "az-key",
+		Query:    "q",
+		Model:    "gpt-5.4-mini",
+		Timeout:  time.Second,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadEnvConfig_AzureOpenAI(t *testing.T) {
+	t.Setenv("PROVIDER", "")
+	t.Setenv("OFFLINE", "")
+	t.Setenv("AZURE_OPENAI", "true")
+	t.Setenv("AZURE_OPENAI_API_KEY", "az-key")
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT", "prod")
+
+	if _, err := loadEnvConfig(); err == nil {
+		t.Error("expected error without AZURE_OPENAI_ENDPOINT")
+	}
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://res.openai.azure.com")
+	cfg, err := loadEnvConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Provider != providerAzure || cfg.ProviderKeys[providerAzure] != "az-key" || cfg.Azure.Deployment != "prod" {
+		t.Errorf("cfg = provider %q, keys %v, azure %+v", cfg.Provider, cfg.ProviderKeys, cfg.Azure)
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PurgeReport counts the records a purge removed or, in a dry run, would
+// remove. Outside a running server only on-disk cache entries (CACHE_DIR)
+// are counted.
+type PurgeReport struct {
+	Before            time.Time `json:"before"`
+	DryRun            bool      `json:"dry_run"`
+	HistoryEntries    int64     `json:"history_entries"`
+	Snapshots         int64     `json:"snapshots"`
+	ComplianceRecords int64     `json:"compliance_records"`
+	Runs              int       `json:"runs"`
+	SessionTurns      int       `json:"session_turns"`
+	LastResponse      bool      `json:"last_response"`
+	CacheEntries      int       `json:"cache_entries"`
+	Error             string    `json:"error,omitempty"`
+}
+
+// parsePurgeCutoff accepts a date (2024-01-01, midnight UTC) or an RFC 3339
+// timestamp.
+func parsePurgeCutoff(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cutoff %q: use YYYY-MM-DD or an RFC 3339 timestamp", s)
+	}
+	return t.UTC(), nil
+}
+
+// purge deletes entries recorded before the cutoff together with their
+// snapshots and embeddings, then vacuums so the deleted text does not linger
+// in free pages.
+func (h *historyStore) purge(ctx context.Context, before time.Time, dryRun bool) (entries, snapshots int64, err error) {
+	if h == nil {
+		return 0, 0, nil
+	}
+	cutoff := before.UTC().Format(time.RFC3339)
+	if dryRun {
+		return h.countPurgeable(ctx, cutoff)
+	}
+	err = h.write(func() error {
+		var err error
+		if entries, snapshots, err = h.countPurgeable(ctx, cutoff); err != nil || entries == 0 {
+			return err
+		}
+		if _, err := h.db.ExecContext(ctx, `DELETE FROM entries WHERE created_at < ?`, cutoff); err != nil {
+			return fmt.Errorf("purge history: %w", err)
+		}
+		if _, err := h.db.ExecContext(ctx, `VACUUM`); err != nil {
+			return fmt.Errorf("vacuum history: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return entries, snapshots, nil
+}
+
+// countPurgeable returns how many entries, and snapshots of them, were
+// recorded before cutoff.
+func (h *historyStore) countPurgeable(ctx context.Context, cutoff string) (entries, snapshots int64, err error) {
+	if err := h.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM entries WHERE created_at < ?`, cutoff).Scan(&entries); err != nil {
+		return 0, 0, fmt.Errorf("purge history: %w", err)
+	}
+	var hasSnapshots bool
+	if err := h.db.QueryRowContext(ctx, `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'snapshots'`).Scan(&hasSnapshots); err != nil {
+		return 0, 0, fmt.Errorf("purge history: %w", err)
+	}
+	if hasSnapshots {
+		err := h.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM snapshots WHERE entry_id IN (SELECT id FROM entries WHERE created_at < ?)`, cutoff).Scan(&snapshots)
+		if err != nil {
+			return 0, 0, fmt.Errorf("purge history: %w", err)
+		}
+	}
+	return entries, snapshots, nil
+}
+
+// purge removes records older than the cutoff from the compliance log. The
+// last removed record stays behind as a "purge" anchor stripped of its
+// content, so the remaining records still verify and new ones keep
+// chaining onto them.
+func (l *complianceLog) purge(before time.Time, dryRun bool) (int64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var kept []complianceRecord
+	var anchor *complianceRecord
+	var removed, earlier int64
+	err := scanComplianceLog(l.path, func(r complianceRecord) error {
+		if !r.Time.Before(before) {
+			kept = append(kept, r)
+			return nil
+		}
+		if r.Kind == "purge" {
+			earlier += r.Purged
+		} else {
+			removed++
+		}
+		anchor = &complianceRecord{Seq: r.Seq, Time: r.Time, Kind: "purge", PrevHash: r.PrevHash, Hash: r.Hash}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if dryRun || removed == 0 {
+		return removed, nil
+	}
+	anchor.Purged = earlier + removed
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".compliance-*")
+	if err != nil {
+		return 0, fmt.Errorf("purge compliance log: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // gone after the rename
+	enc := json.NewEncoder(tmp)
+	for _, r := range append([]complianceRecord{*anchor}, kept...) {
+		if err := enc.Encode(r); err != nil {
+			tmp.Close() //nolint:errcheck
+			return 0, fmt.Errorf("purge compliance log: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() //nolint:errcheck
+		return 0, fmt.Errorf("purge compliance log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("purge compliance log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return 0, fmt.Errorf("purge compliance log: %w", err)
+	}
+	// Appends must go to the new file.
+	l.f.Close() //nolint:errcheck
+	if l.f, err = os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return removed, fmt.Errorf("reopen compliance log: %w", err)
+	}
+	return removed, nil
+}
+
+// purgeRuns deletes run directories started before the cutoff.
+func purgeRuns(root string, before time.Time, dryRun bool) (int, error) {
+	runs, err := listRuns(root)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range runs {
+		if !m.StartedAt.Before(before) {
+			continue
+		}
+		n++
+		if dryRun {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, m.ID)); err != nil {
+			return n - 1, fmt.Errorf("purge runs: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// purgeSessions drops session turns asked before the cutoff and deletes the
+// sessions left without turns.
+func purgeSessions(before time.Time, dryRun bool) (int, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return 0, nil
+	}
+	// Only an existing sessions directory is purged; none is created.
+	if _, err := os.Stat(dir); err != nil {
+		return 0, nil
+	}
+	n := 0
+	err = withSessionsLock(func() error {
+		sessions, err := listSessions()
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			old := 0
+			for _, t := range s.Turns {
+				if t.Time.Before(before) {
+					old++
+				}
+			}
+			if old == 0 || dryRun {
+				n += old
+				continue
+			}
+			if old == len(s.Turns) {
+				err = deleteSession(s.Name)
+			} else {
+				s.Turns = slices.DeleteFunc(s.Turns, func(t sessionTurn) bool { return t.Time.Before(before) })
+				s.Created = s.Turns[0].Time
+				err = s.save()
+			}
+			if err != nil {
+				return fmt.Errorf("purge sessions: %w", err)
+			}
+			n += old
+		}
+		return nil
+	})
+	return n, err
+}
+
+// purge drops cached answers stored before the cutoff, in memory and in the
+// cache directory.
+func (c *responseCache) purge(before time.Time, dryRun bool) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := make(map[string]bool)
+	for key, e := range c.entries {
+		if e.storedAt.Before(before) {
+			old[key] = true
+		}
+	}
+	if c.dir != "" {
+		names, _ := filepath.Glob(filepath.Join(c.dir, "*.json")) //nolint:errcheck // only a bad pattern fails
+		for _, name := range names {
+			key := strings.TrimSuffix(filepath.Base(name), ".json")
+			if e, ok := c.load(key); ok && e.storedAt.Before(before) {
+				old[key] = true
+			}
+		}
+	}
+	if !dryRun {
+		for key := range old {
+			c.drop(key)
+			c.remove(key)
+		}
+	}
+	return len(old)
+}
+
+// purge drops cached answers stored before the cutoff.
+func (c *semanticCache) purge(before time.Time, dryRun bool) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.entries[:0]
+	n := 0
+	for _, e := range c.entries {
+		if e.storedAt.Before(before) {
+			n++
+			if !dryRun {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	c.entries = kept
+	return n
+}
+
+// purgeAll purges every store configured in this process. It stops at the
+// first failure, reporting what was purged until then.
+func purgeAll(ctx context.Context, h *historyStore, cl *complianceLog, runsRoot string, before time.Time, dryRun bool) (PurgeReport, error) {
+	rep := PurgeReport{Before: before, DryRun: dryRun}
+	var err error
+	if rep.HistoryEntries, rep.Snapshots, err = h.purge(ctx, before, dryRun); err != nil {
+		return rep, err
+	}
+	if rep.ComplianceRecords, err = cl.purge(before, dryRun); err != nil {
+		return rep, err
+	}
+	if runsRoot != "" {
+		if rep.Runs, err = purgeRuns(runsRoot, before, dryRun); err != nil {
+			return rep, err
+		}
+	}
+	if rep.SessionTurns, err = purgeSessions(before, dryRun); err != nil {
+		return rep, err
+	}
+	if rep.LastResponse, err = purgeLastResponse(before, dryRun); err != nil {
+		return rep, err
+	}
+	rep.CacheEntries = answerCache.purge(before, dryRun) + querySemanticCache.purge(before, dryRun)
+	return rep, nil
+}
+
+// runPurge implements "answer purge": delete history, compliance, session
+// and run records older than a cutoff.
+func runPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	before := fs.String("before", "", "delete records older than this date (YYYY-MM-DD or RFC 3339); required")
+	dryRun := fs.Bool("dry-run", false, "only report what would be deleted")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer purge -before YYYY-MM-DD [-dry-run] [-json]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	if *before == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cutoff, err := parsePurgeCutoff(*before)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	if activeSealer, err = storeSealerFromEnv(); err != nil {
+		fail(2, err.Error())
+	}
+	// Only stores that already exist are purged; none is created.
+	var h *historyStore
+	if path, err := historyPath(); err == nil {
+		if _, statErr := os.Stat(path); statErr == nil {
+			if h, err = openHistory(path); err != nil {
+				fail(2, err.Error())
+			}
+			defer h.Close() //nolint:errcheck
+		}
+	}
+	var cl *complianceLog
+	if path := os.Getenv("COMPLIANCE_LOG"); path != "" {
+		if _, statErr := os.Stat(path); statErr == nil {
+			if cl, err = openComplianceLog(path); err != nil {
+				fail(2, err.Error())
+			}
+		}
+	}
+	if dir := os.Getenv("CACHE_DIR"); dir != "" {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			// Only the directory matters here; any TTL enables the cache.
+			answerCache = newResponseCache(time.Hour, 0)
+			answerCache.dir, answerCache.sealer = dir, activeSealer
+		}
+	}
+	root, err := runsDir()
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	rep, err := purgeAll(context.Background(), h, cl, root, cutoff, *dryRun)
+	if err != nil {
+		rep.Error = err.Error()
+	}
+	if *asJSON {
+		raw, _ := json.MarshalIndent(rep, "", "  ") //nolint:errcheck
+		fmt.Println(string(raw))
+	} else {
+		printPurgeReport(rep)
+	}
+	if err != nil {
+		fail(3, err.Error())
+	}
+}
+
+func printPurgeReport(rep PurgeReport) {
+	verb := "Deleted"
+	if rep.DryRun {
+		verb = "Would delete"
+	}
+	fmt.Printf("%s records before %s:\n", verb, rep.Before.Format(time.RFC3339))
+	fmt.Printf("  history entries     %d (with %d archived pages)\n", rep.HistoryEntries, rep.Snapshots)
+	fmt.Printf("  compliance records  %d\n", rep.ComplianceRecords)
+	fmt.Printf("  runs                %d\n", rep.Runs)
+	fmt.Printf("  session turns       %d\n", rep.SessionTurns)
+	if rep.LastResponse {
+		fmt.Println("  the last answer recorded for -continue")
+	}
+	fmt.Printf("  cached answers      %d\n", rep.CacheEntries)
+}
+
+// newPurgeTool builds the purge admin tool definition; it is only exposed
+// with -admin-tools.
+func newPurgeTool() mcp.Tool {
+	return mcp.NewTool("purge",
+		mcp.WithDescription("Delete history, cache, compliance log, session and run records older than a cutoff date. "+
+			"Use dry_run to see what would be removed first"),
+		mcp.WithString("before",
+			mcp.Required(),
+			mcp.Description("Cutoff: records older than this are deleted (YYYY-MM-DD or RFC 3339 timestamp)"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.DefaultBool(false),
+			mcp.Description("Only report what would be deleted (default: false)"),
+		),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithSchemaAdditionalProperties(false),
+		mcp.WithOutputSchema[PurgeReport](),
+	)
+}
+
+// purgeHandler returns the handler for the purge tool.
+func purgeHandler() func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		before, err := request.RequireString("before")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		cutoff, err := parsePurgeCutoff(before)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dryRun := request.GetBool("dry_run", false)
+		root, _ := runsDir() //nolint:errcheck // runs are skipped without a data dir
+		rep, err := purgeAll(ctx, answerHistory, activeCompliance, root, cutoff, dryRun)
+		if err != nil {
+			rep.Error = err.Error()
+		}
+		logToClient(ctx, mcp.LoggingLevelNotice, "purge", fmt.Sprintf("Purge before %s (dry_run=%v): %d history entries, %d compliance records, %d runs, %d session turns, %d cache entries",
+			cutoff.Format(time.RFC3339), dryRun, rep.HistoryEntries, rep.ComplianceRecords, rep.Runs, rep.SessionTurns, rep.CacheEntries))
+		return mcp.NewToolResultStructuredOnly(rep), nil
+	}
+}
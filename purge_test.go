@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory_Purge(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHistory(t)
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	for _, q := range []string{"old one", "old two"} {
+		if _, err := h.record(ctx, HistoryEntry{Query: q, Answer: "a"}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	now = time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	if _, err := h.record(ctx, HistoryEntry{Query: "new", Answer: "a"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if n, _, err := h.purge(ctx, cutoff, true); err != nil || n != 2 {
+		t.Fatalf("dry run = %d, %v; want 2", n, err)
+	}
+	if entries, _ := h.list(ctx, "", 10); len(entries) != 3 { //nolint:errcheck
+		t.Fatalf("dry run deleted entries: %+v", entries)
+	}
+	if n, _, err := h.purge(ctx, cutoff, false); err != nil || n != 2 {
+		t.Fatalf("purge = %d, %v; want 2", n, err)
+	}
+	entries, err := h.list(ctx, "", 10)
+	if err != nil || len(entries) != 1 || entries[0].Query != "new" {
+		t.Errorf("entries after purge = %+v, %v", entries, err)
+	}
+	if found, _ := h.search(ctx, "old", "", 10); len(found) != 0 { //nolint:errcheck
+		t.Errorf("purged entries still searchable: %+v", found)
+	}
+}
+
+func TestComplianceLog_Purge(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "compliance.jsonl")
+	l, err := openComplianceLog(path)
+	if err != nil {
+		t.Fatalf("openComplianceLog: %v", err)
+	}
+	t.Cleanup(func() { l.f.Close() }) //nolint:errcheck
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	logCall := func() {
+		id, err := l.logQuery(CallAPIParams{Provider: providerOpenAI, Query: "q"})
+		if err != nil {
+			t.Fatalf("logQuery: %v", err)
+		}
+		l.logAnswer(id, CallAPIParams{Provider: providerOpenAI}, &apiResponse{}, nil)
+		now = now.Add(24 * time.Hour)
+	}
+	logCall() // 2024-06-01
+	logCall() // 2024-06-02
+	logCall() // 2024-06-03
+
+	if n, err := l.purge(time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), true); err != nil || n != 2 {
+		t.Fatalf("dry run = %d, %v; want 2", n, err)
+	}
+	if n, err := l.purge(time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), false); err != nil || n != 2 {
+		t.Fatalf("purge = %d, %v; want 2", n, err)
+	}
+	logCall() // appends after the purge still chain
+	if n, err := verifyComplianceLog(path); err != nil || n != 8 {
+		t.Fatalf("verify after purge = %d, %v; want intact through 8", n, err)
+	}
+
+	// A second purge folds the earlier anchor into the new one.
+	if n, err := l.purge(time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), false); err != nil || n != 2 {
+		t.Fatalf("second purge = %d, %v; want 2", n, err)
+	}
+	first, err := lastComplianceRecordMatching(path, "purge")
+	if err != nil || first.Purged != 4 || first.Seq != 4 {
+		t.Errorf("purge anchor = %+v, %v", first, err)
+	}
+	if _, err := verifyComplianceLog(path); err != nil {
+		t.Errorf("verify after second purge: %v", err)
+	}
+}
+
+func lastComplianceRecordMatching(path, kind string) (complianceRecord, error) {
+	var found complianceRecord
+	err := scanComplianceLog(path, func(r complianceRecord) error {
+		if r.Kind == kind {
+			found = r
+		}
+		return nil
+	})
+	return found, err
+}
+
+func TestPurgeRuns(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for id, started := range map[string]time.Time{
+		"20240101-000000-aaaa": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"20250101-000000-bbbb": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		r := &runArtifacts{dir: filepath.Join(root, id), meta: runMeta{ID: id, StartedAt: started}}
+		if err := os.MkdirAll(r.dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		r.writeMeta()
+	}
+
+	cutoff, err := parsePurgeCutoff("2024-06-01")
+	if err != nil {
+		t.Fatalf("parsePurgeCutoff: %v", err)
+	}
+	if n, err := purgeRuns(root, cutoff, false); err != nil || n != 1 {
+		t.Fatalf("purgeRuns = %d, %v; want 1", n, err)
+	}
+	runs, _ := listRuns(root) //nolint:errcheck
+	if len(runs) != 1 || runs[0].ID != "20250101-000000-bbbb" {
+		t.Errorf("runs after purge = %+v", runs)
+	}
+	if _, err := parsePurgeCutoff("last week"); err == nil {
+		t.Error("expected error for an invalid cutoff")
+	}
+}
+
+func TestPurgeSessions(t *testing.T) {
+	t.Setenv("SESSIONS_DIR", t.TempDir())
+	old := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	for _, turn := range []struct {
+		session string
+		at      time.Time
+	}{{"stale", old}, {"mixed", old}, {"mixed", old.Add(time.Hour)}, {"mixed", recent}} {
+		if err := appendSessionTurn(turn.session, providerOpenAI, sessionTurn{ResponseID: "resp", Query: "q", Time: turn.at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if n, err := purgeSessions(cutoff, true); err != nil || n != 3 {
+		t.Fatalf("dry run = %d, %v; want 3", n, err)
+	}
+	if sessions, _ := listSessions(); len(sessions) != 2 { //nolint:errcheck
+		t.Fatalf("dry run deleted sessions: %+v", sessions)
+	}
+	if n, err := purgeSessions(cutoff, false); err != nil || n != 3 {
+		t.Fatalf("purge = %d, %v; want 3", n, err)
+	}
+	sessions, err := listSessions()
+	if err != nil || len(sessions) != 1 || sessions[0].Name != "mixed" || len(sessions[0].Turns) != 1 || !sessions[0].Created.Equal(recent) {
+		t.Errorf("sessions after purge = %+v, %v", sessions, err)
+	}
+}
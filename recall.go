@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// recallLimit is how many prior findings -recall injects at most.
+	recallLimit = 3
+	// recallThreshold is the minimum cosine similarity for a prior finding
+	// to count as relevant.
+	recallThreshold = 0.6
+	// recallAnswerRunes bounds each injected prior answer.
+	recallAnswerRunes = 600
+)
+
+// historyEmbeddingsSchema stores one vector per entry, tagged with the
+// embedder that produced it since vectors from different embedders are not
+// comparable.
+const historyEmbeddingsSchema = `
+CREATE TABLE IF NOT EXISTS embeddings (
+	entry_id INTEGER PRIMARY KEY REFERENCES entries(id) ON DELETE CASCADE,
+	embedder TEXT NOT NULL,
+	vector   BLOB NOT NULL
+);`
+
+// recalled is a prior history entry relevant to a new question.
+type recalled struct {
+	HistoryEntry
+	Score float64
+}
+
+// useEmbeddings turns on embedding of recorded questions with the
+// HISTORY_EMBEDDINGS mode (local or openai). An empty mode leaves them off.
+func (h *historyStore) useEmbeddings(mode, baseURL, model, apiKey string) error {
+	if h == nil {
+		return nil
+	}
+	embed, err := newEmbedder(mode, baseURL, model)
+	if err != nil {
+		return fmt.Errorf("HISTORY_EMBEDDINGS: %w", err)
+	}
+	if embed == nil {
+		return nil
+	}
+	if _, err := h.db.Exec(historyEmbeddingsSchema); err != nil {
+		return fmt.Errorf("init history embeddings: %w", err)
+	}
+	h.embed, h.apiKey = embed, apiKey
+	h.embedder = strings.ToLower(mode)
+	if h.embedder != "local" {
+		h.embedder = model
+		if h.embedder == "" {
+			h.embedder = defaultEmbeddingModel
+		}
+	}
+	return nil
+}
+
+// storeEmbedding saves vec as the embedding of entry id's question. New
+// questions are matched against old questions, which is what repeated
+// searches share; the answer is what gets recalled.
+func (h *historyStore) storeEmbedding(ctx context.Context, id int64, vec []float64) error {
+	_, err := h.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO embeddings (entry_id, embedder, vector) VALUES (?, ?, ?)`,
+		id, h.embedder, encodeVector(vec))
+	return err
+}
+
+// reindex embeds every entry that has no vector from the current embedder
+// and returns how many were added.
+func (h *historyStore) reindex(ctx context.Context) (int, error) {
+	if h.embed == nil {
+		return 0, fmt.Errorf("set HISTORY_EMBEDDINGS (local or openai) to index history")
+	}
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT id, query FROM entries e
+		 WHERE NOT EXISTS (SELECT 1 FROM embeddings v WHERE v.entry_id = e.id AND v.embedder = ?)`, h.embedder)
+	if err != nil {
+		return 0, fmt.Errorf("reindex history: %w", err)
+	}
+	type pending struct {
+		id    int64
+		query string
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.query); err != nil {
+			rows.Close() //nolint:errcheck
+			return 0, err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close() //nolint:errcheck
+	// Embedding may call an API, so the vectors are saved in one write at
+	// the end rather than under the file lock.
+	vecs := make([][]float64, 0, len(todo))
+	var embedErr error
+	for _, p := range todo {
+		vec, err := h.embed(ctx, h.apiKey, p.query)
+		if err != nil {
+			embedErr = fmt.Errorf("embed history entry: %w", err)
+			break
+		}
+		vecs = append(vecs, vec)
+	}
+	err = h.write(func() error {
+		for i, vec := range vecs {
+			if err := h.storeEmbedding(ctx, todo[i].id, vec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(vecs), embedErr
+}
+
+// recall returns up to limit prior entries whose embedding is closest to
+// query, best first, dropping those below recallThreshold.
+func (h *historyStore) recall(ctx context.Context, query string, limit int) ([]recalled, error) {
+	if h == nil || h.embed == nil {
+		return nil, nil
+	}
+	vec, err := h.embed(ctx, h.apiKey, query)
+	if err != nil {
+		return nil, fmt.Errorf("embed question: %w", err)
+	}
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT e.id, e.created_at, e.query, e.answer, e.model, e.provider, e.response_id, e.tags, e.content_hash, v.vector
+		 FROM embeddings v JOIN entries e ON e.id = v.entry_id WHERE v.embedder = ?`, h.embedder)
+	if err != nil {
+		return nil, fmt.Errorf("recall history: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var found []recalled
+	for rows.Next() {
+		var blob []byte
+		e, err := scanEntry(func(dest ...any) error { return rows.Scan(append(dest, &blob)...) })
+		if err != nil {
+			return nil, err
+		}
+		if score := cosineSimilarity(vec, decodeVector(blob)); score >= recallThreshold {
+			found = append(found, recalled{HistoryEntry: e, Score: score})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Score > found[j].Score })
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// recallInstructions presents prior findings to the model, dated so it can
+// judge whether they are stale.
+func recallInstructions(found []recalled) string {
+	if len(found) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("The user asked related questions before. Prior findings, which may be outdated:\n")
+	for _, r := range found {
+		fmt.Fprintf(&sb, "- On %s you previously found, for %q: %s\n",
+			r.CreatedAt.Format("2006-01-02"), r.Query, truncateRunes(strings.Join(strings.Fields(r.Answer), " "), recallAnswerRunes))
+	}
+	sb.WriteString("Build on these where still valid, mention when they were found, and search again for anything that may have changed.")
+	return sb.String()
+}
+
+// encodeVector stores a vector as little-endian float32s; the precision is
+// ample for cosine similarity and halves the size.
+func encodeVector(vec []float64) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, f := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(f)))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float64 {
+	vec := make([]float64, len(buf)/4)
+	for i := range vec {
+		vec[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return vec
+}
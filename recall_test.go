@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory_Recall(t *testing.T) {
+	t.Parallel()
+	h := newTestHistory(t)
+	h.now = func() time.Time { return time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	// Entries recorded before embeddings were enabled need a reindex.
+	if _, err := h.record(ctx, HistoryEntry{Query: "kubernetes ingress controller comparison", Answer: "NGINX ingress and Traefik are the most common ingress controllers."}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.useEmbeddings("local", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := h.reindex(ctx); err != nil || n != 1 {
+		t.Fatalf("reindex = %d, %v; want 1", n, err)
+	}
+	if _, err := h.record(ctx, HistoryEntry{Query: "sourdough starter feeding schedule", Answer: "Feed twice daily at room temperature."}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h.reindex(ctx); n != 0 {
+		t.Errorf("second reindex = %d, want 0", n)
+	}
+
+	found, err := h.recall(ctx, "which kubernetes ingress controller", recallLimit)
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if len(found) != 1 || !strings.Contains(found[0].Query, "ingress") {
+		t.Fatalf("recall = %+v, want the ingress entry only", found)
+	}
+
+	rules := recallInstructions(found)
+	if !strings.Contains(rules, "On 2025-02-03 you previously found") || !strings.Contains(rules, "Traefik") {
+		t.Errorf("instructions = %q", rules)
+	}
+	if recallInstructions(nil) != "" {
+		t.Error("no findings should give no instructions")
+	}
+}
+
+func TestVectorEncoding(t *testing.T) {
+	t.Parallel()
+
+	vec := []float64{0.5, -1.25, 0}
+	got := decodeVector(encodeVector(vec))
+	if len(got) != len(vec) || got[0] != 0.5 || got[1] != -1.25 || got[2] != 0 {
+		t.Errorf("round trip = %v, want %v", got, vec)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// relatedModel is the cheap model used to suggest follow-up questions.
+	relatedModel = modelNano
+	// maxRelated caps how many suggestions are kept.
+	maxRelated = 5
+	// relatedAnswerRunes bounds the answer passed to the suggestion pass.
+	relatedAnswerRunes = 4000
+)
+
+// relatedPrompt asks for follow-ups that dig deeper instead of rephrasing
+// the original question.
+const relatedPrompt = `A user researching a topic asked the question below and got the answer that follows.
+Suggest 3 to 5 follow-up questions that would take the research further: gaps, caveats, comparisons or next steps
+the answer does not settle. Do not repeat the original question. Write them in the language of the question,
+one per line, with no numbering and no other text.
+
+Question: %s
+
+Answer:
+%s`
+
+// listMarkerRe matches bullet or numbering prefixes models add despite being
+// told not to.
+var listMarkerRe = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s*`)
+
+// suggestRelated asks a cheap model for follow-up questions to query and
+// answer, on provider, the one that gave the answer.
+func suggestRelated(ctx context.Context, provider, apiKey, baseURL, query, answer string) ([]string, error) {
+	wa := webSearchArgs{model: relatedModel, effort: "none", verbosity: "low"}
+	out, err := synthesizeOn(ctx, provider, apiKey, baseURL, wa, fmt.Sprintf(relatedPrompt, query, truncateRunes(answer, relatedAnswerRunes)))
+	if err != nil {
+		return nil, fmt.Errorf("suggest related questions: %w", err)
+	}
+	return parseRelated(out, query), nil
+}
+
+// parseRelated takes one question per line, stripping list markers and
+// dropping blanks, duplicates and the original question.
+func parseRelated(text, query string) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		q := strings.TrimSpace(listMarkerRe.ReplaceAllString(line, ""))
+		key := strings.ToLower(q)
+		if q == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, q)
+		if len(out) == maxRelated {
+			break
+		}
+	}
+	return out
+}
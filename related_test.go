@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRelated(t *testing.T) {
+	t.Parallel()
+
+	text := "1. How does X compare to Y?\n\n- What changed in 2025?\n* what changed in 2025?\nOriginal question\n" +
+		"Is it safe?\nWho maintains it?\nWhat does it cost?\nWhere is it used?"
+	got := parseRelated(text, "original question")
+	want := []string{"How does X compare to Y?", "What changed in 2025?", "Is it safe?", "Who maintains it?", "What does it cost?"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRelated = %q, want %q", got, want)
+	}
+}
+
+func TestRunWebSearch_Related(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		text := "Rust 1.90 is current."
+		if body.Model == relatedModel {
+			if !strings.Contains(body.Input, text) || len(body.Tools) != 0 {
+				t.Errorf("suggestion prompt = %q, tools = %+v", body.Input, body.Tools)
+			}
+			text = "- What changed in 1.90?\n- When is 1.91 due?"
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": text}}}},
+		})
+	})
+
+	wa := extractWebSearchArgs(map[string]any{"query": "latest Rust?", "related": true, "reasoning_effort": "low"})
+	result, err := runWebSearch(context.Background(), "k", base, wa)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"What changed in 1.90?", "When is 1.91 due?"}
+	if !result.Success || !reflect.DeepEqual(result.Related, want) {
+		t.Errorf("result = %+v, want related %q", result, want)
+	}
+}
+
+func TestRunWebSearch_RelatedOnSelectedProvider(t *testing.T) {
+	_, openAIBase := newJSONServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("the suggestion pass went to OpenAI instead of the selected provider")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	_, deepseekBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body) //nolint:errcheck
+		text := "Rust 1.90 is current."
+		if strings.Contains(string(body), "follow-up questions") {
+			text = "- What changed in 1.90?"
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":      "ds-1",
+			"choices": []map[string]any{{"message": map[string]any{"content": text}}},
+		})
+	})
+	orig := providers["deepseek"].defaultURL
+	providers["deepseek"].defaultURL = deepseekBase
+	t.Cleanup(func() { providers["deepseek"].defaultURL = orig })
+	providerKeys = map[string]string{"deepseek": "dk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	wa := extractWebSearchArgs(map[string]any{"query": "latest Rust?", "related": true, "provider": "deepseek"})
+	result, err := runWebSearch(context.Background(), "k", openAIBase, wa)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"What changed in 1.90?"}; !result.Success || !reflect.DeepEqual(result.Related, want) {
+		t.Errorf("result = %+v, want related %q", result, want)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// chatHelp lists the slash commands of "answer chat".
+const chatHelp = `Commands:
+  /model [name]     Show or switch the model
+  /effort [level]   Show or switch the reasoning effort (none, low, medium, high, xhigh)
+  /reset            Start a new conversation
+  /save [file]      Write the conversation to a Markdown file
+  /help             Show this help
+  /quit             Leave (or Ctrl-D)`
+
+// chatSession is the state of an interactive "answer chat" session.
+type chatSession struct {
+	apiKey        string
+	baseURL       string
+	provider      *provider
+	model         string
+	effort        string
+	verbosity     string
+	webSearch     bool
+	noStore       bool
+	citationStyle string
+
+	// previousID chains the next turn to the last answer on providers that
+	// store responses.
+	previousID string
+	// turns is the conversation so far. Providers without stored responses
+	// get it as messages; /save writes it out.
+	turns []Message
+}
+
+// chained reports whether turns are linked with previous_response_id
+// rather than resent as messages.
+func (s *chatSession) chained() bool {
+	return !s.noStore && (s.provider.name == providerOpenAI || s.provider.name == providerAzure)
+}
+
+// ask sends one turn and returns the answer, rendered for printing.
+func (s *chatSession) ask(ctx context.Context, question string) (string, error) {
+	p := CallAPIParams{
+		Provider:     s.provider.name,
+		APIKey:       s.provider.keyFor(s.apiKey),
+		BaseURL:      s.provider.endpointFor(s.baseURL),
+		Query:        question,
+		Model:        s.model,
+		Effort:       s.effort,
+		Verbosity:    s.verbosity,
+		NoStore:      s.noStore,
+		UserLocation: defaultUserLocation,
+		UseWebSearch: s.webSearch && s.provider.webSearch,
+		Timeout:      getTimeoutForEffort(s.effort),
+		Retry:        retryPolicy,
+	}
+	if s.chained() {
+		p.PreviousResponseID = s.previousID
+	} else {
+		p.Messages = s.turns[max(0, len(s.turns)-maxConversationMessages):]
+	}
+	apiResp, err := CallAPI(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	extracted := ExtractAnswer(apiResp)
+	answer := answerPipeline.apply(extracted.Text)
+	if answer == "" {
+		if err := checkReasoningOnly(apiResp); err != nil {
+			return "", err
+		}
+		return "", errors.New("no answer found in response")
+	}
+	citations := extracted.Citations
+	if answer != extracted.Text {
+		citations = withoutSpans(citations)
+	}
+
+	s.previousID = apiResp.ID
+	s.turns = append(s.turns, Message{Role: "user", Content: question}, Message{Role: "assistant", Content: answer})
+	if _, err := answerHistory.record(ctx, HistoryEntry{Query: question, Answer: answer, Model: apiResp.Model, Provider: s.provider.name, ResponseID: apiResp.ID, Sources: citationURLs(citations)}); err != nil {
+		Warn("Failed to record history", "error", err)
+	}
+	return renderCitations(answer, citations, s.citationStyle), nil
+}
+
+// command runs a slash command; it reports false when the session should
+// end.
+func (s *chatSession) command(line string, out io.Writer) bool {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "quit", "exit":
+		return false
+	case "help":
+		fmt.Fprintln(out, chatHelp)
+	case "model":
+		if arg != "" {
+			s.model = arg
+		}
+		fmt.Fprintf(out, "model: %s\n", s.model)
+	case "effort":
+		if arg != "" {
+			if validateEffort(arg) != arg {
+				fmt.Fprintf(out, "unknown effort %q\n", arg)
+				return true
+			}
+			s.effort = arg
+		}
+		fmt.Fprintf(out, "effort: %s\n", s.effort)
+	case "reset":
+		s.previousID, s.turns = "", nil
+		fmt.Fprintln(out, "Started a new conversation.")
+	case "save":
+		path := arg
+		if path == "" {
+			path = "chat-" + time.Now().Format("20060102-150405") + ".md"
+		}
+		if err := os.WriteFile(path, []byte(s.transcript()), 0o600); err != nil {
+			fmt.Fprintf(out, "save: %v\n", err)
+			return true
+		}
+		fmt.Fprintf(out, "Saved %d turns to %s\n", len(s.turns)/2, path)
+	default:
+		fmt.Fprintf(out, "unknown command /%s; /help lists them\n", name)
+	}
+	return true
+}
+
+// transcript renders the conversation as Markdown.
+func (s *chatSession) transcript() string {
+	var sb strings.Builder
+	for _, m := range s.turns {
+		heading := "You"
+		if m.Role == "assistant" {
+			heading = "Answer"
+		}
+		fmt.Fprintf(&sb, "## %s\n\n%s\n\n", heading, strings.TrimSpace(m.Content))
+	}
+	return sb.String()
+}
+
+// loop reads questions and slash commands from in until /quit or end of
+// input. Ctrl-C cancels the question in flight, not the session.
+func (s *chatSession) loop(ctx context.Context, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "/"):
+			if !s.command(line, out) {
+				return
+			}
+			continue
+		}
+
+		turnCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		answer, err := s.ask(turnCtx, line)
+		stop()
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n\n", err)
+			continue
+		}
+		fmt.Fprintf(out, "\n%s\n\n", answer)
+	}
+}
+
+// runChat implements "answer chat": an interactive session whose turns
+// continue one conversation.
+func runChat(args []string) {
+	envCfg, err := loadEnvConfig()
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if err := applyRuntimeConfig(envCfg); err != nil {
+		fail(2, err.Error())
+	}
+
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	baseURL := fs.String("base", defaultBaseURL, "API endpoint")
+	model := fs.String("model", cmp.Or(envCfg.Model, defaultModel), "model (env MODEL)")
+	effort := fs.String("effort", cmp.Or(envCfg.Effort, defaultEffort), "effort (env EFFORT)")
+	verbosity := fs.String("verbosity", defaultVerbosity, "response verbosity (low, medium, high)")
+	providerName := fs.String("provider", envCfg.Provider, "backend provider: "+strings.Join(providerNames(), ", ")+" (env PROVIDER)")
+	webSearch := fs.Bool("web-search", true, "use web search")
+	noStore := fs.Bool("no-store", noStoreDefault, "ask OpenAI not to store responses; turns are resent instead (env NO_STORE)")
+	citationStyle := fs.String("citation-style", defaultCitationStyle, "render citations as inline, footnotes or apa (env CITATION_STYLE)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer chat [flags]")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\n"+chatHelp)
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	pr, err := lookupProvider(*providerName)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	style, err := parseCitationStyle(*citationStyle)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	s := &chatSession{
+		apiKey:        envCfg.APIKey,
+		baseURL:       resolveBaseURL(*baseURL),
+		provider:      pr,
+		model:         *model,
+		effort:        validateEffort(*effort),
+		verbosity:     validateVerbosity(*verbosity),
+		webSearch:     *webSearch,
+		noStore:       *noStore,
+		citationStyle: style,
+	}
+	fmt.Printf("answer chat (%s, %s, effort %s). /help lists commands; Ctrl-D leaves.\n", pr.name, s.model, s.effort)
+	s.loop(context.Background(), os.Stdin, os.Stdout)
+}
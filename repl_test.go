@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChatSession_ChainsTurns(t *testing.T) {
+	t.Parallel()
+
+	var previous []any
+	var inputs []any
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		previous = append(previous, raw["previous_response_id"])
+		inputs = append(inputs, raw["input"])
+		n := len(previous)
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": fmt.Sprintf("resp_%d", n), "status": "completed",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": fmt.Sprintf("Answer %d", n)}}}},
+		})
+	})
+
+	path := filepath.Join(t.TempDir(), "chat.md")
+	s := &chatSession{apiKey: "k", baseURL: base, provider: providers[providerOpenAI], model: "gpt-5.4-mini", effort: "low"}
+	var out strings.Builder
+	s.loop(t.Context(), strings.NewReader("first\nsecond\n/effort extreme\n/reset\nthird\n/save "+path+"\n/quit\nnever sent\n"), &out)
+
+	if want := []any{nil, "resp_1", nil}; fmt.Sprint(previous) != fmt.Sprint(want) {
+		t.Errorf("previous_response_id per turn = %v, want %v", previous, want)
+	}
+	for _, want := range []string{"Answer 2", `unknown effort "extreme"`, "Started a new conversation.", "Saved 1 turns"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil || string(saved) != "## You\n\nthird\n\n## Answer\n\nAnswer 3\n\n" {
+		t.Errorf("saved transcript = %q, %v", saved, err)
+	}
+
+	// Without stored responses the earlier turns are resent as messages.
+	previous, inputs = nil, nil
+	s = &chatSession{apiKey: "k", baseURL: base, provider: providers[providerOpenAI], model: "gpt-5.4-mini", effort: "low", noStore: true}
+	s.loop(t.Context(), strings.NewReader("first\nsecond\n"), &out)
+	if items, _ := inputs[1].([]any); len(items) != 3 || previous[1] != nil {
+		t.Errorf("second turn input = %v, previous_response_id = %v", inputs[1], previous[1])
+	}
+}
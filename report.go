@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultReportSections = 5
+	maxReportSections     = 10
+)
+
+// reportOutlinePrompt asks for the report structure as JSON. Each section
+// carries the question its research call will answer.
+const reportOutlinePrompt = `Plan a thorough research report on the topic below.
+Reply with JSON only, no prose, in this shape:
+{"title": "<report title>", "sections": [{"title": "<section heading>", "question": "<the specific question this section answers>"}]}
+Use %d sections at most, ordered so that each builds on the previous ones, without overlap.
+Do not include an introduction, conclusion or sources section.
+
+Topic: %s`
+
+// reportSectionPrompt researches one section; the outline is included so the
+// model stays within the section's scope.
+const reportSectionPrompt = `You are writing one section of a research report titled %q.
+Report outline:
+%s
+Write the section %q by researching this question: %s
+
+Write detailed Markdown prose with subheadings (###) where useful, but no top-level heading.
+Do not repeat what earlier sections covered.`
+
+// reportOutline is the planned structure of a report.
+type reportOutline struct {
+	Title    string          `json:"title"`
+	Sections []reportSection `json:"sections"`
+}
+
+type reportSection struct {
+	Title    string `json:"title"`
+	Question string `json:"question"`
+	// Body and Sources are filled in by research; Err records a section
+	// whose research failed.
+	Body    string     `json:"-"`
+	Sources []Citation `json:"-"`
+	Err     string     `json:"-"`
+}
+
+// planReport asks the default provider for an outline of topic.
+func planReport(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, topic string, sections int) (*reportOutline, error) {
+	raw, err := synthesize(ctx, apiKey, baseURL, wa, fmt.Sprintf(reportOutlinePrompt, sections, topic))
+	if err != nil {
+		return nil, fmt.Errorf("plan report: %w", err)
+	}
+	return parseOutline(raw, sections)
+}
+
+// parseOutline decodes the outline, tolerating prose or a code fence around
+// the JSON object, and drops sections without a question.
+func parseOutline(raw string, limit int) (*reportOutline, error) {
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("outline is not JSON")
+	}
+	var o reportOutline
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &o); err != nil {
+		return nil, fmt.Errorf("parse outline: %w", err)
+	}
+	kept := o.Sections[:0]
+	for _, s := range o.Sections {
+		if s.Title = strings.TrimSpace(s.Title); s.Title != "" && strings.TrimSpace(s.Question) != "" {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("outline has no sections")
+	}
+	o.Sections = kept[:min(len(kept), limit)]
+	return &o, nil
+}
+
+// researchReport answers every section in order. Each search continues from
+// the previous section's response where the provider supports it, so later
+// sections can build on earlier findings. Failed sections are recorded and
+// skipped; the report fails only when none succeeded.
+func researchReport(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, o *reportOutline, progress io.Writer) error {
+	var outline strings.Builder
+	for i, s := range o.Sections {
+		fmt.Fprintf(&outline, "%d. %s\n", i+1, s.Title)
+	}
+	var prevID string
+	succeeded := 0
+	for i := range o.Sections {
+		s := &o.Sections[i]
+		fmt.Fprintf(progress, "Researching section %d/%d: %s\n", i+1, len(o.Sections), s.Title)
+		swa := wa
+		swa.query = fmt.Sprintf(reportSectionPrompt, o.Title, outline.String(), s.Title, s.Question)
+		swa.previousResponseID = prevID
+		result, err := runWebSearch(ctx, apiKey, baseURL, swa)
+		switch {
+		case err != nil:
+			s.Err = err.Error()
+		case !result.Success:
+			s.Err = result.Error
+		default:
+			s.Body, s.Sources = result.Answer, result.Citations
+			if !swa.noStore && chainsResponses(result.Provider) {
+				// Unstored responses, and other providers' answers, cannot
+				// be continued.
+				prevID = result.ID
+			}
+			succeeded++
+		}
+	}
+	if succeeded == 0 {
+		return fmt.Errorf("research failed for every section: %s", o.Sections[0].Err)
+	}
+	return nil
+}
+
+// renderReport assembles the Markdown report. Sources are numbered once
+// across the report; each section lists the numbers it relied on and the
+// appendix lists them all.
+func renderReport(w io.Writer, o *reportOutline) {
+	index := map[string]int{}
+	var sources []Citation
+	fmt.Fprintf(w, "# %s\n\n", o.Title)
+	for i, s := range o.Sections {
+		fmt.Fprintf(w, "- [%d. %s](#%s)\n", i+1, s.Title, markdownAnchor(fmt.Sprintf("%d. %s", i+1, s.Title)))
+	}
+	for i, s := range o.Sections {
+		fmt.Fprintf(w, "\n## %d. %s\n\n", i+1, s.Title)
+		if s.Err != "" {
+			fmt.Fprintf(w, "_Research for this section failed: %s_\n", s.Err)
+			continue
+		}
+		fmt.Fprintln(w, strings.TrimSpace(s.Body))
+		var refs []string
+		for _, c := range s.Sources {
+			n, ok := index[c.URL]
+			if !ok {
+				sources = append(sources, c)
+				n = len(sources)
+				index[c.URL] = n
+			}
+			if ref := fmt.Sprintf("[%d]", n); !slices.Contains(refs, ref) {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) > 0 {
+			fmt.Fprintf(w, "\n_Sources: %s_\n", strings.Join(refs, ", "))
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n## Sources")
+	fmt.Fprintln(w)
+	for i, c := range sources {
+		title := c.Title
+		if title == "" {
+			title = c.URL
+		}
+		fmt.Fprintf(w, "%d. [%s](%s)\n", i+1, title, c.URL)
+	}
+}
+
+// markdownAnchor approximates the heading anchor GitHub-flavoured Markdown
+// renderers generate.
+func markdownAnchor(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ' || r == '-':
+			sb.WriteRune('-')
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// runReport implements "answer report": plan an outline, research each
+// section with chained searches and print a long Markdown report.
+func runReport(args []string) {
+	envCfg, err := loadEnvConfig()
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if err := applyRuntimeConfig(envCfg); err != nil {
+		fail(2, err.Error())
+	}
+
+	defaultModelVal, defaultEffortVal := defaultModel, defaultEffort
+	if envCfg.Model != "" {
+		defaultModelVal = envCfg.Model
+	}
+	if envCfg.Effort != "" {
+		defaultEffortVal = envCfg.Effort
+	}
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	model := fs.String("model", defaultModelVal, "model for outline and research (env MODEL)")
+	effort := fs.String("effort", defaultEffortVal, "reasoning effort for each section (env EFFORT)")
+	provider := fs.String("provider", envCfg.Provider, "backend provider for the research: "+strings.Join(providerNames(), ", ")+" (env PROVIDER)")
+	sections := fs.Int("sections", defaultReportSections, fmt.Sprintf("maximum number of sections (1-%d)", maxReportSections))
+	domains := fs.String("domains", "", "comma-separated domains to restrict web search to; a leading - excludes one")
+	baseURL := fs.String("base", defaultBaseURL, "API endpoint")
+	out := fs.String("o", "", "write the report to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer report [flags] <topic>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	topic := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if topic == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	*sections = max(1, min(*sections, maxReportSections))
+	pr, err := lookupProvider(*provider)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	wa := webSearchArgs{
+		model:        *model,
+		effort:       validateEffort(*effort),
+		verbosity:    "high",
+		provider:     pr.name,
+		userLocation: defaultUserLocation,
+		domains:      splitDomains(*domains),
+		useWebSearch: true,
+	}
+	// The outline runs on the default provider, where the research model
+	// name may not exist.
+	plan := webSearchArgs{model: wa.model, effort: "low", verbosity: "medium"}
+	if pr.name != defaultProvider {
+		plan.model = defaultModel
+	}
+
+	// Check the output directory first so a bad path fails before the
+	// research runs; the file itself is only replaced once the report is
+	// complete.
+	if *out != "" {
+		probe, err := os.CreateTemp(filepath.Dir(*out), "."+filepath.Base(*out)+"-*")
+		if err != nil {
+			fail(2, err.Error())
+		}
+		probe.Close()           //nolint:errcheck
+		os.Remove(probe.Name()) //nolint:errcheck
+	}
+
+	if envCfg.RunArtifacts {
+		if activeRun, err = startRun("report", topic); err != nil {
+			fail(2, err.Error())
+		}
+		defer activeRun.finish(nil)
+	}
+
+	ctx := context.Background()
+	base := resolveBaseURL(*baseURL)
+	fmt.Fprintf(os.Stderr, "Planning report on %q\n", topic)
+	outline, err := planReport(ctx, envCfg.APIKey, base, plan, topic, *sections)
+	if err != nil {
+		fail(3, err.Error())
+	}
+	activeRun.saveJSON("outline.json", outline)
+	if err := researchReport(ctx, envCfg.APIKey, base, wa, outline, os.Stderr); err != nil {
+		fail(3, err.Error())
+	}
+	if activeRun != nil {
+		var sb strings.Builder
+		renderReport(&sb, outline)
+		activeRun.save("report.md", []byte(sb.String()))
+		type sectionArtifact struct {
+			Title   string     `json:"title"`
+			Body    string     `json:"body"`
+			Sources []Citation `json:"sources,omitempty"`
+			Error   string     `json:"error,omitempty"`
+		}
+		var sections []sectionArtifact
+		for _, s := range outline.Sections {
+			sections = append(sections, sectionArtifact{s.Title, s.Body, s.Sources, s.Err})
+		}
+		activeRun.saveJSON("sections.json", sections)
+	}
+
+	if *out == "" {
+		renderReport(os.Stdout, outline)
+		return
+	}
+	var sb strings.Builder
+	renderReport(&sb, outline)
+	if err := writeFileAtomic(*out, []byte(sb.String()), 0o644); err != nil {
+		fail(2, err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "Report written to %s\n", *out)
+}
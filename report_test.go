@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseOutline(t *testing.T) {
+	t.Parallel()
+
+	raw := "```json\n" + `{"title": "Heat pumps", "sections": [
+		{"title": "Costs", "question": "What do they cost?"},
+		{"title": "", "question": "dropped"},
+		{"title": "Efficiency", "question": "How efficient are they?"},
+		{"title": "Grants", "question": "Which grants exist?"}]}` + "\n```"
+	o, err := parseOutline(raw, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.Title != "Heat pumps" || len(o.Sections) != 2 || o.Sections[1].Title != "Efficiency" {
+		t.Errorf("outline = %+v", o)
+	}
+	if _, err := parseOutline(`{"title": "x", "sections": []}`, 5); err == nil {
+		t.Error("expected error for an outline without sections")
+	}
+}
+
+func TestResearchAndRenderReport(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		n := calls.Add(1)
+		if n == 2 && body.PreviousResponseID != "resp-1" {
+			t.Errorf("second section previous_response_id = %q, want resp-1", body.PreviousResponseID)
+		}
+		if n == 3 {
+			writeJSON(t, w, http.StatusBadRequest, map[string]any{"error": "bad"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp-" + string(rune('0'+n)),
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+				"type": "output_text", "text": "Findings.",
+				"annotations": []map[string]any{
+					{"type": "url_citation", "url": "https://shared.example", "title": "Shared", "start_index": 0, "end_index": 9},
+					{"type": "url_citation", "url": "https://s" + string(rune('0'+n)) + ".example", "start_index": 0, "end_index": 9},
+				},
+			}}}},
+		})
+	})
+
+	o := &reportOutline{Title: "Heat pumps", Sections: []reportSection{
+		{Title: "Costs", Question: "What do they cost?"},
+		{Title: "Efficiency", Question: "How efficient?"},
+		{Title: "Grants", Question: "Which grants?"},
+	}}
+	wa := webSearchArgs{model: defaultModel, effort: "low", verbosity: "high", useWebSearch: true}
+	if err := researchReport(context.Background(), "k", base, wa, o, io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	renderReport(&buf, o)
+	got := buf.String()
+	for _, want := range []string{
+		"# Heat pumps\n",
+		"- [1. Costs](#1-costs)\n",
+		"## 2. Efficiency\n\nFindings.\n\n_Sources: [1], [3]_\n",
+		"## 3. Grants\n\n_Research for this section failed:",
+		"## Sources\n\n1. [Shared](https://shared.example)\n2. [https://s1.example](https://s1.example)\n3. ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+}
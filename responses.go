@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// getResponseTimeout bounds one GetResponse call; nothing is generated, so
+// it does not depend on the reasoning effort.
+const getResponseTimeout = 30 * time.Second
+
+// responseIDPattern matches the IDs the Responses API hands out (resp_...).
+var responseIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// GetResponse fetches a stored response by ID from the OpenAI Responses API
+// (GET {baseURL}/{id}), so an earlier answer and its citations can be shown
+// again without re-running the query. baseURL is the responses endpoint, as
+// for CallAPI. Only OpenAI stores responses.
+func GetResponse(ctx context.Context, apiKey, baseURL, id string) (*apiResponse, error) {
+	if offlineMode {
+		return nil, ErrOffline
+	}
+	if apiKey == "" {
+		return nil, ErrNoAPIKey
+	}
+	id = strings.TrimSpace(id)
+	if !responseIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid response ID %q", id)
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, getResponseTimeout)
+	defer cancel()
+	p := CallAPIParams{APIKey: apiKey, RequestID: newRequestID()}
+	req, err := newAuthRequest(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/"+url.PathEscape(id), nil, p)
+	if err != nil {
+		return nil, err
+	}
+	Debug("Fetching stored response", "response_id", id, "request_id", p.RequestID)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w (request_id=%s)", err, p.RequestID)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := readAPIResponse(resp)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.RequestID = p.RequestID
+		}
+		return nil, err
+	}
+	var ar apiResponse
+	if err := json.Unmarshal(bodyBytes, &ar); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	ar.RequestID = p.RequestID
+	return &ar, nil
+}
+
+// storedResult turns a fetched response into a tool result. Responses that
+// are still running, or that hold no answer, come back unsuccessful.
+func storedResult(ar *apiResponse) *WebSearchResult {
+	result := &WebSearchResult{
+		ID:        ar.ID,
+		Model:     ar.Model,
+		Effort:    ar.Reasoning.Effort,
+		Provider:  providerOpenAI,
+		RequestID: ar.RequestID,
+		Usage:     ar.Usage,
+	}
+	extracted := ExtractAnswer(ar)
+	result.Answer = answerPipeline.apply(extracted.Text)
+	switch {
+	case ar.Status == "queued" || ar.Status == "in_progress":
+		result.Answer = ""
+		result.Error = fmt.Sprintf("response %s is %s; no answer yet", ar.ID, ar.Status)
+	case result.Answer == "":
+		result.Error = "no answer found in response"
+		if err := checkReasoningOnly(ar); err != nil {
+			result.Error = err.Error()
+		}
+	default:
+		result.Success = true
+		result.ContentHash = contentHash(result.Answer)
+		result.Citations = extracted.Citations
+		if result.Answer != extracted.Text {
+			result.Citations = withoutSpans(result.Citations)
+		}
+	}
+	return result
+}
+
+// runCLIGet prints the stored answer with -get, followed by its sources.
+func runCLIGet(envCfg EnvConfig, args cliArgs) {
+	pr := providers[providerOpenAI]
+	ar, err := GetResponse(context.Background(), pr.keyFor(envCfg.APIKey), pr.endpointFor(args.baseURL), args.get)
+	if err != nil {
+		fail(3, err.Error())
+	}
+	if args.showAll {
+		raw, _ := json.MarshalIndent(ar, "", "  ") //nolint:errcheck // Debug output, error ok to ignore
+		fmt.Println(string(raw))
+		return
+	}
+	result := storedResult(ar)
+	if !result.Success {
+		fail(3, result.Error)
+	}
+	if args.output != outputText {
+		answer := renderCitations(result.Answer, result.Citations, args.citationStyle)
+		if err := writeCLIOutput(os.Stdout, args.output, newCLIOutput(result, answer, nil), args.citationStyle); err != nil {
+			fail(1, err.Error())
+		}
+		return
+	}
+	if args.citationStyle != "" {
+		fmt.Println(styleCitations(result, args.citationStyle).Answer)
+		return
+	}
+	fmt.Println(result.Answer)
+	if len(result.Citations) > 0 {
+		fmt.Println("\nSources:")
+		seen := make(map[string]bool)
+		for _, u := range citationURLs(result.Citations) {
+			if !seen[u] {
+				seen[u] = true
+				fmt.Printf("- %s\n", u)
+			}
+		}
+	}
+}
+
+// newGetResponseTool builds the get_response tool definition.
+func newGetResponseTool() mcp.Tool {
+	return mcp.NewTool("get_response",
+		mcp.WithDescription("Fetch an earlier OpenAI answer and its citations by response ID (the id field of a "+
+			"gpt_websearch result) without running the query again"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The response ID, e.g. resp_abc123"),
+			mcp.Pattern(responseIDPattern.String()),
+		),
+		withCitationStyle(),
+		mcp.WithSchemaAdditionalProperties(false),
+		withResultOutputSchema(),
+	)
+}
+
+// getResponseHandler returns the handler for the get_response tool.
+func getResponseHandler(apiKey, baseURL string) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		style, err := citationStyleArg(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		pr := providers[providerOpenAI]
+		ar, err := GetResponse(ctx, pr.keyFor(apiKey), pr.endpointFor(baseURL), id)
+		if err != nil {
+			return toolErrorResult(ctx, err), nil
+		}
+		return mcp.NewToolResultStructuredOnly(versionedResult(activePager.page(styleCitations(storedResult(ar), style)))), nil
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGetResponse(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("method = %s, want GET", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer k" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.URL.Path {
+		case "/v1/responses/resp_done":
+			writeJSON(t, w, http.StatusOK, map[string]any{
+				"id": "resp_done", "model": "gpt-5.4-mini", "status": "completed",
+				"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+					"type": "output_text", "text": "Answer",
+					"annotations": []map[string]any{{"type": "url_citation", "url": "https://example.com", "start_index": 0, "end_index": 6}},
+				}}}},
+			})
+		case "/v1/responses/resp_running":
+			writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_running", "status": "in_progress"})
+		default:
+			writeJSON(t, w, http.StatusNotFound, map[string]any{"error": map[string]any{"message": "No response found"}})
+		}
+	})
+	endpoint := base + "/v1/responses"
+
+	ar, err := GetResponse(context.Background(), "k", endpoint, "resp_done")
+	if err != nil {
+		t.Fatalf("GetResponse: %v", err)
+	}
+	r := storedResult(ar)
+	if !r.Success || r.Answer != "Answer" || r.ID != "resp_done" || r.Model != "gpt-5.4-mini" {
+		t.Errorf("result = %+v", r)
+	}
+	if len(r.Citations) != 1 || r.Citations[0].URL != "https://example.com" {
+		t.Errorf("citations = %+v", r.Citations)
+	}
+
+	ar, err = GetResponse(context.Background(), "k", endpoint, "resp_running")
+	if err != nil {
+		t.Fatalf("GetResponse: %v", err)
+	}
+	if r := storedResult(ar); r.Success || r.Error == "" {
+		t.Errorf("running response = %+v, want unsuccessful", r)
+	}
+
+	var apiErr *APIError
+	if _, err := GetResponse(context.Background(), "k", endpoint, "resp_missing"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("missing response error = %v, want a 404 APIError", err)
+	}
+	if _, err := GetResponse(context.Background(), "k", endpoint, "../files"); err == nil {
+		t.Error("expected an error for a malformed ID")
+	}
+	if _, err := GetResponse(context.Background(), "", endpoint, "resp_done"); !errors.Is(err, ErrNoAPIKey) {
+		t.Errorf("err = %v, want ErrNoAPIKey", err)
+	}
+}
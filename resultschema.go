@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resultSchemaVersion is the version of the WebSearchResult shape returned
+// by gpt_websearch and get_response, sent to clients as schema_version.
+//
+// The published output schema is closed (additionalProperties: false), so a
+// client that validates against it, or generates types from it, breaks on
+// any field it does not know. The evolution policy is therefore:
+//
+//   - Any change to the shape bumps the version: adding a field as well as
+//     renaming, retyping or removing one.
+//   - Fields are not renamed or removed in place. A superseded field keeps
+//     being sent next to its replacement until the next version.
+//   - Each earlier version stays available: the shape it had is frozen in a
+//     type here (webSearchResultV1, ...), and "answer mcp -schema-version N"
+//     serves that shape and publishes its output schema, so a client can
+//     pin the version it was built against and upgrade when it is ready.
+//
+// Version 1 is the original result, before schema_version existed. Version 2
+// adds schema_version, citations, usage, caching, paging, provenance and
+// source fields.
+const resultSchemaVersion = 2
+
+// resultSchema is the version the MCP server sends; set at startup.
+var resultSchema = resultSchemaVersion
+
+// webSearchResultV1 is the version 1 result.
+type webSearchResultV1 struct {
+	Success            bool   `json:"success"`
+	Answer             string `json:"answer,omitempty"`
+	Query              string `json:"query"`
+	Model              string `json:"model"`
+	Effort             string `json:"effort"`
+	TimeoutUsed        string `json:"timeout_used"`
+	ID                 string `json:"id,omitempty"`
+	RequestedModel     string `json:"requested_model"`
+	RequestedEffort    string `json:"requested_effort"`
+	WebSearchUsed      bool   `json:"web_search_used"`
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// setResultSchema pins the result version the server sends.
+func setResultSchema(version int) error {
+	if version < 1 || version > resultSchemaVersion {
+		return fmt.Errorf("schema version %d not supported (want 1-%d)", version, resultSchemaVersion)
+	}
+	resultSchema = version
+	return nil
+}
+
+// withResultOutputSchema publishes the output schema of the pinned version.
+func withResultOutputSchema() mcp.ToolOption {
+	if resultSchema == 1 {
+		return mcp.WithOutputSchema[webSearchResultV1]()
+	}
+	return mcp.WithOutputSchema[WebSearchResult]()
+}
+
+// versionedResult returns result in the pinned version's shape.
+func versionedResult(result *WebSearchResult) any {
+	if resultSchema == 1 {
+		return webSearchResultV1{
+			Success:            result.Success,
+			Answer:             result.Answer,
+			Query:              result.Query,
+			Model:              result.Model,
+			Effort:             result.Effort,
+			TimeoutUsed:        result.TimeoutUsed,
+			ID:                 result.ID,
+			RequestedModel:     result.RequestedModel,
+			RequestedEffort:    result.RequestedEffort,
+			WebSearchUsed:      result.WebSearchUsed,
+			PreviousResponseID: result.PreviousResponseID,
+			Error:              result.Error,
+		}
+	}
+	out := *result
+	out.SchemaVersion = resultSchemaVersion
+	return &out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestVersionedResult pins the result version, a package setting, so it does
+// not run in parallel.
+func TestVersionedResult(t *testing.T) {
+	t.Cleanup(func() { resultSchema = resultSchemaVersion })
+	result := &WebSearchResult{
+		Success:   true,
+		Answer:    "Paris.",
+		Query:     "capital of France?",
+		Citations: []Citation{{URL: "https://example.fr/paris", Title: "Paris"}},
+		Usage:     &apiUsage{TotalTokens: 42},
+	}
+
+	current, ok := versionedResult(result).(*WebSearchResult)
+	if !ok || current.SchemaVersion != resultSchemaVersion || len(current.Citations) != 1 || result.SchemaVersion != 0 {
+		t.Errorf("current = %+v; result modified: %v", current, result.SchemaVersion != 0)
+	}
+
+	if err := setResultSchema(resultSchemaVersion + 1); err == nil {
+		t.Error("unsupported version accepted")
+	}
+	if err := setResultSchema(1); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(versionedResult(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v1 map[string]any
+	if err := json.Unmarshal(b, &v1); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"schema_version", "citations", "usage"} {
+		if _, ok := v1[field]; ok {
+			t.Errorf("version 1 result has %s: %s", field, b)
+		}
+	}
+	if v1["answer"] != "Paris." || v1["success"] != true {
+		t.Errorf("version 1 result = %s", b)
+	}
+	if _, ok := newGetResponseTool().OutputSchema.Properties["citations"]; ok {
+		t.Error("version 1 output schema lists citations")
+	}
+	if c := newCompactResult(result); c.SchemaVersion != 0 || c.Citations != nil || c.Answer != "Paris." {
+		t.Errorf("version 1 compact result = %+v", c)
+	}
+}
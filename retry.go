@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy controls how CallAPI retries transient failures. The zero value
+// disables retries (a single attempt).
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff ceiling for the first retry; it doubles on
+	// every further attempt up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// retryPolicy is the policy the CLI and MCP handlers pass to CallAPI; set
+// from MAX_RETRIES / RETRY_BASE_DELAY at startup.
+var retryPolicy = RetryPolicy{
+	MaxAttempts: defaultRetryAttempts,
+	BaseDelay:   defaultRetryBaseDelay,
+	MaxDelay:    defaultRetryMaxDelay,
+}
+
+// applyRetryConfig overrides the default retry policy from the environment.
+func applyRetryConfig(cfg EnvConfig) {
+	if cfg.HasMaxRetries {
+		retryPolicy.MaxAttempts = cfg.MaxRetries
+	}
+	if cfg.RetryBaseDelay > 0 {
+		retryPolicy.BaseDelay = cfg.RetryBaseDelay
+	}
+}
+
+// attempts returns the effective number of attempts (at least one).
+func (p RetryPolicy) attempts() int {
+	return max(1, p.MaxAttempts)
+}
+
+// maxDelay returns the longest wait between attempts.
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return defaultRetryMaxDelay
+	}
+	return p.MaxDelay
+}
+
+// backoff returns a full-jitter delay for the given retry (1-based): a random
+// duration in [0, min(MaxDelay, BaseDelay*2^(retry-1))].
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	ceiling := p.maxDelay()
+	d := base
+	for i := 1; i < retry && d < ceiling; i++ {
+		d *= 2
+	}
+	d = min(d, ceiling)
+	return rand.N(d + 1)
+}
+
+// isRetryable reports whether err is a transient failure worth retrying:
+// rate limits, 5xx gateway/server errors, and dropped connections. Context
+// cancellation and deadline expiry are never retried.
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter interprets a Retry-After header value, which is either a
+// number of seconds or an HTTP date. Invalid or past values yield zero.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryDelay picks the wait before the next attempt: the server's Retry-After
+// when it sent one, capped at MaxDelay so a far-off date cannot stall the
+// call, otherwise jittered exponential backoff.
+func (p RetryPolicy) retryDelay(retry int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, p.maxDelay())
+	}
+	return p.backoff(retry)
+}
+
+// sleepContext waits for d or until ctx is done, returning ctx.Err() in the
+// latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
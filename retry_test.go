@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCallAPI_RetriesTransientFailures(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			writeJSON(t, w, http.StatusServiceUnavailable, map[string]string{"error": "overloaded"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_ok"})
+	})
+
+	apiResp, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: time.Second,
+		Retry:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if apiResp.ID != "resp_ok" {
+		t.Errorf("ID = %q, want resp_ok", apiResp.ID)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("hits = %d, want 3", got)
+	}
+}
+
+func TestCallAPI_DoesNotRetryClientErrors(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		writeJSON(t, w, http.StatusBadRequest, map[string]string{"error": "bad"})
+	})
+
+	_, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: time.Second,
+		Retry:   RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond},
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 APIError, got %v", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("hits = %d, want 1", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"429", &APIError{StatusCode: 429}, true},
+		{"500", &APIError{StatusCode: 500}, true},
+		{"502", &APIError{StatusCode: 502}, true},
+		{"503", &APIError{StatusCode: 503}, true},
+		{"400", &APIError{StatusCode: 400}, false},
+		{"401", &APIError{StatusCode: 401}, false},
+		{"conn_reset", fmt.Errorf("http request: %w", syscall.ECONNRESET), true},
+		{"unexpected_eof", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"canceled", fmt.Errorf("http request: %w", context.Canceled), false},
+		{"deadline", fmt.Errorf("http request: %w", context.DeadlineExceeded), false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_BackoffBounded(t *testing.T) {
+	t.Parallel()
+
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 400 * time.Millisecond}
+	for retry := 1; retry <= 6; retry++ {
+		ceiling := min(p.MaxDelay, p.BaseDelay<<(retry-1))
+		for i := 0; i < 50; i++ {
+			if d := p.backoff(retry); d < 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [0, %v]", retry, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryPolicy_RetryAfterClamped(t *testing.T) {
+	t.Parallel()
+
+	p := RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}
+	if d := p.retryDelay(1, &APIError{RetryAfter: time.Hour}); d != 5*time.Second {
+		t.Errorf("retryDelay with Retry-After 1h = %v, want MaxDelay", d)
+	}
+	if d := p.retryDelay(1, &APIError{RetryAfter: 2 * time.Second}); d != 2*time.Second {
+		t.Errorf("retryDelay with Retry-After 2s = %v, want 2s", d)
+	}
+	if d := (RetryPolicy{}).retryDelay(1, &APIError{RetryAfter: time.Hour}); d != defaultRetryMaxDelay {
+		t.Errorf("zero policy: retryDelay = %v, want %v", d, defaultRetryMaxDelay)
+	}
+}
+
+func TestCallAPI_HonorsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0.05")
+			writeJSON(t, w, http.StatusTooManyRequests, map[string]string{"error": "slow down"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_ok"})
+	})
+
+	start := time.Now()
+	apiResp, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: time.Second,
+		// Backoff alone would be ~instant; the wait must come from Retry-After,
+		// which MaxDelay caps.
+		Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Nanosecond, MaxDelay: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("elapsed %v, expected to wait for Retry-After", elapsed)
+	}
+	if apiResp.RetryWait != 50*time.Millisecond {
+		t.Errorf("RetryWait = %v, want 50ms", apiResp.RetryWait)
+	}
+}
+
+func TestCallAPI_RetryAfterExposedInAPIError(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		writeJSON(t, w, http.StatusTooManyRequests, map[string]string{"error": "slow down"})
+	})
+
+	_, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: time.Second,
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("RetryAfter = %v, want 7s", apiErr.RetryAfter)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"-1", 0},
+		{"garbage", 0},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCallAPI_RequestIDStableAcrossRetries(t *testing.T) {
+	idempotencyKeys = true
+	t.Cleanup(func() { idempotencyKeys = false })
+
+	var ids, keys []string
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Client-Request-Id"))
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(ids) == 1 {
+			writeJSON(t, w, http.StatusBadGateway, map[string]string{"error": "flaky"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_ok"})
+	})
+
+	apiResp, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:  "k",
+		BaseURL: base,
+		Query:   "q",
+		Timeout: time.Second,
+		Retry:   RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] != ids[1] || keys[0] != ids[0] || keys[1] != ids[0] {
+		t.Errorf("request IDs = %v, idempotency keys = %v; want one ID reused", ids, keys)
+	}
+	if apiResp.RequestID != ids[0] {
+		t.Errorf("response RequestID = %q, want %q", apiResp.RequestID, ids[0])
+	}
+}
+
+func TestCallAPI_RequestIDInAPIError(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Idempotency-Key") != "" {
+			t.Error("Idempotency-Key sent without IDEMPOTENCY_KEYS")
+		}
+		w.Header().Set("X-Request-Id", "req_upstream")
+		writeJSON(t, w, http.StatusBadRequest, map[string]string{"error": "bad"})
+	})
+
+	_, err := CallAPI(context.Background(), CallAPIParams{
+		APIKey:    "k",
+		BaseURL:   base,
+		Query:     "q",
+		Timeout:   time.Second,
+		RequestID: "ws-test",
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RequestID != "ws-test" || apiErr.UpstreamRequestID != "req_upstream" {
+		t.Fatalf("APIError = %+v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "request_id=ws-test") || !strings.Contains(msg, "upstream_request_id=req_upstream") {
+		t.Errorf("error message = %q", msg)
+	}
+}
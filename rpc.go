@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSON-RPC error codes used by "answer rpc". rpcRequestCancelled is the
+// Language Server Protocol's code for a request cancelled by the client.
+const (
+	rpcParseError       = -32700
+	rpcInvalidRequest   = -32600
+	rpcMethodNotFound   = -32601
+	rpcInvalidParams    = -32602
+	rpcSearchFailed     = -32000
+	rpcRequestCancelled = -32800
+)
+
+// maxRPCMessageBytes bounds one incoming message.
+const maxRPCMessageBytes = 1 << 20
+
+// rpcMethods are the methods "answer rpc" understands.
+var rpcMethods = []string{"initialize", "search", "$/cancelRequest", "shutdown", "exit"}
+
+// rpcRequest is an incoming request or, without an ID, notification.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResult is a successful response; result is always present, if null.
+type rpcResult struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+// rpcErrorResponse is a failed response.
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcError        `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcSearchParams are the params of "search".
+type rpcSearchParams struct {
+	queryRequest
+	CitationStyle string `json:"citation_style,omitempty"`
+	NoCache       bool   `json:"no_cache,omitempty"`
+}
+
+// rpcSearchResult is the result of "search". Every field is always present.
+type rpcSearchResult struct {
+	Query     string      `json:"query"`
+	Answer    string      `json:"answer"`
+	Sources   []apiSource `json:"sources"`
+	Model     string      `json:"model"`
+	ID        string      `json:"id"`
+	Cached    bool        `json:"cached"`
+	ElapsedMS int64       `json:"elapsed_ms"`
+}
+
+// rpcConn reads and writes JSON-RPC messages. Messages are framed with
+// Content-Length headers as in the Language Server Protocol, or are single
+// lines of JSON; the framing of the first message is used for replies.
+type rpcConn struct {
+	r      *bufio.Reader
+	mu     sync.Mutex
+	w      io.Writer
+	lines  bool
+	framed bool // set once the first message fixed the framing
+}
+
+func newRPCConn(r io.Reader, w io.Writer) *rpcConn {
+	return &rpcConn{r: bufio.NewReader(r), w: w}
+}
+
+// readLine reads through the next newline, failing as soon as the line
+// passes maxRPCMessageBytes instead of buffering all of it.
+func (c *rpcConn) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := c.r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxRPCMessageBytes {
+			return nil, fmt.Errorf("message longer than %d bytes", maxRPCMessageBytes)
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
+
+// read returns the next message body, or io.EOF at the end of input.
+func (c *rpcConn) read() ([]byte, error) {
+	for {
+		b, err := c.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\r' && b[0] != '\n' && b[0] != ' ' && b[0] != '\t' {
+			break
+		}
+		_, _ = c.r.ReadByte() //nolint:errcheck // just peeked
+	}
+	c.mu.Lock()
+	if !c.framed {
+		b, _ := c.r.Peek(1) //nolint:errcheck // just peeked
+		c.lines, c.framed = b[0] == '{' || b[0] == '[', true
+	}
+	lines := c.lines
+	c.mu.Unlock()
+
+	if lines {
+		line, err := c.readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return bytes.TrimSpace(line), nil
+	}
+
+	length := -1
+	for {
+		raw, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		header := strings.TrimSpace(string(raw))
+		if header == "" {
+			break
+		}
+		name, value, _ := strings.Cut(header, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 || length > maxRPCMessageBytes {
+		return nil, fmt.Errorf("missing or oversized Content-Length (%d)", length)
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(c.r, body)
+	return body, err
+}
+
+// write sends one message in the connection's framing.
+func (c *rpcConn) write(msg any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lines {
+		_, err = fmt.Fprintf(c.w, "%s\n", body)
+	} else {
+		_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	return err
+}
+
+// rpcServer answers "search" requests for editor plugins. Searches run
+// concurrently and can be cancelled, so search-as-you-type can drop stale
+// lookups.
+type rpcServer struct {
+	conn     *rpcConn
+	apiKey   string
+	baseURL  string
+	model    string
+	effort   string
+	provider string
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+	searches sync.WaitGroup
+	shutdown bool
+}
+
+// serve handles messages until "exit" or the end of input, then waits for
+// the searches in flight.
+func (s *rpcServer) serve(ctx context.Context) error {
+	defer s.searches.Wait()
+	for {
+		body, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(body) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.fail(nil, rpcParseError, "parse error: "+err.Error())
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			s.fail(req.ID, rpcInvalidRequest, `want a JSON-RPC 2.0 request with a method`)
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		s.handle(ctx, req)
+	}
+}
+
+// handle dispatches one request or notification.
+func (s *rpcServer) handle(ctx context.Context, req rpcRequest) {
+	switch req.Method {
+	case "initialize":
+		s.reply(req.ID, map[string]any{
+			"serverInfo":   map[string]string{"name": serverName, "version": serverVersion},
+			"capabilities": map[string]any{"methods": rpcMethods},
+		})
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		s.reply(req.ID, nil)
+	case "$/cancelRequest":
+		var p struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(req.Params, &p) == nil {
+			s.mu.Lock()
+			if cancel, ok := s.inflight[string(p.ID)]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	case "search":
+		s.search(ctx, req)
+	default:
+		if req.ID != nil {
+			s.fail(req.ID, rpcMethodNotFound, "unknown method "+req.Method)
+		}
+	}
+}
+
+// search validates the params and answers in the background. A search sent
+// as a notification could not be answered, so it is ignored.
+func (s *rpcServer) search(ctx context.Context, req rpcRequest) {
+	if req.ID == nil {
+		return
+	}
+	var p rpcSearchParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		s.fail(req.ID, rpcInvalidParams, "invalid params: "+err.Error())
+		return
+	}
+	style, err := parseCitationStyle(p.CitationStyle)
+	switch {
+	case strings.TrimSpace(p.Query) == "":
+		s.fail(req.ID, rpcInvalidParams, "query is required")
+		return
+	case err != nil:
+		s.fail(req.ID, rpcInvalidParams, err.Error())
+		return
+	}
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		s.fail(req.ID, rpcInvalidRequest, "server is shutting down")
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	key := string(req.ID)
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+
+	p.Model, p.Effort = cmp.Or(p.Model, s.model), cmp.Or(p.Effort, s.effort)
+	args := p.toolArgs()
+	args["no_cache"] = p.NoCache
+	if s.provider != "" {
+		args["provider"] = s.provider
+	}
+	s.searches.Add(1)
+	go func() {
+		defer s.searches.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, key)
+			s.mu.Unlock()
+			cancel()
+		}()
+		started := time.Now()
+		result, err := HandleWebSearch(ctx, s.apiKey, s.baseURL, args)
+		switch {
+		case ctx.Err() != nil:
+			s.fail(req.ID, rpcRequestCancelled, "request cancelled")
+			return
+		case err != nil:
+			s.fail(req.ID, rpcSearchFailed, err.Error())
+			return
+		case !result.Success:
+			s.fail(req.ID, rpcSearchFailed, result.Error)
+			return
+		}
+		result = styleCitations(result, style)
+		s.reply(req.ID, rpcSearchResult{
+			Query: result.Query, Answer: result.Answer, Sources: distinctSources(result.Citations),
+			Model: result.Model, ID: result.ID, Cached: result.Cached, ElapsedMS: time.Since(started).Milliseconds(),
+		})
+	}()
+}
+
+func (s *rpcServer) reply(id json.RawMessage, result any) {
+	if id == nil {
+		return
+	}
+	if err := s.conn.write(rpcResult{JSONRPC: "2.0", ID: id, Result: result}); err != nil {
+		Warn("Failed to write RPC response", "error", err)
+	}
+}
+
+func (s *rpcServer) fail(id json.RawMessage, code int, msg string) {
+	if id == nil && code != rpcParseError {
+		return
+	}
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if err := s.conn.write(rpcErrorResponse{JSONRPC: "2.0", ID: id, Error: rpcError{Code: code, Message: msg}}); err != nil {
+		Warn("Failed to write RPC response", "error", err)
+	}
+}
+
+// runRPC implements "answer rpc": a JSON-RPC server on stdin/stdout for
+// editor plugins that want search without an MCP client.
+func runRPC(args []string) {
+	envCfg, err := loadEnvConfig()
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if err := applyRuntimeConfig(envCfg); err != nil {
+		fail(2, err.Error())
+	}
+
+	fs := flag.NewFlagSet("rpc", flag.ExitOnError)
+	baseURL := fs.String("base", defaultBaseURL, "API endpoint")
+	model := fs.String("model", cmp.Or(envCfg.Model, defaultModel), "default model for searches (env MODEL)")
+	effort := fs.String("effort", cmp.Or(envCfg.Effort, "low"), "default effort for searches (env EFFORT)")
+	providerName := fs.String("provider", envCfg.Provider, "backend provider: "+strings.Join(providerNames(), ", ")+" (env PROVIDER)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer rpc [flags]\n\nServes JSON-RPC 2.0 on stdin/stdout; methods: "+strings.Join(rpcMethods, ", "))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		fail(2, err.Error())
+	}
+	if _, err := lookupProvider(*providerName); err != nil {
+		fail(2, err.Error())
+	}
+
+	s := &rpcServer{
+		conn:     newRPCConn(os.Stdin, os.Stdout),
+		apiKey:   envCfg.APIKey,
+		baseURL:  resolveBaseURL(*baseURL),
+		model:    *model,
+		effort:   validateEffort(*effort),
+		provider: *providerName,
+		inflight: make(map[string]context.CancelFunc),
+	}
+	if err := s.serve(context.Background()); err != nil {
+		fail(1, err.Error())
+	}
+}
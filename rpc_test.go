@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRPCTestServer(t *testing.T, r io.Reader, w io.Writer) *rpcServer {
+	t.Helper()
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input any `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		if strings.Contains(fmt.Sprint(body.Input), "slow") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_rpc", "status": "completed", "model": "gpt-5.4-mini",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{
+				"type": "output_text", "text": "Use strings.Cut.",
+				"annotations": []map[string]any{
+					{"type": "url_citation", "url": "https://pkg.go.dev/strings#Cut", "title": "strings", "start_index": 0, "end_index": 15},
+				},
+			}}}},
+		})
+	})
+	return &rpcServer{
+		conn:     newRPCConn(r, w),
+		apiKey:   "k",
+		baseURL:  base,
+		model:    "gpt-5.4-mini",
+		effort:   "low",
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+// rpcReplies decodes newline-delimited replies by ID.
+func rpcReplies(t *testing.T, out string) map[string]map[string]any {
+	t.Helper()
+	replies := make(map[string]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var msg map[string]any
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("reply %q: %v", line, err)
+		}
+		replies[fmt.Sprint(msg["id"])] = msg
+	}
+	return replies
+}
+
+func TestRPCServer_Lines(t *testing.T) {
+	t.Parallel()
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"search","params":{"query":"split a string once in Go","citation_style":"none"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"search","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"hover"}`,
+		`{"jsonrpc":"2.0","method":"hover"}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":6,"method":"initialize"}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := newRPCTestServer(t, strings.NewReader(in), &out).serve(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	replies := rpcReplies(t, out.String())
+	if len(replies) != 6 {
+		t.Fatalf("got %d replies, want 6 (nothing for notifications or after exit):\n%s", len(replies), out.String())
+	}
+	if info := replies["1"]["result"].(map[string]any)["serverInfo"].(map[string]any); info["name"] != serverName {
+		t.Errorf("initialize = %v", replies["1"])
+	}
+	var search struct {
+		Result rpcSearchResult `json:"result"`
+	}
+	b, _ := json.Marshal(replies["2"]) //nolint:errcheck
+	if err := json.Unmarshal(b, &search); err != nil {
+		t.Fatal(err)
+	}
+	if r := search.Result; r.Answer != "Use strings.Cut." || r.ID != "resp_rpc" || len(r.Sources) != 1 || r.Sources[0].URL != "https://pkg.go.dev/strings#Cut" {
+		t.Errorf("search = %+v", r)
+	}
+	for id, code := range map[string]float64{"3": rpcInvalidParams, "4": rpcMethodNotFound, "<nil>": rpcParseError} {
+		if e, ok := replies[id]["error"].(map[string]any); !ok || e["code"] != code {
+			t.Errorf("reply %s = %v, want error %v", id, replies[id], code)
+		}
+	}
+	if r, ok := replies["5"]; !ok || r["result"] != nil {
+		t.Errorf("shutdown = %v", r)
+	}
+}
+
+func TestRPCServer_ContentLengthAndCancel(t *testing.T) {
+	t.Parallel()
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := newRPCTestServer(t, inR, outW)
+	done := make(chan error, 1)
+	go func() { done <- s.serve(t.Context()) }()
+
+	send := func(msg string) {
+		t.Helper()
+		if _, err := fmt.Fprintf(inW, "Content-Length: %d\r\n\r\n%s", len(msg), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reader := bufio.NewReader(outR)
+	receive := func() map[string]any {
+		t.Helper()
+		conn := newRPCConn(reader, io.Discard)
+		body, err := conn.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	send(`{"jsonrpc":"2.0","id":"a","method":"search","params":{"query":"slow lookup"}}`)
+	// Wait for the search to be in flight before cancelling it.
+	for deadline := time.Now().Add(time.Second); ; {
+		s.mu.Lock()
+		n := len(s.inflight)
+		s.mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"a"}}`)
+	if msg := receive(); msg["id"] != "a" || msg["error"].(map[string]any)["code"] != float64(rpcRequestCancelled) {
+		t.Errorf("cancelled search = %v", msg)
+	}
+
+	send(`{"jsonrpc":"2.0","id":"b","method":"search","params":{"query":"framed lookup"}}`)
+	if msg := receive(); msg["id"] != "b" || msg["result"].(map[string]any)["answer"] == "" {
+		t.Errorf("framed search = %v", msg)
+	}
+
+	inW.Close() //nolint:errcheck
+	if err := <-done; err != nil {
+		t.Errorf("serve = %v", err)
+	}
+}
+
+// endlessReader yields '{' and then 'a' forever, counting what it served.
+type endlessReader struct{ served int }
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	if r.served == 0 {
+		p[0] = '{'
+	}
+	r.served += len(p)
+	return len(p), nil
+}
+
+func TestRPCConn_LineTooLong(t *testing.T) {
+	t.Parallel()
+
+	r := &endlessReader{}
+	_, err := newRPCConn(r, io.Discard).read()
+	if err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Fatalf("read = %v, want a size error", err)
+	}
+	if r.served > 2*maxRPCMessageBytes {
+		t.Errorf("read %d bytes of an endless line, want to stop near %d", r.served, maxRPCMessageBytes)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runMetaFile describes a run inside its directory.
+const runMetaFile = "run.json"
+
+// runArtifacts collects everything one CLI invocation produced in its own
+// directory under runsDir: the request, the raw response, the answer or
+// report, fetched pages and the log. A nil *runArtifacts saves nothing, so
+// callers do not need to check whether runs are enabled.
+type runArtifacts struct {
+	dir  string
+	meta runMeta
+	log  *os.File
+}
+
+// runMeta is the run.json of a run.
+type runMeta struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Query      string    `json:"query"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	// Status is running, ok or failed.
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Files  []string `json:"files,omitempty"`
+}
+
+// activeRun is the run of this CLI invocation; set at startup when
+// RUN_ARTIFACTS is on.
+var activeRun *runArtifacts
+
+// runsDir returns where runs are kept: RUNS_DIR, or
+// $XDG_DATA_HOME/websearch/runs.
+func runsDir() (string, error) {
+	if p := os.Getenv("RUNS_DIR"); p != "" {
+		return p, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate runs: %w", err)
+	}
+	return filepath.Join(dir, "runs"), nil
+}
+
+// startRun creates the directory of a new run and starts copying the log
+// into it. kind names the command (answer, report).
+func startRun(kind, query string) (*runArtifacts, error) {
+	root, err := runsDir()
+	if err != nil {
+		return nil, err
+	}
+	var suffix [2]byte
+	_, _ = rand.Read(suffix[:]) //nolint:errcheck // never fails
+	now := time.Now()
+	id := now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:])
+	r := &runArtifacts{
+		dir:  filepath.Join(root, id),
+		meta: runMeta{ID: id, Kind: kind, Query: query, StartedAt: now, Status: "running"},
+	}
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create run dir: %w", err)
+	}
+	if r.log, err = os.OpenFile(filepath.Join(r.dir, "run.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return nil, fmt.Errorf("create run log: %w", err)
+	}
+	teeLog(r.log)
+	r.writeMeta()
+	return r, nil
+}
+
+// save writes one artifact; name may include a subdirectory. Failures are
+// logged: artifacts never break answering.
+func (r *runArtifacts) save(name string, data []byte) {
+	if r == nil {
+		return
+	}
+	path := filepath.Join(r.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		Warn("Failed to save run artifact", "name", name, "error", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		Warn("Failed to save run artifact", "name", name, "error", err)
+		return
+	}
+	if !slices.Contains(r.meta.Files, name) {
+		r.meta.Files = append(r.meta.Files, name)
+	}
+}
+
+// saveJSON writes v as indented JSON.
+func (r *runArtifacts) saveJSON(name string, v any) {
+	if r == nil {
+		return
+	}
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		Warn("Failed to save run artifact", "name", name, "error", err)
+		return
+	}
+	r.save(name, append(raw, '\n'))
+}
+
+// savePages copies the pages archived for a history entry into pages/,
+// numbered in citation order.
+func (r *runArtifacts) savePages(ctx context.Context, h *historyStore, entryID int64) {
+	if r == nil || h == nil || !h.snapshots {
+		return
+	}
+	snaps, err := h.listSnapshots(ctx, entryID)
+	if err != nil {
+		Warn("Failed to list fetched pages", "error", err)
+		return
+	}
+	for i := range snaps {
+		s, err := h.snapshot(ctx, entryID, i+1)
+		if err != nil || len(s.Body) == 0 {
+			continue
+		}
+		r.save(fmt.Sprintf("pages/%02d%s", i+1, pageExt(s.ContentType)), s.Body)
+	}
+	r.saveJSON("pages/index.json", snaps)
+}
+
+// pageExt picks a file extension for a fetched page.
+func pageExt(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType) //nolint:errcheck // empty on failure
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return ".html"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 { //nolint:errcheck
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// finish records the outcome in run.json and closes the log.
+func (r *runArtifacts) finish(err error) {
+	if r == nil || !r.meta.FinishedAt.IsZero() {
+		return
+	}
+	r.meta.FinishedAt = time.Now()
+	r.meta.Status = "ok"
+	if err != nil {
+		r.meta.Status, r.meta.Error = "failed", err.Error()
+	}
+	r.writeMeta()
+	teeLog(nil)
+	r.log.Close() //nolint:errcheck
+}
+
+func (r *runArtifacts) writeMeta() {
+	raw, _ := json.MarshalIndent(r.meta, "", "  ") //nolint:errcheck
+	if err := os.WriteFile(filepath.Join(r.dir, runMetaFile), append(raw, '\n'), 0o600); err != nil {
+		Warn("Failed to write run metadata", "error", err)
+	}
+}
+
+// listRuns returns the runs under root, newest first.
+func listRuns(root string) ([]runMeta, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list runs: %w", err)
+	}
+	var out []runMeta
+	for _, e := range slices.Backward(entries) {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := readRun(filepath.Join(root, e.Name()))
+		if err != nil {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func readRun(dir string) (runMeta, error) {
+	var m runMeta
+	raw, err := os.ReadFile(filepath.Join(dir, runMetaFile))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return m, fmt.Errorf("read %s: %w", runMetaFile, err)
+	}
+	return m, nil
+}
+
+// findRun resolves a run ID, a unique prefix of one, or "last".
+func findRun(root, id string) (string, error) {
+	runs, err := listRuns(root)
+	if err != nil {
+		return "", err
+	}
+	if id == "last" && len(runs) > 0 {
+		return runs[0].ID, nil
+	}
+	var match []string
+	for _, m := range runs {
+		if strings.HasPrefix(m.ID, id) {
+			match = append(match, m.ID)
+		}
+	}
+	switch len(match) {
+	case 0:
+		return "", fmt.Errorf("no run %q", id)
+	case 1:
+		return match[0], nil
+	default:
+		return "", fmt.Errorf("run %q is ambiguous (%d matches)", id, len(match))
+	}
+}
+
+// runRuns implements "answer runs": list runs, show one's files, or open its
+// directory.
+func runRuns(args []string) {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	limit := fs.Int("n", defaultHistoryLimit, "maximum number of runs to list")
+	asJSON := fs.Bool("json", false, "print runs as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer runs [list | show <id> | open <id> | path <id>] [-n N] [-json]")
+		fmt.Fprintln(fs.Output(), "<id> may be a unique prefix or \"last\"")
+		fs.PrintDefaults()
+	}
+	cmd := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		fail(2, err.Error())
+	}
+
+	root, err := runsDir()
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if cmd == "list" {
+		runs, err := listRuns(root)
+		if err != nil {
+			fail(2, err.Error())
+		}
+		runs = runs[:min(len(runs), max(*limit, 0))]
+		if *asJSON {
+			raw, _ := json.MarshalIndent(runs, "", "  ") //nolint:errcheck
+			fmt.Println(string(raw))
+			return
+		}
+		for _, m := range runs {
+			fmt.Printf("%s  %-6s %-7s %s\n", m.ID, m.Kind, m.Status, truncateRunes(m.Query, 60))
+		}
+		return
+	}
+
+	if len(positional) == 0 {
+		fail(2, "usage: answer runs "+cmd+" <id>")
+	}
+	id, err := findRun(root, positional[0])
+	if err != nil {
+		fail(2, err.Error())
+	}
+	dir := filepath.Join(root, id)
+	switch cmd {
+	case "show":
+		m, err := readRun(dir)
+		if err != nil {
+			fail(2, err.Error())
+		}
+		if *asJSON {
+			raw, _ := json.MarshalIndent(m, "", "  ") //nolint:errcheck
+			fmt.Println(string(raw))
+			return
+		}
+		fmt.Printf("%s  %s  %s\n  %s\n  %s\n", m.ID, m.Kind, m.Status, m.Query, dir)
+		if m.Error != "" {
+			fmt.Printf("  error: %s\n", m.Error)
+		}
+		for _, f := range m.Files {
+			fmt.Printf("  %s\n", f)
+		}
+	case "path":
+		fmt.Println(dir)
+	case "open":
+		if err := openPath(dir); err != nil {
+			fmt.Println(dir)
+			fail(2, fmt.Sprintf("could not open the run directory: %v", err))
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// openPath opens a directory with the desktop's file manager.
+func openPath(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("explorer", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return cmd.Start()
+}
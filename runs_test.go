@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunArtifacts(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("RUNS_DIR", root)
+
+	r, err := startRun("answer", "bank rate?")
+	if err != nil {
+		t.Fatalf("startRun: %v", err)
+	}
+	r.save("answer.md", []byte("4.25%\n"))
+	r.saveJSON("pages/index.json", []Snapshot{{URL: "https://example.com"}})
+	Warn("Logged during the run")
+	r.finish(nil)
+	Warn("Logged after the run")
+
+	failed, err := startRun("report", "rates")
+	if err != nil {
+		t.Fatalf("startRun: %v", err)
+	}
+	failed.finish(errors.New("upstream down"))
+
+	runs, err := listRuns(root)
+	if err != nil {
+		t.Fatalf("listRuns: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("runs = %+v", runs)
+	}
+	var ok runMeta
+	for _, m := range runs {
+		if m.Kind == "answer" {
+			ok = m
+		} else if m.Status != "failed" || m.Error != "upstream down" {
+			t.Errorf("failed run = %+v", m)
+		}
+	}
+	if ok.Status != "ok" || ok.Query != "bank rate?" || !slices.Equal(ok.Files, []string{"answer.md", "pages/index.json"}) {
+		t.Errorf("run = %+v", ok)
+	}
+	log, err := os.ReadFile(filepath.Join(root, ok.ID, "run.log"))
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if !strings.Contains(string(log), "Logged during the run") || strings.Contains(string(log), "after the run") {
+		t.Errorf("run.log = %s", log)
+	}
+
+	if id, err := findRun(root, ok.ID); err != nil || id != ok.ID {
+		t.Errorf("findRun(%s) = %q, %v", ok.ID, id, err)
+	}
+	if id, err := findRun(root, "last"); err != nil || id != runs[0].ID {
+		t.Errorf("findRun(last) = %q, %v", id, err)
+	}
+	if _, err := findRun(root, "1999"); err == nil {
+		t.Error("expected error for unknown run")
+	}
+}
+
+func TestPageExt(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"text/html; charset=utf-8": ".html",
+		"text/plain":               ".txt",
+		"application/pdf":          ".pdf",
+		"":                         ".bin",
+	}
+	for in, want := range cases {
+		if got := pageExt(in); got != want {
+			t.Errorf("pageExt(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
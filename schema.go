@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// outputSchemaName is the format name sent with a caller-supplied schema.
+const outputSchemaName = "answer"
+
+// outputSchema is a caller-supplied JSON Schema the answer must satisfy. The
+// raw document is sent upstream as text.format; the compiled form validates
+// the reply, since not every model honours the format strictly.
+type outputSchema struct {
+	raw      json.RawMessage
+	compiled *jsonschema.Schema
+}
+
+// compileOutputSchema parses and compiles a JSON Schema document.
+func compileOutputSchema(raw []byte) (*outputSchema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("decode output schema: %w", err)
+	}
+	if _, ok := doc.(map[string]any); !ok {
+		return nil, fmt.Errorf("output schema must be a JSON object")
+	}
+	const resourceURL = "mem:///websearch/output-schema.json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(resourceURL, doc); err != nil {
+		return nil, fmt.Errorf("register output schema: %w", err)
+	}
+	compiled, err := c.Compile(resourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("compile output schema: %w", err)
+	}
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, raw); err != nil {
+		return nil, fmt.Errorf("decode output schema: %w", err)
+	}
+	return &outputSchema{raw: compact.Bytes(), compiled: compiled}, nil
+}
+
+// loadOutputSchema reads and compiles the schema file given to -schema.
+func loadOutputSchema(path string) (*outputSchema, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	return compileOutputSchema(raw)
+}
+
+// validate checks that answer is JSON matching the schema. A nil schema
+// accepts anything.
+func (s *outputSchema) validate(answer string) error {
+	if s == nil {
+		return nil
+	}
+	v, err := jsonschema.UnmarshalJSON(strings.NewReader(answer))
+	if err != nil {
+		return fmt.Errorf("answer is not valid JSON: %w", err)
+	}
+	if err := s.compiled.Validate(v); err != nil {
+		return fmt.Errorf("answer does not match output schema: %w", err)
+	}
+	return nil
+}
+
+// textFormat returns the Responses API text.format for the schema.
+func (s *outputSchema) textFormat() *reqTextFormat {
+	if s == nil {
+		return nil
+	}
+	return &reqTextFormat{Type: "json_schema", Name: outputSchemaName, Schema: s.raw}
+}
+
+// rawSchema returns the schema document, or nil for a nil schema.
+func (s *outputSchema) rawSchema() json.RawMessage {
+	if s == nil {
+		return nil
+	}
+	return s.raw
+}
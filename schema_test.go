@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {"city": {"type": "string"}, "population": {"type": "integer"}},
+	"required": ["city", "population"],
+	"additionalProperties": false
+}`
+
+func TestOutputSchema_Validate(t *testing.T) {
+	t.Parallel()
+
+	s, err := compileOutputSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.validate(`{"city": "Oslo", "population": 717710}`); err != nil {
+		t.Errorf("valid answer rejected: %v", err)
+	}
+	if err := s.validate(`{"city": "Oslo"}`); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("missing field: got %v", err)
+	}
+	if err := s.validate("Oslo has 717,710 people."); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("prose answer: got %v", err)
+	}
+
+	var nilSchema *outputSchema
+	if err := nilSchema.validate("anything"); err != nil {
+		t.Errorf("nil schema should accept anything, got %v", err)
+	}
+
+	for _, bad := range []string{`[1, 2]`, `{"type": 7}`, `not json`} {
+		if _, err := compileOutputSchema([]byte(bad)); err == nil {
+			t.Errorf("compileOutputSchema(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRunWebSearch_OutputSchema(t *testing.T) {
+	t.Parallel()
+
+	answer := `{"city": "Oslo", "population": "lots"}`
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		f := body.Text.Format
+		if f == nil || f.Type != "json_schema" || f.Name != outputSchemaName || !strings.Contains(string(f.Schema), `"population"`) {
+			t.Errorf("text.format = %+v", f)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": answer}}}},
+		})
+	})
+
+	s, err := compileOutputSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wa := extractWebSearchArgs(map[string]any{"query": "Oslo population", "output_schema": s, "reasoning_effort": "low"})
+	result, err := runWebSearch(context.Background(), "k", base, wa)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || result.Answer != answer || !strings.Contains(result.Error, "does not match output schema") {
+		t.Errorf("result = %+v, want schema mismatch with answer kept", result)
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sealMagic starts every encrypted store file.
+	sealMagic = "WSSEAL1\n"
+	// sealSaltSize is the per-file salt for passphrase keys.
+	sealSaltSize = 16
+	// sealKDFIterations is the PBKDF2-SHA256 work factor for passphrases.
+	sealKDFIterations = 600_000
+	// keychainService and keychainAccount name the store key in the OS
+	// keychain.
+	keychainService = "websearch"
+	keychainAccount = "store-key"
+	// keychainTimeout bounds each keychain helper invocation.
+	keychainTimeout = 10 * time.Second
+)
+
+// storeSealer encrypts local stores at rest with AES-256-GCM. The key is
+// either derived from a passphrase (env STORE_PASSPHRASE) with PBKDF2 and a
+// per-file salt, or a random key kept in the OS keychain (env
+// STORE_KEYCHAIN).
+//
+// A nil *storeSealer leaves stores in plain text and refuses to open
+// encrypted ones.
+type storeSealer struct {
+	passphrase string
+	key        []byte // keychain key; nil for passphrases
+
+	mu   sync.Mutex
+	keys map[string][]byte // derived passphrase keys by salt
+	salt []byte            // salt for files sealed by this process
+}
+
+// activeSealer encrypts the history database and the on-disk answer cache;
+// set at startup from STORE_PASSPHRASE or STORE_KEYCHAIN.
+var activeSealer *storeSealer
+
+// newStoreSealer returns the sealer for the configured key source, or nil
+// when neither is set.
+func newStoreSealer(passphrase string, keychain bool) (*storeSealer, error) {
+	switch {
+	case passphrase != "" && keychain:
+		return nil, errors.New("set either STORE_PASSPHRASE or STORE_KEYCHAIN, not both")
+	case passphrase != "":
+		return &storeSealer{passphrase: passphrase, keys: make(map[string][]byte)}, nil
+	case keychain:
+		key, err := keychainStoreKey()
+		if err != nil {
+			return nil, fmt.Errorf("STORE_KEYCHAIN: %w", err)
+		}
+		return &storeSealer{key: key}, nil
+	}
+	return nil, nil
+}
+
+// storeSealerFromEnv is newStoreSealer for subcommands that read the
+// environment directly.
+func storeSealerFromEnv() (*storeSealer, error) {
+	return newStoreSealer(os.Getenv("STORE_PASSPHRASE"), envBool("STORE_KEYCHAIN"))
+}
+
+// isSealed reports whether data was written by seal.
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealMagic))
+}
+
+// keyFor returns the AES key for files sealed with salt.
+func (s *storeSealer) keyFor(salt []byte) ([]byte, error) {
+	if s.key != nil {
+		return s.key, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, s.passphrase, salt, sealKDFIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	s.keys[string(salt)] = key
+	return key, nil
+}
+
+// aead builds the cipher for salt.
+func (s *storeSealer) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := s.keyFor(salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain as magic | salt | nonce | ciphertext; the header is
+// authenticated too. A nil sealer returns plain unchanged.
+func (s *storeSealer) seal(plain []byte) ([]byte, error) {
+	if s == nil {
+		return plain, nil
+	}
+	s.mu.Lock()
+	if s.salt == nil {
+		s.salt = make([]byte, sealSaltSize)
+		_, _ = rand.Read(s.salt) //nolint:errcheck // never fails
+	}
+	salt := s.salt
+	s.mu.Unlock()
+
+	gcm, err := s.aead(salt)
+	if err != nil {
+		return nil, fmt.Errorf("seal store: %w", err)
+	}
+	header := append([]byte(sealMagic), salt...)
+	nonce := make([]byte, gcm.NonceSize())
+	_, _ = rand.Read(nonce) //nolint:errcheck // never fails
+	out := make([]byte, 0, len(header)+len(nonce)+len(plain)+gcm.Overhead())
+	out = append(append(out, header...), nonce...)
+	return gcm.Seal(out, nonce, plain, header), nil
+}
+
+// open decrypts data written by seal. Data that was never sealed is
+// returned unchanged, so plain stores are encrypted on their next write.
+func (s *storeSealer) open(data []byte) ([]byte, error) {
+	if !isSealed(data) {
+		return data, nil
+	}
+	if s == nil {
+		return nil, ErrStoreLocked
+	}
+	headerLen := len(sealMagic) + sealSaltSize
+	if len(data) < headerLen {
+		return nil, ErrStoreKey
+	}
+	header, salt := data[:headerLen], data[len(sealMagic):headerLen]
+	gcm, err := s.aead(salt)
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+	rest := data[headerLen:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, ErrStoreKey
+	}
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, ErrStoreKey
+	}
+	return plain, nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name()) //nolint:errcheck
+	}
+	return err
+}
+
+// keychainStoreKey reads the store key from the OS keychain, creating a
+// random one on first use. It uses the security tool on macOS and
+// secret-tool (libsecret) elsewhere.
+func keychainStoreKey() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keychainTimeout)
+	defer cancel()
+
+	var lookup, store *exec.Cmd
+	key := make([]byte, 32)
+	_, _ = rand.Read(key) //nolint:errcheck // never fails
+	fresh := hex.EncodeToString(key)
+	switch runtime.GOOS {
+	case "darwin":
+		lookup = exec.CommandContext(ctx, "security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+		store = exec.CommandContext(ctx, "security", "add-generic-password", "-s", keychainService, "-a", keychainAccount, "-w", fresh)
+	case "windows":
+		return nil, errors.New("no supported keychain on Windows; use STORE_PASSPHRASE")
+	default:
+		lookup = exec.CommandContext(ctx, "secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+		store = exec.CommandContext(ctx, "secret-tool", "store", "--label=websearch store key", "service", keychainService, "account", keychainAccount)
+		store.Stdin = strings.NewReader(fresh)
+	}
+
+	if out, err := lookup.Output(); err == nil {
+		stored, err := hex.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil || len(stored) != 32 {
+			return nil, errors.New("keychain entry is not a store key")
+		}
+		return stored, nil
+	} else if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s not found; install it or use STORE_PASSPHRASE", lookup.Path)
+	}
+	store.Stdout, store.Stderr = io.Discard, io.Discard
+	if err := store.Run(); err != nil {
+		return nil, fmt.Errorf("save key in keychain: %w", err)
+	}
+	Info("Created store key in the OS keychain", "service", keychainService, "account", keychainAccount)
+	return key, nil
+}
+
+// fileIsSealed reports whether the file at path was written by seal; a
+// missing file is not.
+func fileIsSealed(path string) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close() //nolint:errcheck
+	head := make([]byte, len(sealMagic))
+	n, _ := io.ReadFull(f, head) //nolint:errcheck // short files are not sealed
+	return isSealed(head[:n]), nil
+}
+
+// sealedHistoryTables are the history tables an encrypted database keeps,
+// parents first; the full-text index is rebuilt from entries on load.
+var sealedHistoryTables = []struct{ name, schema string }{
+	{"entries", historySchema},
+	{"snapshots", historySnapshotsSchema},
+	{"embeddings", historyEmbeddingsSchema},
+}
+
+// historyDump is the plain form of an encrypted history: the rows of each
+// table, gob-encoded before sealing.
+type historyDump struct {
+	Tables []historyTable
+}
+
+type historyTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]any
+}
+
+// openSealedHistory loads the encrypted history at path into an in-memory
+// database, so the plain text never touches the disk. A plain database
+// found at path is encrypted in place. Changes are written back through
+// write, which keeps concurrent processes from overwriting each other.
+func openSealedHistory(path string, s *storeSealer) (*historyStore, error) {
+	db, err := openHistoryDB(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	h := &historyStore{db: db, now: time.Now, sealer: s, path: path}
+	err = h.withFileLock(func() error {
+		raw, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("open history: %w", err)
+		}
+		if err := h.load(raw); err != nil {
+			return err
+		}
+		if len(raw) > 0 && !isSealed(raw) {
+			if err := h.save(); err != nil {
+				return err
+			}
+			Info("Encrypted the history database", "path", path)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() //nolint:errcheck
+		return nil, err
+	}
+	return h, nil
+}
+
+// withFileLock runs fn holding this process's and an exclusive file lock
+// on the encrypted history, so one writer at a time reads, changes and
+// rewrites it.
+func (h *historyStore) withFileLock(fn func() error) error {
+	h.persistMu.Lock()
+	defer h.persistMu.Unlock()
+	unlock, err := lockFile(h.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("lock history: %w", err)
+	}
+	defer unlock()
+	return fn()
+}
+
+// write applies fn's change to the database. An encrypted history lives in
+// memory while another process, a CLI run next to a server, may save its
+// own entries: under the file lock, a file changed since this process last
+// synced is reloaded first, then fn runs and the result is written back.
+// Plain databases leave this to SQLite.
+func (h *historyStore) write(fn func() error) error {
+	if h == nil {
+		return nil
+	}
+	if h.sealer == nil {
+		return fn()
+	}
+	return h.withFileLock(func() error {
+		raw, err := os.ReadFile(h.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("reload history: %w", err)
+		}
+		if sha256.Sum256(raw) != h.synced {
+			if err := h.load(raw); err != nil {
+				return err
+			}
+		}
+		err = fn()
+		if err == nil {
+			err = h.save()
+		}
+		if err != nil {
+			// Memory may hold half of the change: reload before the next one.
+			h.synced = [32]byte{}
+		}
+		return err
+	})
+}
+
+// load replaces the in-memory rows with those of the history file raw,
+// encrypted or a plain database, and marks it synced.
+func (h *historyStore) load(raw []byte) error {
+	var dump historyDump
+	switch {
+	case len(raw) == 0:
+	case isSealed(raw):
+		plain, err := h.sealer.open(raw)
+		if err != nil {
+			return fmt.Errorf("open history: %w", err)
+		}
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&dump); err != nil {
+			return fmt.Errorf("open history: %w", err)
+		}
+	default:
+		db, err := openHistoryDB(h.path)
+		if err != nil {
+			return err
+		}
+		dump, err = dumpHistory(db)
+		db.Close() //nolint:errcheck
+		if err != nil {
+			return err
+		}
+	}
+	if err := restoreHistory(h.db, dump); err != nil {
+		return err
+	}
+	h.synced = sha256.Sum256(raw)
+	return nil
+}
+
+// dumpHistory reads every row of the sealed tables present in db.
+func dumpHistory(db *sql.DB) (historyDump, error) {
+	var dump historyDump
+	for _, t := range sealedHistoryTables {
+		var exists bool
+		if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?`, t.name).Scan(&exists); err != nil {
+			return dump, fmt.Errorf("dump history: %w", err)
+		}
+		if !exists {
+			continue
+		}
+		rows, err := db.Query(`SELECT * FROM ` + t.name)
+		if err != nil {
+			return dump, fmt.Errorf("dump history: %w", err)
+		}
+		table := historyTable{Name: t.name}
+		if table.Columns, err = rows.Columns(); err != nil {
+			rows.Close() //nolint:errcheck
+			return dump, fmt.Errorf("dump history: %w", err)
+		}
+		for rows.Next() {
+			row := make([]any, len(table.Columns))
+			ptrs := make([]any, len(row))
+			for i := range row {
+				ptrs[i] = &row[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close() //nolint:errcheck
+				return dump, fmt.Errorf("dump history: %w", err)
+			}
+			table.Rows = append(table.Rows, row)
+		}
+		rows.Close() //nolint:errcheck
+		if err := rows.Err(); err != nil {
+			return dump, fmt.Errorf("dump history: %w", err)
+		}
+		dump.Tables = append(dump.Tables, table)
+	}
+	return dump, nil
+}
+
+// restoreHistory replaces the rows of db with the dumped ones; the
+// full-text index follows through the entries triggers.
+func restoreHistory(db *sql.DB, dump historyDump) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("load history: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+	for _, t := range slices.Backward(sealedHistoryTables) {
+		var exists bool
+		if err := tx.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?`, t.name).Scan(&exists); err != nil {
+			return fmt.Errorf("load history: %w", err)
+		}
+		if exists {
+			if _, err := tx.Exec(`DELETE FROM ` + t.name); err != nil {
+				return fmt.Errorf("load history: %w", err)
+			}
+		}
+	}
+	for _, table := range dump.Tables {
+		i := slices.IndexFunc(sealedHistoryTables, func(t struct{ name, schema string }) bool { return t.name == table.Name })
+		if i < 0 {
+			return fmt.Errorf("load history: unknown table %q", table.Name)
+		}
+		if _, err := tx.Exec(sealedHistoryTables[i].schema); err != nil {
+			return fmt.Errorf("load history: %w", err)
+		}
+		stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %s (%s) VALUES (?%s)`,
+			table.Name, strings.Join(table.Columns, ", "), strings.Repeat(", ?", len(table.Columns)-1)))
+		if err != nil {
+			return fmt.Errorf("load history: %w", err)
+		}
+		for _, row := range table.Rows {
+			if _, err := stmt.Exec(row...); err != nil {
+				stmt.Close() //nolint:errcheck
+				return fmt.Errorf("load history: %w", err)
+			}
+		}
+		stmt.Close() //nolint:errcheck
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("load history: %w", err)
+	}
+	return nil
+}
+
+// save writes the in-memory history to its file, encrypted, and marks it
+// synced. Callers hold the file lock.
+func (h *historyStore) save() error {
+	dump, err := dumpHistory(h.db)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(dump); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	sealed, err := h.sealer.seal(buf.Bytes())
+	if err == nil {
+		err = writeFileAtomic(h.path, sealed, 0o600)
+	}
+	if err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	h.synced = sha256.Sum256(sealed)
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreSealer_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	s, err := newStoreSealer("correct horse", false)
+	if err != nil {
+		t.Fatalf("newStoreSealer: %v", err)
+	}
+	sealed, err := s.seal([]byte("bank rate?"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if !isSealed(sealed) || bytes.Contains(sealed, []byte("bank rate")) {
+		t.Fatalf("sealed data = %q", sealed)
+	}
+	if plain, err := s.open(sealed); err != nil || string(plain) != "bank rate?" {
+		t.Errorf("open = %q, %v", plain, err)
+	}
+
+	wrong, _ := newStoreSealer("wrong", false) //nolint:errcheck
+	if _, err := wrong.open(sealed); !errors.Is(err, ErrStoreKey) {
+		t.Errorf("wrong passphrase: err = %v, want ErrStoreKey", err)
+	}
+	var none *storeSealer
+	if _, err := none.open(sealed); !errors.Is(err, ErrStoreLocked) {
+		t.Errorf("no key: err = %v, want ErrStoreLocked", err)
+	}
+	if plain, err := s.open([]byte("plain")); err != nil || string(plain) != "plain" {
+		t.Errorf("plain data = %q, %v; want it unchanged", plain, err)
+	}
+	if _, err := newStoreSealer("p", true); err == nil {
+		t.Error("expected error for passphrase and keychain together")
+	}
+}
+
+func TestHistory_Encrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	ctx := context.Background()
+
+	// A plain database is encrypted once a key is configured.
+	h, err := openHistory(path)
+	if err != nil {
+		t.Fatalf("openHistory: %v", err)
+	}
+	if _, err := h.record(ctx, HistoryEntry{Query: "plain question", Answer: "a"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	h.Close() //nolint:errcheck
+
+	activeSealer, _ = newStoreSealer("correct horse", false) //nolint:errcheck
+	t.Cleanup(func() { activeSealer = nil })
+	if h, err = openHistory(path); err != nil {
+		t.Fatalf("openHistory encrypted: %v", err)
+	}
+	if _, err := h.record(ctx, HistoryEntry{Query: "secret question", Answer: "b"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSealed(raw) || bytes.Contains(raw, []byte("question")) {
+		t.Fatal("history file is not encrypted after a write")
+	}
+	h.Close() //nolint:errcheck
+
+	if h, err = openHistory(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	found, err := h.search(ctx, "question", "", 10)
+	if err != nil || len(found) != 2 {
+		t.Errorf("search after reopen = %+v, %v", found, err)
+	}
+	h.Close() //nolint:errcheck
+
+	activeSealer = nil
+	if _, err := openHistory(path); !errors.Is(err, ErrStoreLocked) {
+		t.Errorf("open without key: err = %v, want ErrStoreLocked", err)
+	}
+}
+
+func TestHistory_EncryptedConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	ctx := context.Background()
+	activeSealer, _ = newStoreSealer("correct horse", false) //nolint:errcheck
+	t.Cleanup(func() { activeSealer = nil })
+
+	// Two processes open the same file; each write must keep the other's.
+	a, err := openHistory(path)
+	if err != nil {
+		t.Fatalf("openHistory: %v", err)
+	}
+	defer a.Close() //nolint:errcheck
+	b, err := openHistory(path)
+	if err != nil {
+		t.Fatalf("openHistory: %v", err)
+	}
+	defer b.Close() //nolint:errcheck
+	idA, err := a.record(ctx, HistoryEntry{Query: "first question", Answer: "a"})
+	if err != nil {
+		t.Fatalf("record a: %v", err)
+	}
+	idB, err := b.record(ctx, HistoryEntry{Query: "second question", Answer: "b"})
+	if err != nil {
+		t.Fatalf("record b: %v", err)
+	}
+	if idA == idB {
+		t.Errorf("both writers got entry id %d", idA)
+	}
+	if _, err := a.tag(ctx, idB, []string{"shared"}, false); err != nil {
+		t.Fatalf("tag from a: %v", err)
+	}
+
+	c, err := openHistory(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer c.Close() //nolint:errcheck
+	found, err := c.search(ctx, "question", "", 10)
+	if err != nil || len(found) != 2 {
+		t.Fatalf("search after reopen = %+v, %v", found, err)
+	}
+	tagged, err := c.list(ctx, "shared", 10)
+	if err != nil || len(tagged) != 1 || tagged[0].ID != idB {
+		t.Errorf("tagged = %+v, %v", tagged, err)
+	}
+}
+
+func TestResponseCache_Sealed(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s, _ := newStoreSealer("correct horse", false) //nolint:errcheck
+	c := newResponseCache(time.Hour, 0)
+	if err := c.useDisk(dir, s); err != nil {
+		t.Fatalf("useDisk: %v", err)
+	}
+	c.set("k", WebSearchResult{Success: true, Answer: "secret answer"})
+	raw, err := os.ReadFile(filepath.Join(dir, "k.json"))
+	if err != nil || !isSealed(raw) || bytes.Contains(raw, []byte("secret")) {
+		t.Fatalf("cache file not encrypted: %v", err)
+	}
+
+	reopened := newResponseCache(time.Hour, 0)
+	reopened.useDisk(dir, s) //nolint:errcheck
+	if got, state := reopened.get("k"); state != cacheFresh || got.Answer != "secret answer" {
+		t.Errorf("get = %+v, %v", got, state)
+	}
+	locked := newResponseCache(time.Hour, 0)
+	locked.useDisk(dir, nil) //nolint:errcheck
+	if _, state := locked.get("k"); state != cacheMiss {
+		t.Errorf("get without key = %v, want miss", state)
+	}
+}
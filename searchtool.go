@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Web search tool types of the Responses API. web_search_preview is being
+// retired in favour of web_search, which also takes domain filters.
+const (
+	toolWebSearch        = "web_search"
+	toolWebSearchPreview = "web_search_preview"
+)
+
+// searchToolTypes picks the web search tool type sent with each model (env
+// WEB_SEARCH_TOOL_TYPE for the default, WEB_SEARCH_TOOL_MODELS for
+// per-model overrides), so a model that only knows one type keeps working
+// as the API moves on.
+type searchToolTypes struct {
+	def string
+	// byModel maps a model name, or a prefix ending in "*", onto a type.
+	byModel map[string]string
+}
+
+// webSearchTools holds the tool type settings; set at startup.
+var webSearchTools = searchToolTypes{def: toolWebSearch}
+
+// parseSearchToolTypes reads a default type and a comma-separated list of
+// model=type overrides, e.g. "gpt-4o*=web_search_preview".
+func parseSearchToolTypes(def, models string) (searchToolTypes, error) {
+	t := searchToolTypes{def: toolWebSearch}
+	if def = strings.TrimSpace(def); def != "" {
+		if err := validateSearchToolType(def); err != nil {
+			return t, fmt.Errorf("WEB_SEARCH_TOOL_TYPE: %w", err)
+		}
+		t.def = def
+	}
+	for _, entry := range strings.Split(models, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		model, typ, ok := strings.Cut(entry, "=")
+		model, typ = strings.TrimSpace(model), strings.TrimSpace(typ)
+		if !ok || model == "" {
+			return t, fmt.Errorf("WEB_SEARCH_TOOL_MODELS: %q is not model=type", entry)
+		}
+		if err := validateSearchToolType(typ); err != nil {
+			return t, fmt.Errorf("WEB_SEARCH_TOOL_MODELS: %w", err)
+		}
+		if t.byModel == nil {
+			t.byModel = make(map[string]string)
+		}
+		t.byModel[model] = typ
+	}
+	return t, nil
+}
+
+func validateSearchToolType(typ string) error {
+	if typ != toolWebSearch && typ != toolWebSearchPreview {
+		return fmt.Errorf("unknown tool type %q (want %s or %s)", typ, toolWebSearch, toolWebSearchPreview)
+	}
+	return nil
+}
+
+// typeFor returns the tool type for model: an exact override, else the
+// longest matching prefix override, else the default.
+func (t searchToolTypes) typeFor(model string) string {
+	if typ, ok := t.byModel[model]; ok {
+		return typ
+	}
+	typ, longest := t.def, -1
+	for pattern, v := range t.byModel {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			typ, longest = v, len(prefix)
+		}
+	}
+	if typ == "" {
+		return toolWebSearch
+	}
+	return typ
+}
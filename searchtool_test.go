@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestSearchToolTypes(t *testing.T) {
+	t.Parallel()
+
+	tools, err := parseSearchToolTypes("", "gpt-4o*=web_search_preview, gpt-4o-mini-search=web_search, gpt-4*=web_search_preview")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for model, want := range map[string]string{
+		"gpt-5.4-mini":       toolWebSearch,
+		"gpt-4o":             toolWebSearchPreview,
+		"gpt-4o-mini-search": toolWebSearch,
+		"gpt-4.1":            toolWebSearchPreview,
+	} {
+		if got := tools.typeFor(model); got != want {
+			t.Errorf("typeFor(%q) = %q, want %q", model, got, want)
+		}
+	}
+
+	tools, err = parseSearchToolTypes("web_search_preview", "")
+	if err != nil || tools.typeFor("gpt-5") != toolWebSearchPreview {
+		t.Errorf("default override = %+v, %v", tools, err)
+	}
+	if got := (searchToolTypes{}).typeFor("gpt-5"); got != toolWebSearch {
+		t.Errorf("zero value typeFor = %q, want %q", got, toolWebSearch)
+	}
+
+	for _, bad := range [][2]string{{"web_search_2", ""}, {"", "gpt-5"}, {"", "gpt-5=search"}, {"", "=web_search"}} {
+		if _, err := parseSearchToolTypes(bad[0], bad[1]); err == nil {
+			t.Errorf("parseSearchToolTypes(%q, %q): expected an error", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSemanticThreshold = 0.92
+	defaultEmbeddingModel    = "text-embedding-3-small"
+	semanticCacheMaxEntries  = 1000
+	localEmbeddingDims       = 512
+	embeddingTimeout         = 20 * time.Second
+)
+
+// embedFunc turns text into a vector. Implementations must be safe for
+// concurrent use.
+type embedFunc func(ctx context.Context, apiKey, text string) ([]float64, error)
+
+// semanticEntry is a cached answer together with the embedding of its query.
+type semanticEntry struct {
+	scope    string
+	vector   []float64
+	result   WebSearchResult
+	storedAt time.Time
+}
+
+// semanticCache returns cached answers for near-duplicate questions. Entries
+// only match within the same scope (model, effort, verbosity, web_search), so
+// a cheap answer is never served for a request asking for deeper reasoning.
+// A nil *semanticCache is valid and behaves as a disabled cache.
+type semanticCache struct {
+	mu        sync.Mutex
+	embed     embedFunc
+	threshold float64
+	ttl       time.Duration
+	entries   []semanticEntry
+	now       func() time.Time
+}
+
+// querySemanticCache is the process-wide semantic cache used by
+// HandleWebSearch; nil unless SEMANTIC_CACHE is configured.
+var querySemanticCache *semanticCache
+
+// newSemanticCache builds a semantic cache using the given embedder. It
+// returns nil when ttl is not positive or no embedder is provided.
+func newSemanticCache(embed embedFunc, threshold float64, ttl time.Duration) *semanticCache {
+	if embed == nil || ttl <= 0 {
+		return nil
+	}
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultSemanticThreshold
+	}
+	return &semanticCache{
+		embed:     embed,
+		threshold: threshold,
+		ttl:       ttl,
+		now:       time.Now,
+	}
+}
+
+// newEmbedder resolves the SEMANTIC_CACHE mode to an embedder: "local" uses a
+// dependency-free hashed bag-of-words vector, "openai" calls the embeddings
+// endpoint next to the configured Responses endpoint.
+func newEmbedder(mode, baseURL, model string) (embedFunc, error) {
+	switch strings.ToLower(mode) {
+	case "":
+		return nil, nil
+	case "local":
+		return localEmbed, nil
+	case "openai", "api":
+		if model == "" {
+			model = defaultEmbeddingModel
+		}
+		url := embeddingsURL(baseURL)
+		return func(ctx context.Context, apiKey, text string) ([]float64, error) {
+			return openAIEmbed(ctx, url, apiKey, model, text)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown SEMANTIC_CACHE mode %q (use local or openai)", mode)
+	}
+}
+
+// lookup embeds the query and returns the best cached answer in scope whose
+// cosine similarity meets the threshold, along with that similarity.
+func (c *semanticCache) lookup(ctx context.Context, apiKey, scope, query string) (WebSearchResult, float64, []float64, bool) {
+	if c == nil || scope == "" {
+		return WebSearchResult{}, 0, nil, false
+	}
+	vec, err := c.embed(ctx, apiKey, query)
+	if err != nil {
+		Warn("Semantic cache embedding failed", "error", err)
+		return WebSearchResult{}, 0, nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	best, bestScore := -1, 0.0
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if now.Sub(e.storedAt) >= c.ttl {
+			continue
+		}
+		kept = append(kept, e)
+		if e.scope != scope {
+			continue
+		}
+		if score := cosineSimilarity(vec, e.vector); score >= c.threshold && score > bestScore {
+			best, bestScore = len(kept)-1, score
+		}
+	}
+	c.entries = kept
+	if best < 0 {
+		return WebSearchResult{}, 0, vec, false
+	}
+	return c.entries[best].result, bestScore, vec, true
+}
+
+// store records a successful answer. vec may be the embedding computed during
+// lookup; when nil the query is embedded again.
+func (c *semanticCache) store(ctx context.Context, apiKey, scope, query string, vec []float64, result WebSearchResult) {
+	if c == nil || scope == "" || !result.Success {
+		return
+	}
+	if vec == nil {
+		var err error
+		if vec, err = c.embed(ctx, apiKey, query); err != nil {
+			Warn("Semantic cache embedding failed", "error", err)
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= semanticCacheMaxEntries {
+		c.entries = c.entries[1:]
+	}
+	c.entries = append(c.entries, semanticEntry{scope: scope, vector: vec, result: result, storedAt: c.now()})
+}
+
+// semanticScopeFor is the part of the cache key that must match exactly for
+// a semantic hit. Chained requests and conversations are never served from
+// the semantic cache.
+func semanticScopeFor(wa webSearchArgs) string {
+	if wa.previousResponseID != "" || len(wa.messages) > 0 {
+		return ""
+	}
+	scoped := wa
+	scoped.query = ""
+	return cacheKeyFor(scoped)
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// localEmbed hashes lower-cased word unigrams and bigrams into a fixed-size
+// vector. It only catches rephrasings that share most of their words, but
+// needs no network access or extra dependencies.
+func localEmbed(_ context.Context, _ string, text string) ([]float64, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	vec := make([]float64, localEmbeddingDims)
+	add := func(token string, weight float64) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(token))
+		vec[h.Sum32()%localEmbeddingDims] += weight
+	}
+	for i, w := range words {
+		add(w, 1)
+		if i > 0 {
+			add(words[i-1]+" "+w, 0.5)
+		}
+	}
+	return vec, nil
+}
+
+// embeddingsURL derives the embeddings endpoint from a Responses endpoint,
+// e.g. https://api.openai.com/v1/responses -> https://api.openai.com/v1/embeddings.
+func embeddingsURL(baseURL string) string {
+	if strings.HasSuffix(baseURL, "/responses") {
+		return strings.TrimSuffix(baseURL, "/responses") + "/embeddings"
+	}
+	return strings.TrimRight(baseURL, "/") + "/embeddings"
+}
+
+func openAIEmbed(ctx context.Context, url, apiKey, model, text string) ([]float64, error) {
+	if offlineMode {
+		return nil, ErrOffline
+	}
+	buf, err := json.Marshal(map[string]string{"model": model, "input": text})
+	if err != nil {
+		return nil, fmt.Errorf("marshal embedding request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("build embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	applyOutboundHeaders(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("read embedding response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var out struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("parse embedding json: %w", err)
+	}
+	if len(out.Data) == 0 {
+		return nil, fmt.Errorf("embedding response contained no data")
+	}
+	return out.Data[0].Embedding, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSemanticCache_LookupWithinScope(t *testing.T) {
+	t.Parallel()
+
+	c := newSemanticCache(localEmbed, 0.8, time.Hour)
+	ctx := context.Background()
+	wa := webSearchArgs{query: "What is the capital of France?", model: "m", effort: "low", verbosity: "low", useWebSearch: true}
+	scope := semanticScopeFor(wa)
+
+	c.store(ctx, "", scope, wa.query, nil, WebSearchResult{Success: true, Answer: "Paris", Query: wa.query})
+
+	res, score, _, ok := c.lookup(ctx, "", scope, "what is the capital of france")
+	if !ok {
+		t.Fatalf("expected semantic hit, score=%v", score)
+	}
+	if res.Answer != "Paris" || score < 0.8 {
+		t.Errorf("got answer %q score %v", res.Answer, score)
+	}
+
+	if _, _, _, ok := c.lookup(ctx, "", scope, "best pizza in Naples"); ok {
+		t.Error("unrelated query must not hit")
+	}
+
+	other := wa
+	other.effort = "high"
+	if _, _, _, ok := c.lookup(ctx, "", semanticScopeFor(other), wa.query); ok {
+		t.Error("different scope must not hit")
+	}
+}
+
+func TestSemanticCache_ExpiresEntries(t *testing.T) {
+	t.Parallel()
+
+	c := newSemanticCache(localEmbed, 0.5, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c.store(ctx, "", "s", "hello world", nil, WebSearchResult{Success: true, Answer: "hi"})
+	now = now.Add(2 * time.Minute)
+	if _, _, _, ok := c.lookup(ctx, "", "s", "hello world"); ok {
+		t.Error("expired entry must not hit")
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("expired entries not pruned: %d left", len(c.entries))
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "emb-model" || body["input"] != "hello" {
+			t.Errorf("unexpected body %v", body)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"data": []map[string]any{{"embedding": []float64{0.1, 0.2, 0.3}}},
+		})
+	})
+
+	embed, err := newEmbedder("openai", base+"/v1/responses", "emb-model")
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	vec, err := embed(context.Background(), "k", "hello")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if len(vec) != 3 {
+		t.Errorf("vector length = %d, want 3", len(vec))
+	}
+
+	if _, err := newEmbedder("bogus", base, ""); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
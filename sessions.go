@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sessionNamePattern keeps session names usable as file names.
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// session is a named research thread: the chain of answers asked with
+// -session NAME, each a follow-up to the one before.
+type session struct {
+	Name     string        `json:"name"`
+	Provider string        `json:"provider"`
+	Created  time.Time     `json:"created"`
+	Updated  time.Time     `json:"updated"`
+	Turns    []sessionTurn `json:"turns"`
+}
+
+// sessionTurn is one answer in a session.
+type sessionTurn struct {
+	ResponseID string    `json:"response_id"`
+	Query      string    `json:"query"`
+	Model      string    `json:"model,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// sessionsDir returns SESSIONS_DIR, or sessions in the data directory. Each
+// session is a file of its own, so threads advanced from different
+// terminals do not overwrite each other.
+func sessionsDir() (string, error) {
+	if p := os.Getenv("SESSIONS_DIR"); p != "" {
+		return p, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", fmt.Errorf("locate sessions: %w", err)
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+func sessionPath(name string) (string, error) {
+	if !sessionNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// withSessionsLock runs fn holding an exclusive lock on the sessions
+// directory, so a CLI run and a purge do not overwrite each other's
+// changes to a session.
+func withSessionsLock(fn func() error) error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(dir, ".lock"))
+	if err != nil {
+		return fmt.Errorf("lock sessions: %w", err)
+	}
+	defer unlock()
+	return fn()
+}
+
+// loadSession returns the named session, or ErrSessionNotFound. Sessions are
+// sealed with activeSealer like the history.
+func loadSession(name string) (*session, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+	if raw, err = activeSealer.open(raw); err != nil {
+		return nil, fmt.Errorf("open session %s: %w", name, err)
+	}
+	var s session
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("parse session %s: %w", name, err)
+	}
+	return &s, nil
+}
+
+func (s *session) save() error {
+	path, err := sessionPath(s.Name)
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		raw, err = activeSealer.seal(raw)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create sessions dir: %w", err)
+	}
+	return writeFileAtomic(path, raw, 0o600)
+}
+
+// last returns the session's latest turn; nil for a new session.
+func (s *session) last() *sessionTurn {
+	if s == nil || len(s.Turns) == 0 {
+		return nil
+	}
+	return &s.Turns[len(s.Turns)-1]
+}
+
+// sessionContinuation resolves -session: the response ID the next question
+// follows up on, empty when the session is new. The session's answers must
+// be stored by a provider that chains responses.
+func sessionContinuation(name, provider string, noStore bool) (string, error) {
+	if _, err := sessionPath(name); err != nil {
+		return "", err
+	}
+	if !chainsResponses(provider) || noStore {
+		return "", fmt.Errorf("-session needs stored responses from openai or azure (provider %s, no-store %t)", provider, noStore)
+	}
+	s, err := loadSession(name)
+	if errors.Is(err, ErrSessionNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if s.Provider != provider {
+		return "", fmt.Errorf("session %s is on %s, not %s", name, s.Provider, provider)
+	}
+	return s.last().ResponseID, nil
+}
+
+// appendSessionTurn adds an answer to the named session, creating it on the
+// first answer.
+func appendSessionTurn(name, provider string, turn sessionTurn) error {
+	if _, err := sessionPath(name); err != nil {
+		return err
+	}
+	return withSessionsLock(func() error {
+		s, err := loadSession(name)
+		if errors.Is(err, ErrSessionNotFound) {
+			s, err = &session{Name: name, Provider: provider, Created: turn.Time}, nil
+		}
+		if err != nil {
+			return err
+		}
+		s.Turns = append(s.Turns, turn)
+		s.Updated = turn.Time
+		return s.save()
+	})
+}
+
+// listSessions returns every session, most recently updated first.
+func listSessions() ([]*session, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*session
+	for _, f := range files {
+		s, err := loadSession(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			Warn("Skipping unreadable session", "file", f, "error", err)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Updated.After(sessions[j].Updated) })
+	return sessions, nil
+}
+
+// deleteSession removes the named session.
+func deleteSession(name string) error {
+	path, err := sessionPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// runSessions implements "answer sessions".
+func runSessions(args []string) {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: answer sessions [list | show <name> | delete <name...>] [-json]")
+		fmt.Fprintln(fs.Output(), "Ask within a session with: answer -session <name> \"question\"")
+		fs.PrintDefaults()
+	}
+	cmd := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		fail(2, err.Error())
+	}
+	if activeSealer, err = storeSealerFromEnv(); err != nil {
+		fail(2, err.Error())
+	}
+
+	switch cmd {
+	case "list":
+		sessions, err := listSessions()
+		if err != nil {
+			fail(2, err.Error())
+		}
+		if *asJSON {
+			printSessionsJSON(sessions)
+			return
+		}
+		for _, s := range sessions {
+			var query string
+			if t := s.last(); t != nil {
+				query = t.Query
+			}
+			fmt.Printf("%-20s %3d turns  %s  %s\n", s.Name, len(s.Turns), s.Updated.Local().Format("2006-01-02 15:04"), truncateRunes(query, 50))
+		}
+	case "show":
+		if len(positional) != 1 {
+			fail(2, "usage: answer sessions show <name>")
+		}
+		s, err := loadSession(positional[0])
+		if err != nil {
+			fail(2, err.Error())
+		}
+		if *asJSON {
+			printSessionsJSON(s)
+			return
+		}
+		fmt.Printf("%s  %s  %d turns, started %s\n", s.Name, s.Provider, len(s.Turns), s.Created.Local().Format("2006-01-02 15:04"))
+		for i, t := range s.Turns {
+			fmt.Printf("%3d. %s  %s  %s\n     %s\n", i+1, t.Time.Local().Format("2006-01-02 15:04"), t.ResponseID, t.Model, t.Query)
+		}
+	case "delete":
+		if len(positional) == 0 {
+			fail(2, "usage: answer sessions delete <name...>")
+		}
+		for _, name := range positional {
+			if err := deleteSession(name); err != nil {
+				fail(2, err.Error())
+			}
+			fmt.Printf("Deleted session %s\n", name)
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func printSessionsJSON(v any) {
+	raw, _ := json.MarshalIndent(v, "", "  ") //nolint:errcheck
+	fmt.Println(string(raw))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	t.Setenv("SESSIONS_DIR", t.TempDir())
+	t.Setenv("LAST_RESPONSE_FILE", filepath.Join(t.TempDir(), "last_response.json"))
+
+	if id, err := sessionContinuation("eu-ai-act", providerOpenAI, false); err != nil || id != "" {
+		t.Fatalf("new session = %q, %v; want no previous response", id, err)
+	}
+	if _, err := sessionContinuation("../escape", providerOpenAI, false); err == nil {
+		t.Error("accepted a session name with a path")
+	}
+	if _, err := sessionContinuation("eu-ai-act", providerOpenAI, true); err == nil {
+		t.Error("accepted a session without stored responses")
+	}
+
+	args := cliArgs{question: "What does the EU AI Act require?", session: "eu-ai-act"}
+	rememberLastResponse(args, providerOpenAI, WebSearchResult{ID: "resp_1", Model: "gpt-5.4-mini"})
+	args.question = "And for general-purpose models?"
+	rememberLastResponse(args, providerOpenAI, WebSearchResult{ID: "resp_2", Model: "gpt-5.4-mini"})
+	if err := appendSessionTurn("us-rules", providerOpenAI, sessionTurn{ResponseID: "resp_9", Query: "US?", Time: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err := sessionContinuation("eu-ai-act", providerOpenAI, false); err != nil || id != "resp_2" {
+		t.Errorf("continuation = %q, %v; want resp_2", id, err)
+	}
+	if _, err := sessionContinuation("eu-ai-act", providerAzure, false); err == nil {
+		t.Error("continued an OpenAI session on Azure")
+	}
+	sessions, err := listSessions()
+	if err != nil || len(sessions) != 2 || sessions[0].Name != "eu-ai-act" || len(sessions[0].Turns) != 2 {
+		t.Fatalf("sessions = %+v, %v", sessions, err)
+	}
+	if s := sessions[0]; s.Turns[0].Query != "What does the EU AI Act require?" || s.last().ResponseID != "resp_2" {
+		t.Errorf("turns = %+v", s.Turns)
+	}
+
+	if err := deleteSession("eu-ai-act"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSession("eu-ai-act"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("after delete: %v", err)
+	}
+	if err := deleteSession("eu-ai-act"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+}
+
+func TestSessions_Sealed(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SESSIONS_DIR", dir)
+	activeSealer, _ = newStoreSealer("correct horse", false) //nolint:errcheck
+	t.Cleanup(func() { activeSealer = nil })
+
+	if err := appendSessionTurn("private", providerOpenAI, sessionTurn{ResponseID: "resp_1", Query: "secret question", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "private.json"))
+	if err != nil || !isSealed(raw) {
+		t.Fatalf("session file not sealed: %q, %v", raw, err)
+	}
+	if s, err := loadSession("private"); err != nil || s.last().Query != "secret question" {
+		t.Fatalf("loadSession = %+v, %v", s, err)
+	}
+	activeSealer = nil
+	if _, err := loadSession("private"); !errors.Is(err, ErrStoreLocked) {
+		t.Errorf("without the key: %v, want ErrStoreLocked", err)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maxSnapshotBytes bounds each archived page.
+	maxSnapshotBytes = 5 << 20
+	// maxSnapshotSources bounds how many cited pages one answer archives.
+	maxSnapshotSources = 20
+	// snapshotTimeout bounds fetching all sources of one answer.
+	snapshotTimeout = 20 * time.Second
+	// snapshotConcurrency is how many pages are fetched at once.
+	snapshotConcurrency = 4
+	// waybackAvailableURL is the Internet Archive's availability API, used
+	// to find an archived copy of a page that is gone.
+	waybackAvailableURL = "https://archive.org/wayback/available"
+)
+
+// historySnapshotsSchema stores the cited pages of an entry as fetched when
+// it was recorded: the raw body and, for HTML and plain text, the extracted
+// text. Failed fetches are kept with their error so gaps are visible;
+// archived_url is set when the page was gone and the Wayback Machine's copy
+// was stored instead.
+const historySnapshotsSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id           INTEGER PRIMARY KEY,
+	entry_id     INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+	url          TEXT NOT NULL,
+	fetched_at   TEXT NOT NULL,
+	status       INTEGER NOT NULL DEFAULT 0,
+	content_type TEXT NOT NULL DEFAULT '',
+	body         BLOB,
+	text         TEXT NOT NULL DEFAULT '',
+	error        TEXT NOT NULL DEFAULT '',
+	archived_url TEXT NOT NULL DEFAULT '',
+	UNIQUE (entry_id, url)
+);`
+
+// Snapshot is an archived copy of a page cited by a history entry.
+type Snapshot struct {
+	URL         string    `json:"url"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int       `json:"size"`
+	Text        string    `json:"text,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	// ArchivedURL is the Wayback Machine copy stored because the page
+	// itself returned 404 or 410.
+	ArchivedURL string `json:"archived_url,omitempty"`
+	// Body is the raw page; only loaded by snapshot.
+	Body []byte `json:"-"`
+}
+
+// useSnapshots turns on archiving of cited pages for new entries (env
+// HISTORY_SNAPSHOTS).
+func (h *historyStore) useSnapshots(enabled bool) error {
+	if h == nil || !enabled {
+		return nil
+	}
+	if _, err := h.db.Exec(historySnapshotsSchema); err != nil {
+		return fmt.Errorf("init history snapshots: %w", err)
+	}
+	// Tables created before archive fallback lack archived_url.
+	var n int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('snapshots') WHERE name = 'archived_url'`).Scan(&n); err != nil {
+		return fmt.Errorf("init history snapshots: %w", err)
+	}
+	if n == 0 {
+		if _, err := h.db.Exec(`ALTER TABLE snapshots ADD COLUMN archived_url TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("init history snapshots: %w", err)
+		}
+	}
+	h.snapshots = true
+	h.waybackURL = waybackAvailableURL
+	return nil
+}
+
+// snapshotInBackground makes record return before the cited pages are
+// archived, so a tool result is not held up by their fetches; Close waits
+// for the archives still running.
+func (h *historyStore) snapshotInBackground() {
+	if h != nil {
+		h.asyncSnapshots = true
+	}
+}
+
+// snapshotSources fetches the cited pages of an entry and stores them. Each
+// page is stored even when fetching fails; only database errors are
+// returned.
+func (h *historyStore) snapshotSources(ctx context.Context, entryID int64, urls []string) error {
+	urls = snapshotURLs(urls)
+	if len(urls) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	snaps := make([]Snapshot, len(urls))
+	var g errgroup.Group
+	g.SetLimit(snapshotConcurrency)
+	for i, u := range urls {
+		g.Go(func() error {
+			snaps[i] = fetchSnapshot(ctx, u, h.waybackURL)
+			return nil
+		})
+	}
+	g.Wait() //nolint:errcheck // fetch errors are recorded per snapshot
+
+	// The fetch deadline must not abort storing what was fetched.
+	storeCtx := context.WithoutCancel(ctx)
+	return h.write(func() error {
+		for _, s := range snaps {
+			_, err := h.db.ExecContext(storeCtx,
+				`INSERT OR REPLACE INTO snapshots (entry_id, url, fetched_at, status, content_type, body, text, error, archived_url)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				entryID, s.URL, s.FetchedAt.UTC().Format(time.RFC3339), s.Status, s.ContentType, s.Body, s.Text, s.Error, s.ArchivedURL)
+			if err != nil {
+				return fmt.Errorf("store snapshot: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// snapshotURLs keeps distinct http(s) URLs, up to maxSnapshotSources.
+func snapshotURLs(urls []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || seen[u] {
+			continue
+		}
+		seen[u] = true
+		out = append(out, u)
+		if len(out) == maxSnapshotSources {
+			break
+		}
+	}
+	return out
+}
+
+// fetchSnapshot downloads one page. When the page is gone (404 or 410) and
+// wayback is set, the closest Wayback Machine copy is stored in its place.
+func fetchSnapshot(ctx context.Context, u, wayback string) Snapshot {
+	s := fetchPage(ctx, u)
+	if wayback == "" || (s.Status != http.StatusNotFound && s.Status != http.StatusGone) {
+		return s
+	}
+	archived, err := waybackClosest(ctx, wayback, u, s.FetchedAt)
+	if err != nil || archived == "" {
+		return s
+	}
+	a := fetchPage(ctx, archived)
+	if a.Error != "" || a.Status != http.StatusOK {
+		return s
+	}
+	a.URL, a.ArchivedURL = u, archived
+	return a
+}
+
+// waybackClosest asks the Wayback Machine's availability API for the copy
+// of u closest to at. It returns the raw ("id_") capture URL, without the
+// archive's toolbar and link rewriting, or "" when there is none.
+func waybackClosest(ctx context.Context, api, u string, at time.Time) (string, error) {
+	q := url.Values{"url": {u}, "timestamp": {at.UTC().Format("20060102150405")}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := snapshotClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("wayback availability: status %d", resp.StatusCode)
+	}
+	var avail struct {
+		ArchivedSnapshots struct {
+			Closest *struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Timestamp string `json:"timestamp"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&avail); err != nil {
+		return "", fmt.Errorf("wayback availability: %w", err)
+	}
+	c := avail.ArchivedSnapshots.Closest
+	if c == nil || !c.Available || c.Status != "200" || c.URL == "" {
+		return "", nil
+	}
+	if c.Timestamp != "" {
+		return strings.Replace(c.URL, "/"+c.Timestamp+"/", "/"+c.Timestamp+"id_/", 1), nil
+	}
+	return c.URL, nil
+}
+
+// fetchPage downloads one page. It uses its own client: pages are fetched
+// directly, not through the API gateway or its credentials.
+func fetchPage(ctx context.Context, u string) Snapshot {
+	s := Snapshot{URL: u, FetchedAt: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	req.Header.Set("User-Agent", serverTitle+" (source snapshot)")
+	resp, err := snapshotClient.Do(req)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	defer resp.Body.Close()
+	s.Status = resp.StatusCode
+	s.ContentType = resp.Header.Get("Content-Type")
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotBytes+1))
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	if len(body) > maxSnapshotBytes {
+		body = body[:maxSnapshotBytes]
+		s.Error = fmt.Sprintf("truncated at %d bytes", maxSnapshotBytes)
+	}
+	s.Body, s.Size = body, len(body)
+	mediaType, _, _ := mime.ParseMediaType(s.ContentType) //nolint:errcheck // empty on failure
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		s.Text = htmlText(string(body))
+	case strings.HasPrefix(mediaType, "text/"):
+		s.Text = string(body)
+	}
+	return s
+}
+
+var snapshotClient = &http.Client{Timeout: snapshotTimeout}
+
+var (
+	// htmlSkipRe matches elements whose content is not page text.
+	htmlSkipRe  = regexp.MustCompile(`(?is)<(script|style|noscript|template|svg)\b.*?</(?:script|style|noscript|template|svg)\s*>|<!--.*?-->`)
+	htmlBlockRe = regexp.MustCompile(`(?i)</?(p|div|br|li|tr|h[1-6]|section|article|header|footer|blockquote|pre|table|ul|ol)\b[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]*>`)
+	spaceRunRe  = regexp.MustCompile(`[ \t\r\f\v\x{00a0}]+`)
+	blankRunRe  = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlText extracts readable text from an HTML page: scripts, styles and
+// comments are dropped, block elements become line breaks and entities are
+// decoded.
+func htmlText(page string) string {
+	page = htmlSkipRe.ReplaceAllString(page, " ")
+	page = htmlBlockRe.ReplaceAllString(page, "\n")
+	page = html.UnescapeString(htmlTagRe.ReplaceAllString(page, " "))
+	page = spaceRunRe.ReplaceAllString(page, " ")
+	lines := strings.Split(page, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return strings.TrimSpace(blankRunRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// listSnapshots returns the archived pages of an entry in citation order,
+// without their bodies.
+func (h *historyStore) listSnapshots(ctx context.Context, entryID int64) ([]Snapshot, error) {
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT url, fetched_at, status, content_type, length(body), '', error, archived_url FROM snapshots WHERE entry_id = ? ORDER BY id`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+	var out []Snapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows.Scan, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// snapshot returns the n-th (1-based) archived page of an entry in full.
+func (h *historyStore) snapshot(ctx context.Context, entryID int64, n int) (Snapshot, error) {
+	row := h.db.QueryRowContext(ctx,
+		`SELECT url, fetched_at, status, content_type, body, text, error, archived_url FROM snapshots
+		 WHERE entry_id = ? ORDER BY id LIMIT 1 OFFSET ?`, entryID, max(n-1, 0))
+	s, err := scanSnapshot(row.Scan, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, fmt.Errorf("%w: #%d has no source %d", ErrHistoryNotFound, entryID, n)
+	}
+	return s, err
+}
+
+func scanSnapshot(scan func(dest ...any) error, withBody bool) (Snapshot, error) {
+	var s Snapshot
+	var fetched string
+	var size sql.NullInt64
+	body := any(&size)
+	if withBody {
+		body = &s.Body
+	}
+	if err := scan(&s.URL, &fetched, &s.Status, &s.ContentType, body, &s.Text, &s.Error, &s.ArchivedURL); err != nil {
+		return s, err
+	}
+	s.FetchedAt, _ = time.Parse(time.RFC3339, fetched) //nolint:errcheck
+	s.Size = int(size.Int64)
+	if withBody {
+		s.Size = len(s.Body)
+	}
+	return s, nil
+}
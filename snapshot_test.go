@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHistory_Snapshots(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wayback/available":
+			closest := map[string]any{}
+			if strings.HasSuffix(r.URL.Query().Get("url"), "/moved") && len(r.URL.Query().Get("timestamp")) == 14 {
+				closest = map[string]any{"closest": map[string]any{
+					"available": true, "status": "200", "timestamp": "20240101000000",
+					"url": "http://" + r.Host + "/web/20240101000000/http://example.com/moved",
+				}}
+			}
+			writeJSON(t, w, http.StatusOK, map[string]any{"archived_snapshots": closest})
+		case "/web/20240101000000id_/http://example.com/moved":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("Archived rates.")) //nolint:errcheck
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<html><head><style>p{color:red}</style><script>var x = 1;</script></head>` + //nolint:errcheck
+				`<body><h1>Rates</h1><p>Base rate is 4.25%&nbsp;&amp; falling.</p><!-- hidden --></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	h := newTestHistory(t)
+	if err := h.useSnapshots(true); err != nil {
+		t.Fatalf("useSnapshots: %v", err)
+	}
+	h.waybackURL = base + "/wayback/available"
+	ctx := context.Background()
+	id, err := h.record(ctx, HistoryEntry{
+		Query: "bank rate?", Answer: "4.25%",
+		Sources: []string{base + "/page", base + "/gone", base + "/page", "ftp://example.com/x", base + "/moved"},
+	})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	snaps, err := h.listSnapshots(ctx, id)
+	if err != nil {
+		t.Fatalf("listSnapshots: %v", err)
+	}
+	if len(snaps) != 3 || snaps[0].URL != base+"/page" || snaps[1].Status != http.StatusNotFound || snaps[1].ArchivedURL != "" {
+		t.Fatalf("snapshots = %+v", snaps)
+	}
+	if snaps[0].Size == 0 || snaps[0].Text != "" {
+		t.Errorf("listed snapshot = %+v, want size without text", snaps[0])
+	}
+
+	s, err := h.snapshot(ctx, id, 1)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if s.Text != "Rates\n\nBase rate is 4.25% & falling." {
+		t.Errorf("text = %q", s.Text)
+	}
+	if !strings.Contains(string(s.Body), "<script>") {
+		t.Errorf("body should hold the raw page, got %q", s.Body)
+	}
+	archived, err := h.snapshot(ctx, id, 3)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if archived.URL != base+"/moved" || archived.Text != "Archived rates." || !strings.Contains(archived.ArchivedURL, "20240101000000id_/") {
+		t.Errorf("dead page should fall back to its archived copy, got %+v", archived)
+	}
+	if _, err := h.snapshot(ctx, id, 4); !errors.Is(err, ErrHistoryNotFound) {
+		t.Errorf("missing source error = %v", err)
+	}
+}
+
+func TestHistory_SnapshotsInBackground(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte("<p>Slow page.</p>")) //nolint:errcheck
+	})
+
+	h := newTestHistory(t)
+	if err := h.useSnapshots(true); err != nil {
+		t.Fatalf("useSnapshots: %v", err)
+	}
+	h.snapshotInBackground()
+	ctx, cancel := context.WithCancel(context.Background())
+	id, err := h.record(ctx, HistoryEntry{Query: "slow?", Answer: "yes", Sources: []string{base + "/slow"}})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	// The answer is returned while the page is still being fetched, and
+	// its request ending does not stop the archive.
+	cancel()
+	if snaps, err := h.listSnapshots(context.Background(), id); err != nil || len(snaps) != 0 {
+		t.Fatalf("snapshots before the fetch = %+v, %v", snaps, err)
+	}
+	close(release)
+	h.archiving.Wait()
+	if snaps, err := h.listSnapshots(context.Background(), id); err != nil || len(snaps) != 1 || snaps[0].Error != "" {
+		t.Errorf("snapshots after the fetch = %+v, %v", snaps, err)
+	}
+}
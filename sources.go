@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sourcesOnlyInstructions steer providers that always write a reply towards
+// searching without answering, which keeps the reply short.
+const sourcesOnlyInstructions = "Search the web for pages relevant to the user's question. " +
+	"Do not answer the question; when you have searched, reply with the single word: done."
+
+// SearchSource is one retrieved page returned when synthesis is off.
+type SearchSource struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	// Snippet is an extract of the page, when the provider returns one.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// retrieveSources runs the search for p without generating an answer, for
+// callers that want to read the sources themselves. Perplexity answers from
+// its Search API; other providers are asked to search and not answer, and
+// the pages their searches returned are collected.
+func retrieveSources(ctx context.Context, p CallAPIParams, wa webSearchArgs, degraded []string) (*WebSearchResult, error) {
+	result := &WebSearchResult{
+		Query:           p.Query,
+		Model:           p.Model,
+		Effort:          p.Effort,
+		TimeoutUsed:     p.Timeout.String(),
+		RequestedModel:  wa.model,
+		RequestedEffort: wa.effort,
+		WebSearchUsed:   p.UseWebSearch,
+		Provider:        p.Provider,
+		Degraded:        degraded,
+	}
+	if !p.UseWebSearch {
+		result.Error = fmt.Sprintf("synthesis=false needs web search, which provider %s does not offer here", p.Provider)
+		return result, nil
+	}
+	p.SourcesOnly = true
+	p.Instructions = sourcesOnlyInstructions
+	// Nothing is answered, so answer-shaping options do not apply.
+	p.OutputSchema, p.Tools, p.Verbosity = nil, nil, ""
+
+	apiResp, err := CallAPI(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	result.Sources = collectSources(apiResp, p.BlockedDomains)
+	result.ID, result.RequestID, result.Usage = apiResp.ID, apiResp.RequestID, apiResp.Usage
+	result.Model = cmp.Or(apiResp.Model, result.Model)
+	if apiResp.RetryWait > 0 {
+		result.RetryWait = apiResp.RetryWait.String()
+	}
+	if len(result.Sources) == 0 {
+		result.Error = "The search returned no sources"
+		logToClient(ctx, mcp.LoggingLevelWarning, "api_handler", result.Error)
+		return result, nil
+	}
+	result.Success = true
+	logToClient(ctx, mcp.LoggingLevelDebug, "api_handler", fmt.Sprintf("Search returned %d sources", len(result.Sources)))
+	return result, nil
+}
+
+// collectSources lists the distinct pages in apiResp: the search results on
+// web_search_call items first, then any cited page they missed. Citations
+// also supply titles the search results lack. Pages from blocked domains,
+// and from the excluded ones, are dropped, as they are from citations.
+func collectSources(apiResp *apiResponse, excluded []string) []SearchSource {
+	var sources []SearchSource
+	index := make(map[string]int)
+	add := func(s SearchSource) {
+		s.URL = strings.TrimSpace(s.URL)
+		host := citationHost(s.URL)
+		if s.URL == "" || activeDomains.reputation(host) == reputationBlocked || underDomains(host, excluded) {
+			return
+		}
+		if i, ok := index[s.URL]; ok {
+			sources[i].Title = cmp.Or(sources[i].Title, s.Title)
+			sources[i].Snippet = cmp.Or(sources[i].Snippet, s.Snippet)
+			return
+		}
+		index[s.URL] = len(sources)
+		sources = append(sources, s)
+	}
+	for _, item := range apiResp.Output {
+		if item.Type != "web_search_call" || item.Action == nil {
+			continue
+		}
+		for _, s := range item.Action.Sources {
+			add(SearchSource{URL: s.URL, Title: s.Title, Snippet: s.Snippet})
+		}
+	}
+	for _, c := range ExtractCitations(apiResp) {
+		add(SearchSource{URL: c.URL, Title: c.Title})
+	}
+	return sources
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandleWebSearch_NoSynthesis(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Instructions string   `json:"instructions"`
+			Include      []string `json:"include"`
+			ToolChoice   string   `json:"tool_choice"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if len(body.Include) != 1 || body.Include[0] != "web_search_call.action.sources" || body.ToolChoice != "required" || body.Instructions != sourcesOnlyInstructions {
+			t.Errorf("include = %v, tool_choice = %q, instructions = %q", body.Include, body.ToolChoice, body.Instructions)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_src", "status": "completed", "model": "gpt-5.4-mini",
+			"output": []map[string]any{
+				{"type": "web_search_call", "status": "completed", "action": map[string]any{
+					"type": "search", "query": "go release",
+					"sources": []map[string]any{
+						{"type": "url", "url": "https://go.dev/doc/devel/release"},
+						{"type": "url", "url": "https://go.dev/blog/go1.25"},
+					},
+				}},
+				{"type": "message", "content": []map[string]any{{
+					"type": "output_text", "text": "done",
+					"annotations": []map[string]any{
+						{"type": "url_citation", "url": "https://go.dev/doc/devel/release", "title": "Release History", "start_index": 0, "end_index": 4},
+						{"type": "url_citation", "url": "https://tip.golang.org/doc/go1.26", "title": "Go 1.26 Release Notes", "start_index": 0, "end_index": 4},
+					},
+				}}},
+			},
+		})
+	})
+
+	result, err := HandleWebSearch(t.Context(), "k", base, map[string]any{"query": "latest Go release?", "synthesis": false, "no_cache": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Answer != "" || result.ID != "resp_src" {
+		t.Fatalf("result = %+v", result)
+	}
+	want := []SearchSource{
+		{URL: "https://go.dev/doc/devel/release", Title: "Release History"},
+		{URL: "https://go.dev/blog/go1.25"},
+		{URL: "https://tip.golang.org/doc/go1.26", Title: "Go 1.26 Release Notes"},
+	}
+	if len(result.Sources) != len(want) {
+		t.Fatalf("sources = %+v", result.Sources)
+	}
+	for i := range want {
+		if result.Sources[i] != want[i] {
+			t.Errorf("source %d = %+v, want %+v", i, result.Sources[i], want[i])
+		}
+	}
+
+	noSearch, err := HandleWebSearch(t.Context(), "k", base, map[string]any{"query": "latest Go release?", "synthesis": false, "web_search": false})
+	if err != nil || noSearch.Success {
+		t.Errorf("without web search = %+v, %v; want a failed result", noSearch, err)
+	}
+}
+
+func TestCallAPI_PerplexitySearch(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" {
+			t.Errorf("path = %s, want /search", r.URL.Path)
+		}
+		var req perplexitySearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.Query != "measles?" || req.MaxResults != perplexityMaxResults || len(req.SearchDomainFilter) != 1 {
+			t.Errorf("request = %+v", req)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "search-1",
+			"results": []map[string]any{
+				{"title": "Measles", "url": "https://who.int/measles", "snippet": "Measles is a highly\ncontagious disease."},
+			},
+		})
+	})
+
+	ar, err := CallAPI(context.Background(), CallAPIParams{
+		Provider:       providerPerplexity,
+		APIKey:         "pk",
+		BaseURL:        base + "/chat/completions",
+		Query:          "measles?",
+		AllowedDomains: []string{"who.int"},
+		Timeout:        time.Second,
+		UseWebSearch:   true,
+		SourcesOnly:    true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sources := collectSources(ar, nil)
+	if ar.ID != "search-1" || len(sources) != 1 || sources[0].Title != "Measles" || sources[0].Snippet == "" {
+		t.Errorf("id = %q, sources = %+v", ar.ID, sources)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// streamEvent is the subset of Responses API server-sent event payloads we
+// act on. Every event carries its type in the JSON body, so the SSE "event:"
+// line is not needed.
+type streamEvent struct {
+	Type     string       `json:"type"`
+	Delta    string       `json:"delta"`
+	Response *apiResponse `json:"response"`
+	Message  string       `json:"message"`
+	Code     string       `json:"code"`
+}
+
+// streamFailure captures the error object attached to response.failed.
+type streamFailure struct {
+	Response struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"response"`
+}
+
+// readResponseStream consumes a Responses API SSE stream, invoking onDelta for
+// every output text delta and returning the final response object carried by
+// the terminal event.
+func readResponseStream(r io.Reader, onDelta func(string)) (*apiResponse, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxResponseBodySize)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if payload, ok := strings.CutPrefix(line, "data:"); ok {
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(strings.TrimPrefix(payload, " "))
+			}
+			continue
+		}
+
+		// Blank line terminates an event.
+		if data.Len() == 0 {
+			continue
+		}
+		payload := data.String()
+		data.Reset()
+
+		final, err := handleStreamEvent(payload, onDelta)
+		if err != nil || final != nil {
+			return final, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	// Tolerate a missing trailing blank line after the last event.
+	if data.Len() > 0 {
+		final, err := handleStreamEvent(data.String(), onDelta)
+		if err != nil || final != nil {
+			return final, err
+		}
+	}
+	return nil, ErrStreamIncomplete
+}
+
+// handleStreamEvent processes one SSE data payload. It returns a non-nil
+// response when the stream reached a terminal event.
+func handleStreamEvent(payload string, onDelta func(string)) (*apiResponse, error) {
+	if payload == "[DONE]" {
+		return nil, nil
+	}
+	var ev streamEvent
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		return nil, fmt.Errorf("parse stream event: %w", err)
+	}
+
+	switch ev.Type {
+	case "response.output_text.delta":
+		if onDelta != nil && ev.Delta != "" {
+			onDelta(ev.Delta)
+		}
+	case "response.completed", "response.incomplete":
+		if ev.Response == nil {
+			return nil, fmt.Errorf("stream event %s without response", ev.Type)
+		}
+		return ev.Response, nil
+	case "response.failed":
+		var f streamFailure
+		_ = json.Unmarshal([]byte(payload), &f) //nolint:errcheck // already parsed once
+		return nil, fmt.Errorf("response failed: %s: %s", f.Response.Error.Code, f.Response.Error.Message)
+	case "error":
+		return nil, fmt.Errorf("stream error: %s: %s", ev.Code, ev.Message)
+	}
+	return nil, nil
+}
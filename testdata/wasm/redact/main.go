@@ -0,0 +1,29 @@
+// Command redact is the WASM plugin used by wasm_test.go: it replaces
+// "secret" in queries and upper-cases answers.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	in, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		os.Exit(1)
+	}
+	text := string(in)
+	switch os.Getenv("WEBSEARCH_STAGE") {
+	case "query":
+		text = strings.ReplaceAll(text, "secret", "[redacted]")
+	case "answer":
+		if strings.Contains(text, "fail") {
+			fmt.Fprintln(os.Stderr, "refusing")
+			os.Exit(2)
+		}
+		text = strings.ToUpper(text)
+	}
+	fmt.Print(text)
+}
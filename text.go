@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis marks text shortened by the truncate helpers.
+const ellipsis = "…"
+
+// maxLogFieldRunes bounds user-supplied strings echoed into log messages.
+const maxLogFieldRunes = 200
+
+// contentHash identifies an answer by its text: the SHA-256 of the answer
+// with runs of whitespace collapsed, so the same content fetched again under
+// a new response ID, or re-wrapped, hashes the same.
+func contentHash(answer string) string {
+	if strings.TrimSpace(answer) == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(answer), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncateRunes shortens s to at most n user-perceived characters, never
+// splitting a multi-byte sequence or a grapheme cluster (emoji with
+// modifiers, ZWJ sequences, flags, combining marks). When s is cut, the
+// result ends with an ellipsis that counts towards n.
+func truncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	keep := n - utf8.RuneCountInString(ellipsis)
+	if keep <= 0 {
+		return ellipsis
+	}
+	cut := runeToByteOffset(s, keep)
+	return s[:graphemeBoundaryBefore(s, cut)] + ellipsis
+}
+
+// truncateBytes shortens s to at most maxBytes bytes of valid UTF-8, cutting
+// on a grapheme boundary. No ellipsis is added, so callers with hard byte
+// budgets (headers, storage fields) get exactly what fits.
+func truncateBytes(s string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:graphemeBoundaryBefore(s, cut)]
+}
+
+// truncateForLog bounds a user-supplied value before it is written to logs.
+func truncateForLog(s string) string {
+	return truncateRunes(s, maxLogFieldRunes)
+}
+
+// graphemeBoundaryBefore moves a rune-aligned cut position left until it no
+// longer separates a rune from the grapheme cluster it extends. This is a
+// pragmatic subset of UAX #29 covering the sequences that commonly appear in
+// model output: combining marks, variation selectors, emoji modifiers,
+// zero-width joiners, keycaps and regional-indicator flag pairs.
+func graphemeBoundaryBefore(s string, cut int) int {
+	for cut > 0 && cut < len(s) {
+		next, _ := utf8.DecodeRuneInString(s[cut:])
+		prev, prevSize := utf8.DecodeLastRuneInString(s[:cut])
+		switch {
+		case extendsCluster(next), prev == zeroWidthJoiner:
+			cut -= prevSize
+		case isRegionalIndicator(prev) && isRegionalIndicator(next) && oddRegionalRun(s[:cut]):
+			cut -= prevSize
+		default:
+			return cut
+		}
+	}
+	return cut
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// extendsCluster reports whether r attaches to the preceding rune.
+func extendsCluster(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner, r == '\u20e3': // ZWJ, combining keycap
+		return true
+	case r >= '\ufe00' && r <= '\ufe0f': // variation selectors
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // emoji skin tone modifiers
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // emoji tag sequences
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// oddRegionalRun reports whether s ends with an odd number of regional
+// indicators, meaning the last one is the first half of an unfinished flag.
+func oddRegionalRun(s string) bool {
+	n := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeLastRuneInString(s)
+		if !isRegionalIndicator(r) {
+			break
+		}
+		n++
+		s = s[:len(s)-size]
+	}
+	return n%2 == 1
+}
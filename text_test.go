@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "short_unchanged", in: "hello", n: 10, want: "hello"},
+		{name: "exact_unchanged", in: "hello", n: 5, want: "hello"},
+		{name: "ascii_cut", in: "hello world", n: 6, want: "hello…"},
+		{name: "cjk_cut", in: "東京都庁舎の展望台", n: 4, want: "東京都…"},
+		{name: "emoji_cut", in: "ok 😀😀😀", n: 5, want: "ok 😀…"},
+		{name: "skin_tone_not_split", in: "ab👍🏽cd", n: 4, want: "ab…"},
+		{name: "zwj_family_not_split", in: "a👨\u200d👩\u200d👧z", n: 4, want: "a…"},
+		{name: "flag_pair_not_split", in: "x🇵🇱🇩🇪", n: 3, want: "x…"},
+		{name: "combining_mark_not_split", in: "cafe\u0301 au lait", n: 5, want: "caf…"},
+		{name: "zero", in: "abc", n: 0, want: ""},
+		{name: "only_ellipsis", in: "abc", n: 1, want: "…"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := truncateRunes(tt.in, tt.n)
+			if got != tt.want {
+				t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("result is not valid UTF-8: %q", got)
+			}
+			if utf8.RuneCountInString(got) > tt.n {
+				t.Errorf("result has %d runes, limit %d", utf8.RuneCountInString(got), tt.n)
+			}
+		})
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{name: "fits", in: "abc", max: 3, want: "abc"},
+		{name: "mid_cjk", in: "日本語", max: 4, want: "日"},
+		{name: "mid_emoji", in: "a😀b", max: 3, want: "a"},
+		{name: "after_emoji_before_modifier", in: "👍🏽", max: 4, want: ""},
+		{name: "zero", in: "abc", max: 0, want: ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := truncateBytes(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("truncateBytes(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+			if len(got) > tt.max || !utf8.ValidString(got) {
+				t.Errorf("invalid result %q for limit %d", got, tt.max)
+			}
+		})
+	}
+}
+
+func TestAPIError_TruncatesLongBody(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("東", maxErrorBodyRunes*2)
+	err := &APIError{StatusCode: 500, Body: body}
+	msg := err.Error()
+	if !utf8.ValidString(msg) {
+		t.Fatal("error message is not valid UTF-8")
+	}
+	if utf8.RuneCountInString(msg) > maxErrorBodyRunes+100 {
+		t.Errorf("error message not truncated: %d runes", utf8.RuneCountInString(msg))
+	}
+	if err.Body != body {
+		t.Error("APIError.Body must keep the full body")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	t.Parallel()
+
+	h := contentHash("The answer.\n\nWith  sources.")
+	if len(h) != 64 {
+		t.Fatalf("contentHash = %q, want 64 hex digits", h)
+	}
+	if got := contentHash("  The answer. With sources.\n"); got != h {
+		t.Errorf("re-wrapped answer hashes to %q, want %q", got, h)
+	}
+	if contentHash("The answer. With other sources.") == h {
+		t.Error("different answers share a hash")
+	}
+	if got := contentHash(" \n"); got != "" {
+		t.Errorf("contentHash of a blank answer = %q, want empty", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// translateModel is the cheap model used for the translation pass.
+const translateModel = modelNano
+
+// translatePrompt asks for a faithful translation that leaves the
+// placeholder tokens alone; they stand in for code and URLs.
+const translatePrompt = `Translate the text below into %s.
+Keep the Markdown formatting. Copy every token of the form ⟦n⟧ exactly as it appears, in place;
+they stand for code and links that must not change. Reply with the translation only.
+
+%s`
+
+// protectedRe matches the spans a translation must not touch: fenced code
+// blocks, inline code and URLs (including Markdown link targets), leaving
+// trailing sentence punctuation outside the URL.
+var protectedRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]+`|https?://[^\\s<>()\\[\\]]*[^\\s<>()\\[\\].,;:!?'\"]")
+
+// protectSpans replaces code and URLs in text with numbered placeholders and
+// returns the masked text with the original spans, indexed by number.
+func protectSpans(text string) (string, []string) {
+	var spans []string
+	masked := protectedRe.ReplaceAllStringFunc(text, func(m string) string {
+		spans = append(spans, m)
+		return placeholder(len(spans) - 1)
+	})
+	return masked, spans
+}
+
+// restoreSpans puts the protected spans back, failing if the translation
+// lost any placeholder.
+func restoreSpans(text string, spans []string) (string, error) {
+	pairs := make([]string, 0, 2*len(spans))
+	for i, s := range spans {
+		ph := placeholder(i)
+		if !strings.Contains(text, ph) {
+			return "", fmt.Errorf("translation dropped protected segment %s", ph)
+		}
+		pairs = append(pairs, ph, s)
+	}
+	return strings.NewReplacer(pairs...).Replace(text), nil
+}
+
+func placeholder(i int) string {
+	return fmt.Sprintf("⟦%d⟧", i)
+}
+
+// translateAnswer translates answer into lang with a second, cheap model
+// pass on provider, the one that gave the answer, leaving code blocks and
+// URLs intact. The provider maps translateModel onto its own small model.
+func translateAnswer(ctx context.Context, provider, apiKey, baseURL, answer, lang string) (string, error) {
+	masked, spans := protectSpans(answer)
+	wa := webSearchArgs{model: translateModel, effort: "none", verbosity: defaultVerbosity}
+	out, err := synthesizeOn(ctx, provider, apiKey, baseURL, wa, fmt.Sprintf(translatePrompt, lang, masked))
+	if err != nil {
+		return "", fmt.Errorf("translate: %w", err)
+	}
+	return restoreSpans(out, spans)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProtectSpans(t *testing.T) {
+	t.Parallel()
+
+	text := "Run `go test` first.\n\n```go\nfmt.Println(\"hi\")\n```\n\nSee [docs](https://go.dev/doc) or https://pkg.go.dev."
+	masked, spans := protectSpans(text)
+	if len(spans) != 4 {
+		t.Fatalf("spans = %q, want 4", spans)
+	}
+	for _, s := range spans {
+		if strings.Contains(masked, s) {
+			t.Errorf("masked text still contains %q", s)
+		}
+	}
+	if spans[3] != "https://pkg.go.dev" {
+		t.Errorf("bare URL span = %q", spans[3])
+	}
+
+	restored, err := restoreSpans(masked, spans)
+	if err != nil || restored != text {
+		t.Errorf("restoreSpans = %q, %v; want original text", restored, err)
+	}
+	if _, err := restoreSpans(strings.Replace(masked, placeholder(1), "", 1), spans); err == nil {
+		t.Error("expected error when a placeholder is dropped")
+	}
+}
+
+func TestTranslateAnswer(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if body.Model != translateModel || len(body.Tools) != 0 {
+			t.Errorf("model = %q, tools = %+v; want %s without tools", body.Model, body.Tools, translateModel)
+		}
+		if strings.Contains(body.Input, "https://example.com") || !strings.Contains(body.Input, "German") {
+			t.Errorf("prompt should mask URLs and name the language, got %q", body.Input)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "resp",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "Siehe ⟦0⟧ und ⟦1⟧."}}}},
+		})
+	})
+
+	got, err := translateAnswer(context.Background(), providerOpenAI, "k", base, "See https://example.com and `make`.", "German")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "Siehe https://example.com und `make`." {
+		t.Errorf("translation = %q", got)
+	}
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/mark3labs/mcp-go/server"
@@ -13,6 +20,9 @@ const (
 	httpReadTimeout  = 30 * time.Second
 	httpWriteTimeout = 15 * time.Minute // must accommodate the longest web-search (timeoutXHigh = 15m)
 	httpIdleTimeout  = 120 * time.Second
+	// httpShutdownTimeout is how long requests in flight may finish after
+	// SIGTERM or Ctrl-C before their connections are closed.
+	httpShutdownTimeout = 10 * time.Second
 )
 
 // RunStdioTransport runs the MCP server using STDIO transport.
@@ -34,9 +44,23 @@ func RunStdioTransport(mcpServer *server.MCPServer) error {
 // and returns HTTP 401 before any MCP handshake occurs. Tokens generated by
 // GeminiMCP with the same secret key are accepted without modification.
 //
+// When cfg.Dashboard is set the web dashboard is served under /dashboard/;
+// its API endpoints sit behind the same auth middleware. With cfg.Webhooks
+// POST /hooks/query answers questions in the background and posts the
+// results to a callback URL. With cfg.API POST /api/search answers
+// synchronously in plain JSON.
+//
 // When cfg.Heartbeat > 0 the server sends periodic SSE heartbeat pings on
 // streaming connections — important for long-running web-search requests that
 // would otherwise be silently dropped by proxies or load balancers.
+//
+// SIGTERM or Ctrl-C shut the server down cleanly and return nil, so a
+// service manager or "answer mcp stop" ends it without an error. With
+// cfg.PIDFile set the server's PID is kept there while it listens.
+//
+// Once listening, the server prints its URL as an MCP_URL= line on stdout
+// and, with cfg.AddrFile, records it there; with port 0 that is the only
+// way to learn the port the system chose.
 func RunHTTPTransport(mcpServer *server.MCPServer, cfg MCPConfig) error {
 	var mcpOpts []server.StreamableHTTPOption
 
@@ -58,17 +82,92 @@ func RunHTTPTransport(mcpServer *server.MCPServer, cfg MCPConfig) error {
 
 	mux := http.NewServeMux()
 	mux.Handle("/", handler)
-
-	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
-	Info("Starting HTTP server", "addr", addr)
-	Info("MCP endpoint", "url", fmt.Sprintf("http://%s/", addr))
+	if activeDashboard != nil {
+		mux.Handle("/dashboard/", activeDashboard.handler(cfg))
+	}
+	if cfg.API {
+		var api http.Handler = newSearchAPI(cfg)
+		if cfg.AuthEnabled {
+			api = newAuthHTTPMiddleware([]byte(cfg.AuthSecretKey), api)
+		}
+		mux.Handle("/api/search", api)
+	}
+	var hooks *webhookServer
+	if cfg.Webhooks {
+		// Webhooks are only served with auth (see runMCPMode).
+		hooks = newWebhookServer(cfg)
+		mux.Handle("/hooks/query", newAuthHTTPMiddleware([]byte(cfg.AuthSecretKey), hooks))
+	}
 
 	srv := &http.Server{
-		Addr:         addr,
 		Handler:      mux,
 		ReadTimeout:  httpReadTimeout,
 		WriteTimeout: httpWriteTimeout,
 		IdleTimeout:  httpIdleTimeout,
 	}
-	return srv.ListenAndServe()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, cfg.Port))
+	if err != nil {
+		return err
+	}
+	listening := resolveListenAddr(cfg.Host, ln)
+	addr := net.JoinHostPort(listening.Host, strconv.Itoa(listening.Port))
+	srv.Addr = addr
+	// The address file is written before the PID file, so "answer mcp
+	// -daemon" finds it once the PID file appears.
+	if cfg.AddrFile != "" {
+		if err := writeAddrFile(cfg.AddrFile, listening); err != nil {
+			ln.Close() //nolint:errcheck
+			return err
+		}
+		defer removeAddrFile(cfg.AddrFile)
+	}
+	// The PID file appears only once the server listens, so "answer mcp
+	// -daemon" knows it started.
+	if cfg.PIDFile != "" {
+		if err := writePIDFile(cfg.PIDFile); err != nil {
+			ln.Close() //nolint:errcheck
+			return err
+		}
+		defer removePIDFile(cfg.PIDFile)
+	}
+
+	Info("Starting HTTP server", "addr", addr)
+	Info("MCP endpoint", "url", listening.URL)
+	listening.announce(os.Stdout)
+	if activeDashboard != nil {
+		Info("Dashboard", "url", fmt.Sprintf("http://%s/dashboard/", addr))
+	}
+	if cfg.API {
+		Info("Search API endpoint", "url", fmt.Sprintf("http://%s/api/search", addr), "timeout", newSearchAPI(cfg).timeout)
+	}
+	if cfg.Webhooks {
+		Info("Webhook endpoint", "url", fmt.Sprintf("http://%s/hooks/query", addr), "signed", cfg.WebhookSecret != "")
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	Info("Shutting down HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Long SSE streams do not end by themselves.
+		if errors.Is(err, context.DeadlineExceeded) {
+			return srv.Close()
+		}
+		return err
+	}
+	// Accepted webhook queries still owe their callers a delivery.
+	if hooks != nil {
+		if err := hooks.wait(shutdownCtx); err != nil {
+			Warn("Webhook queries still running at shutdown", "error", err)
+		}
+	}
+	return nil
 }
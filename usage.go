@@ -0,0 +1,122 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runUsage is what the calls behind a CLI answer consumed, printed after
+// it with -show-usage.
+type runUsage struct {
+	Calls           int
+	Cached          int
+	InputTokens     int
+	OutputTokens    int
+	ReasoningTokens int
+	WebSearches     int
+	// USD is the estimated price of the priced calls; Unpriced lists the
+	// models without a list price, whose calls it leaves out.
+	USD      float64
+	Unpriced []string
+}
+
+// add counts one upstream call on model; webSearches is the number of
+// searches it ran, each billed on top of the tokens.
+func (u *runUsage) add(model string, usage *apiUsage, webSearches int) {
+	u.Calls++
+	u.WebSearches += webSearches
+	var in, out int
+	if usage != nil {
+		in, out = usage.InputTokens, usage.OutputTokens
+		u.InputTokens += in
+		u.OutputTokens += out
+		u.ReasoningTokens += usage.OutputTokensDetails.ReasoningTokens
+	}
+	price, ok := priceFor(model)
+	if !ok {
+		if !slices.Contains(u.Unpriced, model) {
+			u.Unpriced = append(u.Unpriced, model)
+		}
+		return
+	}
+	u.USD += (float64(in)*price.input+float64(out)*price.output)/1e6 + float64(webSearches)*webSearchCallPrice
+}
+
+// addResult counts an answer of a batch or ensemble, where the raw response
+// is gone: an answer that searched is assumed to have searched once.
+func (u *runUsage) addResult(r *WebSearchResult) {
+	switch {
+	case r.Cached:
+		u.Cached++
+	case r.Usage != nil || r.Success:
+		searches := 0
+		if r.WebSearchUsed && r.Success {
+			searches = 1
+		}
+		// Failed calls may not report the model they ran on.
+		u.add(cmp.Or(r.Model, r.RequestedModel), r.Usage, searches)
+	}
+}
+
+// countWebSearches returns the number of web_search_call items in resp.
+func countWebSearches(resp *apiResponse) int {
+	n := 0
+	for _, item := range resp.Output {
+		if item.Type == "web_search_call" {
+			n++
+		}
+	}
+	return n
+}
+
+// write prints the summary as one line, e.g.
+//
+//	usage: 1 call, 2400 input + 9100 output tokens (8200 reasoning), 2 web searches, ~$0.1240, 41.2s
+func (u *runUsage) write(w io.Writer, elapsed time.Duration) {
+	elapsed = elapsed.Round(10 * time.Millisecond)
+	if u.Calls == 0 {
+		fmt.Fprintf(w, "usage: %s, no tokens used, %s\n", plural(u.Cached, "cached answer"), elapsed)
+		return
+	}
+	parts := []string{plural(u.Calls, "call")}
+	if u.Cached > 0 {
+		parts = append(parts, plural(u.Cached, "cached answer"))
+	}
+	parts = append(parts, fmt.Sprintf("%d input + %d output tokens (%d reasoning)", u.InputTokens, u.OutputTokens, u.ReasoningTokens))
+	if u.WebSearches > 0 {
+		parts = append(parts, plural(u.WebSearches, "web search"))
+	}
+	switch {
+	case len(u.Unpriced) == 0:
+		parts = append(parts, fmt.Sprintf("~$%.4f", u.USD))
+	case u.USD > 0:
+		parts = append(parts, fmt.Sprintf("~$%.4f plus %s (no list price)", u.USD, strings.Join(u.Unpriced, ", ")))
+	default:
+		parts = append(parts, fmt.Sprintf("cost unknown (no list price for %s)", strings.Join(u.Unpriced, ", ")))
+	}
+	parts = append(parts, elapsed.String())
+	fmt.Fprintf(w, "usage: %s\n", strings.Join(parts, ", "))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if strings.HasSuffix(noun, "h") {
+		return fmt.Sprintf("%d %ses", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// printUsage writes the -show-usage summary to stderr, clear of the answer
+// on stdout.
+func (a cliArgs) printUsage(u *runUsage, started time.Time) {
+	if a.showUsage {
+		u.write(os.Stderr, time.Since(started))
+	}
+}
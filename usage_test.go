@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+
+	resp := &apiResponse{
+		Model:  "gpt-5.1-2025-11-13",
+		Output: []respItem{{Type: "reasoning"}, {Type: "web_search_call"}, {Type: "web_search_call"}, {Type: "message"}},
+		Usage:  &apiUsage{InputTokens: 1_000_000, OutputTokens: 100_000, TotalTokens: 1_100_000, OutputTokensDetails: outputTokensDetails{ReasoningTokens: 80_000}},
+	}
+	var u runUsage
+	u.add(resp.Model, resp.Usage, countWebSearches(resp))
+	// $1.25 input + $1.00 output + two searches.
+	if want := 2.25 + 2*webSearchCallPrice; u.USD < want-1e-9 || u.USD > want+1e-9 {
+		t.Errorf("USD = %f, want %f", u.USD, want)
+	}
+	var b strings.Builder
+	u.write(&b, 41237*time.Millisecond)
+	want := "usage: 1 call, 1000000 input + 100000 output tokens (80000 reasoning), 2 web searches, ~$2.2700, 41.24s\n"
+	if b.String() != want {
+		t.Errorf("summary = %q, want %q", b.String(), want)
+	}
+
+	u.addResult(&WebSearchResult{Success: true, Model: "grok-4", WebSearchUsed: true, Usage: &apiUsage{InputTokens: 10, OutputTokens: 5}})
+	u.addResult(&WebSearchResult{Success: true, Cached: true})
+	u.addResult(&WebSearchResult{Error: "timeout", RequestedModel: "gpt-5.1"})
+	if u.Calls != 2 || u.Cached != 1 || u.WebSearches != 3 || u.InputTokens != 1_000_010 {
+		t.Errorf("after results = %+v", u)
+	}
+	b.Reset()
+	u.write(&b, time.Second)
+	if got := b.String(); !strings.Contains(got, "1 cached answer") || !strings.Contains(got, "plus grok-4 (no list price)") {
+		t.Errorf("summary = %q", got)
+	}
+
+	b.Reset()
+	(&runUsage{Cached: 1}).write(&b, 20*time.Millisecond)
+	if got := b.String(); got != "usage: 1 cached answer, no tokens used, 20ms\n" {
+		t.Errorf("cached summary = %q", got)
+	}
+
+	b.Reset()
+	unpriced := runUsage{}
+	unpriced.add("llama3.2", &apiUsage{InputTokens: 3, OutputTokens: 4}, 0)
+	unpriced.write(&b, time.Second)
+	if got := b.String(); !strings.Contains(got, "cost unknown (no list price for llama3.2)") {
+		t.Errorf("unpriced summary = %q", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// verifyJudgePrompt asks the default provider to compare two answers and
+// report the result as JSON.
+const verifyJudgePrompt = `Compare two answers to the same question for factual consistency.
+Reply with JSON only, no prose, in this shape:
+{"agreement": "high" | "partial" | "low", "score": <0.0-1.0>, "summary": "<one sentence>",
+ "conflicts": [{"claim": "<topic>", "original": "<what answer A says>", "second": "<what answer B says>"}]}
+Differences in wording or detail that do not contradict each other are not conflicts.
+
+Question: %s
+
+Answer A (original):
+%s
+
+Answer B (second opinion):
+%s`
+
+// VerifyConflict is a claim on which the two answers disagree.
+type VerifyConflict struct {
+	Claim    string `json:"claim"`
+	Original string `json:"original"`
+	Second   string `json:"second"`
+}
+
+// VerifyResult reports how well a second provider's answer agrees with the
+// original one.
+type VerifyResult struct {
+	Success      bool             `json:"success"`
+	Query        string           `json:"query"`
+	Provider     string           `json:"provider"`
+	Model        string           `json:"model,omitempty"`
+	SecondAnswer string           `json:"second_answer,omitempty"`
+	Agreement    string           `json:"agreement,omitempty"`
+	Score        float64          `json:"score,omitempty"`
+	Summary      string           `json:"summary,omitempty"`
+	Conflicts    []VerifyConflict `json:"conflicts,omitempty"`
+	// Sources are the citations behind the second answer.
+	Sources []Citation `json:"sources,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// verifyVerdict is the judge's JSON reply.
+type verifyVerdict struct {
+	Agreement string           `json:"agreement"`
+	Score     float64          `json:"score"`
+	Summary   string           `json:"summary"`
+	Conflicts []VerifyConflict `json:"conflicts"`
+}
+
+// runVerify re-asks wa.query with wa.provider and has the default provider
+// judge the second answer against the original.
+func runVerify(ctx context.Context, apiKey, baseURL string, wa webSearchArgs, original string) (*VerifyResult, error) {
+	second, err := runWebSearch(ctx, apiKey, baseURL, wa)
+	if err != nil {
+		return nil, err
+	}
+	result := &VerifyResult{
+		Query:        wa.query,
+		Provider:     second.Provider,
+		Model:        second.Model,
+		SecondAnswer: second.Answer,
+		Sources:      second.Citations,
+	}
+	if !second.Success {
+		result.Error = "second opinion failed: " + second.Error
+		return result, nil
+	}
+
+	// The judge runs on the default provider, so the second opinion's model
+	// name may not exist there.
+	judge := wa
+	judge.model = defaultModel
+	raw, err := synthesize(ctx, apiKey, baseURL, judge, fmt.Sprintf(verifyJudgePrompt, wa.query, original, second.Answer))
+	if err != nil {
+		result.Error = "comparison failed: " + err.Error()
+		return result, nil
+	}
+	verdict, err := parseVerdict(raw)
+	if err != nil {
+		result.Error = err.Error()
+		result.Summary = truncateRunes(raw, maxErrorBodyRunes)
+		return result, nil
+	}
+
+	result.Success = true
+	result.Agreement = verdict.Agreement
+	result.Score = verdict.Score
+	result.Summary = verdict.Summary
+	result.Conflicts = verdict.Conflicts
+	return result, nil
+}
+
+// secondOpinionProvider picks the provider for the second opinion. An
+// explicit provider is kept unless it would repeat the default provider's
+// default model; without provider or model, the first other provider with
+// an API key is chosen, so the answers come from different models.
+func secondOpinionProvider(provider, model, apiKey string) (string, error) {
+	switch {
+	case provider != "":
+		pr, err := lookupProvider(provider)
+		if err != nil {
+			return "", err
+		}
+		if pr.name == defaultProvider && model == "" {
+			return "", ErrNoSecondOpinion
+		}
+		return pr.name, nil
+	case model != "":
+		return defaultProvider, nil
+	}
+	for _, name := range providerNames() {
+		if name != defaultProvider && providers[name].keyFor(apiKey) != "" {
+			return name, nil
+		}
+	}
+	return "", ErrNoSecondOpinion
+}
+
+// parseVerdict decodes the judge's reply, tolerating prose or a code fence
+// around the JSON object.
+func parseVerdict(raw string) (verifyVerdict, error) {
+	var v verifyVerdict
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start < 0 || end < start {
+		return v, fmt.Errorf("comparison returned no JSON verdict")
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &v); err != nil {
+		return v, fmt.Errorf("parse verdict: %w", err)
+	}
+	switch v.Agreement {
+	case "high", "partial", "low":
+	default:
+		return v, fmt.Errorf("verdict has unknown agreement level %q", v.Agreement)
+	}
+	v.Score = max(0, min(1, v.Score))
+	return v, nil
+}
+
+// newVerifyTool builds the verify tool definition.
+func newVerifyTool() mcp.Tool {
+	return mcp.NewTool("verify",
+		mcp.WithDescription("Re-ask a previously answered question with a second provider or model and report "+
+			"how well the answers agree, listing conflicting claims with the second answer's sources"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The question that was answered"),
+		),
+		mcp.WithString("answer",
+			mcp.Required(),
+			mcp.Description("The original answer to check"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("Provider for the second opinion (default: the first provider other than the server's PROVIDER "+
+				"with an API key; with only model set, PROVIDER)"),
+			mcp.Enum(providerNames()...),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model for the second opinion (default: provider default); "+
+				"required when provider is the server's PROVIDER"),
+		),
+		mcp.WithString("reasoning_effort",
+			mcp.DefaultString(toolEffort),
+			mcp.Description("Reasoning effort level: none, low, medium, high, or xhigh"),
+			mcp.Enum("none", "low", "medium", "high", "xhigh"),
+		),
+		mcp.WithSchemaAdditionalProperties(false),
+		mcp.WithOutputSchema[VerifyResult](),
+	)
+}
+
+// verifyHandler returns the handler for the verify tool.
+func verifyHandler(apiKey, baseURL string) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := request.RequireString("query")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		answer, err := request.RequireString("answer")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		model := request.GetString("model", "")
+		provider, err := secondOpinionProvider(request.GetString("provider", ""), model, apiKey)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		wa := extractWebSearchArgs(map[string]interface{}{
+			"query":            query,
+			"model":            model,
+			"reasoning_effort": request.GetString("reasoning_effort", toolEffort),
+			"provider":         provider,
+		})
+
+		logToClient(ctx, mcp.LoggingLevelInfo, "verify", fmt.Sprintf(
+			"Verifying answer: query='%s', provider=%s", truncateForLog(query), wa.provider))
+
+		result, err := runVerify(ctx, apiKey, baseURL, wa, answer)
+		if err != nil {
+			return toolErrorResult(ctx, err), nil
+		}
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseVerdict(t *testing.T) {
+	t.Parallel()
+
+	v, err := parseVerdict("```json\n{\"agreement\": \"partial\", \"score\": 1.4, \"conflicts\": [{\"claim\": \"year\", \"original\": \"1990\", \"second\": \"1991\"}]}\n```")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Agreement != "partial" || v.Score != 1 || len(v.Conflicts) != 1 || v.Conflicts[0].Second != "1991" {
+		t.Errorf("verdict = %+v", v)
+	}
+
+	for _, bad := range []string{"no json here", `{"agreement": "maybe"}`, `{"agreement": `} {
+		if _, err := parseVerdict(bad); err == nil {
+			t.Errorf("parseVerdict(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	_, openaiBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if !strings.Contains(body.Input, "Answer B (second opinion):\nThe bridge opened in 1937.") {
+			t.Errorf("judge prompt missing second answer: %q", body.Input)
+		}
+		if body.Model != defaultModel {
+			t.Errorf("judge model = %q, want %q", body.Model, defaultModel)
+		}
+		verdict := `{"agreement":"low","score":0.2,"summary":"Opening year differs.","conflicts":[{"claim":"opening year","original":"1933","second":"1937"}]}`
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":     "judge",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": verdict}}}},
+		})
+	})
+	_, xaiBase := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id":        "xai",
+			"model":     "grok-4",
+			"choices":   []map[string]any{{"message": map[string]any{"content": "The bridge opened in 1937."}}},
+			"citations": []string{"https://example.com/bridge"},
+		})
+	})
+
+	orig := providers["xai"].defaultURL
+	providers["xai"].defaultURL = xaiBase
+	t.Cleanup(func() { providers["xai"].defaultURL = orig })
+	providerKeys = map[string]string{"xai": "xk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	wa := extractWebSearchArgs(map[string]any{"query": "When did the Golden Gate Bridge open?", "provider": "xai", "model": "grok-4"})
+	result, err := runVerify(context.Background(), "ok", openaiBase, wa, "It opened in 1933.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success || result.Agreement != "low" || len(result.Conflicts) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Provider != "xai" || len(result.Sources) != 1 || result.Sources[0].URL != "https://example.com/bridge" {
+		t.Errorf("provider/sources = %q/%+v", result.Provider, result.Sources)
+	}
+}
+
+func TestSecondOpinionProvider(t *testing.T) {
+	providerKeys = map[string]string{"xai": "xk"}
+	t.Cleanup(func() { providerKeys = map[string]string{} })
+
+	for _, tt := range []struct {
+		provider, model, want string
+	}{
+		{"", "", "xai"},
+		{"", "gpt-5.1-mini", defaultProvider},
+		{"anthropic", "", "anthropic"},
+		{defaultProvider, "gpt-5.1-mini", defaultProvider},
+	} {
+		if got, err := secondOpinionProvider(tt.provider, tt.model, "k"); err != nil || got != tt.want {
+			t.Errorf("secondOpinionProvider(%q, %q) = %q, %v; want %q", tt.provider, tt.model, got, err, tt.want)
+		}
+	}
+	if _, err := secondOpinionProvider(defaultProvider, "", "k"); !errors.Is(err, ErrNoSecondOpinion) {
+		t.Errorf("same provider and model: err = %v, want ErrNoSecondOpinion", err)
+	}
+	providerKeys = map[string]string{}
+	if _, err := secondOpinionProvider("", "", "k"); !errors.Is(err, ErrNoSecondOpinion) {
+		t.Errorf("no other provider: err = %v, want ErrNoSecondOpinion", err)
+	}
+}
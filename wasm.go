@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	// wasmPluginTimeout bounds one plugin run.
+	wasmPluginTimeout = 5 * time.Second
+	// wasmMemoryLimitPages caps plugin memory at 256 MiB (64 KiB pages).
+	wasmMemoryLimitPages = 4096
+	// maxWasmStderr bounds the stderr repeated in a plugin error.
+	maxWasmStderr = 2000
+)
+
+// Plugin stages: a query plugin rewrites the question before it is sent
+// and an answer plugin rewrites the answer text.
+const (
+	wasmStageQuery  = "query"
+	wasmStageAnswer = "answer"
+)
+
+// wasmPlugins runs WebAssembly text transformers (env WASM_PLUGINS). Each
+// plugin is a WASI command module: it reads the text on stdin, finds the
+// stage in argv[1] and WEBSEARCH_STAGE, and writes the replacement text to
+// stdout. Plugins are sandboxed: no filesystem, network or host
+// environment, and each run gets a fresh instance. A nil *wasmPlugins
+// leaves text unchanged.
+type wasmPlugins struct {
+	runtime wazero.Runtime
+	modules []wasmModule
+}
+
+type wasmModule struct {
+	name     string
+	compiled wazero.CompiledModule
+}
+
+// activeWasm holds the WASM plugins; set at startup from WASM_PLUGINS.
+var activeWasm *wasmPlugins
+
+// loadWasmPlugins compiles the comma-separated .wasm files in paths; they
+// run in the order given. It returns nil when paths is empty.
+func loadWasmPlugins(ctx context.Context, paths string) (*wasmPlugins, error) {
+	var files []string
+	for _, p := range strings.Split(paths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			files = append(files, p)
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(wasmMemoryLimitPages))
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	w := &wasmPlugins{runtime: rt}
+	for _, path := range files {
+		bin, err := os.ReadFile(path)
+		if err != nil {
+			rt.Close(ctx) //nolint:errcheck
+			return nil, fmt.Errorf("WASM_PLUGINS: %w", err)
+		}
+		compiled, err := rt.CompileModule(ctx, bin)
+		if err != nil {
+			rt.Close(ctx) //nolint:errcheck
+			return nil, fmt.Errorf("WASM_PLUGINS: compile %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		w.modules = append(w.modules, wasmModule{name: name, compiled: compiled})
+	}
+	return w, nil
+}
+
+// transform passes text through every plugin in turn for stage.
+func (w *wasmPlugins) transform(ctx context.Context, stage, text string) (string, error) {
+	if w == nil {
+		return text, nil
+	}
+	for _, m := range w.modules {
+		out, err := w.run(ctx, m, stage, text)
+		if err != nil {
+			return "", err
+		}
+		text = out
+	}
+	return text, nil
+}
+
+func (w *wasmPlugins) run(ctx context.Context, m wasmModule, stage, text string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, wasmPluginTimeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{limit: maxResponseBodySize}
+	stderr := &cappedBuffer{limit: maxWasmStderr}
+	cfg := wazero.NewModuleConfig().
+		WithName(""). // anonymous, so concurrent calls get their own instance
+		WithArgs(m.name, stage).
+		WithEnv("WEBSEARCH_STAGE", stage).
+		WithStdin(strings.NewReader(text)).
+		WithStdout(stdout).
+		WithStderr(stderr)
+
+	mod, err := w.runtime.InstantiateModule(ctx, m.compiled, cfg)
+	if mod != nil {
+		mod.Close(ctx) //nolint:errcheck
+	}
+	var exit *sys.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", wasmPluginTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return "", fmt.Errorf("wasm plugin %s (%s): %w: %s", m.name, stage, err, msg)
+		}
+		return "", fmt.Errorf("wasm plugin %s (%s): %w", m.name, stage, err)
+	}
+	if stdout.truncated {
+		return "", fmt.Errorf("wasm plugin %s (%s): output exceeds %d bytes", m.name, stage, maxResponseBodySize)
+	}
+	return stdout.buf.String(), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildWasmPlugin compiles testdata/wasm/redact for WASI.
+func buildWasmPlugin(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a WASM module")
+	}
+	out := filepath.Join(t.TempDir(), "redact.wasm")
+	cmd := exec.Command("go", "build", "-o", out, "./testdata/wasm/redact")
+	cmd.Env = append(cmd.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build WASM plugin: %v: %s", err, msg)
+	}
+	return out
+}
+
+func TestWasmPlugins(t *testing.T) {
+	path := buildWasmPlugin(t)
+	ctx := context.Background()
+	w, err := loadWasmPlugins(ctx, " "+path+" ,")
+	if err != nil {
+		t.Fatalf("loadWasmPlugins: %v", err)
+	}
+	t.Cleanup(func() { w.runtime.Close(ctx) }) //nolint:errcheck
+
+	if got, err := w.transform(ctx, wasmStageQuery, "my secret plan"); err != nil || got != "my [redacted] plan" {
+		t.Errorf("query = %q, %v", got, err)
+	}
+	p, _ := newPostProcessor(PostProcessConfig{Plugins: w}) //nolint:errcheck
+	if got := p.apply("paris\n"); got != "PARIS" {
+		t.Errorf("answer = %q", got)
+	}
+	if _, err := w.transform(ctx, wasmStageAnswer, "fail"); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("failing plugin err = %v", err)
+	}
+	if got := p.apply("fail"); got != "fail" {
+		t.Errorf("answer after plugin failure = %q, want it unchanged", got)
+	}
+
+	// Query plugins run before the request leaves.
+	activeWasm = w
+	t.Cleanup(func() { activeWasm = nil })
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if raw, _ := json.Marshal(body.Input); strings.Contains(string(raw), "secret") { //nolint:errcheck
+			t.Errorf("query sent unredacted: %s", raw)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]any{"id": "resp_w", "output": []any{}})
+	})
+	if _, err := CallAPI(ctx, CallAPIParams{APIKey: "k", BaseURL: base, Query: "the secret", Timeout: 2 * time.Second}); err != nil {
+		t.Fatalf("CallAPI: %v", err)
+	}
+
+	if _, err := loadWasmPlugins(ctx, filepath.Join(t.TempDir(), "missing.wasm")); err == nil {
+		t.Error("expected error for a missing plugin")
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxWebhookJobs caps the queries answered in the background at once;
+	// further requests get 503 until one finishes.
+	maxWebhookJobs = 16
+	// webhookTimeout bounds one callback delivery.
+	webhookTimeout = 30 * time.Second
+	// webhookSignatureHeader carries the HMAC-SHA256 of the callback body
+	// when WEBHOOK_SECRET is set.
+	webhookSignatureHeader = "X-Answer-Signature"
+)
+
+// webhookClient delivers callbacks. It refuses to connect to a private
+// address, whatever the host resolved to when the callback was checked, so
+// a host that rebinds its DNS between the check and the dial gains nothing.
+// webhookAllowedClient delivers to WEBHOOK_ALLOWED_HOSTS without that
+// guard. Neither goes through the outbound proxy, which would dial on
+// their behalf, and neither follows redirects.
+var (
+	webhookClient        = newCallbackClient(true)
+	webhookAllowedClient = newCallbackClient(false)
+)
+
+// newCallbackClient returns a client for callback deliveries; with
+// guarded set it only dials public addresses. configureHTTPClient sets the
+// TLS config of its transport.
+func newCallbackClient(guarded bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if guarded {
+		dialer.Control = refusePrivateDial
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refusePrivateDial is a net.Dialer Control hook that fails connections to
+// addresses isPublicAddr rejects.
+func refusePrivateDial(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ap.Addr()) {
+		return fmt.Errorf("%w (dialing %s)", ErrCallbackPrivate, ap.Addr())
+	}
+	return nil
+}
+
+// webhookRequest is the body of POST /hooks/query.
+type webhookRequest struct {
+	queryRequest
+	CallbackURL string `json:"callback_url"`
+	// Reference is an opaque caller value echoed in the callback.
+	Reference string `json:"reference,omitempty"`
+}
+
+// webhookDelivery is the body POSTed to the callback URL.
+type webhookDelivery struct {
+	ID        string           `json:"id"`
+	Reference string           `json:"reference,omitempty"`
+	Result    *WebSearchResult `json:"result,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// webhookServer accepts questions over plain HTTP, answers them in the
+// background and POSTs each result to the caller's callback URL.
+type webhookServer struct {
+	apiKey  string
+	baseURL string
+	secret  []byte
+	// allowedHosts may be called back on private addresses.
+	allowedHosts []string
+	slots        chan struct{}
+	jobs         sync.WaitGroup
+	// retryDelays are the waits between callback attempts.
+	retryDelays []time.Duration
+}
+
+func newWebhookServer(cfg MCPConfig) *webhookServer {
+	return &webhookServer{
+		apiKey:       cfg.APIKey,
+		baseURL:      cfg.BaseURL,
+		secret:       []byte(cfg.WebhookSecret),
+		allowedHosts: splitHosts(cfg.WebhookAllowedHosts),
+		slots:        make(chan struct{}, maxWebhookJobs),
+		retryDelays:  []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+	}
+}
+
+// ServeHTTP validates the request, answers 202 with the job ID and starts
+// the search.
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeHTTPJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHTTPRequestBytes)).Decode(&req); err != nil {
+		writeHTTPJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeHTTPJSON(w, http.StatusBadRequest, map[string]string{"error": "query is required"})
+		return
+	}
+	if err := s.checkCallback(r.Context(), req.CallbackURL); err != nil {
+		writeHTTPJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		w.Header().Set("Retry-After", "30")
+		writeHTTPJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "too many queries in progress; retry later"})
+		return
+	}
+	id := newWebhookID()
+	// The job outlives the request but keeps the caller's identity.
+	ctx := context.WithoutCancel(r.Context())
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		defer func() { <-s.slots }()
+		s.run(ctx, id, req)
+	}()
+	writeHTTPJSON(w, http.StatusAccepted, map[string]string{"id": id, "status": "accepted"})
+}
+
+// wait blocks until the background jobs are done or ctx ends.
+func (s *webhookServer) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run answers req and delivers the outcome to its callback URL.
+func (s *webhookServer) run(ctx context.Context, id string, req webhookRequest) {
+	started := time.Now()
+	result, err := HandleWebSearch(ctx, s.apiKey, s.baseURL, req.toolArgs())
+	activeDashboard.record(ctx, req.Query, result, err, time.Since(started))
+
+	d := webhookDelivery{ID: id, Reference: req.Reference, Result: result}
+	if err != nil {
+		d.Result, d.Error = nil, err.Error()
+	}
+	if err := s.deliver(ctx, req.CallbackURL, d); err != nil {
+		Error("Webhook delivery failed", "id", id, "callback_host", callbackHost(req.CallbackURL), "error", err)
+		return
+	}
+	Info("Webhook delivered", "id", id, "callback_host", callbackHost(req.CallbackURL), "took", time.Since(started).Round(time.Millisecond))
+}
+
+// deliver POSTs d to callback, retrying network errors and 5xx/429
+// responses after each of s.retryDelays.
+func (s *webhookServer) deliver(ctx context.Context, callback string, d webhookDelivery) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err = s.post(ctx, callback, body)
+		var permanent *permanentDeliveryError
+		if err == nil || errors.As(err, &permanent) || attempt >= len(s.retryDelays) {
+			return err
+		}
+		Warn("Retrying webhook delivery", "id", d.ID, "attempt", attempt+1, "error", err)
+		if err := sleepContext(ctx, s.retryDelays[attempt]); err != nil {
+			return err
+		}
+	}
+}
+
+// permanentDeliveryError is a callback response, or a refused callback
+// address, that retrying won't fix.
+type permanentDeliveryError struct {
+	status int
+	err    error
+}
+
+func (e *permanentDeliveryError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("callback answered HTTP %d", e.status)
+}
+
+// post makes one delivery attempt.
+func (s *webhookServer) post(ctx context.Context, callback string, body []byte) error {
+	// The host is resolved again, for a clear error if its address changed
+	// since the request was accepted; webhookClient enforces the same rule
+	// on the address it actually dials.
+	if err := s.checkCallback(ctx, callback); errors.Is(err, ErrCallbackPrivate) {
+		return &permanentDeliveryError{err: err}
+	} else if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", serverTitle+" (webhook)")
+	if len(s.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader, signWebhook(s.secret, body))
+	}
+	client := webhookClient
+	if s.allowed(callback) {
+		client = webhookAllowedClient
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, ErrCallbackPrivate) {
+		return &permanentDeliveryError{err: err}
+	} else if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //nolint:errcheck // drained for connection reuse
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("callback answered HTTP %d", resp.StatusCode)
+	default:
+		return &permanentDeliveryError{status: resp.StatusCode}
+	}
+}
+
+// signWebhook returns the signature header value for body:
+// "sha256=" followed by the hex HMAC-SHA256 under secret.
+func signWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkCallback accepts absolute http and https URLs whose host resolves
+// only to public addresses, so a caller cannot make the server POST to
+// itself, its network or a cloud metadata endpoint. Hosts in allowedHosts
+// are accepted as they are.
+func (s *webhookServer) checkCallback(ctx context.Context, raw string) error {
+	if raw == "" {
+		return errors.New("callback_url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https URL, got %q", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	if s.allowed(raw) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("callback_url host %s: %w", host, err)
+	}
+	for _, a := range addrs {
+		if !isPublicAddr(a) {
+			return fmt.Errorf("%w (%s is %s)", ErrCallbackPrivate, host, a)
+		}
+	}
+	return nil
+}
+
+// allowed reports whether the host of callback is in s.allowedHosts.
+func (s *webhookServer) allowed(callback string) bool {
+	u, err := url.Parse(callback)
+	return err == nil && slices.Contains(s.allowedHosts, strings.ToLower(u.Hostname()))
+}
+
+// isPublicAddr reports whether a is a routable unicast address: not
+// private, loopback, link-local, unspecified or multicast.
+func isPublicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	return a.IsGlobalUnicast() && !a.IsPrivate()
+}
+
+// callbackHost is the host of a callback URL, which is logged instead of the
+// URL since its path or query may hold a secret.
+func callbackHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// splitHosts parses the comma-separated WEBHOOK_ALLOWED_HOSTS.
+func splitHosts(s string) []string {
+	var hosts []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// newWebhookID returns a random job ID.
+func newWebhookID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:]) //nolint:errcheck // never fails
+	return "hook-" + hex.EncodeToString(b[:])
+}
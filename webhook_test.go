@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookServer(t *testing.T) {
+	t.Parallel()
+
+	_, base := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "resp_1", "status": "completed",
+			"output": []map[string]any{{"type": "message", "content": []map[string]any{{"type": "output_text", "text": "Canberra."}}}},
+		})
+	})
+
+	var (
+		mu        sync.Mutex
+		attempts  int
+		body      []byte
+		signature string
+	)
+	_, callback := newJSONServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body) //nolint:errcheck
+		signature = r.Header.Get(webhookSignatureHeader)
+	})
+
+	// The callback server listens on loopback, which must be allowed.
+	s := newWebhookServer(MCPConfig{APIKey: "k", BaseURL: base, WebhookSecret: "shh", WebhookAllowedHosts: " 127.0.0.1 ,example.internal"})
+	s.retryDelays = []time.Duration{time.Millisecond}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	post := func(payload string) (int, map[string]string) {
+		t.Helper()
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(payload)) //nolint:noctx
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&out) //nolint:errcheck
+		return resp.StatusCode, out
+	}
+
+	for _, payload := range []string{`{"query":"x"}`, `{"query":"","callback_url":"` + callback + `"}`, `{"query":"x","callback_url":"ftp://example.com"}`, `{`} {
+		if status, _ := post(payload); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", payload, status)
+		}
+	}
+
+	strict := httptest.NewServer(newWebhookServer(MCPConfig{APIKey: "k", BaseURL: base}))
+	t.Cleanup(strict.Close)
+	resp, err := http.Post(strict.URL, "application/json", strings.NewReader(`{"query":"x","callback_url":"`+callback+`"}`)) //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("loopback callback without allowlist: status %d, want 400", resp.StatusCode)
+	}
+
+	status, accepted := post(`{"query":"Capital of Australia?","callback_url":"` + callback + `","reference":"row-7","effort":"low"}`)
+	if status != http.StatusAccepted || !strings.HasPrefix(accepted["id"], "hook-") {
+		t.Fatalf("status %d, body %v", status, accepted)
+	}
+	s.jobs.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var d webhookDelivery
+	if err := json.Unmarshal(body, &d); err != nil {
+		t.Fatalf("callback body %q: %v", body, err)
+	}
+	if attempts != 2 || d.ID != accepted["id"] || d.Reference != "row-7" || d.Result == nil || d.Result.Answer != "Canberra." {
+		t.Errorf("after %d attempts delivered %+v", attempts, d)
+	}
+	if signature != signWebhook([]byte("shh"), body) {
+		t.Errorf("signature = %q", signature)
+	}
+}
+
+func TestWebhookCheckCallback(t *testing.T) {
+	t.Parallel()
+
+	s := newWebhookServer(MCPConfig{WebhookAllowedHosts: "10.0.0.5"})
+	ctx := context.Background()
+	for _, raw := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://192.168.1.10/",
+		"http://[::1]/",
+		"http://[fe80::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://0.0.0.0/",
+	} {
+		if err := s.checkCallback(ctx, raw); !errors.Is(err, ErrCallbackPrivate) {
+			t.Errorf("%s: err = %v, want ErrCallbackPrivate", raw, err)
+		}
+	}
+	for _, raw := range []string{"https://93.184.216.34/hook", "http://10.0.0.5:9000/hook"} {
+		if err := s.checkCallback(ctx, raw); err != nil {
+			t.Errorf("%s: %v", raw, err)
+		}
+	}
+}
+
+func TestWebhookClient_RefusesPrivateDial(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	// A host that passed checkCallback may resolve to loopback by the time
+	// it is dialed; the guarded client refuses the connection itself.
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webhookClient.Do(req); !errors.Is(err, ErrCallbackPrivate) {
+		t.Fatalf("guarded client: err = %v, want ErrCallbackPrivate", err)
+	}
+	resp, err := webhookAllowedClient.Do(req)
+	if err != nil {
+		t.Fatalf("allowlist client: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestWebhookServer_WaitsForJobs(t *testing.T) {
+	t.Parallel()
+
+	s := newWebhookServer(MCPConfig{})
+	s.jobs.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait with a job running = %v, want DeadlineExceeded", err)
+	}
+	s.jobs.Done()
+	if err := s.wait(context.Background()); err != nil {
+		t.Fatalf("wait after the job = %v", err)
+	}
+}